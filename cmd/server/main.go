@@ -13,6 +13,7 @@ import (
 
 	"github.com/qiniu/codeagent/internal/agent"
 	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/usage"
 	"github.com/qiniu/codeagent/internal/webhook"
 	"github.com/qiniu/codeagent/internal/workspace"
 
@@ -93,11 +94,16 @@ func main() {
 		status := map[string]interface{}{
 			"status":          "OK",
 			"workspace_count": workspaceManager.GetWorkspaceCount(),
+			"queue":           enhancedAgent.GetQueueStats(),
 			"timestamp":       time.Now().Format(time.RFC3339),
 		}
 
 		json.NewEncoder(w).Encode(status)
 	})
+	// 用量导出接口（需要 server.admin_token）
+	mux.Handle("/usage", usage.Handler(enhancedAgent.GetUsageStore(), cfg.Server.AdminToken))
+	// 手动触发定时任务接口（需要 server.admin_token）
+	mux.HandleFunc("/dispatch", webhookHandler.HandleDispatch)
 
 	// 创建 HTTP 服务器
 	server := &http.Server{
@@ -2,9 +2,14 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/qiniu/codeagent/internal/automation"
+	"github.com/qiniu/codeagent/internal/budget"
+	"github.com/qiniu/codeagent/internal/ci"
 	"github.com/qiniu/codeagent/internal/code"
 	"github.com/qiniu/codeagent/internal/config"
 	"github.com/qiniu/codeagent/internal/events"
@@ -13,6 +18,11 @@ import (
 	"github.com/qiniu/codeagent/internal/mcp"
 	"github.com/qiniu/codeagent/internal/mcp/servers"
 	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/internal/permission"
+	"github.com/qiniu/codeagent/internal/queue"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/internal/review"
+	"github.com/qiniu/codeagent/internal/usage"
 	"github.com/qiniu/codeagent/internal/workspace"
 	"github.com/qiniu/codeagent/pkg/models"
 
@@ -33,6 +43,21 @@ type EnhancedAgent struct {
 	mcpManager  mcp.MCPManager
 	mcpClient   mcp.MCPClient
 	taskFactory *interaction.TaskFactory
+
+	// 持久化任务队列及worker池
+	queue       *queue.FileStore
+	deliveries  *queue.DeliveryStore
+	scheduler   *Scheduler
+	queuedMu    sync.Mutex
+	queued      map[string]*queuedTask // 因没有执行名额而回到队列的任务，按任务ID索引
+	tasks       *TaskRegistry
+	usage       *usage.Store
+	budgets     *budget.Checker
+	permissions *permission.Policy
+	repoConfigs *repoconfig.Loader
+	stopCh      chan struct{}
+	stopOnce    sync.Once
+	workersWG   sync.WaitGroup
 }
 
 // NewEnhancedAgent 创建增强版Agent
@@ -67,6 +92,7 @@ func NewEnhancedAgent(cfg *config.Config, workspaceManager *workspace.Manager) (
 
 	// 5. 初始化SessionManager
 	sessionManager := code.NewSessionManager(cfg)
+	sessionManager.SetMCPClient(mcpClient)
 
 	// 6. 初始化模式管理器
 	modeManager := modes.NewManager()
@@ -80,17 +106,71 @@ func NewEnhancedAgent(cfg *config.Config, workspaceManager *workspace.Manager) (
 		xl.Infof("Custom command handler registered with global config path: %s", cfg.Commands.GlobalPath)
 	}
 
-	reviewHandler := modes.NewReviewHandler(clientManager, workspaceManager, mcpClient, sessionManager, cfg)
+	// 记录每个PR最近一次审查的提交，推送新提交时只审查增量变更
+	reviewState, err := review.NewStateStore(cfg.StatePath("reviews.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open review state: %w", err)
+	}
+	reviewPolicy, err := review.ParsePolicy(cfg.Review.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid review.policy: %w", err)
+	}
+	reviewHandler := modes.NewReviewHandler(clientManager, workspaceManager, mcpClient, sessionManager, cfg, reviewState, review.DefaultPolicy().Merge(reviewPolicy))
 	tagHandler := modes.NewTagHandler(cfg.CodeProvider, clientManager, workspaceManager, mcpClient, sessionManager, reviewHandler, cfg)
-	agentHandler := modes.NewAgentHandler(clientManager, workspaceManager, mcpClient)
+	// 记录已经被自动化规则处理过的Issue
+	issueAutomation, err := automation.NewStore(cfg.StatePath("automation.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open automation state: %w", err)
+	}
+	agentHandler := modes.NewAgentHandler(clientManager, workspaceManager, mcpClient, tagHandler, issueAutomation)
 
 	modeManager.RegisterHandler(tagHandler)
 	modeManager.RegisterHandler(agentHandler)
 	modeManager.RegisterHandler(reviewHandler)
 
+	// 记录每个PR连续自动修复CI的次数
+	ciAttempts, err := ci.NewAttemptStore(cfg.StatePath("ci-fix.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ci fix state: %w", err)
+	}
+	modeManager.RegisterHandler(modes.NewCIFixHandler(clientManager, workspaceManager, sessionManager, ciAttempts, cfg))
+	modeManager.RegisterHandler(modes.NewPushHandler(clientManager, workspaceManager, tagHandler))
+
 	// 7. 创建任务工厂
 	taskFactory := interaction.NewTaskFactory()
 
+	// 8. 打开持久化任务队列
+	jobQueue, err := queue.NewFileStore(cfg.QueueDir(), queue.Options{
+		MaxAttempts:  cfg.Queue.MaxAttempts,
+		RetryBackoff: cfg.Queue.RetryBackoff,
+		Retention:    cfg.Queue.Retention,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job queue: %w", err)
+	}
+
+	// 9. 打开投递去重存储
+	deliveries, err := queue.NewDeliveryStore(cfg.StatePath("deliveries.json"), cfg.Queue.DeliveryTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open delivery store: %w", err)
+	}
+
+	// 10. 打开用量统计存储
+	usageStore, err := usage.NewStore(cfg.StatePath("usage.jsonl"), cfg.Usage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage store: %w", err)
+	}
+
+	// 11. 创建命令权限策略
+	permissions, err := permission.NewPolicy(cfg.Permissions, githubPermissionResolver(clientManager))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create permission policy: %w", err)
+	}
+
+	// 12. 创建仓库配置加载器，CodeAgent分支使用仓库配置的前缀
+	repoConfigs := repoconfig.NewLoader(&githubRepoConfigFetcher{clientManager: clientManager})
+	workspaceManager.SetBranchPrefixFunc(repoConfigs.BranchPrefix)
+
 	agent := &EnhancedAgent{
 		config:         cfg,
 		clientManager:  clientManager,
@@ -101,16 +181,62 @@ func NewEnhancedAgent(cfg *config.Config, workspaceManager *workspace.Manager) (
 		mcpManager:     mcpManager,
 		mcpClient:      mcpClient,
 		taskFactory:    taskFactory,
+		queue:          jobQueue,
+		deliveries:     deliveries,
+		scheduler:      NewScheduler(cfg.Scheduler.MaxConcurrent, cfg.Scheduler.MaxPerRepo),
+		tasks:          NewTaskRegistry(),
+		usage:          usageStore,
+		budgets:        budget.NewChecker(usageStore, cfg.Budget),
+		permissions:    permissions,
+		repoConfigs:    repoConfigs,
+		stopCh:         make(chan struct{}),
 	}
 
-	xl.Infof("Enhanced Agent initialized with %d MCP servers and %d mode handlers",
-		len(mcpManager.GetServers()), modeManager.GetHandlerCount())
+	// 执行名额释放后立即唤醒排在前面的任务，不必等待重试间隔
+	agent.scheduler.OnReady(agent.wakeQueuedJobs)
+
+	// 定时任务通过agent将单个PR的子任务加入任务队列
+	agentHandler.SetJobQueue(agent)
+
+	// 控制命令处理器需要通过agent管理正在执行的任务
+	modeManager.RegisterHandler(modes.NewControlHandler(clientManager, workspaceManager, sessionManager, agent, cfg, permissions))
+
+	workers := cfg.Queue.Workers
+	if workers <= 0 {
+		workers = defaultQueueWorkers
+	}
+	agent.startWorkers(workers)
+	if len(cfg.Cron.Repos) > 0 {
+		agent.startCron(cfg.Cron.Repos)
+		xl.Infof("Cron scheduler started for %d repositories", len(cfg.Cron.Repos))
+	}
+
+	xl.Infof("Enhanced Agent initialized with %d MCP servers, %d mode handlers and %d queue workers",
+		len(mcpManager.GetServers()), modeManager.GetHandlerCount(), workers)
 
 	return agent, nil
 }
 
 // ProcessGitHubWebhookEvent 处理来自Webhook的GitHub事件（推荐方法）
+// 已处理过的 delivery ID 会被跳过，避免重复投递导致重复的PR或评论
 func (a *EnhancedAgent) ProcessGitHubWebhookEvent(ctx context.Context, eventType string, deliveryID string, payload []byte) error {
+	return a.processWebhookEvent(ctx, eventType, deliveryID, payload, false)
+}
+
+// ReprocessGitHubWebhookEvent 跳过去重检查，强制重新处理事件
+func (a *EnhancedAgent) ReprocessGitHubWebhookEvent(ctx context.Context, eventType string, deliveryID string, payload []byte) error {
+	return a.processWebhookEvent(ctx, eventType, deliveryID, payload, true)
+}
+
+// ForgetDelivery 删除去重记录，使下一次相同 delivery ID 的投递被重新处理
+func (a *EnhancedAgent) ForgetDelivery(deliveryID string) error {
+	if a.deliveries == nil {
+		return nil
+	}
+	return a.deliveries.Forget(deliveryID)
+}
+
+func (a *EnhancedAgent) processWebhookEvent(ctx context.Context, eventType string, deliveryID string, payload []byte, force bool) error {
 	xl := xlog.NewWith(ctx)
 
 	startTime := time.Now()
@@ -119,12 +245,33 @@ func (a *EnhancedAgent) ProcessGitHubWebhookEvent(ctx context.Context, eventType
 	// 1. 解析GitHub事件为类型安全的上下文
 	githubCtx, err := a.eventParser.ParseWebhookEvent(ctx, eventType, deliveryID, payload)
 	if err != nil {
-		return fmt.Errorf("failed to parse webhook event: %w", err)
+		// 解析失败重试也不会成功
+		return queue.Permanent(fmt.Errorf("failed to parse webhook event: %w", err))
+	}
+
+	// 2. 分发前检查是否为重复投递
+	if !a.markDelivery(ctx, deliveryID, force) {
+		xl.Infof("Skipping duplicate delivery %s for event %s", deliveryID, eventType)
+		return nil
 	}
 
 	return a.processGitHubContext(ctx, githubCtx, startTime)
 }
 
+// markDelivery 记录delivery ID，返回是否需要继续处理
+func (a *EnhancedAgent) markDelivery(ctx context.Context, deliveryID string, force bool) bool {
+	if a.deliveries == nil || deliveryID == "" {
+		return true
+	}
+
+	isNew, err := a.deliveries.MarkIfNew(deliveryID, force)
+	if err != nil {
+		// 去重存储写入失败时不阻塞事件处理
+		xlog.NewWith(ctx).Warnf("Failed to persist delivery %s: %v", deliveryID, err)
+	}
+	return isNew
+}
+
 // processGitHubContext 处理已解析的GitHub上下文
 func (a *EnhancedAgent) processGitHubContext(ctx context.Context, githubCtx models.GitHubContext, startTime time.Time) error {
 	xl := xlog.NewWith(ctx)
@@ -132,18 +279,96 @@ func (a *EnhancedAgent) processGitHubContext(ctx context.Context, githubCtx mode
 	xl.Infof("Parsed event type: %s for repository: %s",
 		githubCtx.GetEventType(), githubCtx.GetRepository().GetFullName())
 
+	// 读取仓库级配置，覆盖全局的provider、触发词等设置
+	ctx = a.loadRepoConfig(ctx, githubCtx)
+
 	// 2. 选择合适的处理器
 	handler, err := a.modeManager.SelectHandler(ctx, githubCtx)
 	if err != nil {
-		return fmt.Errorf("no handler available: %w", err)
+		return queue.Permanent(fmt.Errorf("no handler available: %w", err))
 	}
 
 	xl.Infof("Selected handler with mode: %s (priority: %d)",
 		handler.GetMode(), handler.GetPriority())
 
-	// 3. 执行处理
-	err = handler.Execute(ctx, githubCtx)
+	// 检查触发命令的用户是否有权限执行该命令
+	usageInfo := a.usageTaskInfo(ctx, githubCtx)
+	ctx, allowed, err := a.authorize(ctx, githubCtx, usageInfo, handler.GetMode())
+	if err != nil {
+		return fmt.Errorf("failed to check permission: %w", err)
+	}
+	if !allowed {
+		return nil
+	}
+
+	// 控制命令（如 /cancel）需要在目标任务运行期间生效，不能排在它后面
+	if handler.GetMode() == modes.ControlMode {
+		if err := handler.Execute(ctx, githubCtx); err != nil {
+			xl.Errorf("Control command failed: %v", err)
+			return fmt.Errorf("handler execution failed: %w", err)
+		}
+		return nil
+	}
+
+	// 3. 检查用量预算，超出预算的任务不再执行
+	if !a.checkBudget(ctx, githubCtx, usageInfo, handler.GetMode()) {
+		return nil
+	}
+
+	// 4. 申请执行名额：同一PR/Issue串行执行，并受全局和单仓库并发上限约束，
+	// 拿不到名额的任务回到队列等待；任务状态同时报告为PR head提交上的check run
+	ctx, checks, release, err := a.acquireExecutionSlot(ctx, githubCtx, handler.GetMode(), usageInfo)
 	if err != nil {
+		return fmt.Errorf("failed to acquire execution slot: %w", err)
+	}
+	defer release()
+	checks.Started(ctx)
+	// 自动审查的限流间隔从拿到名额开始计算，排队等待名额的任务再次检查预算时不会被自己限流
+	a.budgets.RecordAutoReview(budgetRequest(usageInfo, handler.GetMode()))
+
+	// 5. 登记正在执行的任务，使其可以被 /cancel 取消，并统计任务中所有AI调用的用量
+	usageTask := a.usage.NewTask(usageInfo)
+	taskCtx := usage.NewContext(ctx, usageTask)
+	if key, ok := taskKeyFromContext(githubCtx, a.workspace); ok && a.tasks != nil {
+		var task *RunningTask
+		var done func()
+		taskCtx, task, done = a.tasks.Start(taskCtx, key, handler.GetHandlerName())
+		defer done()
+		taskCtx = modes.WithTaskAlias(taskCtx, func(number int) {
+			a.tasks.AddAlias(task.ID, number)
+			usageTask.SetPR(number)
+			checks.SetPullRequest(number)
+		})
+	}
+
+	// 6. 执行处理
+	err = handler.Execute(taskCtx, githubCtx)
+	if _, ok := queue.DeferDelay(err); ok {
+		// 处理器要求稍后再执行（如等待其他检查完成），check run保留到下一次执行
+		a.storeQueuedTask(jobIDFromContext(ctx), &queuedTask{checks: checks})
+		return err
+	}
+	cancelled := err != nil && errors.Is(taskCtx.Err(), context.Canceled) && ctx.Err() == nil
+	checks.Completed(ctx, err, cancelled, usageTask.Usage(), time.Since(startTime))
+	if err != nil {
+		if cancelled {
+			// 用户主动取消的任务不需要重试
+			xl.Infof("Handler execution cancelled: %v", err)
+			return nil
+		}
+		var denied *permission.DeniedError
+		if errors.As(err, &denied) {
+			// 自定义命令声明了更高的权限要求
+			xl.Warnf("Permission denied: %v", err)
+			a.reportPermissionDenied(ctx, githubCtx, denied)
+			return nil
+		}
+		if timeoutErr, ok := code.AsTimeoutError(err); ok {
+			// 超时重试大概率仍然超时，回复部分输出后不再重试
+			xl.Warnf("Handler execution timed out: %v", err)
+			a.reportTimeout(ctx, githubCtx, timeoutErr)
+			return queue.Permanent(fmt.Errorf("handler execution failed: %w", err))
+		}
 		xl.Errorf("Handler execution failed: %v", err)
 		return fmt.Errorf("handler execution failed: %w", err)
 	}
@@ -154,6 +379,27 @@ func (a *EnhancedAgent) processGitHubContext(ctx context.Context, githubCtx mode
 	return nil
 }
 
+// reportTimeout 在触发任务的Issue/PR上回复超时信息和超时前的部分输出
+func (a *EnhancedAgent) reportTimeout(ctx context.Context, event models.GitHubContext, timeoutErr *code.TimeoutError) {
+	xl := xlog.NewWith(ctx)
+
+	key, ok := taskKeyFromContext(event, a.workspace)
+	if !ok || key.Number == 0 || a.clientManager == nil {
+		return
+	}
+	repo := event.GetRepository()
+	client, err := a.clientManager.GetClient(ctx, &models.Repository{Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+	if err != nil {
+		xl.Warnf("Failed to get GitHub client for timeout report: %v", err)
+		return
+	}
+
+	body := interaction.RenderTimeoutComment(timeoutErr.Provider, timeoutErr.Timeout, timeoutErr.PartialOutput)
+	if _, err := client.CreateComment(ctx, repo.GetOwner().GetLogin(), repo.GetName(), key.Number, body); err != nil {
+		xl.Warnf("Failed to report timeout: %v", err)
+	}
+}
+
 // GetMCPManager 获取MCP管理器（用于外部扩展）
 func (a *EnhancedAgent) GetMCPManager() mcp.MCPManager {
 	return a.mcpManager
@@ -168,6 +414,11 @@ func (a *EnhancedAgent) GetModeManager() *modes.Manager {
 func (a *EnhancedAgent) Shutdown(ctx context.Context) error {
 	xl := xlog.NewWith(ctx)
 
+	// 停止worker池，未完成的任务将在下次启动时恢复
+	if err := a.stopWorkers(ctx); err != nil {
+		xl.Warnf("Failed to stop queue workers gracefully: %v", err)
+	}
+
 	// 关闭MCP管理器
 	if err := a.mcpManager.Shutdown(ctx); err != nil {
 		xl.Errorf("Failed to shutdown MCP manager: %v", err)
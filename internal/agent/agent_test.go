@@ -0,0 +1,400 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/budget"
+	"github.com/qiniu/codeagent/internal/code"
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/events"
+	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/internal/permission"
+	"github.com/qiniu/codeagent/internal/queue"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const issueCommentPayload = `{
+	"action": "created",
+	"repository": {"id": 1, "name": "repo", "full_name": "org/repo", "owner": {"login": "org"}},
+	"sender": {"login": "alice"},
+	"issue": {"number": 7, "title": "Add feature"},
+	"comment": {"id": 100, "body": "/code implement it", "user": {"login": "alice"}}
+}`
+
+const pullRequestPayload = `{
+	"action": "opened",
+	"number": 8,
+	"repository": {"id": 1, "name": "repo", "full_name": "org/repo", "owner": {"login": "org"}},
+	"sender": {"login": "alice"},
+	"pull_request": {"number": 8, "title": "Feature", "head": {"ref": "feature"}, "base": {"ref": "main"}}
+}`
+
+// recordingHandler 统计被调用次数的处理器
+type recordingHandler struct {
+	*modes.BaseHandler
+	calls int32
+}
+
+func (h *recordingHandler) CanHandle(ctx context.Context, event models.GitHubContext) bool {
+	return true
+}
+
+func (h *recordingHandler) Execute(ctx context.Context, event models.GitHubContext) error {
+	atomic.AddInt32(&h.calls, 1)
+	return nil
+}
+
+func newTestAgent(t *testing.T) (*EnhancedAgent, *recordingHandler) {
+	t.Helper()
+
+	deliveries, err := queue.NewDeliveryStore(filepath.Join(t.TempDir(), "deliveries.json"), time.Hour)
+	require.NoError(t, err)
+
+	handler := &recordingHandler{BaseHandler: modes.NewBaseHandler(modes.TagMode, 10, "recording handler")}
+	modeManager := modes.NewManager()
+	modeManager.RegisterHandler(handler)
+
+	return &EnhancedAgent{
+		eventParser: events.NewParser(),
+		modeManager: modeManager,
+		deliveries:  deliveries,
+	}, handler
+}
+
+func TestProcessGitHubWebhookEvent_Redelivery(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		payload   string
+	}{
+		{name: "issue_comment", eventType: "issue_comment", payload: issueCommentPayload},
+		{name: "pull_request", eventType: "pull_request", payload: pullRequestPayload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, handler := newTestAgent(t)
+			ctx := context.Background()
+
+			require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, tt.eventType, "delivery-1", []byte(tt.payload)))
+			assert.EqualValues(t, 1, atomic.LoadInt32(&handler.calls))
+
+			// GitHub redelivery reuses the same X-GitHub-Delivery ID
+			require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, tt.eventType, "delivery-1", []byte(tt.payload)))
+			assert.EqualValues(t, 1, atomic.LoadInt32(&handler.calls), "redelivered event must not be dispatched again")
+
+			// A new delivery is processed normally
+			require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, tt.eventType, "delivery-2", []byte(tt.payload)))
+			assert.EqualValues(t, 2, atomic.LoadInt32(&handler.calls))
+		})
+	}
+}
+
+func TestProcessGitHubWebhookEvent_BudgetExceeded(t *testing.T) {
+	agent, handler := newTestAgent(t)
+	ctx := context.Background()
+
+	store, err := usage.NewStore(filepath.Join(t.TempDir(), "usage.jsonl"), config.UsageConfig{})
+	require.NoError(t, err)
+	_, err = store.Append(usage.Record{Repo: "org/repo", User: "alice", Trigger: usage.TriggerCommand,
+		TokenUsage: models.TokenUsage{Calls: 1, CostUSD: 5}})
+	require.NoError(t, err)
+	agent.usage = store
+	agent.budgets = budget.NewChecker(store, config.BudgetConfig{
+		Repos: map[string]config.BudgetLimit{"org/repo": {DailyCost: 5}},
+	})
+
+	// 人工命令超出仓库预算，不再执行
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "issue_comment", "delivery-1", []byte(issueCommentPayload)))
+	assert.EqualValues(t, 0, atomic.LoadInt32(&handler.calls))
+
+	// 其他自动任务同样受仓库预算约束
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "pull_request", "delivery-2", []byte(pullRequestPayload)))
+	assert.EqualValues(t, 0, atomic.LoadInt32(&handler.calls))
+
+	// 自动审查同样受仓库预算约束
+	reviewer := &recordingHandler{BaseHandler: modes.NewBaseHandler(modes.ReviewMode, 10, "review handler")}
+	agent.modeManager = modes.NewManager()
+	agent.modeManager.RegisterHandler(reviewer)
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "pull_request", "delivery-3", []byte(pullRequestPayload)))
+	assert.EqualValues(t, 0, atomic.LoadInt32(&reviewer.calls))
+}
+
+func TestProcessGitHubWebhookEvent_PermissionDenied(t *testing.T) {
+	agent, handler := newTestAgent(t)
+	ctx := context.Background()
+
+	roles := map[string]string{"alice": "read"}
+	policy, err := permission.NewPolicy(config.PermissionsConfig{}, func(ctx context.Context, owner, repo, user string) (string, error) {
+		return roles[user], nil
+	})
+	require.NoError(t, err)
+	agent.permissions = policy
+
+	// 只有read权限的用户不能执行 /code
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "issue_comment", "delivery-1", []byte(issueCommentPayload)))
+	assert.EqualValues(t, 0, atomic.LoadInt32(&handler.calls))
+
+	// 自动触发的事件不检查权限
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "pull_request", "delivery-2", []byte(pullRequestPayload)))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&handler.calls))
+}
+
+func TestProcessGitHubWebhookEvent_IssueAutomationPermission(t *testing.T) {
+	agent, _ := newTestAgent(t)
+	handler := &recordingHandler{BaseHandler: modes.NewBaseHandler(modes.AgentMode, 20, "automation handler")}
+	agent.modeManager = modes.NewManager()
+	agent.modeManager.RegisterHandler(handler)
+	ctx := context.Background()
+
+	roles := map[string]string{"alice": "read", "bob": "write"}
+	policy, err := permission.NewPolicy(config.PermissionsConfig{}, func(ctx context.Context, owner, repo, user string) (string, error) {
+		return roles[user], nil
+	})
+	require.NoError(t, err)
+	agent.permissions = policy
+
+	labeled := func(sender string) []byte {
+		return []byte(fmt.Sprintf(`{
+	"action": "labeled",
+	"repository": {"id": 1, "name": "repo", "full_name": "org/repo", "owner": {"login": "org"}},
+	"sender": {"login": %q},
+	"issue": {"number": 7, "title": "Add feature"},
+	"label": {"name": "codeagent"}
+}`, sender))
+	}
+
+	// 自动化规则等同于触发者执行 /code
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "issues", "delivery-1", labeled("alice")))
+	assert.EqualValues(t, 0, atomic.LoadInt32(&handler.calls))
+
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "issues", "delivery-2", labeled("bob")))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&handler.calls))
+}
+
+// staticRepoConfigFetcher 返回固定内容的仓库配置
+type staticRepoConfigFetcher string
+
+func (f staticRepoConfigFetcher) DefaultBranchSHA(ctx context.Context, owner, repo string) (string, error) {
+	return "abc123", nil
+}
+
+func (f staticRepoConfigFetcher) FileContent(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	return []byte(f), nil
+}
+
+// providerHandler 记录处理器看到的仓库配置provider
+type providerHandler struct {
+	*modes.BaseHandler
+	provider string
+}
+
+func (h *providerHandler) CanHandle(ctx context.Context, event models.GitHubContext) bool {
+	return true
+}
+
+func (h *providerHandler) Execute(ctx context.Context, event models.GitHubContext) error {
+	h.provider = repoconfig.FromContext(ctx).ProviderOr("default")
+	return nil
+}
+
+func TestProcessGitHubWebhookEvent_RepoConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		provider string
+	}{
+		{name: "valid", content: "provider: gemini\n", provider: "gemini"},
+		{name: "invalid falls back to global config", content: "provider: copilot\n", provider: "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, _ := newTestAgent(t)
+			handler := &providerHandler{BaseHandler: modes.NewBaseHandler(modes.TagMode, 20, "provider handler")}
+			agent.modeManager = modes.NewManager()
+			agent.modeManager.RegisterHandler(handler)
+			agent.repoConfigs = repoconfig.NewLoader(staticRepoConfigFetcher(tt.content))
+
+			require.NoError(t, agent.ProcessGitHubWebhookEvent(context.Background(), "issue_comment", "delivery-1", []byte(issueCommentPayload)))
+			assert.Equal(t, tt.provider, handler.provider)
+		})
+	}
+}
+
+func TestProcessGitHubWebhookEvent_ForceReprocess(t *testing.T) {
+	agent, handler := newTestAgent(t)
+	ctx := context.Background()
+	payload := []byte(issueCommentPayload)
+
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "issue_comment", "delivery-1", payload))
+	require.NoError(t, agent.ReprocessGitHubWebhookEvent(ctx, "issue_comment", "delivery-1", payload))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&handler.calls))
+
+	require.NoError(t, agent.ForgetDelivery("delivery-1"))
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "issue_comment", "delivery-1", payload))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&handler.calls))
+}
+
+func TestProcessGitHubWebhookEvent_WithoutDeliveryID(t *testing.T) {
+	agent, handler := newTestAgent(t)
+	ctx := context.Background()
+	payload := []byte(issueCommentPayload)
+
+	// Without a delivery ID there is nothing to deduplicate on
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "issue_comment", "", payload))
+	require.NoError(t, agent.ProcessGitHubWebhookEvent(ctx, "issue_comment", "", payload))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&handler.calls))
+}
+
+func TestProcessJob_RetryBypassesDeduplication(t *testing.T) {
+	agent, handler := newTestAgent(t)
+	store, err := queue.NewFileStore(t.TempDir(), queue.Options{})
+	require.NoError(t, err)
+	agent.queue = store
+
+	_, err = store.Enqueue("issue_comment", "delivery-1", []byte(issueCommentPayload), false)
+	require.NoError(t, err)
+	job, err := store.Claim()
+	require.NoError(t, err)
+
+	// The first attempt recorded the delivery but failed afterwards
+	_, err = agent.deliveries.MarkIfNew("delivery-1", false)
+	require.NoError(t, err)
+	job.Attempts = 2
+
+	agent.processJob(0, job)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&handler.calls))
+
+	got, err := store.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, queue.JobStateSucceeded, got.State)
+}
+
+// timeoutHandler 模拟AI调用超时
+type timeoutHandler struct {
+	*modes.BaseHandler
+}
+
+func (h *timeoutHandler) CanHandle(ctx context.Context, event models.GitHubContext) bool {
+	return true
+}
+
+func (h *timeoutHandler) Execute(ctx context.Context, event models.GitHubContext) error {
+	return fmt.Errorf("failed to read AI response: %w", &code.TimeoutError{Provider: "claude", Timeout: time.Minute, PartialOutput: "partial"})
+}
+
+func TestProcessGitHubWebhookEvent_TimeoutIsNotRetried(t *testing.T) {
+	modeManager := modes.NewManager()
+	modeManager.RegisterHandler(&timeoutHandler{BaseHandler: modes.NewBaseHandler(modes.TagMode, 10, "timeout handler")})
+	agent := &EnhancedAgent{
+		eventParser: events.NewParser(),
+		modeManager: modeManager,
+		tasks:       NewTaskRegistry(),
+	}
+
+	err := agent.ProcessGitHubWebhookEvent(context.Background(), "issue_comment", "", []byte(issueCommentPayload))
+	require.Error(t, err)
+	assert.True(t, queue.IsPermanent(err))
+
+	timeoutErr, ok := code.AsTimeoutError(err)
+	require.True(t, ok)
+	assert.Equal(t, "partial", timeoutErr.PartialOutput)
+}
+
+func TestProcessJob_PermanentFailureForgetsDelivery(t *testing.T) {
+	deliveries, err := queue.NewDeliveryStore(filepath.Join(t.TempDir(), "deliveries.json"), time.Hour)
+	require.NoError(t, err)
+	store, err := queue.NewFileStore(t.TempDir(), queue.Options{})
+	require.NoError(t, err)
+
+	modeManager := modes.NewManager()
+	modeManager.RegisterHandler(&timeoutHandler{BaseHandler: modes.NewBaseHandler(modes.TagMode, 10, "timeout handler")})
+	agent := &EnhancedAgent{
+		eventParser: events.NewParser(),
+		modeManager: modeManager,
+		deliveries:  deliveries,
+		queue:       store,
+		tasks:       NewTaskRegistry(),
+	}
+
+	_, err = store.Enqueue("issue_comment", "delivery-1", []byte(issueCommentPayload), false)
+	require.NoError(t, err)
+	job, err := store.Claim()
+	require.NoError(t, err)
+
+	agent.processJob(0, job)
+	got, err := store.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, queue.JobStateFailed, got.State)
+
+	// GitHub redelivery of the failed event must be processed again
+	assert.False(t, deliveries.Seen("delivery-1"))
+}
+
+// deferringHandler 第一次执行时要求稍后重试
+type deferringHandler struct {
+	*modes.BaseHandler
+	calls int32
+}
+
+func (h *deferringHandler) CanHandle(ctx context.Context, event models.GitHubContext) bool {
+	return true
+}
+
+func (h *deferringHandler) Execute(ctx context.Context, event models.GitHubContext) error {
+	if atomic.AddInt32(&h.calls, 1) == 1 {
+		return queue.Defer(fmt.Errorf("checks are still running"), time.Millisecond)
+	}
+	return nil
+}
+
+func TestProcessJob_HandlerDeferral(t *testing.T) {
+	store, err := queue.NewFileStore(t.TempDir(), queue.Options{})
+	require.NoError(t, err)
+
+	handler := &deferringHandler{BaseHandler: modes.NewBaseHandler(modes.CIFixMode, 2, "deferring handler")}
+	modeManager := modes.NewManager()
+	modeManager.RegisterHandler(handler)
+	agent := &EnhancedAgent{
+		eventParser: events.NewParser(),
+		modeManager: modeManager,
+		queue:       store,
+		scheduler:   NewScheduler(1, 1),
+		tasks:       NewTaskRegistry(),
+	}
+
+	_, err = store.Enqueue("issue_comment", "delivery-1", []byte(issueCommentPayload), false)
+	require.NoError(t, err)
+	job, err := store.Claim()
+	require.NoError(t, err)
+
+	agent.processJob(0, job)
+	got, err := store.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, queue.JobStateQueued, got.State)
+	assert.Equal(t, 0, got.Attempts, "a deferral requested by the handler must not use up an attempt")
+	running, waiting := agent.scheduler.Stats()
+	assert.Equal(t, 0, running, "the slot must be released while the job waits")
+	assert.Equal(t, 0, waiting)
+
+	require.Eventually(t, func() bool {
+		job, err = store.Claim()
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+	agent.processJob(0, job)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&handler.calls))
+	got, err = store.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, queue.JobStateSucceeded, got.State)
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	"github.com/qiniu/codeagent/internal/budget"
+	"github.com/qiniu/codeagent/internal/interaction"
+	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/xlog"
+)
+
+// checkBudget 在启动AI会话前检查用量预算，返回false表示任务不应执行。
+// 人工命令超出预算时回复说明评论；自动任务超出预算或被限流时只记录日志，避免每次推送都产生评论
+func (a *EnhancedAgent) checkBudget(ctx context.Context, event models.GitHubContext, info usage.TaskInfo, mode modes.ExecutionMode) bool {
+	xl := xlog.NewWith(ctx)
+
+	if a.budgets == nil {
+		return true
+	}
+	// PR关闭时只清理资源，不调用AI
+	if event.GetEventType() == models.EventPullRequest && event.GetEventAction() == "closed" {
+		return true
+	}
+
+	auto := info.Trigger == usage.TriggerAuto
+	err := a.budgets.Check(budgetRequest(info, mode))
+	if err == nil {
+		return true
+	}
+	xl.Warnf("Skipping %s on %s#%d: %v", info.Command, info.Repo, info.Number, err)
+
+	var exceeded *budget.ExceededError
+	if auto || !errors.As(err, &exceeded) {
+		return false
+	}
+	a.reportBudgetExceeded(ctx, event, info.User, exceeded)
+	return false
+}
+
+// budgetRequest 任务对应的预算检查请求
+func budgetRequest(info usage.TaskInfo, mode modes.ExecutionMode) budget.Request {
+	return budget.Request{
+		Repo:   info.Repo,
+		Number: info.Number,
+		User:   info.User,
+		Auto:   info.Trigger == usage.TriggerAuto,
+		Review: mode == modes.ReviewMode,
+	}
+}
+
+// reportBudgetExceeded 在触发任务的Issue/PR上回复预算用完的说明
+func (a *EnhancedAgent) reportBudgetExceeded(ctx context.Context, event models.GitHubContext, user string, exceeded *budget.ExceededError) {
+	xl := xlog.NewWith(ctx)
+
+	key, ok := taskKeyFromContext(event, a.workspace)
+	if !ok || key.Number == 0 || a.clientManager == nil {
+		return
+	}
+	repo := event.GetRepository()
+	client, err := a.clientManager.GetClient(ctx, &models.Repository{Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+	if err != nil {
+		xl.Warnf("Failed to get GitHub client for budget notice: %v", err)
+		return
+	}
+
+	body := interaction.RenderBudgetExceededComment(user, exceeded.Describe(), exceeded.ResetAt)
+	if _, err := client.CreateComment(ctx, repo.GetOwner().GetLogin(), repo.GetName(), key.Number, body); err != nil {
+		xl.Warnf("Failed to report exceeded budget: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/interaction"
+	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/qiniu/x/xlog"
+)
+
+// taskCheckRunPrefix 任务check run名称的前缀，后接命令名，例如 codeagent/task/code
+const taskCheckRunPrefix = "codeagent/task/"
+
+// taskChecks 将一个任务报告为PR head提交上的check run。
+// 从Issue触发的任务在创建PR之后才有head提交，此时只在任务结束时创建已完成的check run；
+// 任务推送了新提交时，新的head提交上也会创建一个已完成的check run，使结果出现在PR的checks页
+type taskChecks struct {
+	client  *ghclient.Client
+	repo    *github.Repository
+	name    string
+	summary string
+
+	mu       sync.Mutex
+	number   int // PR编号，0表示还没有关联的PR
+	reporter *interaction.CheckRunReporter
+}
+
+// newTaskChecks 为任务创建check run报告器；未启用check run或无法获取GitHub客户端时返回nil
+func (a *EnhancedAgent) newTaskChecks(ctx context.Context, event models.GitHubContext, mode modes.ExecutionMode, info usage.TaskInfo) *taskChecks {
+	xl := xlog.NewWith(ctx)
+
+	if a.clientManager == nil || a.config == nil || !a.config.TaskChecksEnabled() {
+		return nil
+	}
+	repo := event.GetRepository()
+	client, err := a.clientManager.GetClient(ctx, &models.Repository{Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+	if err != nil {
+		xl.Warnf("Failed to get GitHub client for check runs: %v", err)
+		return nil
+	}
+
+	checks := &taskChecks{
+		client:  client,
+		repo:    repo,
+		name:    taskCheckRunName(info, mode),
+		summary: taskCheckRunSummary(info),
+		number:  info.PR,
+	}
+	if headSHA := pullRequestHeadSHA(event); headSHA != "" {
+		checks.reporter = interaction.NewCheckRunReporter(client, repo, checks.name, headSHA, "")
+	} else if checks.number > 0 {
+		pr, err := client.GetPullRequest(repo.GetOwner().GetLogin(), repo.GetName(), checks.number)
+		if err != nil {
+			xl.Warnf("Failed to get head commit of PR #%d for check runs: %v", checks.number, err)
+		} else {
+			checks.reporter = interaction.NewCheckRunReporter(client, repo, checks.name, pr.GetHead().GetSHA(), "")
+		}
+	}
+	return checks
+}
+
+// Queued 任务进入排队时创建排队状态的check run
+func (c *taskChecks) Queued(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	reporter := c.reporter
+	c.mu.Unlock()
+	if reporter == nil {
+		return
+	}
+	if err := reporter.Queued(ctx); err != nil {
+		xlog.NewWith(ctx).Warnf("Failed to report queued task: %v", err)
+	}
+}
+
+// Started 任务开始执行时将check run更新为执行中
+func (c *taskChecks) Started(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	reporter := c.reporter
+	c.mu.Unlock()
+	if reporter == nil {
+		return
+	}
+	if err := reporter.Started(ctx); err != nil {
+		xlog.NewWith(ctx).Warnf("Failed to report started task: %v", err)
+	}
+}
+
+// SetPullRequest 记录任务创建或关联的PR，任务结束时在该PR上报告结果
+func (c *taskChecks) SetPullRequest(number int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.number == 0 {
+		c.number = number
+	}
+}
+
+// Completed 以任务结果完成check run
+func (c *taskChecks) Completed(ctx context.Context, execErr error, cancelled bool, tokenUsage models.TokenUsage, duration time.Duration) {
+	if c == nil {
+		return
+	}
+	xl := xlog.NewWith(ctx)
+
+	c.mu.Lock()
+	number, reporter := c.number, c.reporter
+	c.mu.Unlock()
+	if number == 0 {
+		// 任务没有关联的PR，没有可以报告的提交
+		return
+	}
+
+	owner, name := c.repo.GetOwner().GetLogin(), c.repo.GetName()
+	pr, err := c.client.GetPullRequest(owner, name, number)
+	if err != nil {
+		xl.Warnf("Failed to get PR #%d for check runs: %v", number, err)
+		return
+	}
+	headSHA := pr.GetHead().GetSHA()
+
+	result := &models.ProgressExecutionResult{
+		Success:        execErr == nil,
+		Cancelled:      cancelled,
+		Duration:       duration,
+		Summary:        c.summary,
+		BranchName:     pr.GetHead().GetRef(),
+		PullRequestURL: pr.GetHTMLURL(),
+	}
+	if execErr != nil && !cancelled {
+		result.Error = execErr.Error()
+	}
+	if tokenUsage.Calls > 0 {
+		result.Usage = &tokenUsage
+	}
+	if reporter == nil || reporter.HeadSHA() != headSHA {
+		result.CommitSHA = headSHA
+		result.FilesChanged = c.changedFiles(ctx, pr, reporter)
+	}
+
+	if reporter != nil {
+		if err := reporter.Completed(ctx, result); err != nil {
+			xl.Warnf("Failed to report completed task: %v", err)
+		}
+		if reporter.HeadSHA() == headSHA {
+			return
+		}
+	}
+	// 任务推送了新提交，在新的head提交上报告结果
+	latest := interaction.NewCheckRunReporter(c.client, c.repo, c.name, headSHA, "")
+	if err := latest.Completed(ctx, result); err != nil {
+		xl.Warnf("Failed to report completed task on %s: %v", headSHA, err)
+	}
+}
+
+// changedFiles 返回任务修改的文件：任务开始时已有PR的，比较开始时和当前的head提交；否则返回PR的全部变更文件
+func (c *taskChecks) changedFiles(ctx context.Context, pr *github.PullRequest, reporter *interaction.CheckRunReporter) []string {
+	xl := xlog.NewWith(ctx)
+	owner, name := c.repo.GetOwner().GetLogin(), c.repo.GetName()
+
+	var files []*github.CommitFile
+	if reporter != nil {
+		comparison, err := c.client.CompareCommits(ctx, owner, name, reporter.HeadSHA(), pr.GetHead().GetSHA())
+		if err != nil {
+			xl.Warnf("Failed to compare task commits: %v", err)
+			return nil
+		}
+		files = comparison.Files
+	} else {
+		var err error
+		files, err = c.client.ListPullRequestFiles(ctx, owner, name, pr.GetNumber())
+		if err != nil {
+			xl.Warnf("Failed to list files of PR #%d: %v", pr.GetNumber(), err)
+			return nil
+		}
+	}
+
+	var changed []string
+	for _, file := range files {
+		if file.GetStatus() != "removed" {
+			changed = append(changed, file.GetFilename())
+		}
+	}
+	return changed
+}
+
+// pullRequestHeadSHA 返回PR事件中的head提交，其他事件返回空字符串
+func pullRequestHeadSHA(event models.GitHubContext) string {
+	switch e := event.(type) {
+	case *models.PullRequestContext:
+		return e.PullRequest.GetHead().GetSHA()
+	case *models.PullRequestReviewContext:
+		return e.PullRequest.GetHead().GetSHA()
+	case *models.PullRequestReviewCommentContext:
+		return e.PullRequest.GetHead().GetSHA()
+	}
+	return ""
+}
+
+// taskCheckRunName 返回任务check run的名称：命令触发的任务使用命令名，自动触发的任务使用处理模式
+func taskCheckRunName(info usage.TaskInfo, mode modes.ExecutionMode) string {
+	if info.Trigger != usage.TriggerCommand {
+		return taskCheckRunPrefix + string(mode)
+	}
+	if strings.HasPrefix(info.Command, "@") {
+		return taskCheckRunPrefix + "mention"
+	}
+	return taskCheckRunPrefix + strings.TrimPrefix(info.Command, "/")
+}
+
+// taskCheckRunSummary 返回check run总结中的任务来源说明
+func taskCheckRunSummary(info usage.TaskInfo) string {
+	source := fmt.Sprintf("#%d", info.Number)
+	if info.Trigger != usage.TriggerCommand {
+		return fmt.Sprintf("Automatic `%s` task for %s.", info.Command, source)
+	}
+	return fmt.Sprintf("`%s` requested by @%s on %s.", info.Command, info.User, source)
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskCheckRunName(t *testing.T) {
+	assert.Equal(t, "codeagent/task/code", taskCheckRunName(usage.TaskInfo{Trigger: usage.TriggerCommand, Command: "/code"}, modes.TagMode))
+	assert.Equal(t, "codeagent/task/mention", taskCheckRunName(usage.TaskInfo{Trigger: usage.TriggerCommand, Command: "@qiniu-ci"}, modes.TagMode))
+	assert.Equal(t, "codeagent/task/review", taskCheckRunName(usage.TaskInfo{Trigger: usage.TriggerAuto, Command: "pull_request.opened"}, modes.ReviewMode))
+}
+
+func TestPullRequestHeadSHA(t *testing.T) {
+	pr := &github.PullRequest{Head: &github.PullRequestBranch{SHA: github.String("abc123")}}
+	assert.Equal(t, "abc123", pullRequestHeadSHA(&models.PullRequestContext{PullRequest: pr}))
+	assert.Equal(t, "abc123", pullRequestHeadSHA(&models.PullRequestReviewCommentContext{PullRequest: pr}))
+	assert.Empty(t, pullRequestHeadSHA(&models.IssueCommentContext{}))
+}
+
+func TestTaskChecks_NilSafe(t *testing.T) {
+	agent, _ := newTestAgent(t)
+	ctx := context.Background()
+	checks := agent.newTaskChecks(ctx, &models.IssueCommentContext{}, modes.TagMode, usage.TaskInfo{})
+	assert.Nil(t, checks)
+
+	// nil报告器的所有方法都不做任何操作
+	checks.Queued(ctx)
+	checks.Started(ctx)
+	checks.SetPullRequest(8)
+	checks.Completed(ctx, nil, false, models.TokenUsage{}, 0)
+}
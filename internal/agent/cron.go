@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/events"
+	"github.com/qiniu/codeagent/internal/queue"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/qiniu/x/xlog"
+)
+
+const (
+	// cronInterval 检查定时任务的周期，小于一分钟以免错过整分钟
+	cronInterval = 20 * time.Second
+	// cronMaxCatchUp 进程暂停后最多补偿的分钟数
+	cronMaxCatchUp = time.Hour
+	// cronLoadTimeout 读取单个仓库配置的超时时间
+	cronLoadTimeout = 30 * time.Second
+)
+
+// startCron 启动定时任务调度，每分钟按仓库配置的 automation.schedules 生成schedule事件
+func (a *EnhancedAgent) startCron(repos []string) {
+	a.workersWG.Add(1)
+	go a.runCron(repos)
+}
+
+func (a *EnhancedAgent) runCron(repos []string) {
+	defer a.workersWG.Done()
+
+	ticker := time.NewTicker(cronInterval)
+	defer ticker.Stop()
+
+	last := time.Now().UTC().Truncate(time.Minute)
+	for {
+		select {
+		case <-a.stopCh:
+			return
+		case now := <-ticker.C:
+			current := now.UTC().Truncate(time.Minute)
+			if current.Sub(last) > cronMaxCatchUp {
+				last = current.Add(-cronMaxCatchUp)
+			}
+			// 依次检查上次之后的每一分钟，事件使用确定的delivery ID，重复生成时会被去重
+			for last.Before(current) {
+				last = last.Add(time.Minute)
+				for _, repo := range repos {
+					ctx, cancel := context.WithTimeout(context.Background(), cronLoadTimeout)
+					a.enqueueDueSchedules(ctx, repo, last)
+					cancel()
+				}
+			}
+		}
+	}
+}
+
+// enqueueDueSchedules 将仓库在t所在分钟到期的定时任务加入任务队列
+func (a *EnhancedAgent) enqueueDueSchedules(ctx context.Context, fullName string, t time.Time) {
+	xl := xlog.NewWith(ctx)
+
+	repo, err := cronRepository(fullName)
+	if err != nil {
+		xl.Warnf("Skipping cron repo: %v", err)
+		return
+	}
+	if a.repoConfigs == nil {
+		return
+	}
+	cfg, err := a.repoConfigs.Load(ctx, repo.GetOwner().GetLogin(), repo.GetName())
+	if err != nil {
+		xl.Warnf("Failed to load repository config of %s for cron: %v", fullName, err)
+		return
+	}
+
+	for _, rule := range cfg.DueSchedules(t) {
+		payload, err := json.Marshal(&events.SchedulePayload{Schedule: rule.Cron, Job: rule.Job, Repo: repo})
+		if err != nil {
+			xl.Errorf("Failed to encode schedule event: %v", err)
+			continue
+		}
+		deliveryID := fmt.Sprintf("schedule-%s-%s-%s", fullName, rule.Job, t.Format("200601021504"))
+		if _, err := a.EnqueueWebhookEvent(ctx, string(models.EventSchedule), deliveryID, payload, false); err != nil {
+			xl.Errorf("Failed to enqueue job %s for %s: %v", rule.Job, fullName, err)
+			continue
+		}
+		xl.Infof("Scheduled job %s for %s (cron: %s)", rule.Job, fullName, rule.Cron)
+	}
+}
+
+// EnqueuePullRequestJob 实现 modes.JobQueue，将定时任务中单个PR的子任务作为schedule事件加入任务队列
+func (a *EnhancedAgent) EnqueuePullRequestJob(ctx context.Context, repo *github.Repository, job, cron string, number int, deliveryID string) error {
+	payload, err := json.Marshal(&events.SchedulePayload{Schedule: cron, Job: job, Repo: repo, PullRequest: number})
+	if err != nil {
+		return fmt.Errorf("failed to encode schedule event: %w", err)
+	}
+	if _, err := a.EnqueueWebhookEvent(ctx, string(models.EventSchedule), deliveryID, payload, false); err != nil {
+		return fmt.Errorf("failed to enqueue job %s for PR #%d: %w", job, number, err)
+	}
+	return nil
+}
+
+// DispatchJob 手动触发仓库的定时任务，inputs覆盖仓库配置中的任务参数
+func (a *EnhancedAgent) DispatchJob(ctx context.Context, fullName, job string, inputs map[string]interface{}) (*queue.Job, error) {
+	if !models.IsValidJob(job) {
+		return nil, fmt.Errorf("unknown job %q", job)
+	}
+	repo, err := cronRepository(fullName)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := map[string]interface{}{}
+	for key, value := range inputs {
+		merged[key] = value
+	}
+	merged["job"] = job
+	rawInputs, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inputs: %w", err)
+	}
+	payload, err := json.Marshal(&github.WorkflowDispatchEvent{
+		Inputs:   rawInputs,
+		Workflow: github.String(job),
+		Repo:     repo,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode workflow dispatch event: %w", err)
+	}
+
+	deliveryID := fmt.Sprintf("dispatch-%s-%s-%d", fullName, job, time.Now().UnixNano())
+	return a.EnqueueWebhookEvent(ctx, string(models.EventWorkflowDispatch), deliveryID, payload, false)
+}
+
+// cronRepository 根据 owner/repo 构造事件中的仓库信息
+func cronRepository(fullName string) (*github.Repository, error) {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("repository %q must be in owner/repo form", fullName)
+	}
+	return &github.Repository{
+		FullName: github.String(fullName),
+		Name:     github.String(name),
+		Owner:    &github.User{Login: github.String(owner)},
+	}, nil
+}
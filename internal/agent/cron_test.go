@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/queue"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnqueueDueSchedules(t *testing.T) {
+	agent, handler := newTestAgent(t)
+	ctx := context.Background()
+
+	jobQueue, err := queue.NewFileStore(t.TempDir(), queue.Options{})
+	require.NoError(t, err)
+	agent.queue = jobQueue
+	agent.repoConfigs = repoconfig.NewLoader(staticRepoConfigFetcher(`
+automation:
+  schedules:
+    - job: stale-issues
+      cron: "0 3 * * *"
+    - job: review-open-prs
+      cron: "0 3 * * 1"
+`))
+
+	// 不在调度时间内
+	agent.enqueueDueSchedules(ctx, "owner/repo", time.Date(2024, 5, 5, 3, 1, 0, 0, time.UTC))
+	assert.Empty(t, jobQueue.List())
+
+	// 同一分钟被检查两次时只执行一次
+	due := time.Date(2024, 5, 6, 3, 0, 0, 0, time.UTC) // 周一
+	agent.enqueueDueSchedules(ctx, "owner/repo", due)
+	agent.enqueueDueSchedules(ctx, "owner/repo", due)
+	jobs := jobQueue.List()
+	require.Len(t, jobs, 4)
+	for _, job := range jobs {
+		assert.Equal(t, "schedule", job.EventType)
+		require.NoError(t, agent.processWebhookEvent(ctx, job.EventType, job.DeliveryID, job.Payload, false))
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt32(&handler.calls))
+
+	agent.enqueueDueSchedules(ctx, "not-a-repo", due)
+	assert.Len(t, jobQueue.List(), 4)
+}
+
+func TestDispatchJob(t *testing.T) {
+	agent, handler := newTestAgent(t)
+	ctx := context.Background()
+
+	jobQueue, err := queue.NewFileStore(t.TempDir(), queue.Options{})
+	require.NoError(t, err)
+	agent.queue = jobQueue
+
+	_, err = agent.DispatchJob(ctx, "owner/repo", "cleanup", nil)
+	assert.Error(t, err)
+	_, err = agent.DispatchJob(ctx, "owner", "stale-issues", nil)
+	assert.Error(t, err)
+
+	job, err := agent.DispatchJob(ctx, "owner/repo", "stale-issues", map[string]interface{}{"days": 7})
+	require.NoError(t, err)
+	assert.Equal(t, "workflow_dispatch", job.EventType)
+	require.NoError(t, agent.processWebhookEvent(ctx, job.EventType, job.DeliveryID, job.Payload, false))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&handler.calls))
+}
+
+func TestEnqueuePullRequestJob(t *testing.T) {
+	agent, _ := newTestAgent(t)
+	ctx := context.Background()
+
+	jobQueue, err := queue.NewFileStore(t.TempDir(), queue.Options{})
+	require.NoError(t, err)
+	agent.queue = jobQueue
+
+	repo, err := cronRepository("owner/repo")
+	require.NoError(t, err)
+	require.NoError(t, agent.EnqueuePullRequestJob(ctx, repo, "review-open-prs", "0 3 * * 1", 12, "schedule-1-pr-12"))
+
+	jobs := jobQueue.List()
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "schedule-1-pr-12", jobs[0].DeliveryID)
+
+	// 子任务按PR串行调度，可以通过 /cancel 单独取消
+	event, err := agent.eventParser.ParseWebhookEvent(ctx, jobs[0].EventType, jobs[0].DeliveryID, jobs[0].Payload)
+	require.NoError(t, err)
+	key, ok := taskKeyFromContext(event, agent.workspace)
+	require.True(t, ok)
+	assert.Equal(t, TaskKey{Repo: "owner/repo", Number: 12}, key)
+}
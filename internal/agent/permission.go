@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/interaction"
+	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/internal/permission"
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/xlog"
+)
+
+// authorize 检查触发命令的用户在仓库上的角色是否满足命令要求，返回携带用户角色的ctx。
+// 权限不足时回复说明评论并返回false；Issue自动化规则视为由触发事件的用户执行 /code，
+// 权限不足时只记录日志；其他自动触发的事件不需要检查
+func (a *EnhancedAgent) authorize(ctx context.Context, event models.GitHubContext, info usage.TaskInfo, mode modes.ExecutionMode) (context.Context, bool, error) {
+	xl := xlog.NewWith(ctx)
+
+	command := info.Command
+	automated := false
+	if _, ok := event.(*models.IssuesContext); ok && mode == modes.AgentMode && info.Trigger != usage.TriggerCommand {
+		command = models.CommandCode
+		automated = true
+	}
+	if a.permissions == nil || (info.Trigger != usage.TriggerCommand && !automated) || info.User == "" {
+		return ctx, true, nil
+	}
+
+	repo := event.GetRepository()
+	role, err := a.permissions.Check(ctx, repo.GetOwner().GetLogin(), repo.GetName(), info.User, command)
+	if err != nil {
+		var denied *permission.DeniedError
+		if errors.As(err, &denied) {
+			xl.Warnf("Permission denied: %v", err)
+			if !automated {
+				a.reportPermissionDenied(ctx, event, denied)
+			}
+			return ctx, false, nil
+		}
+		return ctx, false, err
+	}
+	xl.Infof("User %s has %s access, allowed to run %s", info.User, role, command)
+	return permission.NewContext(ctx, role), true, nil
+}
+
+// reportPermissionDenied 在触发命令的Issue/PR上回复权限不足的说明
+func (a *EnhancedAgent) reportPermissionDenied(ctx context.Context, event models.GitHubContext, denied *permission.DeniedError) {
+	xl := xlog.NewWith(ctx)
+
+	key, ok := taskKeyFromContext(event, a.workspace)
+	if !ok || key.Number == 0 || a.clientManager == nil {
+		return
+	}
+	repo := event.GetRepository()
+	client, err := a.clientManager.GetClient(ctx, &models.Repository{Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+	if err != nil {
+		xl.Warnf("Failed to get GitHub client for permission notice: %v", err)
+		return
+	}
+
+	body := interaction.RenderPermissionDeniedComment(denied.User, denied.Command, denied.Role.String(), denied.Required.String())
+	if _, err := client.CreateComment(ctx, repo.GetOwner().GetLogin(), repo.GetName(), key.Number, body); err != nil {
+		xl.Warnf("Failed to report permission denial: %v", err)
+	}
+}
+
+// githubPermissionResolver 通过GitHub协作者接口查询用户在仓库上的角色
+func githubPermissionResolver(clientManager ghclient.ClientManagerInterface) permission.Resolver {
+	return func(ctx context.Context, owner, repo, user string) (string, error) {
+		client, err := clientManager.GetClient(ctx, &models.Repository{Owner: owner, Name: repo})
+		if err != nil {
+			return "", err
+		}
+		return client.GetPermissionLevel(ctx, owner, repo, user)
+	}
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/interaction"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/xlog"
+)
+
+// loadRepoConfig 读取仓库默认分支上的 .codeagent/config.yaml，返回携带仓库配置的ctx。
+// 读取失败或配置不合法时沿用全局配置，配置错误会在触发事件的Issue/PR上回复一次
+func (a *EnhancedAgent) loadRepoConfig(ctx context.Context, event models.GitHubContext) context.Context {
+	xl := xlog.NewWith(ctx)
+
+	repo := event.GetRepository()
+	if a.repoConfigs == nil || repo == nil {
+		return ctx
+	}
+
+	cfg, err := a.repoConfigs.Load(ctx, repo.GetOwner().GetLogin(), repo.GetName())
+	if err != nil {
+		var invalid *repoconfig.ValidationError
+		if errors.As(err, &invalid) {
+			xl.Warnf("Ignoring repository config: %v", err)
+			if a.repoConfigs.MarkReported(invalid) {
+				a.reportRepoConfigError(ctx, event, invalid)
+			}
+			return ctx
+		}
+		xl.Warnf("Failed to load repository config, using global config: %v", err)
+		return ctx
+	}
+	if cfg == nil {
+		return ctx
+	}
+	return repoconfig.NewContext(ctx, cfg)
+}
+
+// reportRepoConfigError 在触发事件的Issue/PR上回复仓库配置的校验错误
+func (a *EnhancedAgent) reportRepoConfigError(ctx context.Context, event models.GitHubContext, invalid *repoconfig.ValidationError) {
+	xl := xlog.NewWith(ctx)
+
+	key, ok := taskKeyFromContext(event, a.workspace)
+	if !ok || key.Number == 0 || a.clientManager == nil {
+		return
+	}
+	repo := event.GetRepository()
+	client, err := a.clientManager.GetClient(ctx, &models.Repository{Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+	if err != nil {
+		xl.Warnf("Failed to get GitHub client for repository config notice: %v", err)
+		return
+	}
+
+	body := interaction.RenderRepoConfigErrorComment(repoconfig.Path, invalid.SHA, invalid.Problems)
+	if _, err := client.CreateComment(ctx, repo.GetOwner().GetLogin(), repo.GetName(), key.Number, body); err != nil {
+		xl.Warnf("Failed to report repository config error: %v", err)
+	}
+}
+
+// githubRepoConfigFetcher 通过GitHub API读取仓库默认分支上的配置文件
+type githubRepoConfigFetcher struct {
+	clientManager ghclient.ClientManagerInterface
+}
+
+func (f *githubRepoConfigFetcher) DefaultBranchSHA(ctx context.Context, owner, repo string) (string, error) {
+	client, err := f.clientManager.GetClient(ctx, &models.Repository{Owner: owner, Name: repo})
+	if err != nil {
+		return "", err
+	}
+	return client.GetDefaultBranchSHA(ctx, owner, repo)
+}
+
+func (f *githubRepoConfigFetcher) FileContent(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	client, err := f.clientManager.GetClient(ctx, &models.Repository{Owner: owner, Name: repo})
+	if err != nil {
+		return nil, err
+	}
+	content, err := client.GetFileContent(ctx, owner, repo, path, ref)
+	if errors.Is(err, ghclient.ErrFileNotFound) {
+		return nil, repoconfig.ErrNotFound
+	}
+	return content, err
+}
@@ -0,0 +1,463 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/interaction"
+	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/internal/queue"
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/internal/workspace"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/xlog"
+)
+
+const (
+	// defaultMaxConcurrent 默认全局并发上限
+	defaultMaxConcurrent = 4
+	// defaultMaxPerRepo 默认单仓库并发上限
+	defaultMaxPerRepo = 2
+	// waitingTTL 排队任务超过这个时间没有再次申请名额时放弃其排队位置
+	waitingTTL = 30 * time.Minute
+	// slotRetryInterval 排队任务没有被唤醒时重新申请名额的间隔
+	slotRetryInterval = 30 * time.Second
+)
+
+// TaskKey 标识一个串行执行单元：同一仓库下的同一个PR或Issue
+type TaskKey struct {
+	Repo   string // owner/repo
+	Number int    // PR或Issue编号，0表示仓库级任务
+}
+
+// String 返回任务键的可读形式
+func (k TaskKey) String() string {
+	if k.Number == 0 {
+		return k.Repo
+	}
+	return fmt.Sprintf("%s#%d", k.Repo, k.Number)
+}
+
+// PositionFunc 在任务排队位置变化时被调用，position 从1开始
+type PositionFunc func(position int)
+
+// waitingTask 没有拿到执行名额而回到任务队列的任务，调度器为它保留排队位置
+type waitingTask struct {
+	id         string
+	key        TaskKey
+	lastSeen   time.Time
+	onPosition PositionFunc
+	position   int
+}
+
+// Scheduler 控制handler执行的并发：
+// 同一(仓库, PR/Issue)串行执行，同时受全局并发上限和单仓库并发上限约束。
+// 调度器不会阻塞调用方：拿不到名额的任务回到任务队列，调度器按到达顺序(FIFO)为其保留位置，
+// 名额释放时通过 OnReady 唤醒排在前面的任务。
+type Scheduler struct {
+	mu            sync.Mutex
+	maxConcurrent int
+	maxPerRepo    int
+	running       map[TaskKey]int
+	repoRunning   map[string]int
+	totalRunning  int
+	waiting       []*waitingTask
+	onReady       func(ids []string)
+	now           func() time.Time
+}
+
+// NewScheduler 创建调度器，非正数的上限使用默认值
+func NewScheduler(maxConcurrent, maxPerRepo int) *Scheduler {
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrent
+	}
+	if maxPerRepo <= 0 {
+		maxPerRepo = defaultMaxPerRepo
+	}
+	return &Scheduler{
+		maxConcurrent: maxConcurrent,
+		maxPerRepo:    maxPerRepo,
+		running:       make(map[TaskKey]int),
+		repoRunning:   make(map[string]int),
+		now:           time.Now,
+	}
+}
+
+// OnReady 设置名额释放后的回调，参数为排队中已经可以执行的任务id
+func (s *Scheduler) OnReady(fn func(ids []string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onReady = fn
+}
+
+// TryAcquire 尝试立即为key申请执行名额，成功时返回的release必须在任务结束后调用。
+// 拿不到名额时返回nil和从1开始的排队位置：id非空的任务保留排队位置，之后需要用同一个id再次申请，
+// 排队位置变化时通过onPosition通知；id为空的任务不保留排队位置。
+func (s *Scheduler) TryAcquire(id string, key TaskKey, onPosition PositionFunc) (func(), int) {
+	s.mu.Lock()
+	s.pruneLocked()
+
+	task := s.findWaitingLocked(id)
+	if task == nil {
+		task = &waitingTask{id: id, key: key}
+		s.waiting = append(s.waiting, task)
+	}
+	task.lastSeen = s.now()
+	if onPosition != nil {
+		task.onPosition = onPosition
+	}
+
+	runnable := false
+	for _, ready := range s.runnableLocked() {
+		if ready == task {
+			runnable = true
+			break
+		}
+	}
+	if !runnable {
+		position := s.positionLocked(task)
+		if id == "" {
+			s.removeWaitingLocked(task)
+		}
+		notify := s.notifyLocked()
+		s.mu.Unlock()
+		notify()
+		return nil, position
+	}
+
+	s.removeWaitingLocked(task)
+	s.running[key]++
+	s.repoRunning[key.Repo]++
+	s.totalRunning++
+	notify := s.notifyLocked()
+	s.mu.Unlock()
+	notify()
+	return s.releaseFunc(key), 0
+}
+
+// Leave 放弃id对应任务的排队位置，任务不再需要执行时调用
+func (s *Scheduler) Leave(id string) {
+	s.mu.Lock()
+	task := s.findWaitingLocked(id)
+	if task == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.removeWaitingLocked(task)
+	notify := s.notifyLocked()
+	s.mu.Unlock()
+	notify()
+}
+
+// Stats 返回当前运行中与排队中的任务数量
+func (s *Scheduler) Stats() (running, waiting int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.totalRunning, len(s.waiting)
+}
+
+// Waiting 返回key对应的排队任务数量
+func (s *Scheduler) Waiting(key TaskKey) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, task := range s.waiting {
+		if task.key == key {
+			count++
+		}
+	}
+	return count
+}
+
+// IsRunning 判断key对应的任务是否正在执行
+func (s *Scheduler) IsRunning(key TaskKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running[key] > 0
+}
+
+func (s *Scheduler) releaseFunc(key TaskKey) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.running[key]--
+			if s.running[key] <= 0 {
+				delete(s.running, key)
+			}
+			s.repoRunning[key.Repo]--
+			if s.repoRunning[key.Repo] <= 0 {
+				delete(s.repoRunning, key.Repo)
+			}
+			s.totalRunning--
+			notify := s.notifyLocked()
+			s.mu.Unlock()
+			notify()
+		})
+	}
+}
+
+// runnableLocked 按FIFO顺序为排队任务预留名额，返回现在就可以执行的排队任务。
+// 调用方必须持有s.mu。
+func (s *Scheduler) runnableLocked() []*waitingTask {
+	running := make(map[TaskKey]int, len(s.running))
+	for key, n := range s.running {
+		running[key] = n
+	}
+	repoRunning := make(map[string]int, len(s.repoRunning))
+	for repo, n := range s.repoRunning {
+		repoRunning[repo] = n
+	}
+	total := s.totalRunning
+
+	blocked := make(map[TaskKey]bool)
+	var runnable []*waitingTask
+	for _, task := range s.waiting {
+		// 同一key的后续任务不能越过前面仍在排队的任务
+		if blocked[task.key] || running[task.key] > 0 || total >= s.maxConcurrent || repoRunning[task.key.Repo] >= s.maxPerRepo {
+			blocked[task.key] = true
+			continue
+		}
+		running[task.key]++
+		repoRunning[task.key.Repo]++
+		total++
+		runnable = append(runnable, task)
+	}
+	return runnable
+}
+
+// notifyLocked 返回用于通知排队位置变化、并唤醒已经可以执行的排队任务的函数。
+// 调用方必须持有s.mu，返回的函数需要在释放锁之后调用。
+func (s *Scheduler) notifyLocked() func() {
+	var updates []func()
+	for i, task := range s.waiting {
+		position := i + 1
+		if task.position == position || task.onPosition == nil {
+			continue
+		}
+		task.position = position
+		cb := task.onPosition
+		updates = append(updates, func() { cb(position) })
+	}
+
+	var ids []string
+	if s.onReady != nil {
+		for _, task := range s.runnableLocked() {
+			ids = append(ids, task.id)
+		}
+	}
+	onReady := s.onReady
+	return func() {
+		for _, update := range updates {
+			update()
+		}
+		if len(ids) > 0 {
+			onReady(ids)
+		}
+	}
+}
+
+// pruneLocked 移除长时间没有再次申请的排队任务，避免它们一直占着排队位置
+func (s *Scheduler) pruneLocked() {
+	cutoff := s.now().Add(-waitingTTL)
+	remaining := s.waiting[:0]
+	for _, task := range s.waiting {
+		if task.lastSeen.After(cutoff) {
+			remaining = append(remaining, task)
+		}
+	}
+	s.waiting = remaining
+}
+
+func (s *Scheduler) findWaitingLocked(id string) *waitingTask {
+	if id == "" {
+		return nil
+	}
+	for _, task := range s.waiting {
+		if task.id == id {
+			return task
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) positionLocked(target *waitingTask) int {
+	for i, task := range s.waiting {
+		if task == target {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (s *Scheduler) removeWaitingLocked(target *waitingTask) {
+	for i, task := range s.waiting {
+		if task == target {
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// taskKeyFromContext 从GitHub事件中提取调度键，CI事件按CI修复处理器选中的CodeAgent PR调度
+func taskKeyFromContext(event models.GitHubContext, ws *workspace.Manager) (TaskKey, bool) {
+	repo := event.GetRepository()
+	if repo == nil || repo.GetFullName() == "" {
+		return TaskKey{}, false
+	}
+	key := TaskKey{Repo: repo.GetFullName()}
+
+	switch e := event.(type) {
+	case *models.IssueCommentContext:
+		key.Number = e.Issue.GetNumber()
+	case *models.IssuesContext:
+		key.Number = e.Issue.GetNumber()
+	case *models.PullRequestContext:
+		key.Number = e.PullRequest.GetNumber()
+	case *models.PullRequestReviewContext:
+		key.Number = e.PullRequest.GetNumber()
+	case *models.PullRequestReviewCommentContext:
+		key.Number = e.PullRequest.GetNumber()
+	case *models.ScheduleContext:
+		key.Number = e.PullRequest
+	case models.CIContext:
+		key.Number = modes.CIPullRequest(ws, e).GetNumber()
+	}
+	return key, true
+}
+
+// jobIDKey 正在处理的队列任务ID在context中的key
+type jobIDKey struct{}
+
+func withJobID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, jobIDKey{}, id)
+}
+
+func jobIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(jobIDKey{}).(string)
+	return id
+}
+
+// queuedTask 排队中的任务在多次申请名额之间保留的状态
+type queuedTask struct {
+	mu      sync.Mutex
+	started bool
+	checks  *taskChecks
+	comment *interaction.ProgressCommentManager
+}
+
+// acquireExecutionSlot 为事件申请执行名额，返回的ctx携带排队时创建的进度评论。
+// 没有空闲名额时在进度评论中展示排队位置、将check run标记为排队中，并返回 queue.Defer 错误：
+// 任务回到队列而不是占用worker，名额释放后再被唤醒。
+func (a *EnhancedAgent) acquireExecutionSlot(ctx context.Context, event models.GitHubContext, mode modes.ExecutionMode, info usage.TaskInfo) (context.Context, *taskChecks, func(), error) {
+	xl := xlog.NewWith(ctx)
+
+	jobID := jobIDFromContext(ctx)
+	state := a.takeQueuedTask(jobID)
+	if state == nil {
+		state = &queuedTask{checks: a.newTaskChecks(ctx, event, mode, info)}
+	}
+
+	key, ok := taskKeyFromContext(event, a.workspace)
+	if a.scheduler == nil || !ok {
+		return ctx, state.checks, func() {}, nil
+	}
+
+	// 排队位置回调由释放名额的goroutine触发，需要与出队后的状态更新互斥
+	onPosition := func(position int) {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+
+		xl.Infof("Task %s is queued at position %d", key, position)
+		if state.started {
+			return
+		}
+		state.checks.Queued(ctx)
+		if key.Number == 0 || a.clientManager == nil {
+			return
+		}
+		if state.comment == nil {
+			repo := event.GetRepository()
+			client, err := a.clientManager.GetClient(ctx, &models.Repository{Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+			if err != nil {
+				xl.Warnf("Failed to get GitHub client for queue position: %v", err)
+				return
+			}
+			state.comment = interaction.NewProgressCommentManager(client, repo, key.Number)
+		}
+		if err := state.comment.UpdateQueuePosition(ctx, position); err != nil {
+			xl.Warnf("Failed to update queue position: %v", err)
+		}
+	}
+
+	release, position := a.scheduler.TryAcquire(jobID, key, onPosition)
+	if release == nil {
+		a.storeQueuedTask(jobID, state)
+		return ctx, nil, nil, queue.Defer(fmt.Errorf("task %s is queued at position %d", key, position), slotRetryInterval)
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.started = true
+	if state.comment != nil {
+		if err := state.comment.MarkDequeued(ctx); err != nil {
+			xl.Warnf("Failed to update progress comment: %v", err)
+		}
+		// 处理器在同一Issue/PR上创建的进度评论复用排队时的评论
+		if commentID := state.comment.GetContext().CommentID; commentID != nil {
+			ctx = interaction.WithQueuedComment(ctx, key.Number, *commentID)
+		}
+	}
+	return ctx, state.checks, release, nil
+}
+
+// takeQueuedTask 取出任务上一次排队时保留的状态
+func (a *EnhancedAgent) takeQueuedTask(jobID string) *queuedTask {
+	if jobID == "" {
+		return nil
+	}
+	a.queuedMu.Lock()
+	defer a.queuedMu.Unlock()
+
+	state := a.queued[jobID]
+	delete(a.queued, jobID)
+	return state
+}
+
+// storeQueuedTask 保留排队任务的状态，下一次申请名额时继续使用
+func (a *EnhancedAgent) storeQueuedTask(jobID string, state *queuedTask) {
+	if jobID == "" {
+		return
+	}
+	a.queuedMu.Lock()
+	defer a.queuedMu.Unlock()
+
+	if a.queued == nil {
+		a.queued = make(map[string]*queuedTask)
+	}
+	a.queued[jobID] = state
+}
+
+// leaveQueue 任务不再重新申请名额时放弃排队位置和保留的状态
+func (a *EnhancedAgent) leaveQueue(jobID string) {
+	a.takeQueuedTask(jobID)
+	if a.scheduler != nil {
+		a.scheduler.Leave(jobID)
+	}
+}
+
+// wakeQueuedJobs 唤醒已经可以拿到执行名额的排队任务
+func (a *EnhancedAgent) wakeQueuedJobs(ids []string) {
+	if a.queue == nil {
+		return
+	}
+	for _, id := range ids {
+		if err := a.queue.Wake(id); err != nil {
+			xlog.New("").Warnf("Failed to wake queued job %s: %v", id, err)
+		}
+	}
+}
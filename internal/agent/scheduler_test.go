@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/internal/queue"
+	"github.com/qiniu/codeagent/internal/workspace"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// positionRecorder 记录每个任务最近一次的排队位置
+type positionRecorder struct {
+	mu        sync.Mutex
+	positions map[string][]int
+}
+
+func newPositionRecorder() *positionRecorder {
+	return &positionRecorder{positions: make(map[string][]int)}
+}
+
+func (r *positionRecorder) record(id string) PositionFunc {
+	return func(position int) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.positions[id] = append(r.positions[id], position)
+	}
+}
+
+func (r *positionRecorder) get(id string) []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.positions[id]...)
+}
+
+// readyRecorder 记录调度器唤醒的任务
+type readyRecorder struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (r *readyRecorder) onReady(ids []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, ids...)
+}
+
+func (r *readyRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ids...)
+}
+
+func mustAcquire(t *testing.T, s *Scheduler, id string, key TaskKey) func() {
+	t.Helper()
+	release, position := s.TryAcquire(id, key, nil)
+	require.NotNil(t, release, "task %s should get a slot, queued at position %d", id, position)
+	return release
+}
+
+func TestScheduler_SerializesSameKey(t *testing.T) {
+	s := NewScheduler(10, 10)
+	ready := &readyRecorder{}
+	s.OnReady(ready.onReady)
+	positions := newPositionRecorder()
+	key := TaskKey{Repo: "org/repo", Number: 1}
+
+	release1 := mustAcquire(t, s, "job-1", key)
+
+	release, position := s.TryAcquire("job-2", key, positions.record("job-2"))
+	assert.Nil(t, release)
+	assert.Equal(t, 1, position)
+	assert.Equal(t, []int{1}, positions.get("job-2"))
+
+	running, waiting := s.Stats()
+	assert.Equal(t, 1, running)
+	assert.Equal(t, 1, waiting)
+	assert.Equal(t, 1, s.Waiting(key))
+
+	// Retrying while the key is still busy keeps the same place
+	release, position = s.TryAcquire("job-2", key, nil)
+	assert.Nil(t, release)
+	assert.Equal(t, 1, position)
+	assert.Equal(t, 1, s.Waiting(key))
+
+	release1()
+	assert.Equal(t, []string{"job-2"}, ready.get())
+
+	release2 := mustAcquire(t, s, "job-2", key)
+	release2()
+	assert.False(t, s.IsRunning(key))
+	_, waiting = s.Stats()
+	assert.Equal(t, 0, waiting)
+}
+
+func TestScheduler_DifferentKeysRunConcurrently(t *testing.T) {
+	s := NewScheduler(10, 10)
+
+	release1 := mustAcquire(t, s, "job-1", TaskKey{Repo: "org/repo", Number: 1})
+	release2 := mustAcquire(t, s, "job-2", TaskKey{Repo: "org/repo", Number: 2})
+
+	running, _ := s.Stats()
+	assert.Equal(t, 2, running)
+	release1()
+	release2()
+}
+
+func TestScheduler_PerRepoCap(t *testing.T) {
+	s := NewScheduler(10, 1)
+
+	release1 := mustAcquire(t, s, "job-1", TaskKey{Repo: "org/repo", Number: 1})
+
+	release, _ := s.TryAcquire("job-2", TaskKey{Repo: "org/repo", Number: 2}, nil)
+	assert.Nil(t, release)
+
+	// Other repositories are not affected by the per-repo cap
+	mustAcquire(t, s, "job-3", TaskKey{Repo: "org/other", Number: 1})()
+
+	release1()
+	mustAcquire(t, s, "job-2", TaskKey{Repo: "org/repo", Number: 2})()
+}
+
+func TestScheduler_GlobalCapAndQueuePositions(t *testing.T) {
+	s := NewScheduler(1, 10)
+	ready := &readyRecorder{}
+	s.OnReady(ready.onReady)
+	positions := newPositionRecorder()
+
+	release1 := mustAcquire(t, s, "job-1", TaskKey{Repo: "a/a", Number: 1})
+
+	release, position := s.TryAcquire("job-2", TaskKey{Repo: "b/b", Number: 1}, positions.record("job-2"))
+	assert.Nil(t, release)
+	assert.Equal(t, 1, position)
+	release, position = s.TryAcquire("job-3", TaskKey{Repo: "c/c", Number: 1}, positions.record("job-3"))
+	assert.Nil(t, release)
+	assert.Equal(t, 2, position)
+
+	release1()
+	assert.Equal(t, []string{"job-2"}, ready.get())
+
+	// The freed slot is reserved for the task at the head of the queue
+	release, position = s.TryAcquire("job-3", TaskKey{Repo: "c/c", Number: 1}, nil)
+	assert.Nil(t, release)
+	assert.Equal(t, 2, position)
+
+	release2 := mustAcquire(t, s, "job-2", TaskKey{Repo: "b/b", Number: 1})
+	assert.Equal(t, []int{2, 1}, positions.get("job-3"), "queue position must move up")
+
+	release2()
+	assert.Contains(t, ready.get(), "job-3")
+	mustAcquire(t, s, "job-3", TaskKey{Repo: "c/c", Number: 1})()
+}
+
+func TestScheduler_Leave(t *testing.T) {
+	s := NewScheduler(10, 10)
+	positions := newPositionRecorder()
+	key := TaskKey{Repo: "org/repo", Number: 1}
+
+	release1 := mustAcquire(t, s, "job-1", key)
+	release, _ := s.TryAcquire("job-2", key, nil)
+	assert.Nil(t, release)
+	release, _ = s.TryAcquire("job-3", key, positions.record("job-3"))
+	assert.Nil(t, release)
+
+	s.Leave("job-2")
+	assert.Equal(t, []int{2, 1}, positions.get("job-3"))
+	assert.Equal(t, 1, s.Waiting(key))
+
+	release1()
+	mustAcquire(t, s, "job-3", key)()
+}
+
+func TestScheduler_WithoutIDKeepsNoPlace(t *testing.T) {
+	s := NewScheduler(10, 10)
+	key := TaskKey{Repo: "org/repo", Number: 1}
+
+	release1 := mustAcquire(t, s, "", key)
+	release, position := s.TryAcquire("", key, nil)
+	assert.Nil(t, release)
+	assert.Equal(t, 1, position)
+
+	_, waiting := s.Stats()
+	assert.Equal(t, 0, waiting)
+	release1()
+}
+
+func TestScheduler_PrunesAbandonedTasks(t *testing.T) {
+	s := NewScheduler(10, 10)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	key := TaskKey{Repo: "org/repo", Number: 1}
+
+	release1 := mustAcquire(t, s, "job-1", key)
+	release, _ := s.TryAcquire("job-2", key, nil)
+	assert.Nil(t, release)
+	release1()
+
+	// job-2 never came back for its slot
+	now = now.Add(waitingTTL + time.Minute)
+	mustAcquire(t, s, "job-3", key)()
+	assert.Equal(t, 0, s.Waiting(key))
+}
+
+func TestProcessJob_DefersWhenNoSlot(t *testing.T) {
+	agent, handler := newTestAgent(t)
+	store, err := queue.NewFileStore(t.TempDir(), queue.Options{})
+	require.NoError(t, err)
+	agent.queue = store
+	agent.scheduler = NewScheduler(1, 1)
+	agent.scheduler.OnReady(agent.wakeQueuedJobs)
+	agent.tasks = NewTaskRegistry()
+
+	// Another task is running on the same issue
+	busy := mustAcquire(t, agent.scheduler, "other", TaskKey{Repo: "org/repo", Number: 7})
+
+	_, err = store.Enqueue("issue_comment", "delivery-1", []byte(issueCommentPayload), false)
+	require.NoError(t, err)
+	job, err := store.Claim()
+	require.NoError(t, err)
+
+	agent.processJob(0, job)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&handler.calls))
+	got, err := store.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, queue.JobStateQueued, got.State)
+	assert.Equal(t, 0, got.Attempts, "waiting for a slot must not use up an attempt")
+	assert.Equal(t, 1, got.Deferrals)
+	assert.Equal(t, 1, agent.scheduler.Waiting(TaskKey{Repo: "org/repo", Number: 7}))
+
+	// Releasing the slot wakes the deferred job right away
+	busy()
+	job, err = store.Claim()
+	require.NoError(t, err)
+	agent.processJob(0, job)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&handler.calls), "deferred job must not be skipped as a duplicate delivery")
+
+	got, err = store.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, queue.JobStateSucceeded, got.State)
+	_, waiting := agent.scheduler.Stats()
+	assert.Equal(t, 0, waiting)
+}
+
+func TestTaskKeyFromContext_CIEventUsesAgentPR(t *testing.T) {
+	humanPR := &github.PullRequest{Number: github.Int(9), Head: &github.PullRequestBranch{Ref: github.String("feature/login")}}
+	agentPR := &github.PullRequest{Number: github.Int(8), Head: &github.PullRequestBranch{Ref: github.String("bots/issue-7-1700000000")}}
+	event := &models.CheckRunContext{
+		BaseContext: models.BaseContext{
+			Type:       models.EventCheckRun,
+			Repository: &github.Repository{FullName: github.String("owner/repo"), Name: github.String("repo"), Owner: &github.User{Login: github.String("owner")}},
+		},
+		CheckRun: &github.CheckRun{PullRequests: []*github.PullRequest{humanPR, agentPR}},
+	}
+	ws := workspace.NewManager(&config.Config{})
+	ws.SetBranchPrefixFunc(func(org, repo string) string { return "bots" })
+
+	// 与CI修复处理器修复的PR一致，而不是事件中的第一个PR
+	key, ok := taskKeyFromContext(event, ws)
+	require.True(t, ok)
+	assert.Equal(t, TaskKey{Repo: "owner/repo", Number: 8}, key)
+	assert.Equal(t, modes.CIPullRequest(ws, event), agentPR)
+}
@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/modes"
+)
+
+// RunningTask 正在执行的handler任务
+type RunningTask struct {
+	ID        uint64
+	Key       TaskKey
+	Handler   string
+	StartedAt time.Time
+	Aliases   []int // 任务执行过程中关联的其他Issue/PR编号
+
+	cancel context.CancelFunc
+}
+
+// TaskRegistry 记录正在执行的任务，支持按Issue/PR取消
+type TaskRegistry struct {
+	mu     sync.Mutex
+	nextID uint64
+	tasks  map[uint64]*RunningTask
+}
+
+// NewTaskRegistry 创建任务注册表
+func NewTaskRegistry() *TaskRegistry {
+	return &TaskRegistry{tasks: make(map[uint64]*RunningTask)}
+}
+
+// Start 登记一个任务并返回可被取消的ctx，任务结束后必须调用返回的done
+func (r *TaskRegistry) Start(ctx context.Context, key TaskKey, handler string) (context.Context, *RunningTask, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	r.mu.Lock()
+	r.nextID++
+	task := &RunningTask{
+		ID:        r.nextID,
+		Key:       key,
+		Handler:   handler,
+		StartedAt: time.Now(),
+		cancel:    cancel,
+	}
+	r.tasks[task.ID] = task
+	r.mu.Unlock()
+
+	done := func() {
+		r.mu.Lock()
+		delete(r.tasks, task.ID)
+		r.mu.Unlock()
+		cancel()
+	}
+	return ctx, task, done
+}
+
+// AddAlias 将任务关联到同一仓库下的另一个Issue/PR编号
+func (r *TaskRegistry) AddAlias(id uint64, number int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[id]
+	if !ok || number == task.Key.Number {
+		return
+	}
+	for _, alias := range task.Aliases {
+		if alias == number {
+			return
+		}
+	}
+	task.Aliases = append(task.Aliases, number)
+}
+
+// Cancel 取消与key匹配（包括别名）的所有任务，返回取消的任务数量
+func (r *TaskRegistry) Cancel(key TaskKey) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cancelled := 0
+	for _, task := range r.tasks {
+		if task.matches(key) {
+			task.cancel()
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+// List 返回正在执行的任务快照，按开始时间排序
+func (r *TaskRegistry) List() []RunningTask {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tasks := make([]RunningTask, 0, len(r.tasks))
+	for _, task := range r.tasks {
+		snapshot := *task
+		snapshot.Aliases = append([]int(nil), task.Aliases...)
+		snapshot.cancel = nil
+		tasks = append(tasks, snapshot)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+func (t *RunningTask) matches(key TaskKey) bool {
+	if t.Key.Repo != key.Repo {
+		return false
+	}
+	if t.Key.Number == key.Number {
+		return true
+	}
+	for _, alias := range t.Aliases {
+		if alias == key.Number {
+			return true
+		}
+	}
+	return false
+}
+
+// CancelTask 实现 modes.TaskController，取消指定Issue/PR上正在执行的任务
+func (a *EnhancedAgent) CancelTask(repo string, number int) bool {
+	if a.tasks == nil {
+		return false
+	}
+	return a.tasks.Cancel(TaskKey{Repo: repo, Number: number}) > 0
+}
+
+// TaskStatus 实现 modes.TaskController，返回指定Issue/PR上正在执行的任务和排队等待的任务数量
+func (a *EnhancedAgent) TaskStatus(repo string, number int) ([]modes.TaskInfo, int) {
+	key := TaskKey{Repo: repo, Number: number}
+	var running []modes.TaskInfo
+	if a.tasks != nil {
+		for _, task := range a.tasks.List() {
+			if task.matches(key) {
+				running = append(running, modes.TaskInfo{Handler: task.Handler, StartedAt: task.StartedAt})
+			}
+		}
+	}
+	var queued int
+	if a.scheduler != nil {
+		queued = a.scheduler.Waiting(key)
+	}
+	return running, queued
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/events"
+	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskRegistry_CancelByKeyAndAlias(t *testing.T) {
+	registry := NewTaskRegistry()
+	key := TaskKey{Repo: "org/repo", Number: 7}
+
+	ctx, task, done := registry.Start(context.Background(), key, "tag_handler")
+	defer done()
+
+	assert.Equal(t, 0, registry.Cancel(TaskKey{Repo: "org/repo", Number: 8}))
+	assert.Equal(t, 0, registry.Cancel(TaskKey{Repo: "org/other", Number: 7}))
+	assert.NoError(t, ctx.Err())
+
+	// /code 创建PR后，任务也可以在PR中被取消
+	registry.AddAlias(task.ID, 8)
+	assert.Equal(t, 1, registry.Cancel(TaskKey{Repo: "org/repo", Number: 8}))
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestTaskRegistry_DoneRemovesTask(t *testing.T) {
+	registry := NewTaskRegistry()
+	key := TaskKey{Repo: "org/repo", Number: 7}
+
+	_, _, done := registry.Start(context.Background(), key, "tag_handler")
+	require.Len(t, registry.List(), 1)
+
+	done()
+	assert.Empty(t, registry.List())
+	assert.Equal(t, 0, registry.Cancel(key))
+}
+
+// blockingHandler 阻塞直到ctx被取消
+type blockingHandler struct {
+	*modes.BaseHandler
+	started chan struct{}
+}
+
+func (h *blockingHandler) CanHandle(ctx context.Context, event models.GitHubContext) bool {
+	return true
+}
+
+func (h *blockingHandler) Execute(ctx context.Context, event models.GitHubContext) error {
+	close(h.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestProcessGitHubContext_CancelRunningTask(t *testing.T) {
+	handler := &blockingHandler{
+		BaseHandler: modes.NewBaseHandler(modes.TagMode, 10, "blocking handler"),
+		started:     make(chan struct{}),
+	}
+	modeManager := modes.NewManager()
+	modeManager.RegisterHandler(handler)
+
+	agent := &EnhancedAgent{
+		eventParser: events.NewParser(),
+		modeManager: modeManager,
+		scheduler:   NewScheduler(1, 1),
+		tasks:       NewTaskRegistry(),
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- agent.ProcessGitHubWebhookEvent(context.Background(), "issue_comment", "", []byte(issueCommentPayload))
+	}()
+
+	select {
+	case <-handler.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not started")
+	}
+	assert.False(t, agent.CancelTask("org/repo", 8))
+	assert.True(t, agent.CancelTask("org/repo", 7))
+
+	select {
+	case err := <-errCh:
+		// 被取消的任务不会返回错误，避免队列重试
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled task did not stop")
+	}
+	assert.Empty(t, agent.tasks.List())
+	assert.False(t, agent.scheduler.IsRunning(TaskKey{Repo: "org/repo", Number: 7}))
+}
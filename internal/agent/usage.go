@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/pkg/models"
+)
+
+// usageTaskInfo 从事件中提取用量统计的归属信息：仓库、Issue/PR、触发用户和命令
+func (a *EnhancedAgent) usageTaskInfo(ctx context.Context, event models.GitHubContext) usage.TaskInfo {
+	info := usage.TaskInfo{
+		Repo: event.GetRepository().GetFullName(),
+		User: event.GetSender().GetLogin(),
+	}
+	if key, ok := taskKeyFromContext(event, a.workspace); ok {
+		info.Number = key.Number
+	}
+	switch e := event.(type) {
+	case *models.IssueCommentContext:
+		if e.IsPRComment {
+			info.PR = info.Number
+		}
+	case *models.PullRequestContext, *models.PullRequestReviewContext, *models.PullRequestReviewCommentContext, models.CIContext:
+		info.PR = info.Number
+	case *models.ScheduleContext:
+		info.PR = e.PullRequest
+	}
+
+	var mentionConfig models.MentionConfig
+	if a.config != nil {
+		mentionConfig = &models.ConfigMentionAdapter{
+			Triggers:       a.config.Mention.Triggers,
+			DefaultTrigger: a.config.Mention.DefaultTrigger,
+		}
+	}
+	mentionConfig = repoconfig.FromContext(ctx).MentionConfig(mentionConfig)
+	if cmdInfo, ok := models.HasCommandWithConfig(event, mentionConfig); ok && cmdInfo.Command != "" {
+		info.Command = cmdInfo.Command
+		if models.IsHelpCommand(cmdInfo) {
+			// "@trigger help" 与 /help 按同一个命令统计和检查权限
+			info.Command = models.CommandHelp
+		}
+		info.Trigger = usage.TriggerCommand
+	} else {
+		info.Trigger = usage.TriggerAuto
+		info.Command = string(event.GetEventType())
+		if action := event.GetEventAction(); action != "" {
+			info.Command += "." + action
+		}
+	}
+	return info
+}
+
+// GetUsageStore 获取用量存储（用于导出接口）
+func (a *EnhancedAgent) GetUsageStore() *usage.Store {
+	return a.usage
+}
@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/events"
+	"github.com/qiniu/codeagent/internal/queue"
+
+	"github.com/qiniu/x/reqid"
+	"github.com/qiniu/x/xlog"
+)
+
+const (
+	// defaultQueueWorkers 未配置时的worker数量
+	// 排队等待执行名额的任务会占用worker，因此需要大于调度器的全局并发上限
+	defaultQueueWorkers = 16
+	// workerIdleInterval 无任务时的轮询间隔，用于拾取到期的重试任务
+	workerIdleInterval = time.Second
+	// queuePruneInterval 清理已完成任务的周期
+	queuePruneInterval = time.Hour
+)
+
+// EnqueueWebhookEvent 将webhook事件持久化到任务队列，由worker池异步处理
+// force 为 true 时跳过 delivery ID 去重检查
+func (a *EnhancedAgent) EnqueueWebhookEvent(ctx context.Context, eventType string, deliveryID string, payload []byte, force bool) (*queue.Job, error) {
+	xl := xlog.NewWith(ctx)
+
+	job, err := a.queue.Enqueue(eventType, deliveryID, payload, force)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue webhook event: %w", err)
+	}
+
+	xl.Debugf("Enqueued webhook event %s as job %s (delivery_id: %s)", eventType, job.ID, deliveryID)
+	return job, nil
+}
+
+// GetQueueStats 获取任务队列中各状态的任务数量
+func (a *EnhancedAgent) GetQueueStats() map[queue.JobState]int {
+	return a.queue.Stats()
+}
+
+// startWorkers 启动worker池消费任务队列
+func (a *EnhancedAgent) startWorkers(n int) {
+	for i := 0; i < n; i++ {
+		a.workersWG.Add(1)
+		go a.runWorker(i)
+	}
+
+	a.workersWG.Add(1)
+	go a.runQueuePruner()
+}
+
+// stopWorkers 通知worker退出并等待正在执行的任务完成
+func (a *EnhancedAgent) stopWorkers(ctx context.Context) error {
+	a.stopOnce.Do(func() { close(a.stopCh) })
+
+	done := make(chan struct{})
+	go func() {
+		a.workersWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// 未完成的任务保持running状态，下次启动时会重新入队
+		return fmt.Errorf("timed out waiting for workers: %w", ctx.Err())
+	}
+}
+
+func (a *EnhancedAgent) runWorker(id int) {
+	defer a.workersWG.Done()
+
+	ticker := time.NewTicker(workerIdleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopCh:
+			return
+		default:
+		}
+
+		job, err := a.queue.Claim()
+		if err == nil {
+			a.processJob(id, job)
+			continue
+		}
+		if !errors.Is(err, queue.ErrNoJobReady) {
+			xlog.New("").Errorf("Worker %d failed to claim job: %v", id, err)
+		}
+
+		select {
+		case <-a.stopCh:
+			return
+		case <-a.queue.Ready():
+		case <-ticker.C:
+		}
+	}
+}
+
+// processJob 执行单个任务并根据结果更新队列状态
+func (a *EnhancedAgent) processJob(workerID int, job *queue.Job) {
+	ctx := reqid.NewContext(context.Background(), traceIDFromDelivery(job.DeliveryID))
+	xl := xlog.NewWith(ctx)
+
+	xl.Infof("Worker %d processing job %s (%s, attempt %d/%d)",
+		workerID, job.ID, job.EventType, job.Attempts, job.MaxAttempts)
+
+	err := a.processJobSafely(queue.NewContext(withJobID(ctx, job.ID), job), job)
+	if delay, ok := queue.DeferDelay(err); ok {
+		// 没有执行名额的任务回到队列，释放worker处理其他任务
+		if _, qerr := a.queue.Fail(job.ID, err); qerr != nil {
+			xl.Errorf("Failed to defer job %s: %v", job.ID, qerr)
+			a.leaveQueue(job.ID)
+			return
+		}
+		xl.Infof("Job %s deferred for up to %s: %v", job.ID, delay, err)
+		return
+	}
+	a.leaveQueue(job.ID)
+	if err == nil {
+		if err := a.queue.Complete(job.ID); err != nil {
+			xl.Errorf("Failed to mark job %s as succeeded: %v", job.ID, err)
+		}
+		xl.Infof("enhanced agent event processing completed successfully")
+		return
+	}
+
+	if errors.Is(err, events.ErrUnsupportedEventType) {
+		xl.Debugf("enhanced agent unsupported event type: %v", err)
+	} else {
+		xl.Warnf("enhanced agent event processing error: %v", err)
+	}
+
+	updated, qerr := a.queue.Fail(job.ID, err)
+	if qerr != nil {
+		xl.Errorf("Failed to record failure for job %s: %v", job.ID, qerr)
+		return
+	}
+	if updated.State == queue.JobStateQueued {
+		xl.Infof("Job %s will be retried at %s", job.ID, updated.NextRunAt.Format(time.RFC3339))
+		return
+	}
+	xl.Warnf("Job %s failed permanently after %d attempt(s)", job.ID, updated.Attempts)
+	// 不再重试的投递需要删除去重记录，GitHub重新投递时才能再次处理
+	if err := a.ForgetDelivery(job.DeliveryID); err != nil {
+		xl.Warnf("Failed to forget delivery %s: %v", job.DeliveryID, err)
+	}
+}
+
+// processJobSafely 处理任务并将panic转换为错误，避免worker退出
+func (a *EnhancedAgent) processJobSafely(ctx context.Context, job *queue.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while processing job %s: %v", job.ID, r)
+		}
+	}()
+	// 重试或排队后重新执行的任务已经通过了去重检查，不能再被当作重复投递跳过
+	force := job.Force || job.Attempts > 1 || job.Deferrals > 0
+	return a.processWebhookEvent(ctx, job.EventType, job.DeliveryID, job.Payload, force)
+}
+
+// runQueuePruner 周期性清理过期的已完成任务
+func (a *EnhancedAgent) runQueuePruner() {
+	defer a.workersWG.Done()
+
+	ticker := time.NewTicker(queuePruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopCh:
+			return
+		case <-ticker.C:
+			if removed := a.queue.Prune(); removed > 0 {
+				xlog.New("").Infof("Pruned %d finished jobs from queue", removed)
+			}
+		}
+	}
+}
+
+// traceIDFromDelivery 使用delivery ID前8位作为追踪ID
+func traceIDFromDelivery(deliveryID string) string {
+	if deliveryID == "" {
+		return "unknown"
+	}
+	if len(deliveryID) > 8 {
+		return deliveryID[:8]
+	}
+	return deliveryID
+}
@@ -0,0 +1,108 @@
+package automation
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Claim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "automation.json")
+	store, err := NewStore(path)
+	require.NoError(t, err)
+
+	claimed, err := store.Claim("org/repo", 7, "delivery-1", "labeled")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// 队列重试同一投递时仍然可以处理
+	claimed, err = store.Claim("org/repo", 7, "delivery-1", "labeled")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// 分配事件不会再次处理同一个Issue
+	claimed, err = store.Claim("org/repo", 7, "delivery-2", "assigned")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	claimed, err = store.Claim("org/repo", 8, "delivery-2", "assigned")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	reopened, err := NewStore(path)
+	require.NoError(t, err)
+	claim, ok := reopened.Get("org/repo", 7)
+	require.True(t, ok)
+	assert.Equal(t, "labeled", claim.Trigger)
+	claimed, err = reopened.Claim("org/repo", 7, "delivery-3", "opened")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+}
+
+func TestStore_Release(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "automation.json")
+	store, err := NewStore(path)
+	require.NoError(t, err)
+
+	claimed, err := store.Claim("org/repo", 7, "delivery-1", "labeled")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	require.NoError(t, store.Release("org/repo", 7))
+	require.NoError(t, store.Release("org/repo", 8))
+
+	// 释放后重新添加标签可以再次处理
+	reopened, err := NewStore(path)
+	require.NoError(t, err)
+	_, ok := reopened.Get("org/repo", 7)
+	assert.False(t, ok)
+	claimed, err = reopened.Claim("org/repo", 7, "delivery-2", "labeled")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestTemplateHeadings_Markdown(t *testing.T) {
+	headings, err := TemplateHeadings(".github/ISSUE_TEMPLATE/feature_request.md", []byte(`---
+name: Feature request
+about: Suggest an idea
+title: "[Feature] "
+---
+
+## Problem
+
+Describe the problem.
+
+##  Proposed   Solution
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"problem", "proposed solution"}, headings)
+
+	assert.True(t, MatchTemplate("## Problem\nLogin is slow\n\n## Proposed Solution\nCache it", headings))
+	assert.False(t, MatchTemplate("## Problem\nLogin is slow", headings))
+	assert.False(t, MatchTemplate("anything", nil))
+}
+
+func TestTemplateHeadings_IssueForm(t *testing.T) {
+	headings, err := TemplateHeadings(".github/ISSUE_TEMPLATE/bug.yml", []byte(`
+name: Bug report
+body:
+  - type: markdown
+    attributes:
+      value: Thanks for reporting!
+  - type: textarea
+    id: what-happened
+    attributes:
+      label: What happened?
+  - type: input
+    attributes:
+      label: Version
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"what happened?", "version"}, headings)
+	assert.True(t, MatchTemplate("### What happened?\n\nIt crashed\n\n### Version\n\n1.2.0", headings))
+
+	_, err = TemplateHeadings("bug.yml", []byte("body: ["))
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package automation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/persist"
+)
+
+// Claim 一个Issue的自动处理记录
+type Claim struct {
+	// 认领该Issue的webhook投递，队列重试同一投递时仍然允许处理
+	DeliveryID string `json:"delivery_id"`
+	// 触发处理的事件动作：labeled、assigned、opened
+	Trigger   string    `json:"trigger"`
+	ClaimedAt time.Time `json:"claimed_at"`
+}
+
+// Store 记录已经自动处理过的Issue，保证标签和分配同时触发时Issue只被处理一次；状态保存在单个JSON文件中
+type Store struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	claims *persist.Map[Claim] // owner/repo#number
+}
+
+// NewStore 打开（或创建）path处的自动处理记录
+func NewStore(path string) (*Store, error) {
+	claims, err := persist.Open[Claim](path, "automation state")
+	if err != nil {
+		return nil, err
+	}
+	return &Store{now: time.Now, claims: claims}, nil
+}
+
+// Claim 为投递认领Issue，Issue已被其他投递认领时返回false
+func (s *Store) Claim(repo string, number int, deliveryID, trigger string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := persist.IssueKey(repo, number)
+	if claim, ok := s.claims.Get(key); ok {
+		return claim.DeliveryID == deliveryID, nil
+	}
+	return true, s.claims.Set(key, Claim{DeliveryID: deliveryID, Trigger: trigger, ClaimedAt: s.now()})
+}
+
+// Release 删除Issue的认领记录，之后匹配的事件可以再次自动处理该Issue
+func (s *Store) Release(repo string, number int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.claims.Delete(persist.IssueKey(repo, number))
+}
+
+// Get 返回Issue的自动处理记录
+func (s *Store) Get(repo string, number int) (Claim, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.claims.Get(persist.IssueKey(repo, number))
+}
@@ -0,0 +1,101 @@
+package automation
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// templateDir Issue模板所在目录
+const templateDir = ".github/ISSUE_TEMPLATE"
+
+// TemplatePaths 返回模板名可能对应的文件路径，Markdown模板和Issue表单都支持
+func TemplatePaths(name string) []string {
+	return []string{
+		path.Join(templateDir, name+".md"),
+		path.Join(templateDir, name+".yml"),
+		path.Join(templateDir, name+".yaml"),
+	}
+}
+
+// issueForm Issue表单（YAML模板）中与正文有关的部分
+type issueForm struct {
+	Body []struct {
+		Type       string `yaml:"type"`
+		Attributes struct {
+			Label string `yaml:"label"`
+		} `yaml:"attributes"`
+	} `yaml:"body"`
+}
+
+// TemplateHeadings 提取模板在Issue正文中生成的标题：Markdown模板为其中的标题行，
+// Issue表单的每个输入项在正文中渲染为 "### <label>"
+func TemplateHeadings(file string, content []byte) ([]string, error) {
+	if strings.HasSuffix(file, ".md") {
+		return markdownHeadings(string(stripFrontMatter(content))), nil
+	}
+
+	var form issueForm
+	if err := yaml.Unmarshal(content, &form); err != nil {
+		return nil, fmt.Errorf("failed to parse issue form %s: %w", file, err)
+	}
+	var headings []string
+	for _, item := range form.Body {
+		if item.Type == "markdown" || strings.TrimSpace(item.Attributes.Label) == "" {
+			continue
+		}
+		headings = append(headings, normalizeHeading(item.Attributes.Label))
+	}
+	return headings, nil
+}
+
+// MatchTemplate Issue正文是否包含模板的全部标题；没有标题的模板无法识别，总是返回false
+func MatchTemplate(body string, headings []string) bool {
+	if len(headings) == 0 {
+		return false
+	}
+	present := make(map[string]bool)
+	for _, heading := range markdownHeadings(body) {
+		present[heading] = true
+	}
+	for _, heading := range headings {
+		if !present[heading] {
+			return false
+		}
+	}
+	return true
+}
+
+// stripFrontMatter 去掉Markdown模板开头的YAML front matter
+func stripFrontMatter(content []byte) []byte {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(content, []byte("---\n")) {
+		return content
+	}
+	end := bytes.Index(content[4:], []byte("\n---"))
+	if end < 0 {
+		return content
+	}
+	return content[4+end+4:]
+}
+
+func markdownHeadings(text string) []string {
+	var headings []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		if heading := normalizeHeading(strings.TrimLeft(line, "#")); heading != "" {
+			headings = append(headings, heading)
+		}
+	}
+	return headings
+}
+
+func normalizeHeading(heading string) string {
+	return strings.ToLower(strings.Join(strings.Fields(heading), " "))
+}
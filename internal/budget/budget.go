@@ -0,0 +1,237 @@
+package budget
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/pkg/models"
+)
+
+// Scope 预算的作用范围
+type Scope string
+
+const (
+	ScopeOrg        Scope = "org"
+	ScopeRepo       Scope = "repo"
+	ScopeUser       Scope = "user"
+	ScopeAutoReview Scope = "auto-review"
+)
+
+// Period 预算周期
+type Period string
+
+const (
+	PeriodDaily   Period = "daily"
+	PeriodMonthly Period = "monthly"
+)
+
+// Request 一次待执行的AI任务
+type Request struct {
+	Repo   string // owner/repo
+	Number int    // Issue/PR编号
+	User   string // 触发任务的用户
+	// 是否为事件自动触发的任务，自动任务不受用户预算约束
+	Auto bool
+	// 是否为代码审查任务，自动触发的审查还受自动审查预算和限流约束
+	Review bool
+}
+
+// ExceededError 预算已用完
+type ExceededError struct {
+	Scope  Scope
+	Name   string // 组织、仓库或用户名
+	Period Period
+	Unit   string // tokens 或 USD
+	Used   float64
+	Limit  float64
+	// 预算重置的时间
+	ResetAt time.Time
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s %s budget for %s %s exceeded: %s of %s used",
+		e.Period, e.Unit, e.Scope, e.Name, e.format(e.Used), e.format(e.Limit))
+}
+
+// Describe 返回面向用户的预算说明
+func (e *ExceededError) Describe() string {
+	return fmt.Sprintf("The %s budget of **%s** for %s `%s` is used up (%s used).",
+		e.Period, e.format(e.Limit), e.Scope, e.Name, e.format(e.Used))
+}
+
+func (e *ExceededError) format(value float64) string {
+	if e.Unit == "USD" {
+		return fmt.Sprintf("$%.2f", value)
+	}
+	return fmt.Sprintf("%.0f tokens", value)
+}
+
+// ThrottledError 自动审查触发过于频繁
+type ThrottledError struct {
+	Repo       string
+	Number     int
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("auto-review for %s#%d throttled, next review allowed in %s", e.Repo, e.Number, e.RetryAfter.Round(time.Second))
+}
+
+// Checker 在启动AI会话前检查用量预算
+type Checker struct {
+	store *usage.Store
+	cfg   config.BudgetConfig
+	now   func() time.Time
+
+	mu             sync.Mutex
+	lastAutoReview map[string]time.Time
+}
+
+// NewChecker 创建预算检查器，用量来自 store 中记录的provider输出
+func NewChecker(store *usage.Store, cfg config.BudgetConfig) *Checker {
+	return &Checker{
+		store:          store,
+		cfg:            cfg,
+		now:            time.Now,
+		lastAutoReview: make(map[string]time.Time),
+	}
+}
+
+// Check 检查任务是否可以执行，超出预算时返回 *ExceededError，自动审查过于频繁时返回 *ThrottledError。
+// 组织和仓库预算统计并约束所有任务，用户预算只统计并约束人工命令
+func (c *Checker) Check(req Request) error {
+	if c == nil {
+		return nil
+	}
+
+	type budgetCheck struct {
+		scope  Scope
+		name   string
+		limits map[string]config.BudgetLimit
+		filter usage.Filter
+	}
+	org, _, _ := strings.Cut(req.Repo, "/")
+	checks := []budgetCheck{
+		{ScopeOrg, org, c.cfg.Orgs, usage.Filter{Org: org}},
+		{ScopeRepo, req.Repo, c.cfg.Repos, usage.Filter{Repo: req.Repo}},
+	}
+	if !req.Auto {
+		checks = append(checks, budgetCheck{ScopeUser, req.User, c.cfg.Users, usage.Filter{User: req.User, Trigger: usage.TriggerCommand}})
+	}
+	for _, check := range checks {
+		if check.name == "" {
+			continue
+		}
+		limit, ok := lookup(check.limits, check.name)
+		if !ok {
+			continue
+		}
+		if err := c.checkLimit(check.scope, check.name, limit, check.filter); err != nil {
+			return err
+		}
+	}
+	if req.Auto && req.Review {
+		return c.checkAutoReview(req)
+	}
+	return nil
+}
+
+// checkAutoReview 自动审查使用独立的预算，并限制同一PR的审查频率
+func (c *Checker) checkAutoReview(req Request) error {
+	if limit, ok := lookup(c.cfg.AutoReview.Repos, req.Repo); ok {
+		filter := usage.Filter{Repo: req.Repo, Trigger: usage.TriggerAuto}
+		if err := c.checkLimit(ScopeAutoReview, req.Repo, limit, filter); err != nil {
+			return err
+		}
+	}
+
+	interval := c.cfg.AutoReview.MinInterval
+	if interval <= 0 || req.Number == 0 {
+		return nil
+	}
+	key := fmt.Sprintf("%s#%d", req.Repo, req.Number)
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.lastAutoReview[key]; ok && now.Sub(last) < interval {
+		return &ThrottledError{Repo: req.Repo, Number: req.Number, RetryAfter: interval - now.Sub(last)}
+	}
+	return nil
+}
+
+// RecordAutoReview 记录自动审查开始执行的时间，min_interval 从此时开始计算。
+// 任务拿到执行名额后才调用，排队或重试的任务再次检查时不会被自己限流
+func (c *Checker) RecordAutoReview(req Request) {
+	if c == nil || !req.Auto || !req.Review {
+		return
+	}
+	interval := c.cfg.AutoReview.MinInterval
+	if interval <= 0 || req.Number == 0 {
+		return
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 顺便清理已过间隔的记录
+	for k, last := range c.lastAutoReview {
+		if now.Sub(last) >= interval {
+			delete(c.lastAutoReview, k)
+		}
+	}
+	c.lastAutoReview[fmt.Sprintf("%s#%d", req.Repo, req.Number)] = now
+}
+
+func (c *Checker) checkLimit(scope Scope, name string, limit config.BudgetLimit, filter usage.Filter) error {
+	now := c.now().UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	periods := []struct {
+		period Period
+		since  time.Time
+		reset  time.Time
+		tokens int64
+		cost   float64
+	}{
+		{PeriodDaily, dayStart, dayStart.AddDate(0, 0, 1), limit.DailyTokens, limit.DailyCost},
+		{PeriodMonthly, monthStart, monthStart.AddDate(0, 1, 0), limit.MonthlyTokens, limit.MonthlyCost},
+	}
+	for _, p := range periods {
+		if p.tokens <= 0 && p.cost <= 0 {
+			continue
+		}
+		filter.Since = p.since
+		used := c.total(filter)
+		if p.tokens > 0 && used.TotalTokens() >= p.tokens {
+			return &ExceededError{Scope: scope, Name: name, Period: p.period, Unit: "tokens",
+				Used: float64(used.TotalTokens()), Limit: float64(p.tokens), ResetAt: p.reset}
+		}
+		if p.cost > 0 && used.CostUSD >= p.cost {
+			return &ExceededError{Scope: scope, Name: name, Period: p.period, Unit: "USD",
+				Used: used.CostUSD, Limit: p.cost, ResetAt: p.reset}
+		}
+	}
+	return nil
+}
+
+func (c *Checker) total(filter usage.Filter) models.TokenUsage {
+	if c.store == nil {
+		return models.TokenUsage{}
+	}
+	return c.store.Total(filter)
+}
+
+// lookup 查找名称对应的预算，没有单独配置时使用 "*"
+func lookup(limits map[string]config.BudgetLimit, name string) (config.BudgetLimit, bool) {
+	limit, ok := limits[name]
+	if !ok {
+		limit, ok = limits["*"]
+	}
+	return limit, ok && !limit.IsZero()
+}
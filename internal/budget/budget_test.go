@@ -0,0 +1,166 @@
+package budget
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T, records ...usage.Record) *usage.Store {
+	t.Helper()
+	store, err := usage.NewStore(filepath.Join(t.TempDir(), "usage.jsonl"), config.UsageConfig{})
+	require.NoError(t, err)
+	for _, record := range records {
+		_, err := store.Append(record)
+		require.NoError(t, err)
+	}
+	return store
+}
+
+func TestChecker_CommandBudgets(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t,
+		usage.Record{Time: now.Add(-time.Hour), Repo: "org/a", User: "alice", Trigger: usage.TriggerCommand,
+			TokenUsage: models.TokenUsage{Calls: 1, InputTokens: 800, CostUSD: 4}},
+		usage.Record{Time: now.AddDate(0, 0, -3), Repo: "org/b", User: "bob", Trigger: usage.TriggerCommand,
+			TokenUsage: models.TokenUsage{Calls: 1, CostUSD: 30}},
+	)
+
+	checker := NewChecker(store, config.BudgetConfig{
+		Orgs:  map[string]config.BudgetLimit{"org": {MonthlyCost: 50}},
+		Repos: map[string]config.BudgetLimit{"*": {DailyTokens: 1000}, "org/b": {DailyCost: 10}},
+		Users: map[string]config.BudgetLimit{"alice": {DailyCost: 4}},
+	})
+	checker.now = func() time.Time { return now }
+
+	// alice 当日费用已达上限
+	err := checker.Check(Request{Repo: "org/b", User: "alice"})
+	var exceeded *ExceededError
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, ScopeUser, exceeded.Scope)
+	assert.Equal(t, PeriodDaily, exceeded.Period)
+	assert.Equal(t, "USD", exceeded.Unit)
+	assert.Equal(t, time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC), exceeded.ResetAt)
+	assert.Contains(t, exceeded.Describe(), "$4.00")
+
+	// org/b 单独配置了费用预算，不使用 "*" 的token预算
+	assert.NoError(t, checker.Check(Request{Repo: "org/b", User: "bob"}))
+
+	// org/c 使用 "*" 的token预算，尚未用量
+	assert.NoError(t, checker.Check(Request{Repo: "org/c", User: "bob"}))
+
+	// 组织月度预算
+	_, err = store.Append(usage.Record{Time: now, Repo: "org/c", User: "carol", Trigger: usage.TriggerCommand,
+		TokenUsage: models.TokenUsage{Calls: 1, CostUSD: 20}})
+	require.NoError(t, err)
+	err = checker.Check(Request{Repo: "org/d", User: "bob"})
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, ScopeOrg, exceeded.Scope)
+	assert.Equal(t, PeriodMonthly, exceeded.Period)
+	assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), exceeded.ResetAt)
+
+	// 没有配置预算的组织不受限制
+	assert.NoError(t, checker.Check(Request{Repo: "other/a", User: "bob"}))
+}
+
+func TestChecker_AutoReview(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t,
+		usage.Record{Time: now.Add(-time.Hour), Repo: "org/a", Trigger: usage.TriggerAuto,
+			TokenUsage: models.TokenUsage{Calls: 1, CostUSD: 2}},
+	)
+
+	checker := NewChecker(store, config.BudgetConfig{
+		AutoReview: config.AutoReviewBudgetConfig{
+			Repos:       map[string]config.BudgetLimit{"org/a": {DailyCost: 2}},
+			MinInterval: 10 * time.Minute,
+		},
+	})
+	checker.now = func() time.Time { return now }
+
+	var exceeded *ExceededError
+	require.True(t, errors.As(checker.Check(Request{Repo: "org/a", Number: 1, Auto: true, Review: true}), &exceeded))
+	assert.Equal(t, ScopeAutoReview, exceeded.Scope)
+
+	review := Request{Repo: "org/b", Number: 1, Auto: true, Review: true}
+	require.NoError(t, checker.Check(review))
+	// 没有开始执行的审查（例如排队后重新检查）不会限流
+	require.NoError(t, checker.Check(review))
+	checker.RecordAutoReview(review)
+	var throttled *ThrottledError
+	require.True(t, errors.As(checker.Check(review), &throttled))
+	assert.Equal(t, 10*time.Minute, throttled.RetryAfter)
+	// 其他PR不受影响
+	require.NoError(t, checker.Check(Request{Repo: "org/b", Number: 2, Auto: true, Review: true}))
+
+	now = now.Add(10 * time.Minute)
+	assert.NoError(t, checker.Check(Request{Repo: "org/b", Number: 1, Auto: true, Review: true}))
+}
+
+func TestChecker_AutoTasks(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t,
+		usage.Record{Time: now.Add(-time.Hour), Repo: "org/a", User: "alice", Trigger: usage.TriggerAuto,
+			TokenUsage: models.TokenUsage{Calls: 1, CostUSD: 10}},
+	)
+
+	checker := NewChecker(store, config.BudgetConfig{
+		Repos: map[string]config.BudgetLimit{"org/a": {DailyCost: 10}},
+		Users: map[string]config.BudgetLimit{"alice": {DailyCost: 1}},
+		AutoReview: config.AutoReviewBudgetConfig{
+			Repos: map[string]config.BudgetLimit{"org/a": {DailyCost: 100}},
+		},
+	})
+	checker.now = func() time.Time { return now }
+
+	// 自动任务的用量计入仓库预算
+	var exceeded *ExceededError
+	require.True(t, errors.As(checker.Check(Request{Repo: "org/a", User: "bob"}), &exceeded))
+	assert.Equal(t, ScopeRepo, exceeded.Scope)
+
+	// 审查以外的自动任务（例如CI修复）同样受仓库预算约束
+	require.True(t, errors.As(checker.Check(Request{Repo: "org/a", Number: 1, Auto: true}), &exceeded))
+	assert.Equal(t, ScopeRepo, exceeded.Scope)
+
+	// 自动审查同样受仓库预算约束
+	require.True(t, errors.As(checker.Check(Request{Repo: "org/a", Number: 1, Auto: true, Review: true}), &exceeded))
+	assert.Equal(t, ScopeRepo, exceeded.Scope)
+
+	// 用户预算只统计人工命令
+	assert.NoError(t, checker.Check(Request{Repo: "org/b", User: "alice"}))
+}
+
+func TestChecker_AutoReviewOrgBudget(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t,
+		usage.Record{Time: now.Add(-time.Hour), Repo: "org/a", Trigger: usage.TriggerAuto,
+			TokenUsage: models.TokenUsage{Calls: 1, CostUSD: 5}},
+	)
+
+	checker := NewChecker(store, config.BudgetConfig{
+		Orgs: map[string]config.BudgetLimit{"org": {DailyCost: 5}},
+		AutoReview: config.AutoReviewBudgetConfig{
+			Repos: map[string]config.BudgetLimit{"*": {DailyCost: 100}},
+		},
+	})
+	checker.now = func() time.Time { return now }
+
+	// 组织预算用完后，自动审查不再执行
+	var exceeded *ExceededError
+	require.True(t, errors.As(checker.Check(Request{Repo: "org/b", Number: 1, Auto: true, Review: true}), &exceeded))
+	assert.Equal(t, ScopeOrg, exceeded.Scope)
+	assert.Equal(t, "org", exceeded.Name)
+}
+
+func TestChecker_Nil(t *testing.T) {
+	var checker *Checker
+	assert.NoError(t, checker.Check(Request{Repo: "org/a", User: "alice"}))
+}
@@ -0,0 +1,132 @@
+package ci
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/persist"
+)
+
+// PRAttempts 一个PR的自动修复记录
+type PRAttempts struct {
+	// 连续自动修复的次数，CI在非CodeAgent修复的提交上失败时重新计数
+	Count int `json:"count"`
+	// 最近一次尝试修复时失败的head提交，同一提交只修复一次
+	FailedSHA string `json:"failed_sha"`
+	// 最近一次修复推送的提交
+	FixSHA      string    `json:"fix_sha,omitempty"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// AttemptStore 记录每个PR连续自动修复CI的次数，避免修复提交再次失败时无限循环；状态保存在单个JSON文件中
+type AttemptStore struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	attempts *persist.Map[PRAttempts] // owner/repo#number
+	// previous 进行中的修复登记之前的记录，修复失败时用于回滚
+	previous map[string]PRAttempts
+}
+
+// NewAttemptStore 打开（或创建）path处的修复记录存储
+func NewAttemptStore(path string) (*AttemptStore, error) {
+	attempts, err := persist.Open[PRAttempts](path, "ci fix state")
+	if err != nil {
+		return nil, err
+	}
+	return &AttemptStore{
+		now:      time.Now,
+		attempts: attempts,
+		previous: make(map[string]PRAttempts),
+	}, nil
+}
+
+// BeginResult Begin 的结果
+type BeginResult int
+
+const (
+	// Started 登记了一次新的修复
+	Started BeginResult = iota
+	// AlreadyAttempted 该提交已经尝试过修复，或已经提示过达到上限
+	AlreadyAttempted
+	// LimitReached 连续修复次数达到上限，该提交不再修复
+	LimitReached
+)
+
+// Begin 为headSHA上的CI失败登记一次修复，返回连续修复的次数。
+// 同一提交只登记一次；maxAttempts>0且连续修复次数达到上限时返回 LimitReached（同一提交只返回一次）；
+// 失败的提交不是上次修复推送的提交时（例如有人推送了新提交），重新计数
+func (s *AttemptStore) Begin(repo string, number int, headSHA string, maxAttempts int) (int, BeginResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := persist.IssueKey(repo, number)
+	previous, _ := s.attempts.Get(key)
+	state := previous
+	if state.FailedSHA == headSHA {
+		return state.Count, AlreadyAttempted, nil
+	}
+	if state.FixSHA != headSHA {
+		state.Count = 0
+	}
+	state.FailedSHA = headSHA
+	state.AttemptedAt = s.now()
+	if maxAttempts > 0 && state.Count >= maxAttempts {
+		return state.Count, LimitReached, s.attempts.Set(key, state)
+	}
+
+	s.previous[key] = previous
+	state.Count++
+	state.FixSHA = ""
+	return state.Count, Started, s.attempts.Set(key, state)
+}
+
+// Cancel 撤销 Begin 为headSHA登记的修复（例如修复过程出错），该提交的失败之后可以再次修复，也不计入连续次数
+func (s *AttemptStore) Cancel(repo string, number int, headSHA string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := persist.IssueKey(repo, number)
+	previous, ok := s.previous[key]
+	if current, _ := s.attempts.Get(key); !ok || current.FailedSHA != headSHA {
+		return nil
+	}
+	delete(s.previous, key)
+	if previous == (PRAttempts{}) {
+		return s.attempts.Delete(key)
+	}
+	return s.attempts.Set(key, previous)
+}
+
+// Get 返回PR的自动修复记录
+func (s *AttemptStore) Get(repo string, number int) (PRAttempts, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.attempts.Get(persist.IssueKey(repo, number))
+}
+
+// RecordFix 记录修复推送的提交，该提交上的CI再次失败时计为连续修复
+func (s *AttemptStore) RecordFix(repo string, number int, fixSHA string) error {
+	if fixSHA == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := persist.IssueKey(repo, number)
+	state, _ := s.attempts.Get(key)
+	state.FixSHA = fixSHA
+	delete(s.previous, key)
+	return s.attempts.Set(key, state)
+}
+
+// Forget 删除PR的修复记录
+func (s *AttemptStore) Forget(repo string, number int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := persist.IssueKey(repo, number)
+	delete(s.previous, key)
+	return s.attempts.Delete(key)
+}
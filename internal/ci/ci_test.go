@@ -0,0 +1,152 @@
+package ci
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleLog = `2024-05-01T10:00:00.0000000Z ##[group]Run actions/checkout@v4
+2024-05-01T10:00:01.0000000Z Syncing repository
+2024-05-01T10:00:02.0000000Z ##[endgroup]
+2024-05-01T10:00:03.0000000Z ##[group]Run go build ./...
+2024-05-01T10:00:04.0000000Z go build ./...
+2024-05-01T10:00:05.0000000Z ##[endgroup]
+2024-05-01T10:00:06.0000000Z ##[group]Run go test ./...
+2024-05-01T10:00:07.0000000Z go test ./...
+2024-05-01T10:00:08.0000000Z ##[endgroup]
+2024-05-01T10:00:09.0000000Z ok   example.com/a 0.1s
+2024-05-01T10:00:10.0000000Z --- FAIL: TestB (0.00s)
+2024-05-01T10:00:11.0000000Z     b_test.go:12: want 1, got 2
+2024-05-01T10:00:12.0000000Z FAIL example.com/b 0.2s
+2024-05-01T10:00:13.0000000Z ##[error]Process completed with exit code 1.
+2024-05-01T10:00:14.0000000Z ##[group]Post job cleanup.
+2024-05-01T10:00:15.0000000Z ##[endgroup]`
+
+func TestTrimLog(t *testing.T) {
+	trimmed := TrimLog(sampleLog, 100)
+	assert.True(t, strings.HasPrefix(trimmed, "##[group]Run go test ./..."), trimmed)
+	assert.Contains(t, trimmed, "b_test.go:12: want 1, got 2")
+	assert.Contains(t, trimmed, "##[error]Process completed with exit code 1.")
+	assert.NotContains(t, trimmed, "Syncing repository")
+	assert.NotContains(t, trimmed, "go build")
+	assert.NotContains(t, trimmed, "2024-05-01T")
+
+	trimmed = TrimLog(sampleLog, 3)
+	assert.Contains(t, trimmed, "##[group]Run go test ./...")
+	assert.Contains(t, trimmed, "lines omitted")
+	assert.NotContains(t, trimmed, "ok   example.com/a")
+	assert.Contains(t, trimmed, "FAIL example.com/b 0.2s")
+
+	// 没有错误标记时保留日志末尾
+	assert.Equal(t, "c\nd", TrimLog("a\nb\nc\nd", 0)[4:])
+}
+
+func TestAttemptStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci-fix.json")
+	store, err := NewAttemptStore(path)
+	require.NoError(t, err)
+
+	count, result, err := store.Begin("org/repo", 8, "sha1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, Started, result)
+	assert.Equal(t, 1, count)
+
+	// 同一提交上的其他CI事件不再重复修复
+	_, result, err = store.Begin("org/repo", 8, "sha1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, AlreadyAttempted, result)
+
+	// 修复提交再次失败时连续计数
+	require.NoError(t, store.RecordFix("org/repo", 8, "fix1"))
+	count, result, err = store.Begin("org/repo", 8, "fix1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, Started, result)
+	assert.Equal(t, 2, count)
+
+	require.NoError(t, store.RecordFix("org/repo", 8, "fix2"))
+	count, result, err = store.Begin("org/repo", 8, "fix2", 2)
+	require.NoError(t, err)
+	assert.Equal(t, LimitReached, result, "attempts must stop at the cap")
+	assert.Equal(t, 2, count)
+
+	// 达到上限只提示一次
+	_, result, err = store.Begin("org/repo", 8, "fix2", 2)
+	require.NoError(t, err)
+	assert.Equal(t, AlreadyAttempted, result)
+
+	// 重新打开后状态仍然存在
+	reopened, err := NewAttemptStore(path)
+	require.NoError(t, err)
+	state, ok := reopened.Get("org/repo", 8)
+	require.True(t, ok)
+	assert.Equal(t, 2, state.Count)
+	assert.Equal(t, "fix2", state.FixSHA)
+
+	// 其他人推送的提交失败时重新计数
+	count, result, err = reopened.Begin("org/repo", 8, "human1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, Started, result)
+	assert.Equal(t, 1, count)
+}
+
+func TestAttemptStore_Cancel(t *testing.T) {
+	store, err := NewAttemptStore(filepath.Join(t.TempDir(), "ci-fix.json"))
+	require.NoError(t, err)
+
+	// 修复出错时撤销登记，同一提交可以再次修复
+	_, result, err := store.Begin("org/repo", 8, "sha1", 2)
+	require.NoError(t, err)
+	require.Equal(t, Started, result)
+	require.NoError(t, store.Cancel("org/repo", 8, "sha1"))
+	_, ok := store.Get("org/repo", 8)
+	assert.False(t, ok)
+
+	count, result, err := store.Begin("org/repo", 8, "sha1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, Started, result)
+	assert.Equal(t, 1, count)
+
+	// 撤销修复提交上的登记恢复之前的连续计数
+	require.NoError(t, store.RecordFix("org/repo", 8, "fix1"))
+	_, _, err = store.Begin("org/repo", 8, "fix1", 2)
+	require.NoError(t, err)
+	require.NoError(t, store.Cancel("org/repo", 8, "fix1"))
+	state, ok := store.Get("org/repo", 8)
+	require.True(t, ok)
+	assert.Equal(t, 1, state.Count)
+	assert.Equal(t, "fix1", state.FixSHA)
+
+	// 已经成功推送修复的登记不能撤销
+	count, _, err = store.Begin("org/repo", 8, "fix1", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	require.NoError(t, store.RecordFix("org/repo", 8, "fix2"))
+	require.NoError(t, store.Cancel("org/repo", 8, "fix1"))
+	state, _ = store.Get("org/repo", 8)
+	assert.Equal(t, 2, state.Count)
+}
+
+func TestBuildFixPrompt(t *testing.T) {
+	prompt := BuildFixPrompt(FixRequest{
+		PRNumber: 8,
+		PRTitle:  "Add feature",
+		Branch:   "codeagent/claude/issue-7-1700000000",
+		HeadSHA:  "0123456789abcdef",
+		Failures: []Failure{
+			{Name: "test", Conclusion: "failure", Log: "```go\nFAIL\n```"},
+			{Name: "lint", Conclusion: "timed_out"},
+		},
+		Instructions: "Only touch the parser.",
+	})
+
+	assert.Contains(t, prompt, "pull request #8 (Add feature) failed on commit 0123456")
+	assert.Contains(t, prompt, "### test (failure)")
+	assert.Contains(t, prompt, "````\n```go\nFAIL\n```\n````")
+	assert.Contains(t, prompt, "No log is available for this check.")
+	assert.Contains(t, prompt, "Only touch the parser.")
+	assert.Contains(t, prompt, "Do not disable, skip or delete tests")
+}
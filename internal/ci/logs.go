@@ -0,0 +1,71 @@
+package ci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// groupMarker GitHub Actions 日志中步骤（及步骤内分组）的开始标记
+	groupMarker = "##[group]"
+	// errorMarker GitHub Actions 日志中的错误标记，失败步骤以它结尾
+	errorMarker = "##[error]"
+)
+
+// timestampPattern GitHub Actions 日志每行开头的时间戳
+var timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?Z ?`)
+
+// TrimLog 将job日志裁剪为失败的步骤：按 ##[group] 切分日志，只保留包含 ##[error] 的部分，
+// 每部分保留开头的步骤命令和结尾的 maxLines 行；找不到错误标记时保留整个日志的最后 maxLines 行
+func TrimLog(log string, maxLines int) string {
+	lines := strings.Split(strings.ReplaceAll(log, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = timestampPattern.ReplaceAllString(line, "")
+	}
+
+	var sections [][]string
+	var current []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, groupMarker) && len(current) > 0 {
+			sections = append(sections, current)
+			current = nil
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		sections = append(sections, current)
+	}
+
+	var failed []string
+	for _, section := range sections {
+		if !containsError(section) {
+			continue
+		}
+		failed = append(failed, strings.Join(tail(section, maxLines), "\n"))
+	}
+	if len(failed) == 0 {
+		return strings.TrimSpace(strings.Join(tail(lines, maxLines), "\n"))
+	}
+	return strings.TrimSpace(strings.Join(failed, "\n\n"))
+}
+
+func containsError(lines []string) bool {
+	for _, line := range lines {
+		if strings.Contains(line, errorMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+// tail 保留第一行（步骤命令）和最后 maxLines 行
+func tail(lines []string, maxLines int) []string {
+	if maxLines <= 0 || len(lines) <= maxLines+1 {
+		return lines
+	}
+	omitted := len(lines) - maxLines - 1
+	result := make([]string, 0, maxLines+2)
+	result = append(result, lines[0], fmt.Sprintf("... (%d lines omitted) ...", omitted))
+	return append(result, lines[len(lines)-maxLines:]...)
+}
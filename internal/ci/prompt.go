@@ -0,0 +1,85 @@
+package ci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxFailures 提示词中最多包含的失败检查数量
+const maxFailures = 5
+
+// Failure 一个失败的CI检查
+type Failure struct {
+	// 检查名称，GitHub Actions 中为job名称
+	Name       string
+	Conclusion string
+	URL        string
+	// 裁剪后的失败日志，非 GitHub Actions 的检查为检查输出
+	Log string
+}
+
+// FixRequest 修复CI的上下文
+type FixRequest struct {
+	PRNumber int
+	PRTitle  string
+	Branch   string
+	HeadSHA  string
+	Failures []Failure
+	// 触发 /fix-ci 时附带的说明
+	Instructions string
+}
+
+// BuildFixPrompt 构建修复失败CI的提示词
+func BuildFixPrompt(req FixRequest) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("The CI build of pull request #%d (%s) failed on commit %s of branch `%s`.\n",
+		req.PRNumber, req.PRTitle, shortSHA(req.HeadSHA), req.Branch))
+	sb.WriteString("Fix the failing build. The repository is checked out at that commit in the current directory.\n\n")
+
+	sb.WriteString("## Failing checks\n")
+	failures := req.Failures
+	if len(failures) > maxFailures {
+		failures = failures[:maxFailures]
+	}
+	for _, failure := range failures {
+		sb.WriteString(fmt.Sprintf("\n### %s (%s)\n", failure.Name, failure.Conclusion))
+		if failure.URL != "" {
+			sb.WriteString(failure.URL + "\n")
+		}
+		if log := strings.TrimSpace(failure.Log); log != "" {
+			fence := "```"
+			for strings.Contains(log, fence) {
+				fence += "`"
+			}
+			sb.WriteString(fmt.Sprintf("\n%s\n%s\n%s\n", fence, log, fence))
+		} else {
+			sb.WriteString("\nNo log is available for this check.\n")
+		}
+	}
+	if omitted := len(req.Failures) - len(failures); omitted > 0 {
+		sb.WriteString(fmt.Sprintf("\n%d more failing checks are not shown.\n", omitted))
+	}
+
+	if instructions := strings.TrimSpace(req.Instructions); instructions != "" {
+		sb.WriteString("\n## Additional instructions\n")
+		sb.WriteString(instructions + "\n")
+	}
+
+	sb.WriteString(`
+## Requirements
+- Find the root cause from the logs and fix it in the code; reproduce the failure locally first when possible.
+- Keep the change minimal and focused on making the build pass.
+- Do not disable, skip or delete tests, and do not weaken lint or CI configuration to hide the failure.
+- If the failure is unrelated to this branch (for example a flaky test or an infrastructure problem), do not change any files and explain why.
+- Do not commit or push; CodeAgent commits and pushes your changes.
+- End with a short summary of the root cause and the fix.
+`)
+	return sb.String()
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
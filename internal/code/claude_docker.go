@@ -2,11 +2,13 @@ package code
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/qiniu/codeagent/internal/config"
 	"github.com/qiniu/codeagent/pkg/models"
@@ -20,6 +22,7 @@ const (
 // claudeCode Docker implementation with MCP support
 type claudeCode struct {
 	containerName string
+	timeout       time.Duration
 }
 
 func NewClaudeDocker(workspace *models.Workspace, cfg *config.Config) (Code, error) {
@@ -44,6 +47,7 @@ func NewClaudeDocker(workspace *models.Workspace, cfg *config.Config) (Code, err
 		log.Infof("Found existing container: %s, reusing it", containerName)
 		return &claudeCode{
 			containerName: containerName,
+			timeout:       cfg.Claude.Timeout,
 		}, nil
 	}
 
@@ -112,8 +116,13 @@ func NewClaudeDocker(workspace *models.Workspace, cfg *config.Config) (Code, err
 	if cfg.Claude.BaseURL != "" {
 		args = append(args, "-e", fmt.Sprintf("ANTHROPIC_BASE_URL=%s", cfg.Claude.BaseURL))
 	}
-	if cfg.Claude.Model != "" {
-		args = append(args, "-e", fmt.Sprintf("ANTHROPIC_MODEL=%s", cfg.Claude.Model))
+	model := cfg.Claude.Model
+	if workspace.Model != "" {
+		// 仓库配置覆盖的模型
+		model = workspace.Model
+	}
+	if model != "" {
+		args = append(args, "-e", fmt.Sprintf("ANTHROPIC_MODEL=%s", model))
 	}
 	if cfg.GitHub.GHToken != "" {
 		args = append(args, "-e", fmt.Sprintf("GH_TOKEN=%s", cfg.GitHub.GHToken))
@@ -153,44 +162,47 @@ func NewClaudeDocker(workspace *models.Workspace, cfg *config.Config) (Code, err
 
 	return &claudeCode{
 		containerName: containerName,
+		timeout:       cfg.Claude.Timeout,
 	}, nil
 }
 
-func (c *claudeCode) Prompt(message string) (*Response, error) {
+func (c *claudeCode) Prompt(ctx context.Context, message string) (*Response, error) {
 	log.Infof("Executing Claude with Docker container %s", c.containerName)
 
 	args := []string{
 		"exec",
 		c.containerName,
 		"claude",
-		"--mcp-config", targetMCPConfigPath,
-		"--dangerously-skip-permissions",
+	}
+	if IsReadOnly(ctx) {
+		// 只读模式不加载可以写入GitHub的MCP工具，也不跳过权限确认
+		args = append(args, claudeReadOnlyArgs()...)
+	} else {
+		args = append(args, "--mcp-config", targetMCPConfigPath, "--dangerously-skip-permissions")
+	}
+	args = append(args,
+		"--output-format", "stream-json",
+		"--verbose", // stream-json 输出模式要求开启
 		"-c",
 		"-p", message,
-	}
+	)
 
 	log.Infof("Claude command: docker %s", strings.Join(args, " "))
 
-	cmd := exec.Command("docker", args...)
-
 	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	stdout, err := cmd.StdoutPipe()
+	stdout, err := startProcess(ctx, ProviderClaude, c.timeout, func(ctx context.Context) *exec.Cmd {
+		cmd := newDockerExecCommand(ctx, c.containerName, "claude", args...)
+		cmd.Stderr = &stderr
+		return cmd
+	})
 	if err != nil {
-		log.Errorf("Failed to start claude command: %v", err)
-		log.Errorf("Stderr: %s", stderr.String())
-		return nil, err
-	}
-
-	if err := cmd.Start(); err != nil {
 		log.Errorf("Failed to start claude command: %v", err)
 		log.Errorf("Stderr: %s", stderr.String())
 		return nil, fmt.Errorf("failed to execute claude: %w", err)
 	}
 
 	log.Infof("Claude MCP command started successfully")
-	return &Response{Out: stdout}, nil
+	return newStreamJSONResponse(stdout), nil
 }
 
 func (c *claudeCode) Close() error {
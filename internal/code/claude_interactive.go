@@ -3,6 +3,7 @@ package code
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -29,6 +30,7 @@ type claudeInteractive struct {
 	closed        bool
 	ctx           context.Context
 	cancel        context.CancelFunc
+	timeout       time.Duration
 }
 
 // InteractiveSession 管理交互式会话
@@ -50,7 +52,7 @@ func NewClaudeInteractive(workspace *models.Workspace, cfg *config.Config) (Code
 	if isContainerRunning(containerName) {
 		log.Infof("Found existing interactive container: %s, reusing it", containerName)
 		// 连接到现有容器
-		return connectToExistingContainer(containerName, workspace)
+		return connectToExistingContainer(containerName, workspace, cfg.Claude.Timeout)
 	}
 
 	// 确保路径存在
@@ -166,6 +168,7 @@ func NewClaudeInteractive(workspace *models.Workspace, cfg *config.Config) (Code
 		closed:        false,
 		ctx:           ctx,
 		cancel:        cancel,
+		timeout:       cfg.Claude.Timeout,
 	}
 
 	// 等待Claude CLI初始化完成
@@ -187,7 +190,7 @@ func (c *claudeInteractive) waitForReady() error {
 }
 
 // connectToExistingContainer 连接到现有的交互式容器
-func connectToExistingContainer(containerName string, workspace *models.Workspace) (Code, error) {
+func connectToExistingContainer(containerName string, workspace *models.Workspace, timeout time.Duration) (Code, error) {
 	// 通过docker exec连接到现有容器
 	args := []string{
 		"exec",
@@ -243,16 +246,24 @@ func connectToExistingContainer(containerName string, workspace *models.Workspac
 		closed:        false,
 		ctx:           ctx,
 		cancel:        cancel,
+		timeout:       timeout,
 	}, nil
 }
 
-func (c *claudeInteractive) Prompt(message string) (*Response, error) {
+func (c *claudeInteractive) Prompt(ctx context.Context, message string) (*Response, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	if c.closed {
 		return nil, fmt.Errorf("interactive session is closed")
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if IsReadOnly(ctx) {
+		// 交互式进程启动后无法按次限制工具
+		return nil, fmt.Errorf("read-only mode is not supported by interactive claude sessions")
+	}
 
 	// 更新会话信息
 	c.session.LastActivity = time.Now()
@@ -286,14 +297,27 @@ func (c *claudeInteractive) Prompt(message string) (*Response, error) {
 
 	log.Debugf("Creating InteractiveResponseReader for message #%d", c.session.MessageCount)
 
+	// 任务被取消或超时时关闭会话，终止容器内的 claude 进程并让读取器尽快返回
+	ctx, cancel, timeout := withPromptTimeout(ctx, c.timeout)
+	stopAfter := context.AfterFunc(ctx, func() {
+		log.Infof("Prompt cancelled or timed out, closing interactive session %s", c.session.ID)
+		killContainerProcess(c.containerName, "claude")
+		c.Close()
+	})
+
 	// 创建响应读取器
 	responseReader := &InteractiveResponseReader{
 		stdout:  c.stdout,
 		session: c.session,
-		ctx:     c.ctx,
+		ctx:     ctx,
+		timeout: timeout,
+		stop: func() {
+			stopAfter()
+			cancel()
+		},
 	}
 
-	return &Response{Out: responseReader}, nil
+	return NewTextResponse(responseReader), nil
 }
 
 // InteractiveResponseReader 处理交互式响应读取
@@ -303,6 +327,8 @@ type InteractiveResponseReader struct {
 	buffer  bytes.Buffer
 	done    bool
 	ctx     context.Context
+	timeout time.Duration
+	stop    func()
 	mutex   sync.Mutex
 }
 
@@ -321,8 +347,15 @@ func (r *InteractiveResponseReader) Read(p []byte) (n int, err error) {
 	log.Debugf("InteractiveResponseReader: Read %d bytes, error: %v, buffer size: %d", n, err, r.buffer.Len())
 
 	if err != nil {
+		if ctxErr := r.ctx.Err(); ctxErr != nil {
+			r.finish()
+			if errors.Is(ctxErr, context.DeadlineExceeded) {
+				return 0, newTimeoutError(ProviderClaude, r.timeout, r.buffer.Bytes())
+			}
+			return 0, ctxErr
+		}
 		if err == io.EOF {
-			r.done = true
+			r.finish()
 			log.Infof("InteractiveResponseReader: EOF reached, total buffer size: %d", r.buffer.Len())
 		}
 		return 0, err
@@ -340,7 +373,7 @@ func (r *InteractiveResponseReader) Read(p []byte) (n int, err error) {
 
 	// 简化响应完成检测 - 只在非常明确的情况下才结束
 	if r.isResponseComplete(buffer[:n]) {
-		r.done = true
+		r.finish()
 		log.Infof("InteractiveResponseReader: Response complete detected, total buffer size: %d", r.buffer.Len())
 		return n, io.EOF
 	}
@@ -348,6 +381,14 @@ func (r *InteractiveResponseReader) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+// finish 标记响应结束，之后的取消不再影响会话
+func (r *InteractiveResponseReader) finish() {
+	r.done = true
+	if r.stop != nil {
+		r.stop()
+	}
+}
+
 // isResponseComplete 检查响应是否完成 - 简化版本
 func (r *InteractiveResponseReader) isResponseComplete(data []byte) bool {
 	responseText := string(data)
@@ -384,6 +425,13 @@ func (r *InteractiveResponseReader) isResponseComplete(data []byte) bool {
 	return false
 }
 
+// IsClosed 会话是否已关闭
+func (c *claudeInteractive) IsClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
 func (c *claudeInteractive) Close() error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
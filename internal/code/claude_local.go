@@ -3,6 +3,7 @@ package code
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -43,36 +44,43 @@ func checkClaudeCLI() error {
 }
 
 // Prompt 实现 Code 接口 - 本地 CLI 版本
-func (c *claudeLocal) Prompt(message string) (*Response, error) {
+func (c *claudeLocal) Prompt(ctx context.Context, message string) (*Response, error) {
 	// 执行本地 claude CLI 调用
-	output, err := c.executeClaudeLocal(message)
+	output, err := c.executeClaudeLocal(ctx, message)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute claude prompt: %w", err)
 	}
 
 	// 返回结果
-	return &Response{
-		Out: bytes.NewReader(output),
-	}, nil
+	return newStreamJSONResponse(bytes.NewReader(output)), nil
 }
 
 // executeClaudeLocal 执行本地 claude CLI 调用
-func (c *claudeLocal) executeClaudeLocal(prompt string) ([]byte, error) {
+func (c *claudeLocal) executeClaudeLocal(ctx context.Context, prompt string) ([]byte, error) {
 	// 构建 claude CLI 命令
 	args := []string{
+		"--output-format", "stream-json",
+		"--verbose", // stream-json 输出模式要求开启
 		"-p",
 		prompt,
 	}
+	if c.workspace.Model != "" {
+		args = append(args, "--model", c.workspace.Model)
+	}
+	if IsReadOnly(ctx) {
+		args = append(args, claudeReadOnlyArgs()...)
+	}
 
 	// 设置超时 - 使用配置中的超时时间，默认为 5 分钟
 	timeout := c.config.Claude.Timeout
 	if timeout == 0 {
 		timeout = 5 * time.Minute
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "claude", args...)
+	cmd.WaitDelay = processWaitDelay
 	cmd.Dir = c.workspace.Path // 设置工作目录，Claude CLI 会自动读取该目录的文件作为上下文
 
 	// 设置环境变量
@@ -80,16 +88,29 @@ func (c *claudeLocal) executeClaudeLocal(prompt string) ([]byte, error) {
 
 	log.Infof("Executing local claude CLI in directory %s: claude %s", c.workspace.Path, strings.Join(args, " "))
 
-	// 执行命令并获取输出
-	output, err := cmd.CombinedOutput()
+	// 执行命令并获取输出，只有标准输出是 stream-json 事件，标准错误用于错误信息
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	output := stdout.Bytes()
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			log.Infof("Claude CLI execution cancelled")
+			return nil, fmt.Errorf("claude CLI execution cancelled: %w", ctx.Err())
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			log.Warnf("Claude CLI execution timed out after %s, this might be due to large codebase or complex task", timeout)
-			return nil, fmt.Errorf("claude CLI execution timed out: %w", err)
+			return nil, newTimeoutError(ProviderClaude, timeout, output)
+		}
+
+		// CLI 通常把错误写到标准错误，没有时错误结果在 stream-json 输出中
+		outputStr := strings.TrimSpace(stderr.String())
+		if outputStr == "" {
+			outputStr = string(output)
 		}
 
 		// 检查是否是 API 密钥相关错误
-		outputStr := string(output)
 		if strings.Contains(outputStr, "API Error") || strings.Contains(outputStr, "fetch failed") || strings.Contains(outputStr, "authentication") {
 			return nil, fmt.Errorf("claude API error - please check CLAUDE_API_KEY: %w, output: %s", err, outputStr)
 		}
@@ -101,6 +122,9 @@ func (c *claudeLocal) executeClaudeLocal(prompt string) ([]byte, error) {
 
 		return nil, fmt.Errorf("claude CLI execution failed: %w, output: %s", err, outputStr)
 	}
+	if stderr.Len() > 0 {
+		log.Warnf("Claude CLI stderr: %s", strings.TrimSpace(stderr.String()))
+	}
 
 	log.Infof("Local claude CLI execution completed successfully")
 	return output, nil
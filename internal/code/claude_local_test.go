@@ -0,0 +1,53 @@
+package code
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClaudeLocal 使用PATH中执行script的假 claude CLI 创建本地实现
+func fakeClaudeLocal(t *testing.T, script string) *claudeLocal {
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "claude"), []byte("#!/bin/sh\n"+script), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return &claudeLocal{workspace: &models.Workspace{Path: t.TempDir()}, config: &config.Config{}}
+}
+
+func TestClaudeLocal_ParsesStdoutOnly(t *testing.T) {
+	c := fakeClaudeLocal(t, `echo "warning: update available" >&2
+echo '{"type":"result","subtype":"success","is_error":false,"result":"Done"}'
+`)
+
+	resp, err := c.Prompt(context.Background(), "fix it")
+	require.NoError(t, err)
+	var events []Event
+	resp.OnEvent(func(e Event) { events = append(events, e) })
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "Done\n", string(out))
+	require.Len(t, events, 1)
+	assert.Equal(t, EventResult, events[0].Type)
+}
+
+func TestClaudeLocal_ReportsStderrOnFailure(t *testing.T) {
+	c := fakeClaudeLocal(t, `echo '{"type":"system","subtype":"init"}'
+echo "API Error: invalid key" >&2
+exit 1
+`)
+
+	_, err := c.Prompt(context.Background(), "fix it")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "please check CLAUDE_API_KEY")
+	assert.Contains(t, err.Error(), "API Error: invalid key")
+	assert.NotContains(t, err.Error(), `"type":"system"`)
+}
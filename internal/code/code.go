@@ -1,35 +1,64 @@
 package code
 
 import (
+	"context"
 	"fmt"
 	"io"
 
 	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/mcp"
 	"github.com/qiniu/codeagent/pkg/models"
 )
 
 const (
 	ProviderClaude = "claude"
 	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+	ProviderFake   = "fake" // 回放脚本化响应，用于端到端测试
 )
 
+// Response 一次AI调用的响应。
+// Out 返回与CLI文本输出模式一致的纯文本；需要结构化事件的调用方在读取 Out 之前通过 OnEvent 注册回调，
+// 事件在读取 Out 的过程中同步分发。
 type Response struct {
 	Out io.Reader
+
+	// Provider 实际产生该响应的provider，由后备链填写，为空时即为请求的provider
+	Provider string
+	// FallbackFrom 主provider失败后改用后备provider时，记录原本请求的provider
+	FallbackFrom string
+
+	events *eventReader // 不支持结构化事件的响应为nil
+}
+
+// OnEvent 注册结构化事件回调，必须在读取 Out 之前调用
+func (r *Response) OnEvent(handler EventHandler) {
+	if r.events != nil {
+		r.events.handlers = append(r.events.handlers, handler)
+	}
+}
+
+// Result 返回最终结果事件，Out 读取完毕前或不支持结构化事件时返回nil
+func (r *Response) Result() *Event {
+	if r.events == nil {
+		return nil
+	}
+	return r.events.result
 }
 
 type Code interface {
-	Prompt(message string) (*Response, error)
+	// Prompt 执行一次AI调用，ctx取消时会终止背后的CLI进程
+	Prompt(ctx context.Context, message string) (*Response, error)
 	Close() error
 }
 
 func New(workspace *models.Workspace, cfg *config.Config) (Code, error) {
-	// 优先使用workspace中指定的AI模型，如果没有则使用配置中的默认模型
-	var provider string
-	if workspace.AIModel != "" {
-		provider = workspace.AIModel
-	} else {
-		provider = cfg.CodeProvider
-	}
+	return newCode(workspace, cfg, nil)
+}
+
+// newCode 创建代码提供者，mcpClient 为进程内执行工具调用的provider提供MCP工具，可以为nil
+func newCode(workspace *models.Workspace, cfg *config.Config, mcpClient mcp.MCPClient) (Code, error) {
+	provider := providerFor(workspace, cfg)
 
 	// 根据 code provider 和 use_docker 配置创建相应的代码提供者
 	switch provider {
@@ -48,7 +77,20 @@ func New(workspace *models.Workspace, cfg *config.Config) (Code, error) {
 			return NewGeminiDocker(workspace, cfg)
 		}
 		return NewGeminiLocal(workspace, cfg)
+	case ProviderOpenAI:
+		// 直接通过HTTP调用，不依赖CLI和Docker
+		return NewOpenAI(workspace, cfg, mcpClient)
+	case ProviderFake:
+		return NewFake(workspace, cfg)
 	default:
 		return nil, fmt.Errorf("unsupported code provider: %s", provider)
 	}
 }
+
+// providerFor 优先使用workspace中指定的AI模型，如果没有则使用配置中的默认模型
+func providerFor(workspace *models.Workspace, cfg *config.Config) string {
+	if workspace.AIModel != "" {
+		return workspace.AIModel
+	}
+	return cfg.CodeProvider
+}
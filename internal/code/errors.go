@@ -0,0 +1,61 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultPromptTimeout 未配置超时时间时单次AI调用的最长执行时间
+	defaultPromptTimeout = 30 * time.Minute
+	// maxPartialOutput 超时错误中保留的部分输出上限，超出时只保留末尾
+	maxPartialOutput = 64 * 1024
+)
+
+// TimeoutError AI调用超过了配置的超时时间，携带超时前已经产生的输出
+type TimeoutError struct {
+	Provider      string
+	Timeout       time.Duration
+	PartialOutput string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s execution timed out after %s", e.Provider, e.Timeout)
+}
+
+// Unwrap 使 errors.Is(err, context.DeadlineExceeded) 成立
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// AsTimeoutError 从错误链中提取超时错误
+func AsTimeoutError(err error) (*TimeoutError, bool) {
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return timeoutErr, true
+	}
+	return nil, false
+}
+
+// withPromptTimeout 为单次AI调用设置超时，非正数使用默认值
+func withPromptTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc, time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultPromptTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+// newTimeoutError 构造超时错误，过长的部分输出只保留末尾
+func newTimeoutError(provider string, timeout time.Duration, partial []byte) *TimeoutError {
+	if len(partial) > maxPartialOutput {
+		partial = partial[len(partial)-maxPartialOutput:]
+	}
+	return &TimeoutError{
+		Provider:      provider,
+		Timeout:       timeout,
+		PartialOutput: string(partial),
+	}
+}
@@ -0,0 +1,165 @@
+package code
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/log"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// FakeFixture fake provider 的响应脚本
+type FakeFixture struct {
+	Responses []FakeResponse `yaml:"responses"`
+}
+
+// FakeResponse 一次脚本化的AI响应
+type FakeResponse struct {
+	// Match 提示词需要包含的文本，为空时匹配任意提示词
+	Match string `yaml:"match"`
+	// Repeat 为true时可以被重复使用，否则只使用一次
+	Repeat bool `yaml:"repeat"`
+	// Output 返回的文本输出
+	Output string `yaml:"output"`
+	// Files 写入工作区的文件，key为相对路径
+	Files map[string]string `yaml:"files"`
+	// Delete 从工作区删除的文件
+	Delete []string `yaml:"delete"`
+	// Error 不为空时 Prompt 返回该错误
+	Error string `yaml:"error"`
+}
+
+// fakeCode 按脚本回放响应和文件修改的 Code 实现
+type fakeCode struct {
+	workspace *models.Workspace
+	tools     *workspaceTools
+
+	mu        sync.Mutex
+	responses []FakeResponse
+	used      []bool
+}
+
+// NewFake 从 cfg.Fake.Fixture 加载响应脚本，创建 fake provider
+func NewFake(workspace *models.Workspace, cfg *config.Config) (Code, error) {
+	if cfg.Fake.Fixture == "" {
+		return nil, fmt.Errorf("fake provider requires fake.fixture")
+	}
+	data, err := os.ReadFile(cfg.Fake.Fixture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fake fixture: %w", err)
+	}
+
+	var fixture FakeFixture
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fake fixture %s: %w", cfg.Fake.Fixture, err)
+	}
+	return newFakeCode(workspace, fixture), nil
+}
+
+func newFakeCode(workspace *models.Workspace, fixture FakeFixture) *fakeCode {
+	return &fakeCode{
+		workspace: workspace,
+		tools:     &workspaceTools{root: workspace.Path},
+		responses: fixture.Responses,
+		used:      make([]bool, len(fixture.Responses)),
+	}
+}
+
+// Prompt 使用第一个匹配且未使用的脚本响应
+func (f *fakeCode) Prompt(ctx context.Context, message string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	resp, ok := f.next(message)
+	if !ok {
+		return nil, fmt.Errorf("no fake response matches prompt: %.80q", message)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if IsReadOnly(ctx) && (len(resp.Files) > 0 || len(resp.Delete) > 0) {
+		return nil, fmt.Errorf("fake response modifies files in read-only mode")
+	}
+
+	var out bytes.Buffer
+	emit := func(event Event) {
+		data, _ := json.Marshal(event)
+		out.Write(append(data, '\n'))
+	}
+
+	paths := make([]string, 0, len(resp.Files))
+	for path := range resp.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := f.writeFile(path, resp.Files[path]); err != nil {
+			return nil, err
+		}
+		emit(Event{Type: EventToolCall, ToolName: "write_file", Input: map[string]interface{}{"path": path}})
+		emit(Event{Type: EventFileEdit, ToolName: "write_file", FilePath: path})
+	}
+	for _, path := range resp.Delete {
+		full, err := f.tools.resolve(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Remove(full); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to delete %s: %w", path, err)
+		}
+	}
+
+	if strings.TrimSpace(resp.Output) != "" {
+		emit(Event{Type: EventText, Text: resp.Output})
+	}
+	emit(Event{Type: EventResult, Text: resp.Output, Model: ProviderFake, Usage: &Usage{}})
+
+	log.Infof("Fake provider replayed response (%d files changed)", len(resp.Files)+len(resp.Delete))
+	return newEventStreamResponse(&out), nil
+}
+
+func (f *fakeCode) next(message string) (FakeResponse, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, resp := range f.responses {
+		if f.used[i] || !strings.Contains(message, resp.Match) {
+			continue
+		}
+		if !resp.Repeat {
+			f.used[i] = true
+		}
+		return resp, true
+	}
+	return FakeResponse{}, false
+}
+
+func (f *fakeCode) writeFile(path, content string) error {
+	full, err := f.tools.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func (f *fakeCode) Close() error {
+	return nil
+}
@@ -0,0 +1,100 @@
+package code
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeFixture = `responses:
+  - match: "commit message"
+    repeat: true
+    output: "feat: add greeting"
+  - match: "fail"
+    error: "scripted failure"
+  - output: |
+      ## Summary
+      Added greeting
+    files:
+      pkg/hello.go: |
+        package pkg
+    delete:
+      - old.txt
+`
+
+func TestFake_Replay(t *testing.T) {
+	dir := t.TempDir()
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	require.NoError(t, os.WriteFile(fixture, []byte(fakeFixture), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), 0644))
+
+	cfg := &config.Config{Fake: config.FakeConfig{Fixture: fixture}}
+	c, err := New(&models.Workspace{AIModel: ProviderFake, Path: dir}, cfg)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	resp, err := c.Prompt(ctx, "implement the greeting")
+	require.NoError(t, err)
+	var edited []string
+	resp.OnEvent(func(e Event) {
+		if e.Type == EventFileEdit {
+			edited = append(edited, e.FilePath)
+		}
+	})
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "## Summary\nAdded greeting\n", string(out))
+	assert.Equal(t, []string{"pkg/hello.go"}, edited)
+	assert.Equal(t, ProviderFake, resp.Result().Model)
+
+	data, err := os.ReadFile(filepath.Join(dir, "pkg", "hello.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package pkg\n", string(data))
+	assert.NoFileExists(t, filepath.Join(dir, "old.txt"))
+
+	// 一次性响应已被使用，repeat 响应可以重复使用
+	_, err = c.Prompt(ctx, "implement the greeting")
+	assert.ErrorContains(t, err, "no fake response matches prompt")
+	for i := 0; i < 2; i++ {
+		resp, err = c.Prompt(ctx, "generate a commit message")
+		require.NoError(t, err)
+		out, err = io.ReadAll(resp.Out)
+		require.NoError(t, err)
+		assert.Equal(t, "feat: add greeting\n", string(out))
+	}
+
+	_, err = c.Prompt(ctx, "this should fail")
+	assert.EqualError(t, err, "scripted failure")
+}
+
+func TestFake_ReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	c := newFakeCode(&models.Workspace{Path: dir}, FakeFixture{Responses: []FakeResponse{
+		{Match: "plan", Output: "## Approach"},
+		{Match: "edit", Files: map[string]string{"a.txt": "a"}},
+	}})
+	ctx := WithReadOnly(context.Background())
+
+	resp, err := c.Prompt(ctx, "make a plan")
+	require.NoError(t, err)
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "## Approach\n", string(out))
+
+	_, err = c.Prompt(ctx, "edit the file")
+	assert.ErrorContains(t, err, "read-only mode")
+	assert.NoFileExists(t, filepath.Join(dir, "a.txt"))
+}
+
+func TestFake_RequiresFixture(t *testing.T) {
+	_, err := New(&models.Workspace{AIModel: ProviderFake, Path: t.TempDir()}, &config.Config{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,303 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/log"
+)
+
+// failoverCooldown provider因限流、认证或崩溃失败后，在这段时间内优先跳过它
+const failoverCooldown = 10 * time.Minute
+
+// ErrorClass AI调用失败的类别，用于决定是否切换到后备provider
+type ErrorClass string
+
+const (
+	ErrorClassRateLimit ErrorClass = "rate_limit" // 限流或额度用尽
+	ErrorClassAuth      ErrorClass = "auth"       // 认证失败或密钥无效
+	ErrorClassCrash     ErrorClass = "crash"      // CLI或容器无法启动、进程异常退出
+	ErrorClassTimeout   ErrorClass = "timeout"    // 超过配置的超时时间
+	ErrorClassCanceled  ErrorClass = "canceled"   // 任务被取消
+	ErrorClassUnknown   ErrorClass = "unknown"
+)
+
+// Failover 判断该类错误是否应切换到下一个provider。
+// 超时不切换：工作区里可能已有部分修改，且换一个provider大概率同样超时
+func (c ErrorClass) Failover() bool {
+	switch c {
+	case ErrorClassRateLimit, ErrorClassAuth, ErrorClassCrash:
+		return true
+	}
+	return false
+}
+
+var errorClassPatterns = []struct {
+	class    ErrorClass
+	patterns []string
+}{
+	{ErrorClassRateLimit, []string{
+		"rate limit", "rate_limit", "ratelimit", "too many requests", "status 429",
+		"quota", "usage limit", "resource_exhausted", "resource exhausted", "overloaded", "credit balance",
+	}},
+	{ErrorClassAuth, []string{
+		"status 401", "status 403", "unauthorized", "authentication", "invalid api key", "invalid x-api-key",
+		"api key not valid", "api_key_invalid", "permission denied", "forbidden",
+	}},
+	{ErrorClassCrash, []string{
+		"broken pipe", "process has already exited", "exit status", "signal: killed", "executable file not found",
+		"not available", "cli not found", "failed to start", "failed to create container", "no such container",
+		"cannot connect to the docker daemon",
+	}},
+}
+
+// ClassifyError 根据错误链和错误信息对AI调用失败进行分类
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	if _, ok := AsTimeoutError(err); ok {
+		return ErrorClassTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, group := range errorClassPatterns {
+		for _, pattern := range group.patterns {
+			if strings.Contains(msg, pattern) {
+				return group.class
+			}
+		}
+	}
+	return ErrorClassUnknown
+}
+
+// ProviderChain 返回主provider及去重后的后备provider
+func ProviderChain(primary string, fallbacks []string) []string {
+	chain := []string{primary}
+	seen := map[string]bool{primary: true}
+	for _, provider := range fallbacks {
+		provider = strings.TrimSpace(provider)
+		if provider == "" || seen[provider] {
+			continue
+		}
+		seen[provider] = true
+		chain = append(chain, provider)
+	}
+	return chain
+}
+
+// fallbackCode 按顺序使用provider链，前一个provider因可切换的错误失败时改用下一个
+type fallbackCode struct {
+	providers []string
+	create    func(provider string) (Code, error)
+
+	mu       sync.Mutex
+	sessions map[string]Code
+	failedAt map[string]time.Time
+	now      func() time.Time
+}
+
+// newFallbackCode 创建provider链，至少需要一个provider能创建成功
+func newFallbackCode(providers []string, create func(provider string) (Code, error)) (*fallbackCode, error) {
+	f := &fallbackCode{
+		providers: providers,
+		create:    create,
+		sessions:  make(map[string]Code),
+		failedAt:  make(map[string]time.Time),
+		now:       time.Now,
+	}
+
+	var errs []string
+	for _, provider := range providers {
+		if _, err := f.session(provider); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", provider, err))
+			continue
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("no provider in chain could be started: %s", strings.Join(errs, "; "))
+}
+
+// Prompt 依次尝试冷却期之外的provider，全部处于冷却期时按原顺序全部尝试。
+// 输出流在调用方读到任何内容之前以可切换的错误结束时，同样改用下一个provider
+func (f *fallbackCode) Prompt(ctx context.Context, message string) (*Response, error) {
+	resp, provider, rest, err := f.start(ctx, message, f.candidates())
+	if err != nil {
+		return nil, err
+	}
+
+	events := &eventReader{}
+	out := &Response{events: events}
+	r := &failoverReader{f: f, ctx: ctx, message: message, resp: out, events: events}
+	r.attach(resp, provider, rest)
+	out.Out = r
+	return out, nil
+}
+
+// start 依次尝试providers，返回第一个成功开始执行的响应、对应的provider以及之后还可以尝试的provider
+func (f *fallbackCode) start(ctx context.Context, message string, providers []string) (*Response, string, []string, error) {
+	var lastErr error
+	for i, provider := range providers {
+		c, err := f.session(provider)
+		if err != nil {
+			log.Warnf("Failed to start provider %s, trying next provider: %v", provider, err)
+			f.markFailed(provider)
+			lastErr = err
+			continue
+		}
+
+		resp, err := c.Prompt(ctx, message)
+		if err == nil {
+			f.clearFailed(provider)
+			return resp, provider, providers[i+1:], nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", nil, err
+		}
+
+		class := ClassifyError(err)
+		if !class.Failover() {
+			return nil, "", nil, err
+		}
+		log.Warnf("Provider %s failed (%s), trying next provider: %v", provider, class, err)
+		f.markFailed(provider)
+		lastErr = err
+	}
+	return nil, "", nil, fmt.Errorf("all providers failed (%s): %w", strings.Join(providers, ", "), lastErr)
+}
+
+// failoverReader 读取当前provider的输出。调用方还没有读到任何输出、provider也还没有调用过工具时，
+// 输出流以可切换的错误（限流、认证失败、进程异常退出）结束则改用下一个provider重新执行
+type failoverReader struct {
+	f       *fallbackCode
+	ctx     context.Context
+	message string
+
+	resp     *Response    // 返回给调用方的响应，切换provider时更新其 Provider 和 FallbackFrom
+	events   *eventReader // 汇总各provider的事件，调用方通过 resp.OnEvent 注册的回调挂在这里
+	current  *Response
+	provider string
+	rest     []string // 之后还可以尝试的provider
+
+	consumed bool // 调用方已读到输出或provider已调用工具，此后不再切换
+}
+
+// attach 切换到provider的响应，并把其事件转发给调用方
+func (r *failoverReader) attach(resp *Response, provider string, rest []string) {
+	r.current, r.provider, r.rest = resp, provider, rest
+	r.resp.Provider = provider
+	r.resp.FallbackFrom = ""
+	if provider != r.f.providers[0] {
+		r.resp.FallbackFrom = r.f.providers[0]
+	}
+	resp.OnEvent(func(event Event) {
+		// 工具调用可能已经修改了工作区或执行了提交、推送，换provider重新执行会重复这些副作用
+		if event.Type == EventToolCall || event.Type == EventFileEdit {
+			r.consumed = true
+		}
+		r.events.emit(event)
+	})
+}
+
+func (r *failoverReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.current.Out.Read(p)
+		if n > 0 {
+			r.consumed = true
+			return n, err
+		}
+		if err == nil || errors.Is(err, io.EOF) || r.consumed || len(r.rest) == 0 || r.ctx.Err() != nil {
+			return n, err
+		}
+
+		class := ClassifyError(err)
+		if !class.Failover() {
+			return n, err
+		}
+		log.Warnf("Provider %s failed before producing output (%s), trying next provider: %v", r.provider, class, err)
+		r.f.markFailed(r.provider)
+
+		resp, provider, rest, startErr := r.f.start(r.ctx, r.message, r.rest)
+		if startErr != nil {
+			return 0, errors.Join(err, startErr)
+		}
+		r.attach(resp, provider, rest)
+	}
+}
+
+// candidates 返回本次调用要尝试的provider
+func (f *fallbackCode) candidates() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var candidates []string
+	for _, provider := range f.providers {
+		if failedAt, ok := f.failedAt[provider]; ok && f.now().Sub(failedAt) < failoverCooldown {
+			continue
+		}
+		candidates = append(candidates, provider)
+	}
+	if len(candidates) == 0 {
+		return f.providers
+	}
+	return candidates
+}
+
+// session 获取或创建provider的会话
+func (f *fallbackCode) session(provider string) (Code, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if c, ok := f.sessions[provider]; ok && !isClosed(c) {
+		return c, nil
+	}
+	c, err := f.create(provider)
+	if err != nil {
+		return nil, err
+	}
+	f.sessions[provider] = c
+	return c, nil
+}
+
+func (f *fallbackCode) markFailed(provider string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failedAt[provider] = f.now()
+}
+
+func (f *fallbackCode) clearFailed(provider string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.failedAt, provider)
+}
+
+// IsClosed 主provider的会话被关闭（例如任务被取消）时，整个链需要重新创建
+func (f *fallbackCode) IsClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.sessions[f.providers[0]]
+	return ok && isClosed(c)
+}
+
+// Close 关闭链上已创建的所有会话
+func (f *fallbackCode) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var errs []error
+	for provider, c := range f.sessions {
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s session: %w", provider, err))
+		}
+		delete(f.sessions, provider)
+	}
+	return errors.Join(errs...)
+}
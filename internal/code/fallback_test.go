@@ -0,0 +1,245 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubCode 返回固定输出或错误，并记录调用次数；stream 非空时按 stream-json 解析输出
+type stubCode struct {
+	output    string
+	stream    string
+	streamErr error
+	err       error
+	calls     int
+	closed    bool
+}
+
+func (s *stubCode) Prompt(ctx context.Context, message string) (*Response, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.stream != "" || s.streamErr != nil {
+		pr, pw := io.Pipe()
+		go func() {
+			io.WriteString(pw, s.stream)
+			pw.CloseWithError(s.streamErr)
+		}()
+		return newStreamJSONResponse(pr), nil
+	}
+	return NewTextResponse(strings.NewReader(s.output)), nil
+}
+
+func (s *stubCode) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ""},
+		{newTimeoutError(ProviderClaude, time.Minute, nil), ErrorClassTimeout},
+		{fmt.Errorf("wrapped: %w", context.Canceled), ErrorClassCanceled},
+		{errors.New("Claude AI usage limit reached|1750000000"), ErrorClassRateLimit},
+		{errors.New("chat completions returned status 429: slow down"), ErrorClassRateLimit},
+		{errors.New("RESOURCE_EXHAUSTED: quota exceeded"), ErrorClassRateLimit},
+		{errors.New("chat completions returned status 401: bad key"), ErrorClassAuth},
+		{errors.New("claude API error - please check CLAUDE_API_KEY: authentication failed"), ErrorClassAuth},
+		{errors.New("claude CLI not available: exec: \"claude\": executable file not found in $PATH"), ErrorClassCrash},
+		{errors.New("write |1: broken pipe"), ErrorClassCrash},
+		{errors.New("no fake response matches prompt"), ErrorClassUnknown},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ClassifyError(tt.err), "%v", tt.err)
+	}
+
+	assert.True(t, ErrorClassRateLimit.Failover())
+	assert.True(t, ErrorClassAuth.Failover())
+	assert.True(t, ErrorClassCrash.Failover())
+	assert.False(t, ErrorClassTimeout.Failover())
+	assert.False(t, ErrorClassCanceled.Failover())
+	assert.False(t, ErrorClassUnknown.Failover())
+}
+
+func TestProviderChain(t *testing.T) {
+	assert.Equal(t, []string{"claude"}, ProviderChain("claude", nil))
+	assert.Equal(t, []string{"claude", "gemini", "openai"}, ProviderChain("claude", []string{"gemini", " claude", "", "openai", "gemini"}))
+}
+
+func newTestChain(t *testing.T, stubs map[string]*stubCode, providers ...string) *fallbackCode {
+	f, err := newFallbackCode(providers, func(provider string) (Code, error) {
+		stub, ok := stubs[provider]
+		if !ok {
+			return nil, fmt.Errorf("%s CLI not available", provider)
+		}
+		return stub, nil
+	})
+	require.NoError(t, err)
+	return f
+}
+
+func TestFallbackCode_FailsOver(t *testing.T) {
+	claude := &stubCode{err: errors.New("claude CLI execution failed: exit status 1, output: rate limit exceeded")}
+	gemini := &stubCode{output: "done"}
+	f := newTestChain(t, map[string]*stubCode{"claude": claude, "gemini": gemini}, "claude", "gemini")
+
+	resp, err := f.Prompt(context.Background(), "hi")
+	require.NoError(t, err)
+	out, _ := io.ReadAll(resp.Out)
+	assert.Equal(t, "done", string(out))
+	assert.Equal(t, "gemini", resp.Provider)
+	assert.Equal(t, "claude", resp.FallbackFrom)
+
+	// 冷却期内直接使用后备provider
+	resp, err = f.Prompt(context.Background(), "again")
+	require.NoError(t, err)
+	assert.Equal(t, 1, claude.calls)
+	assert.Equal(t, 2, gemini.calls)
+
+	// 冷却期过后重新优先尝试主provider
+	claude.err = nil
+	f.now = func() time.Time { return time.Now().Add(failoverCooldown) }
+	resp, err = f.Prompt(context.Background(), "later")
+	require.NoError(t, err)
+	assert.Equal(t, "claude", resp.Provider)
+	assert.Empty(t, resp.FallbackFrom)
+
+	require.NoError(t, f.Close())
+	assert.True(t, claude.closed)
+	assert.True(t, gemini.closed)
+}
+
+func TestFallbackCode_FailsOverOnStreamError(t *testing.T) {
+	claude := &stubCode{
+		stream:    `{"type":"result","is_error":true,"result":"Claude AI usage limit reached","usage":{"input_tokens":10}}` + "\n",
+		streamErr: errors.New("claude exited with error: exit status 1"),
+	}
+	gemini := &stubCode{output: "done"}
+	f := newTestChain(t, map[string]*stubCode{"claude": claude, "gemini": gemini}, "claude", "gemini")
+
+	resp, err := f.Prompt(context.Background(), "hi")
+	require.NoError(t, err)
+	var results []string
+	resp.OnEvent(func(event Event) {
+		if event.Type == EventResult {
+			results = append(results, resp.Provider)
+		}
+	})
+	assert.Equal(t, "claude", resp.Provider)
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "done", string(out))
+	assert.Equal(t, "gemini", resp.Provider)
+	assert.Equal(t, "claude", resp.FallbackFrom)
+	assert.Equal(t, []string{"claude", "gemini"}, results, "events of both attempts reach the caller")
+	require.NotNil(t, resp.Result())
+	assert.False(t, resp.Result().IsError)
+	assert.Equal(t, []string{"gemini"}, f.candidates())
+}
+
+func TestFallbackCode_DoesNotFailOverAfterOutput(t *testing.T) {
+	for name, claude := range map[string]*stubCode{
+		"unknown error": {stream: `{"type":"result","is_error":true,"result":"invalid prompt"}` + "\n"},
+		"file edited": {
+			stream:    `{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Write","input":{"file_path":"a.go"}}]}}` + "\n",
+			streamErr: errors.New("claude exited with error: exit status 1"),
+		},
+		"shell command run": {
+			stream:    `{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"git push origin HEAD"}}]}}` + "\n",
+			streamErr: errors.New("claude exited with error: exit status 1"),
+		},
+		"output read": {
+			stream:    `{"type":"result","result":"partial"}` + "\n",
+			streamErr: errors.New("claude exited with error: exit status 1"),
+		},
+	} {
+		gemini := &stubCode{output: "done"}
+		f := newTestChain(t, map[string]*stubCode{"claude": claude, "gemini": gemini}, "claude", "gemini")
+
+		resp, err := f.Prompt(context.Background(), "hi")
+		require.NoError(t, err, name)
+		_, err = io.ReadAll(resp.Out)
+		assert.Error(t, err, name)
+		assert.Zero(t, gemini.calls, name)
+		assert.Equal(t, "claude", resp.Provider, name)
+	}
+}
+
+func TestFallbackCode_DoesNotFailOverOnTimeoutOrUnknown(t *testing.T) {
+	for _, primaryErr := range []error{
+		newTimeoutError(ProviderClaude, time.Minute, []byte("partial")),
+		errors.New("invalid prompt"),
+	} {
+		gemini := &stubCode{output: "done"}
+		f := newTestChain(t, map[string]*stubCode{"claude": {err: primaryErr}, "gemini": gemini}, "claude", "gemini")
+
+		_, err := f.Prompt(context.Background(), "hi")
+		assert.ErrorIs(t, err, primaryErr)
+		assert.Zero(t, gemini.calls)
+	}
+}
+
+func TestFallbackCode_StartFailure(t *testing.T) {
+	gemini := &stubCode{output: "done"}
+	f := newTestChain(t, map[string]*stubCode{"gemini": gemini}, "claude", "gemini")
+
+	resp, err := f.Prompt(context.Background(), "hi")
+	require.NoError(t, err)
+	assert.Equal(t, "gemini", resp.Provider)
+
+	_, err = newFallbackCode([]string{"claude", "gemini"}, func(provider string) (Code, error) {
+		return nil, fmt.Errorf("%s CLI not available", provider)
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "claude: claude CLI not available; gemini: gemini CLI not available")
+}
+
+func TestFallbackCode_AllFail(t *testing.T) {
+	f := newTestChain(t, map[string]*stubCode{
+		"claude": {err: errors.New("status 429")},
+		"gemini": {err: errors.New("status 401")},
+	}, "claude", "gemini")
+
+	_, err := f.Prompt(context.Background(), "hi")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "all providers failed (claude, gemini)")
+	assert.Equal(t, ErrorClassAuth, ClassifyError(err))
+}
+
+func TestSessionManager_FallbackChain(t *testing.T) {
+	dir := t.TempDir()
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	require.NoError(t, os.WriteFile(fixture, []byte(`responses: [{output: "from fake"}]`), 0644))
+	cfg := &config.Config{
+		CodeProvider:      "unknown-provider",
+		FallbackProviders: []string{ProviderFake},
+		Fake:              config.FakeConfig{Fixture: fixture},
+	}
+	ws := &models.Workspace{Org: "qiniu", Repo: "codeagent", Path: dir}
+
+	c, err := NewSessionManager(cfg).GetSession(ws)
+	require.NoError(t, err)
+	resp, err := c.Prompt(context.Background(), "hi")
+	require.NoError(t, err)
+	assert.Equal(t, ProviderFake, resp.Provider)
+	assert.Equal(t, "unknown-provider", resp.FallbackFrom)
+	assert.Empty(t, ws.AIModel, "fallback must not modify the caller's workspace")
+}
@@ -2,11 +2,13 @@ package code
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/qiniu/codeagent/internal/config"
 	"github.com/qiniu/codeagent/pkg/models"
@@ -16,6 +18,8 @@ import (
 // geminiDocker Docker 实现（交互式模式）
 type geminiDocker struct {
 	containerName string
+	timeout       time.Duration
+	model         string
 }
 
 // getGoogleCloudProject 获取 Google Cloud 项目ID，优先使用配置文件中的值
@@ -39,6 +43,8 @@ func NewGeminiDocker(workspace *models.Workspace, cfg *config.Config) (Code, err
 		log.Infof("Found existing container: %s, reusing it", containerName)
 		return &geminiDocker{
 			containerName: containerName,
+			timeout:       cfg.Gemini.Timeout,
+			model:         workspace.Model,
 		}, nil
 	}
 
@@ -142,35 +148,43 @@ func NewGeminiDocker(workspace *models.Workspace, cfg *config.Config) (Code, err
 
 	return &geminiDocker{
 		containerName: containerName,
+		timeout:       cfg.Gemini.Timeout,
+		model:         workspace.Model,
 	}, nil
 }
 
 // Prompt 实现 Code 接口
-func (g *geminiDocker) Prompt(message string) (*Response, error) {
+func (g *geminiDocker) Prompt(ctx context.Context, message string) (*Response, error) {
 	args := []string{
 		"exec",
 		g.containerName,
 		"gemini",
-		"-y",
+	}
+	if !IsReadOnly(ctx) {
+		// 只读模式不自动批准，非交互模式下需要确认的写文件和执行命令工具不可用
+		args = append(args, "-y")
+	}
+	args = append(args,
+		"--output-format", "json", // 包含token用量统计
 		"-p",
 		message,
+	)
+	if g.model != "" {
+		args = append(args, "--model", g.model)
 	}
 
-	cmd := exec.Command("docker", args...)
-
 	log.Infof("Executing gemini CLI with docker: %s", strings.Join(args, " "))
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-
 	// 启动命令
-	if err := cmd.Start(); err != nil {
+	stdout, err := startProcess(ctx, ProviderGemini, g.timeout, func(ctx context.Context) *exec.Cmd {
+		return newDockerExecCommand(ctx, g.containerName, "gemini", args...)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to execute gemini: %w", err)
 	}
 
-	return &Response{Out: stdout}, nil
+	// JSON输出在结束时一次性给出，包含最终回复和token用量
+	return newGeminiJSONResponse(stdout), nil
 }
 
 // Close 实现 Code 接口
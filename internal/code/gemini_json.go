@@ -0,0 +1,125 @@
+package code
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// geminiJSONOutput gemini CLI `--output-format json` 的输出
+type geminiJSONOutput struct {
+	Response string `json:"response"`
+	Stats    *struct {
+		Models map[string]struct {
+			Tokens struct {
+				Prompt     int64 `json:"prompt"`
+				Candidates int64 `json:"candidates"`
+				Cached     int64 `json:"cached"`
+				Thoughts   int64 `json:"thoughts"`
+			} `json:"tokens"`
+		} `json:"models"`
+	} `json:"stats"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// newGeminiJSONResponse 解析 gemini CLI 的JSON输出，Out 只返回 response 文本，与文本输出模式一致；
+// 输出不是JSON时（不支持该参数的旧版本CLI）按文本模式处理
+func newGeminiJSONResponse(out io.Reader) *Response {
+	r := newEventReader(out, bufferLine)
+	r.flush = flushGeminiJSON
+	return &Response{Out: r, events: r}
+}
+
+// bufferLine 缓存原始输出，等待结束时整体解析
+func bufferLine(r *eventReader, line []byte) ([]Event, string) {
+	r.raw.Write(line)
+	return nil, ""
+}
+
+func flushGeminiJSON(r *eventReader) ([]Event, string) {
+	raw := r.raw.Bytes()
+	output, ok := parseGeminiJSON(raw)
+	if !ok {
+		var events []Event
+		var text strings.Builder
+		for _, line := range bytes.SplitAfter(raw, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			lineEvents, lineText := decodeTextLine(r, line)
+			events = append(events, lineEvents...)
+			text.WriteString(lineText)
+		}
+		return events, text.String()
+	}
+
+	result := Event{Type: EventResult, Text: output.Response, Usage: &Usage{}}
+	if output.Error != nil {
+		result.IsError = true
+		result.Text = output.Error.Message
+	}
+
+	// 多个模型参与时（例如路由到flash模型），用量合计，模型取用量最大的一个
+	var maxTokens int64 = -1
+	if output.Stats != nil {
+		for name, stats := range output.Stats.Models {
+			tokens := stats.Tokens
+			input := tokens.Prompt - tokens.Cached
+			if input < 0 {
+				input = 0
+			}
+			result.Usage.InputTokens += input
+			result.Usage.CacheReadInputTokens += tokens.Cached
+			result.Usage.OutputTokens += tokens.Candidates + tokens.Thoughts
+			if total := tokens.Prompt + tokens.Candidates; total > maxTokens {
+				maxTokens = total
+				result.Model = name
+			}
+		}
+	}
+	r.model = result.Model
+
+	var events []Event
+	if strings.TrimSpace(output.Response) != "" {
+		r.transcript.WriteString(output.Response)
+		r.transcript.WriteString("\n")
+		events = append(events, Event{Type: EventText, Text: output.Response})
+	}
+	events = append(events, result)
+
+	// 失败的结果作为错误返回，不透传给 Out
+	if result.IsError {
+		return events, ""
+	}
+	text := result.Text
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return events, text
+}
+
+// parseGeminiJSON 从输出中解析JSON结果，忽略JSON之前混入的日志行
+func parseGeminiJSON(raw []byte) (*geminiJSONOutput, bool) {
+	start := -1
+	if bytes.HasPrefix(raw, []byte("{")) {
+		start = 0
+	} else if i := bytes.Index(raw, []byte("\n{")); i >= 0 {
+		start = i + 1
+	}
+	if start < 0 {
+		return nil, false
+	}
+
+	var output geminiJSONOutput
+	if err := json.NewDecoder(bytes.NewReader(raw[start:])).Decode(&output); err != nil {
+		return nil, false
+	}
+	if output.Response == "" && output.Stats == nil && output.Error == nil {
+		return nil, false
+	}
+	return &output, true
+}
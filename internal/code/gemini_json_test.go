@@ -0,0 +1,64 @@
+package code
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const geminiJSONFixture = `Loaded cached credentials.
+{
+  "response": "## Summary\nDone",
+  "stats": {
+    "models": {
+      "gemini-2.5-pro": {"tokens": {"prompt": 1200, "candidates": 300, "cached": 200, "thoughts": 50}},
+      "gemini-2.5-flash": {"tokens": {"prompt": 100, "candidates": 10, "cached": 0, "thoughts": 0}}
+    }
+  }
+}
+`
+
+func TestGeminiJSONResponse_Usage(t *testing.T) {
+	resp := newGeminiJSONResponse(strings.NewReader(geminiJSONFixture))
+
+	var types []EventType
+	resp.OnEvent(func(e Event) { types = append(types, e.Type) })
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "## Summary\nDone\n", string(out))
+	assert.Equal(t, []EventType{EventText, EventResult}, types)
+
+	result := resp.Result()
+	require.NotNil(t, result)
+	assert.Equal(t, "gemini-2.5-pro", result.Model)
+	require.NotNil(t, result.Usage)
+	assert.EqualValues(t, 1100, result.Usage.InputTokens)
+	assert.EqualValues(t, 200, result.Usage.CacheReadInputTokens)
+	assert.EqualValues(t, 360, result.Usage.OutputTokens)
+}
+
+func TestGeminiJSONResponse_Error(t *testing.T) {
+	resp := newGeminiJSONResponse(strings.NewReader(`{"error":{"type":"ApiError","message":"quota exceeded"}}`))
+
+	out, err := io.ReadAll(resp.Out)
+	require.Error(t, err)
+	assert.Equal(t, ErrorClassRateLimit, ClassifyError(err))
+	assert.Empty(t, out)
+	require.NotNil(t, resp.Result())
+	assert.True(t, resp.Result().IsError)
+}
+
+func TestGeminiJSONResponse_PlainTextFallback(t *testing.T) {
+	// 不支持 --output-format 的旧版本CLI输出纯文本
+	resp := newGeminiJSONResponse(strings.NewReader("plain output\nsecond line\n"))
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "plain output\nsecond line\n", string(out))
+	require.NotNil(t, resp.Result())
+	assert.Nil(t, resp.Result().Usage)
+}
@@ -3,6 +3,7 @@ package code
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -43,25 +44,31 @@ func checkGeminiCLI() error {
 }
 
 // Prompt 实现 Code 接口 - 本地 CLI 版本
-func (g *geminiLocal) Prompt(message string) (*Response, error) {
+func (g *geminiLocal) Prompt(ctx context.Context, message string) (*Response, error) {
 	// 执行本地 gemini CLI 调用
-	output, err := g.executeGeminiLocal(message)
+	output, err := g.executeGeminiLocal(ctx, message)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute gemini prompt: %w", err)
 	}
 
 	// 返回结果
-	return &Response{
-		Out: bytes.NewReader(output),
-	}, nil
+	return newGeminiJSONResponse(bytes.NewReader(output)), nil
 }
 
 // executeGeminiLocal 执行本地 gemini CLI 调用
-func (g *geminiLocal) executeGeminiLocal(prompt string) ([]byte, error) {
+func (g *geminiLocal) executeGeminiLocal(ctx context.Context, prompt string) ([]byte, error) {
 	// 构建 gemini CLI 命令
-	args := []string{
-		"-y",
+	var args []string
+	if !IsReadOnly(ctx) {
+		// 只读模式不自动批准，非交互模式下需要确认的写文件和执行命令工具不可用
+		args = append(args, "-y")
+	}
+	args = append(args,
+		"--output-format", "json", // 包含token用量统计
 		"--prompt", prompt,
+	)
+	if g.workspace.Model != "" {
+		args = append(args, "--model", g.workspace.Model)
 	}
 
 	// 设置超时 - 使用配置中的超时时间，默认为 5 分钟
@@ -69,10 +76,11 @@ func (g *geminiLocal) executeGeminiLocal(prompt string) ([]byte, error) {
 	if timeout == 0 {
 		timeout = 5 * time.Minute
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "gemini", args...)
+	cmd.WaitDelay = processWaitDelay
 	cmd.Dir = g.workspace.Path // 设置工作目录，Gemini CLI 会自动读取该目录的文件作为上下文
 
 	// 设置环境变量
@@ -83,9 +91,13 @@ func (g *geminiLocal) executeGeminiLocal(prompt string) ([]byte, error) {
 	// 执行命令并获取输出
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			log.Infof("Gemini CLI execution cancelled")
+			return nil, fmt.Errorf("gemini CLI execution cancelled: %w", ctx.Err())
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			log.Warnf("Gemini CLI execution timed out after %s, this might be due to large codebase or complex task", timeout)
-			return nil, fmt.Errorf("gemini CLI execution timed out: %w", err)
+			return nil, newTimeoutError(ProviderGemini, timeout, output)
 		}
 
 		// 检查是否是 API 密钥相关错误
@@ -0,0 +1,53 @@
+package code
+
+import (
+	"context"
+
+	"github.com/qiniu/codeagent/internal/usage"
+	"github.com/qiniu/codeagent/pkg/models"
+)
+
+// meteredCode 把每次调用结果中的token用量记入ctx中的用量任务
+type meteredCode struct {
+	Code
+	provider string
+}
+
+func (m *meteredCode) Prompt(ctx context.Context, message string) (*Response, error) {
+	resp, err := m.Code.Prompt(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	task := usage.FromContext(ctx)
+	if task == nil {
+		return resp, nil
+	}
+	resp.OnEvent(func(event Event) {
+		if event.Type == EventResult && event.Usage != nil {
+			// 输出流失败后可能切换到后备provider，按产生事件时实际使用的provider记账
+			provider := resp.Provider
+			if provider == "" {
+				provider = m.provider
+			}
+			task.Add(provider, event.Model, event.Usage.TokenUsage())
+		}
+	})
+	return resp, nil
+}
+
+// IsClosed 透传被包装会话的关闭状态
+func (m *meteredCode) IsClosed() bool {
+	return isClosed(m.Code)
+}
+
+// TokenUsage 转换为可累加的用量
+func (u *Usage) TokenUsage() models.TokenUsage {
+	return models.TokenUsage{
+		InputTokens:         u.InputTokens,
+		OutputTokens:        u.OutputTokens,
+		CacheReadTokens:     u.CacheReadInputTokens,
+		CacheCreationTokens: u.CacheCreationInputTokens,
+		CostUSD:             u.CostUSD,
+	}
+}
@@ -0,0 +1,55 @@
+package code
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/usage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamCode 返回固定的 stream-json 输出
+type streamCode struct{ stubCode }
+
+func (s *streamCode) Prompt(ctx context.Context, message string) (*Response, error) {
+	return newStreamJSONResponse(strings.NewReader(s.output)), nil
+}
+
+func TestMeteredCode_RecordsUsage(t *testing.T) {
+	store, err := usage.NewStore(filepath.Join(t.TempDir(), "usage.jsonl"), config.UsageConfig{})
+	require.NoError(t, err)
+	task := store.NewTask(usage.TaskInfo{Repo: "owner/repo", Number: 1, User: "alice", Command: "/code"})
+	ctx := usage.NewContext(context.Background(), task)
+
+	c := &meteredCode{Code: &streamCode{stubCode{output: claudeStreamFixture}}, provider: ProviderClaude}
+	for i := 0; i < 2; i++ {
+		resp, err := c.Prompt(ctx, "implement it")
+		require.NoError(t, err)
+		_, err = io.ReadAll(resp.Out)
+		require.NoError(t, err)
+	}
+
+	total := task.Usage()
+	assert.Equal(t, 2, total.Calls)
+	assert.EqualValues(t, 200, total.InputTokens)
+	assert.EqualValues(t, 40, total.OutputTokens)
+	assert.InDelta(t, 0.5, total.CostUSD, 1e-9)
+
+	summary, err := store.Summarize(usage.Filter{}, usage.GroupByProvider)
+	require.NoError(t, err)
+	require.Len(t, summary.Groups, 1)
+	assert.Equal(t, ProviderClaude, summary.Groups[0].Key)
+
+	// ctx中没有用量任务时不记录
+	resp, err := c.Prompt(context.Background(), "implement it")
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, 2, task.Usage().Calls)
+}
@@ -0,0 +1,400 @@
+package code
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/mcp"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/qiniu/x/log"
+)
+
+const (
+	defaultOpenAIBaseURL  = "https://api.openai.com/v1"
+	defaultOpenAIMaxTurns = 50
+	// maxChatResponseSize chat completions 响应体的读取上限
+	maxChatResponseSize = 32 << 20
+)
+
+const openAISystemPrompt = `You are a software engineering agent working inside a git repository.
+Use the provided tools to inspect files, edit code and run commands. All paths are relative to the repository root.
+Keep changes focused on the task. When you are done, reply with your final answer without calling any tools.`
+
+// openAICompatible 通过 chat completions 协议调用OpenAI兼容接口（OpenAI、vLLM等），
+// 在进程内执行工作区工具和MCP工具
+type openAICompatible struct {
+	workspace *models.Workspace
+	client    *http.Client
+	baseURL   string
+	apiKey    string
+	model     string
+	timeout   time.Duration
+	maxTurns  int
+	tools     *workspaceTools
+	mcpClient mcp.MCPClient
+}
+
+// chatMessage chat completions 的消息
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// chatToolCall 模型返回的工具调用，参数为JSON字符串
+type chatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatTool 提供给模型的工具定义
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Parameters  *models.JSONSchema `json:"parameters"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Tools    []chatTool    `json:"tools,omitempty"`
+}
+
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// NewOpenAI 创建OpenAI兼容接口的实现，mcpClient 为nil时只提供工作区工具
+func NewOpenAI(workspace *models.Workspace, cfg *config.Config, mcpClient mcp.MCPClient) (Code, error) {
+	model := cfg.OpenAI.Model
+	if workspace.Model != "" {
+		// 仓库配置覆盖的模型
+		model = workspace.Model
+	}
+	if model == "" {
+		return nil, fmt.Errorf("openai model is not configured")
+	}
+	if workspace.Path == "" {
+		return nil, fmt.Errorf("workspace path is required for openai provider")
+	}
+
+	baseURL := cfg.OpenAI.BaseURL
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	maxTurns := cfg.OpenAI.MaxTurns
+	if maxTurns <= 0 {
+		maxTurns = defaultOpenAIMaxTurns
+	}
+
+	root, err := filepath.Abs(workspace.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute workspace path: %w", err)
+	}
+	tools := &workspaceTools{root: root, shell: cfg.OpenAI.ShellTool}
+	if tools.shell && cfg.UseDocker {
+		tools.shellImage, tools.network = cfg.OpenAI.ShellImage, cfg.Docker.Network
+	}
+
+	return &openAICompatible{
+		workspace: workspace,
+		client:    &http.Client{},
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		apiKey:    cfg.OpenAI.APIKey,
+		model:     model,
+		timeout:   cfg.OpenAI.Timeout,
+		maxTurns:  maxTurns,
+		tools:     tools,
+		mcpClient: mcpClient,
+	}, nil
+}
+
+// Prompt 在后台运行 agent 循环，事件在读取 Out 时同步分发
+func (o *openAICompatible) Prompt(ctx context.Context, message string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tools := o.prepareTools(ctx)
+	pr, pw := io.Pipe()
+
+	go func() {
+		ctx, cancel, timeout := withPromptTimeout(ctx, o.timeout)
+		defer cancel()
+
+		emit := func(event Event) {
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Warnf("Failed to encode openai event: %v", err)
+				return
+			}
+			// 调用方不再读取输出时终止循环
+			if _, err := pw.Write(append(data, '\n')); err != nil {
+				cancel()
+			}
+		}
+
+		err := o.run(ctx, message, tools, emit)
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			err = newTimeoutError(ProviderOpenAI, timeout, nil)
+		case ctx.Err() != nil:
+			err = ctx.Err()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return newEventStreamResponse(pr), nil
+}
+
+// run 循环调用模型并执行工具，直到模型不再调用工具
+func (o *openAICompatible) run(ctx context.Context, message string, tools []chatTool, emit func(Event)) error {
+	start := time.Now()
+	messages := []chatMessage{
+		{Role: "system", Content: openAISystemPrompt},
+		{Role: "user", Content: message},
+	}
+	usage := &Usage{}
+	model := o.model
+
+	for turn := 0; turn < o.maxTurns; turn++ {
+		resp, err := o.complete(ctx, messages, tools)
+		if err != nil {
+			return err
+		}
+		if resp.Model != "" {
+			model = resp.Model
+		}
+		if resp.Usage != nil {
+			usage.InputTokens += resp.Usage.PromptTokens
+			usage.OutputTokens += resp.Usage.CompletionTokens
+		}
+		if len(resp.Choices) == 0 {
+			return fmt.Errorf("chat completions response has no choices")
+		}
+
+		reply := resp.Choices[0].Message
+		reply.Role = "assistant"
+		messages = append(messages, reply)
+
+		if strings.TrimSpace(reply.Content) != "" {
+			emit(Event{Type: EventText, Text: reply.Content})
+		}
+		if len(reply.ToolCalls) == 0 {
+			emit(Event{Type: EventResult, Text: reply.Content, Model: model, Usage: usage, Duration: time.Since(start)})
+			return nil
+		}
+
+		for _, call := range reply.ToolCalls {
+			result := o.callTool(ctx, call, emit)
+			messages = append(messages, chatMessage{Role: "tool", ToolCallID: call.ID, Content: result})
+		}
+	}
+
+	return fmt.Errorf("openai agent loop exceeded %d turns", o.maxTurns)
+}
+
+// complete 发送一次 chat completions 请求
+func (o *openAICompatible) complete(ctx context.Context, messages []chatMessage, tools []chatTool) (*chatResponse, error) {
+	body, err := json.Marshal(chatRequest{Model: o.model, Messages: messages, Tools: tools})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode chat request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call chat completions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxChatResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chat response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chat completions returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var chatResp chatResponse
+	if err := json.Unmarshal(data, &chatResp); err != nil {
+		return nil, fmt.Errorf("failed to decode chat response: %w", err)
+	}
+	return &chatResp, nil
+}
+
+// callTool 执行一次工具调用并产生对应事件，返回交给模型的结果文本
+func (o *openAICompatible) callTool(ctx context.Context, call chatToolCall, emit func(Event)) string {
+	name := call.Function.Name
+	args := map[string]interface{}{}
+	if strings.TrimSpace(call.Function.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+			emit(Event{Type: EventToolCall, ToolName: name, ToolID: call.ID})
+			return o.toolResult(call, fmt.Sprintf("invalid tool arguments: %v", err), true, emit)
+		}
+	}
+
+	emit(Event{Type: EventToolCall, ToolName: name, ToolID: call.ID, Input: args})
+	if IsReadOnly(ctx) && !readOnlyWorkspaceTools[name] {
+		return o.toolResult(call, fmt.Sprintf("tool %s is not available in read-only mode", name), true, emit)
+	}
+	if key, ok := fileEditTools[name]; ok {
+		if path, _ := args[key].(string); path != "" {
+			emit(Event{Type: EventFileEdit, ToolName: name, ToolID: call.ID, FilePath: path})
+		}
+	}
+
+	if o.tools.has(name) {
+		output, err := o.tools.call(ctx, name, args)
+		if err != nil {
+			return o.toolResult(call, err.Error(), true, emit)
+		}
+		return o.toolResult(call, output, false, emit)
+	}
+
+	if o.mcpClient == nil {
+		return o.toolResult(call, fmt.Sprintf("unknown tool: %s", name), true, emit)
+	}
+	results, err := o.mcpClient.ExecuteToolCalls(ctx, []*models.ToolCall{{
+		ID:       models.MCPID{Value: call.ID},
+		Function: models.ToolFunction{Name: name, Arguments: args},
+	}}, o.mcpContext())
+	if err != nil {
+		return o.toolResult(call, err.Error(), true, emit)
+	}
+	if len(results) == 0 {
+		return o.toolResult(call, "tool returned no result", true, emit)
+	}
+	if !results[0].Success {
+		return o.toolResult(call, results[0].Error, true, emit)
+	}
+	return o.toolResult(call, mcpContentText(results[0].Content), false, emit)
+}
+
+func (o *openAICompatible) toolResult(call chatToolCall, text string, isError bool, emit func(Event)) string {
+	emit(Event{Type: EventToolResult, ToolID: call.ID, Text: text, IsError: isError})
+	if isError {
+		return "Error: " + text
+	}
+	return text
+}
+
+// prepareTools 合并工作区工具与MCP工具，只读模式下只提供读取文件和列出目录的工具
+func (o *openAICompatible) prepareTools(ctx context.Context) []chatTool {
+	tools := o.tools.definitions()
+	if IsReadOnly(ctx) {
+		readOnly := tools[:0]
+		for _, tool := range tools {
+			if readOnlyWorkspaceTools[tool.Function.Name] {
+				readOnly = append(readOnly, tool)
+			}
+		}
+		return readOnly
+	}
+	if o.mcpClient == nil {
+		return tools
+	}
+
+	mcpTools, err := o.mcpClient.PrepareTools(ctx, o.mcpContext())
+	if err != nil {
+		log.Warnf("Failed to prepare MCP tools for openai provider: %v", err)
+		return tools
+	}
+	for _, tool := range mcpTools {
+		schema := tool.InputSchema
+		if schema == nil {
+			schema = &models.JSONSchema{Type: "object"}
+		}
+		tools = append(tools, chatTool{
+			Type:     "function",
+			Function: chatFunction{Name: tool.Name, Description: tool.Description, Parameters: schema},
+		})
+	}
+	return tools
+}
+
+// mcpContext 构建当前工作区对应的MCP上下文
+func (o *openAICompatible) mcpContext() *models.MCPContext {
+	ws := o.workspace
+	mcpCtx := &models.MCPContext{
+		Repository: &models.IssueCommentContext{
+			BaseContext: models.BaseContext{
+				Repository: &github.Repository{
+					Name:     github.String(ws.Repo),
+					FullName: github.String(ws.Org + "/" + ws.Repo),
+					Owner: &github.User{
+						Login: github.String(ws.Org),
+					},
+				},
+			},
+		},
+		WorkspacePath: ws.Path,
+		BranchName:    ws.Branch,
+		Permissions:   []string{"github:read", "github:write"},
+		Constraints:   []string{},
+	}
+	if ws.Issue != nil {
+		mcpCtx.Issue = ws.Issue
+	}
+	if ws.PullRequest != nil {
+		mcpCtx.PullRequest = ws.PullRequest
+	}
+	return mcpCtx
+}
+
+// mcpContentText 将MCP工具结果转换为文本
+func mcpContentText(content interface{}) string {
+	switch v := content.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
+
+func (o *openAICompatible) Close() error {
+	return nil
+}
@@ -0,0 +1,333 @@
+package code
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubChatServer 按顺序返回预设的 chat completions 响应，并记录收到的请求
+type stubChatServer struct {
+	mu        sync.Mutex
+	responses []string
+	requests  []chatRequest
+	headers   []http.Header
+}
+
+func (s *stubChatServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path != "/v1/chat/completions" {
+		http.NotFound(w, r)
+		return
+	}
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.requests = append(s.requests, req)
+	s.headers = append(s.headers, r.Header.Clone())
+
+	if len(s.responses) == 0 {
+		http.Error(w, `{"error":{"message":"no more responses"}}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, s.responses[0])
+	s.responses = s.responses[1:]
+}
+
+func toolCallResponse(id, name string, args map[string]interface{}) string {
+	data, _ := json.Marshal(args)
+	return fmt.Sprintf(`{"model":"stub-model","choices":[{"finish_reason":"tool_calls","message":{"role":"assistant","content":null,"tool_calls":[{"id":%q,"type":"function","function":{"name":%q,"arguments":%q}}]}}],"usage":{"prompt_tokens":10,"completion_tokens":5}}`,
+		id, name, string(data))
+}
+
+func finalResponse(content string) string {
+	return fmt.Sprintf(`{"model":"stub-model","choices":[{"finish_reason":"stop","message":{"role":"assistant","content":%q}}],"usage":{"prompt_tokens":20,"completion_tokens":7}}`, content)
+}
+
+func newTestOpenAI(t *testing.T, serverURL string, mcpClient *fakeMCPClient) (*openAICompatible, string) {
+	dir := t.TempDir()
+	cfg := &config.Config{OpenAI: config.OpenAIConfig{
+		APIKey:    "test-key",
+		BaseURL:   serverURL + "/v1",
+		Model:     "stub-model",
+		Timeout:   time.Minute,
+		ShellTool: true,
+	}}
+	ws := &models.Workspace{Org: "qiniu", Repo: "codeagent", Path: dir}
+
+	var c Code
+	var err error
+	if mcpClient != nil {
+		c, err = NewOpenAI(ws, cfg, mcpClient)
+	} else {
+		c, err = NewOpenAI(ws, cfg, nil)
+	}
+	require.NoError(t, err)
+	return c.(*openAICompatible), dir
+}
+
+func TestOpenAI_AgentLoop(t *testing.T) {
+	stub := &stubChatServer{responses: []string{
+		toolCallResponse("call_1", "write_file", map[string]interface{}{"path": "pkg/hello.txt", "content": "hello"}),
+		toolCallResponse("call_2", "edit_file", map[string]interface{}{"path": "pkg/hello.txt", "old_string": "hello", "new_string": "hello world"}),
+		toolCallResponse("call_3", "run_shell", map[string]interface{}{"command": "cat pkg/hello.txt"}),
+		finalResponse("## Summary\nAdded hello.txt"),
+	}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider, dir := newTestOpenAI(t, server.URL, nil)
+	resp, err := provider.Prompt(context.Background(), "add a greeting")
+	require.NoError(t, err)
+
+	var events []Event
+	resp.OnEvent(func(e Event) { events = append(events, e) })
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "## Summary\nAdded hello.txt\n", string(out))
+
+	data, err := os.ReadFile(filepath.Join(dir, "pkg", "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []EventType{
+		EventToolCall, EventFileEdit, EventToolResult,
+		EventToolCall, EventFileEdit, EventToolResult,
+		EventToolCall, EventToolResult,
+		EventText, EventResult,
+	}, types)
+	assert.Equal(t, "pkg/hello.txt", events[1].FilePath)
+	assert.Equal(t, "hello world", events[7].Text)
+
+	result := resp.Result()
+	require.NotNil(t, result)
+	assert.Equal(t, "stub-model", result.Model)
+	require.NotNil(t, result.Usage)
+	assert.EqualValues(t, 50, result.Usage.InputTokens)
+	assert.EqualValues(t, 22, result.Usage.OutputTokens)
+
+	require.Len(t, stub.requests, 4)
+	assert.Equal(t, "Bearer test-key", stub.headers[0].Get("Authorization"))
+	assert.Equal(t, "stub-model", stub.requests[0].Model)
+	assert.Len(t, stub.requests[0].Tools, 5)
+	// 工具结果按 tool_call_id 回传给模型
+	last := stub.requests[1].Messages[len(stub.requests[1].Messages)-1]
+	assert.Equal(t, "tool", last.Role)
+	assert.Equal(t, "call_1", last.ToolCallID)
+}
+
+func TestOpenAI_ToolErrorsAreReturnedToModel(t *testing.T) {
+	stub := &stubChatServer{responses: []string{
+		toolCallResponse("call_1", "read_file", map[string]interface{}{"path": "../../etc/passwd"}),
+		finalResponse("cannot read"),
+	}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider, _ := newTestOpenAI(t, server.URL, nil)
+	resp, err := provider.Prompt(context.Background(), "read it")
+	require.NoError(t, err)
+
+	var toolResult Event
+	resp.OnEvent(func(e Event) {
+		if e.Type == EventToolResult {
+			toolResult = e
+		}
+	})
+	_, err = io.ReadAll(resp.Out)
+	require.NoError(t, err)
+
+	assert.True(t, toolResult.IsError)
+	assert.Contains(t, toolResult.Text, "outside the workspace")
+	last := stub.requests[1].Messages[len(stub.requests[1].Messages)-1]
+	assert.Contains(t, last.Content, "Error: ")
+}
+
+func TestOpenAI_ReadOnly(t *testing.T) {
+	stub := &stubChatServer{responses: []string{
+		toolCallResponse("call_1", "write_file", map[string]interface{}{"path": "plan.md", "content": "plan"}),
+		finalResponse("## Approach\nEdit main.go"),
+	}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider, dir := newTestOpenAI(t, server.URL, &fakeMCPClient{})
+	resp, err := provider.Prompt(WithReadOnly(context.Background()), "plan it")
+	require.NoError(t, err)
+
+	var toolResult Event
+	resp.OnEvent(func(e Event) {
+		if e.Type == EventToolResult {
+			toolResult = e
+		}
+	})
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "## Approach\nEdit main.go\n", string(out))
+
+	// 只提供读取工具，也不加载MCP工具
+	var names []string
+	for _, tool := range stub.requests[0].Tools {
+		names = append(names, tool.Function.Name)
+	}
+	assert.Equal(t, []string{"read_file", "list_files"}, names)
+	assert.True(t, toolResult.IsError)
+	assert.Contains(t, toolResult.Text, "not available in read-only mode")
+	assert.NoFileExists(t, filepath.Join(dir, "plan.md"))
+}
+
+func TestOpenAI_HTTPError(t *testing.T) {
+	server := httptest.NewServer(&stubChatServer{})
+	defer server.Close()
+
+	provider, _ := newTestOpenAI(t, server.URL, nil)
+	resp, err := provider.Prompt(context.Background(), "hi")
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(resp.Out)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 500")
+}
+
+func TestOpenAI_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	provider, _ := newTestOpenAI(t, server.URL, nil)
+	provider.timeout = 200 * time.Millisecond
+
+	resp, err := provider.Prompt(context.Background(), "hi")
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(resp.Out)
+	timeoutErr, ok := AsTimeoutError(err)
+	require.True(t, ok, "expected timeout error, got %v", err)
+	assert.Equal(t, ProviderOpenAI, timeoutErr.Provider)
+}
+
+// fakeMCPClient 提供一个固定的MCP工具
+type fakeMCPClient struct {
+	calls []*models.ToolCall
+}
+
+func (f *fakeMCPClient) PrepareTools(ctx context.Context, mcpCtx *models.MCPContext) ([]models.Tool, error) {
+	return []models.Tool{{Name: "github-comments__create_comment", Description: "Create a comment"}}, nil
+}
+
+func (f *fakeMCPClient) ExecuteToolCalls(ctx context.Context, calls []*models.ToolCall, mcpCtx *models.MCPContext) ([]*models.ToolResult, error) {
+	f.calls = append(f.calls, calls...)
+	return []*models.ToolResult{{ID: calls[0].ID, Success: true, Content: map[string]interface{}{"id": 1}}}, nil
+}
+
+func (f *fakeMCPClient) BuildPrompt(ctx context.Context, userPrompt string, mcpCtx *models.MCPContext) (string, error) {
+	return userPrompt, nil
+}
+
+func TestOpenAI_MCPTools(t *testing.T) {
+	stub := &stubChatServer{responses: []string{
+		toolCallResponse("call_1", "github-comments__create_comment", map[string]interface{}{"issue_number": 1, "body": "hi"}),
+		finalResponse("commented"),
+	}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	mcpClient := &fakeMCPClient{}
+	provider, _ := newTestOpenAI(t, server.URL, mcpClient)
+	resp, err := provider.Prompt(context.Background(), "comment")
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Out)
+	require.NoError(t, err)
+
+	var names []string
+	for _, tool := range stub.requests[0].Tools {
+		names = append(names, tool.Function.Name)
+	}
+	assert.Contains(t, names, "github-comments__create_comment")
+
+	require.Len(t, mcpClient.calls, 1)
+	assert.Equal(t, "hi", mcpClient.calls[0].Function.Arguments["body"])
+	last := stub.requests[1].Messages[len(stub.requests[1].Messages)-1]
+	assert.Equal(t, `{"id":1}`, last.Content)
+}
+
+func TestWorkspaceTools_Resolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "link")))
+	tools := &workspaceTools{root: root}
+
+	path, err := tools.resolve("src/new.go")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "src", "new.go"), path)
+
+	_, err = tools.resolve("../escape")
+	assert.Error(t, err)
+	_, err = tools.resolve("/etc/passwd")
+	assert.Error(t, err)
+	_, err = tools.resolve("link/file")
+	assert.Error(t, err)
+}
+
+func TestWorkspaceTools_ResolveRejectsGitDir(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git", "hooks"), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(root, ".git"), filepath.Join(root, "meta")))
+	tools := &workspaceTools{root: root}
+
+	for _, path := range []string{".git", ".git/hooks/pre-commit", "./.git/config", "src/../.git/config", filepath.Join(root, ".git", "config"), "meta/hooks/pre-push"} {
+		_, err := tools.resolve(path)
+		assert.ErrorContains(t, err, ".git directory", path)
+	}
+
+	// 名称以 .git 开头的普通文件不受影响
+	_, err := tools.resolve(".gitignore")
+	assert.NoError(t, err)
+	_, err = tools.resolve(".github/workflows/ci.yml")
+	assert.NoError(t, err)
+}
+
+func TestWorkspaceTools_Shell(t *testing.T) {
+	root := t.TempDir()
+	tools := &workspaceTools{root: root}
+	assert.False(t, tools.has("run_shell"), "run_shell is opt-in")
+	assert.Len(t, tools.definitions(), 4)
+
+	// 宿主机上执行时不泄露服务的密钥
+	t.Setenv("OPENAI_API_KEY", "secret")
+	tools.shell = true
+	assert.True(t, tools.has("run_shell"))
+	output, err := tools.call(context.Background(), "run_shell", map[string]interface{}{"command": "pwd; echo \"key=$OPENAI_API_KEY\""})
+	require.NoError(t, err)
+	assert.Equal(t, root+"\nkey=\n", output)
+}
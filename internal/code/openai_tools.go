@@ -0,0 +1,349 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qiniu/codeagent/pkg/models"
+	"github.com/qiniu/x/log"
+)
+
+const (
+	// maxToolOutput 单次工具调用返回给模型的输出上限
+	maxToolOutput = 32 * 1024
+	// shellToolTimeout 单条命令的最长执行时间
+	shellToolTimeout = 10 * time.Minute
+)
+
+// shellEnv 在宿主机上执行 run_shell 时保留的环境变量，其余（例如各类API密钥）不传给命令
+var shellEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TZ", "TMPDIR", "TERM"}
+
+// workspaceTools 限定在工作区目录内的文件与命令工具
+type workspaceTools struct {
+	root string
+	// shell 是否提供 run_shell 工具
+	shell bool
+	// shellImage 非空时 run_shell 在挂载工作区的一次性容器中执行，而不是宿主机
+	shellImage string
+	network    string
+}
+
+// definitions 返回工作区工具的定义
+func (t *workspaceTools) definitions() []chatTool {
+	str := func(description string) *models.JSONSchema {
+		return &models.JSONSchema{Type: "string", Description: description}
+	}
+	tool := func(name, description string, properties map[string]*models.JSONSchema, required ...string) chatTool {
+		return chatTool{
+			Type: "function",
+			Function: chatFunction{
+				Name:        name,
+				Description: description,
+				Parameters:  &models.JSONSchema{Type: "object", Properties: properties, Required: required},
+			},
+		}
+	}
+
+	tools := []chatTool{
+		tool("read_file", "Read a file in the repository.",
+			map[string]*models.JSONSchema{"path": str("File path relative to the repository root")}, "path"),
+		tool("write_file", "Create or overwrite a file in the repository.",
+			map[string]*models.JSONSchema{
+				"path":    str("File path relative to the repository root"),
+				"content": str("Full content of the file"),
+			}, "path", "content"),
+		tool("edit_file", "Replace an exact string in a file. old_string must match exactly once unless replace_all is true.",
+			map[string]*models.JSONSchema{
+				"path":        str("File path relative to the repository root"),
+				"old_string":  str("Exact text to replace"),
+				"new_string":  str("Replacement text"),
+				"replace_all": {Type: "boolean", Description: "Replace every occurrence"},
+			}, "path", "old_string", "new_string"),
+		tool("list_files", "List the entries of a directory in the repository.",
+			map[string]*models.JSONSchema{"path": str("Directory path relative to the repository root, defaults to the root")}),
+	}
+	if t.shell {
+		tools = append(tools, tool("run_shell", "Run a shell command from the repository root and return its combined output.",
+			map[string]*models.JSONSchema{"command": str("Command to run with sh -c")}, "command"))
+	}
+	return tools
+}
+
+// has 判断是否为工作区工具
+func (t *workspaceTools) has(name string) bool {
+	switch name {
+	case "read_file", "write_file", "edit_file", "list_files":
+		return true
+	case "run_shell":
+		return t.shell
+	}
+	return false
+}
+
+// call 执行工作区工具
+func (t *workspaceTools) call(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	switch name {
+	case "read_file":
+		return t.readFile(args)
+	case "write_file":
+		return t.writeFile(args)
+	case "edit_file":
+		return t.editFile(args)
+	case "list_files":
+		return t.listFiles(args)
+	case "run_shell":
+		return t.runShell(ctx, args)
+	default:
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+func (t *workspaceTools) readFile(args map[string]interface{}) (string, error) {
+	path, err := t.pathArg(args, "path")
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) > maxToolOutput {
+		return fmt.Sprintf("%s\n... (truncated, %d bytes total)", data[:maxToolOutput], len(data)), nil
+	}
+	return string(data), nil
+}
+
+func (t *workspaceTools) writeFile(args map[string]interface{}) (string, error) {
+	path, err := t.pathArg(args, "path")
+	if err != nil {
+		return "", err
+	}
+	content, ok := args["content"].(string)
+	if !ok {
+		return "", fmt.Errorf("missing required argument: content")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return fmt.Sprintf("Wrote %d bytes to %s", len(content), args["path"]), nil
+}
+
+func (t *workspaceTools) editFile(args map[string]interface{}) (string, error) {
+	path, err := t.pathArg(args, "path")
+	if err != nil {
+		return "", err
+	}
+	oldString, _ := args["old_string"].(string)
+	newString, _ := args["new_string"].(string)
+	replaceAll, _ := args["replace_all"].(bool)
+	if oldString == "" {
+		return "", fmt.Errorf("missing required argument: old_string")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	content := string(data)
+	count := strings.Count(content, oldString)
+	switch {
+	case count == 0:
+		return "", fmt.Errorf("old_string not found in %s", args["path"])
+	case count > 1 && !replaceAll:
+		return "", fmt.Errorf("old_string matches %d times in %s; add more context or set replace_all", count, args["path"])
+	}
+
+	if replaceAll {
+		content = strings.ReplaceAll(content, oldString, newString)
+	} else {
+		content = strings.Replace(content, oldString, newString, 1)
+	}
+	if err := os.WriteFile(path, []byte(content), info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return fmt.Sprintf("Replaced %d occurrence(s) in %s", count, args["path"]), nil
+}
+
+func (t *workspaceTools) listFiles(args map[string]interface{}) (string, error) {
+	if _, ok := args["path"]; !ok {
+		args["path"] = "."
+	}
+	path, err := t.pathArg(args, "path")
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to list directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			continue
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "\n"), nil
+}
+
+func (t *workspaceTools) runShell(ctx context.Context, args map[string]interface{}) (string, error) {
+	command, _ := args["command"].(string)
+	if strings.TrimSpace(command) == "" {
+		return "", fmt.Errorf("missing required argument: command")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, shellToolTimeout)
+	defer cancel()
+
+	output, err := t.shellCommand(ctx, command).CombinedOutput()
+	if len(output) > maxToolOutput {
+		output = append([]byte("... (truncated)\n"), output[len(output)-maxToolOutput:]...)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s\ncommand failed: %w", output, err)
+	}
+	return string(output), nil
+}
+
+// shellCommand 构造执行命令的进程：配置了镜像时在只挂载工作区的一次性容器中执行，
+// 否则在宿主机的工作区目录中以精简后的环境变量执行
+func (t *workspaceTools) shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if t.shellImage == "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = t.root
+		cmd.Env = scrubbedEnv(os.Environ())
+		cmd.WaitDelay = processWaitDelay
+		return cmd
+	}
+
+	name := fmt.Sprintf("codeagent-shell-%d", time.Now().UnixNano())
+	args := []string{"run", "--rm", "--name", name, "-v", fmt.Sprintf("%s:/workspace", t.root), "-w", "/workspace"}
+	if t.network != "" {
+		args = append(args, "--network", t.network)
+	}
+	args = append(args, t.shellImage, "sh", "-c", command)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Cancel = func() error {
+		// 结束 docker 客户端不会停止容器，需要显式删除
+		if output, err := exec.Command("docker", "rm", "-f", name).CombinedOutput(); err != nil {
+			log.Warnf("Failed to remove shell container %s: %v, output: %s", name, err, strings.TrimSpace(string(output)))
+		}
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = processWaitDelay
+	return cmd
+}
+
+// scrubbedEnv 只保留 shellEnv 中列出的环境变量
+func scrubbedEnv(environ []string) []string {
+	var env []string
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		for _, allowed := range shellEnv {
+			if key == allowed {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// pathArg 读取路径参数并解析为工作区内的绝对路径
+func (t *workspaceTools) pathArg(args map[string]interface{}, key string) (string, error) {
+	path, ok := args[key].(string)
+	if !ok || path == "" {
+		return "", fmt.Errorf("missing required argument: %s", key)
+	}
+	return t.resolve(path)
+}
+
+// resolve 将路径限制在工作区内，包括通过符号链接指向外部的路径；
+// .git 目录下的hooks和配置会在提交推送时于宿主机上执行，不允许读写
+func (t *workspaceTools) resolve(path string) (string, error) {
+	full := path
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(t.root, full)
+	}
+	full = filepath.Clean(full)
+	if !isWithin(t.root, full) {
+		return "", fmt.Errorf("path %s is outside the workspace", path)
+	}
+	if isGitDir(t.root, full) {
+		return "", fmt.Errorf("path %s is inside the .git directory", path)
+	}
+
+	root, err := filepath.EvalSymlinks(t.root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+	real, err := evalExistingSymlinks(full)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	if !isWithin(root, real) {
+		return "", fmt.Errorf("path %s is outside the workspace", path)
+	}
+	if isGitDir(root, real) {
+		return "", fmt.Errorf("path %s is inside the .git directory", path)
+	}
+	return full, nil
+}
+
+// evalExistingSymlinks 解析路径中已存在部分的符号链接，不存在的部分原样拼接
+func evalExistingSymlinks(path string) (string, error) {
+	rest := ""
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest), nil
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// isWithin 判断 path 是否位于 root 之内（含 root 本身）
+// isGitDir 判断工作区内的path是否为 .git 或其中的文件
+func isGitDir(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	first, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	return first == ".git"
+}
+
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,104 @@
+package code
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/log"
+)
+
+// processWaitDelay 进程被取消后等待其输出管道关闭的最长时间
+const processWaitDelay = 5 * time.Second
+
+// killContainerProcess 杀掉容器内匹配名称的进程。
+// 取消 docker exec 客户端并不会终止容器内的进程，需要显式清理。
+func killContainerProcess(containerName, process string) {
+	cmd := exec.Command("docker", "exec", containerName, "pkill", "-f", process)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Warnf("Failed to kill %s in container %s: %v, output: %s", process, containerName, err, strings.TrimSpace(string(output)))
+		return
+	}
+	log.Infof("Killed %s in container %s", process, containerName)
+}
+
+// newDockerExecCommand 创建可被ctx取消的 docker exec 命令，
+// 取消时会先杀掉容器内的CLI进程再结束本地 docker 客户端
+func newDockerExecCommand(ctx context.Context, containerName, process string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Cancel = func() error {
+		killContainerProcess(containerName, process)
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = processWaitDelay
+	return cmd
+}
+
+// startProcess 在超时控制下启动build构造的命令并返回其标准输出。
+// 输出读完时进程已被回收；命令因ctx取消而结束时读取返回ctx的错误，
+// 超时时返回携带部分输出的 *TimeoutError，而不是被截断的EOF；
+// 退出码非零时读完已有输出后返回退出错误，供调用方判断是否切换provider
+func startProcess(ctx context.Context, provider string, timeout time.Duration, build func(ctx context.Context) *exec.Cmd) (io.Reader, error) {
+	ctx, cancel, timeout := withPromptTimeout(ctx, timeout)
+	cmd := build(ctx)
+
+	pr, pw := io.Pipe()
+	partial := &tailBuffer{max: maxPartialOutput}
+	cmd.Stdout = io.MultiWriter(pw, partial)
+	if err := cmd.Start(); err != nil {
+		cancel()
+		pw.Close()
+		return nil, err
+	}
+
+	go func() {
+		defer cancel()
+		err := cmd.Wait()
+		switch ctxErr := ctx.Err(); {
+		case errors.Is(ctxErr, context.DeadlineExceeded):
+			log.Warnf("%s execution timed out after %s", provider, timeout)
+			pw.CloseWithError(newTimeoutError(provider, timeout, partial.Bytes()))
+			return
+		case ctxErr != nil:
+			pw.CloseWithError(ctxErr)
+			return
+		}
+		if err != nil {
+			log.Warnf("Process %s exited with error: %v", cmd.Path, err)
+			pw.CloseWithError(fmt.Errorf("%s exited with error: %w", provider, err))
+			return
+		}
+		pw.Close()
+	}()
+	return pr, nil
+}
+
+// tailBuffer 只保留最近写入的max字节
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > 2*b.max {
+		b.buf = append([]byte(nil), b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes 返回保留的内容
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf...)
+}
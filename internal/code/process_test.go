@@ -0,0 +1,78 @@
+package code
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shellCommand 构造可被ctx终止的测试命令
+func shellCommand(script string) func(ctx context.Context) *exec.Cmd {
+	return func(ctx context.Context) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, "sh", "-c", script)
+		cmd.WaitDelay = 100 * time.Millisecond
+		return cmd
+	}
+}
+
+func TestStartProcess_ReadsUntilExit(t *testing.T) {
+	out, err := startProcess(context.Background(), "test", time.Minute, shellCommand("echo hello"))
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(out)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+}
+
+func TestStartProcess_ExitStatus(t *testing.T) {
+	out, err := startProcess(context.Background(), "test", time.Minute, shellCommand("echo partial; exit 3"))
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(out)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "test exited with error: exit status 3")
+	assert.Equal(t, ErrorClassCrash, ClassifyError(err))
+	assert.Equal(t, "partial\n", string(data))
+}
+
+func TestStartProcess_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := startProcess(ctx, "test", time.Minute, shellCommand("echo started; sleep 30"))
+	require.NoError(t, err)
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	data, err := io.ReadAll(out)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "started\n", string(data))
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestStartProcess_Timeout(t *testing.T) {
+	out, err := startProcess(context.Background(), "test", 200*time.Millisecond, shellCommand("echo partial; sleep 30"))
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(out)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	timeoutErr, ok := AsTimeoutError(err)
+	require.True(t, ok)
+	assert.Equal(t, "test", timeoutErr.Provider)
+	assert.Equal(t, 200*time.Millisecond, timeoutErr.Timeout)
+	assert.Equal(t, "partial\n", timeoutErr.PartialOutput)
+}
+
+func TestNewTimeoutError_KeepsTail(t *testing.T) {
+	partial := make([]byte, maxPartialOutput+10)
+	partial[len(partial)-1] = 'x'
+
+	err := newTimeoutError("test", time.Second, partial)
+	assert.Len(t, err.PartialOutput, maxPartialOutput)
+	assert.Equal(t, byte('x'), err.PartialOutput[maxPartialOutput-1])
+}
@@ -0,0 +1,32 @@
+package code
+
+import (
+	"context"
+	"strings"
+)
+
+// readOnlyKey 只读模式在context中的key
+type readOnlyKey struct{}
+
+// claudeDisallowedTools claude CLI 只读模式下禁用的写文件和执行命令工具
+var claudeDisallowedTools = []string{"Bash", "Edit", "MultiEdit", "Write", "NotebookEdit"}
+
+// readOnlyWorkspaceTools openai provider 只读模式下可用的工作区工具
+var readOnlyWorkspaceTools = map[string]bool{"read_file": true, "list_files": true}
+
+// WithReadOnly 返回以只读模式执行 Prompt 的context：
+// 禁用写文件、执行命令和MCP工具，也不会跳过CLI的权限确认
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// IsReadOnly 判断 Prompt 是否需要以只读模式执行
+func IsReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey{}).(bool)
+	return readOnly
+}
+
+// claudeReadOnlyArgs 只读模式下追加的 claude CLI 参数
+func claudeReadOnlyArgs() []string {
+	return []string{"--disallowedTools", strings.Join(claudeDisallowedTools, ",")}
+}
@@ -5,13 +5,15 @@ import (
 	"sync"
 
 	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/mcp"
 	"github.com/qiniu/codeagent/pkg/models"
 )
 
 type SessionManager struct {
-	mu    sync.RWMutex
-	codes map[string]Code
-	cfg   *config.Config
+	mu        sync.RWMutex
+	codes     map[string]Code
+	cfg       *config.Config
+	mcpClient mcp.MCPClient
 }
 
 func NewSessionManager(cfg *config.Config) *SessionManager {
@@ -21,15 +23,21 @@ func NewSessionManager(cfg *config.Config) *SessionManager {
 	}
 }
 
+// SetMCPClient sets the MCP client exposed to providers that execute tool calls in-process.
+func (sm *SessionManager) SetMCPClient(mcpClient mcp.MCPClient) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.mcpClient = mcpClient
+}
+
 // GetSession retrieves an existing Code session or creates a new one.
 func (sm *SessionManager) GetSession(workspace *models.Workspace) (Code, error) {
-	// 新的session key包含AI模型信息：aimodel-org-repo-pr-number
-	key := fmt.Sprintf("%s-%s-%s-%d", workspace.AIModel, workspace.Org, workspace.Repo, workspace.PRNumber)
+	key := sessionKey(workspace)
 	sm.mu.RLock()
 	c, ok := sm.codes[key]
 	sm.mu.RUnlock()
 
-	if ok {
+	if ok && !isClosed(c) {
 		return c, nil
 	}
 
@@ -37,11 +45,11 @@ func (sm *SessionManager) GetSession(workspace *models.Workspace) (Code, error)
 	defer sm.mu.Unlock()
 
 	// Double-check if the code object was created by another goroutine while we were waiting for the write lock
-	if code, ok := sm.codes[key]; ok {
+	if code, ok := sm.codes[key]; ok && !isClosed(code) {
 		return code, nil
 	}
 
-	c, err := New(workspace, sm.cfg)
+	c, err := sm.newSession(workspace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new code session: %w", err)
 	}
@@ -49,12 +57,45 @@ func (sm *SessionManager) GetSession(workspace *models.Workspace) (Code, error)
 	return c, nil
 }
 
+// newSession 创建记录用量的会话；配置了后备provider时使用按顺序故障转移的provider链
+func (sm *SessionManager) newSession(workspace *models.Workspace) (Code, error) {
+	providers := ProviderChain(providerFor(workspace, sm.cfg), sm.cfg.FallbackProviders)
+	var c Code
+	var err error
+	if len(providers) == 1 {
+		c, err = newCode(workspace, sm.cfg, sm.mcpClient)
+	} else {
+		c, err = sm.newFallbackSession(workspace, providers)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &meteredCode{Code: c, provider: providers[0]}, nil
+}
+
+func (sm *SessionManager) newFallbackSession(workspace *models.Workspace, providers []string) (Code, error) {
+	f, err := newFallbackCode(providers, func(provider string) (Code, error) {
+		if provider == providers[0] {
+			return newCode(workspace, sm.cfg, sm.mcpClient)
+		}
+		// 后备provider使用工作区副本，使 newCode 按该provider创建
+		fallback := *workspace
+		fallback.AIModel = provider
+		// 仓库配置的模型只适用于默认provider
+		fallback.Model = ""
+		return newCode(&fallback, sm.cfg, sm.mcpClient)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
 // CloseSession closes and removes a Code session from the manager.
 func (sm *SessionManager) CloseSession(workspace *models.Workspace) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	// 新的session key包含AI模型信息：aimodel-org-repo-pr-number
-	key := fmt.Sprintf("%s-%s-%s-%d", workspace.AIModel, workspace.Org, workspace.Repo, workspace.PRNumber)
+	key := sessionKey(workspace)
 
 	if c, ok := sm.codes[key]; ok {
 		delete(sm.codes, key)
@@ -62,3 +103,22 @@ func (sm *SessionManager) CloseSession(workspace *models.Workspace) error {
 	}
 	return nil
 }
+
+// HasSession 工作空间是否有未关闭的会话
+func (sm *SessionManager) HasSession(workspace *models.Workspace) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	c, ok := sm.codes[sessionKey(workspace)]
+	return ok && !isClosed(c)
+}
+
+// sessionKey 会话的key包含AI模型信息：aimodel-org-repo-pr-number
+func sessionKey(workspace *models.Workspace) string {
+	return fmt.Sprintf("%s-%s-%s-%d", workspace.AIModel, workspace.Org, workspace.Repo, workspace.PRNumber)
+}
+
+// isClosed 判断会话是否已经被关闭（例如任务被取消时关闭的交互式会话）
+func isClosed(c Code) bool {
+	closer, ok := c.(interface{ IsClosed() bool })
+	return ok && closer.IsClosed()
+}
@@ -0,0 +1,363 @@
+package code
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/qiniu/x/log"
+)
+
+// EventType AI执行过程中产生的结构化事件类型
+type EventType string
+
+const (
+	EventText       EventType = "text"        // assistant 输出的文本
+	EventToolCall   EventType = "tool_call"   // 调用工具
+	EventToolResult EventType = "tool_result" // 工具返回结果
+	EventFileEdit   EventType = "file_edit"   // 修改文件（由编辑类工具调用派生）
+	EventResult     EventType = "result"      // 最终结果，包含用量信息
+)
+
+// Usage 单次AI调用的token用量
+type Usage struct {
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	CostUSD                  float64 `json:"cost_usd,omitempty"`
+}
+
+// Event 结构化事件，不同类型使用不同字段
+type Event struct {
+	Type     EventType
+	Text     string                 // 文本、工具结果内容或最终结果
+	ToolName string                 // tool_call 的工具名
+	ToolID   string                 // 关联 tool_call 与 tool_result
+	Input    map[string]interface{} // tool_call 的参数
+	FilePath string                 // file_edit 修改的文件
+	IsError  bool                   // tool_result / result 是否失败
+	Model    string                 // result 使用的模型
+	Usage    *Usage                 // result 的用量，未知时为nil
+	Duration time.Duration          // result 的执行耗时
+}
+
+// EventHandler 处理结构化事件的回调
+type EventHandler func(Event)
+
+// fileEditTools 会修改文件的工具及其文件路径参数
+var fileEditTools = map[string]string{
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"Write":        "file_path",
+	"NotebookEdit": "notebook_path",
+	"write_file":   "path", // openai provider 的工作区工具
+	"edit_file":    "path",
+}
+
+// IsFileEditTool 判断工具是否会修改文件
+func IsFileEditTool(name string) bool {
+	_, ok := fileEditTools[name]
+	return ok
+}
+
+// lineDecoder 将一行原始输出解码为事件，以及需要透传给 Out 的文本
+type lineDecoder func(r *eventReader, line []byte) (events []Event, text string)
+
+// eventReader 按行读取provider输出，分发结构化事件，并通过Read返回兼容旧调用方的纯文本
+type eventReader struct {
+	src      *bufio.Reader
+	decode   lineDecoder
+	handlers []EventHandler
+
+	pending    bytes.Buffer    // 待通过Read返回的文本
+	text       strings.Builder // 已透传给 Out 的文本
+	transcript strings.Builder // stream-json 模式下 assistant 输出的文本
+	model      string
+	result     *Event
+	raw        bytes.Buffer                           // 需要整体解析的输出（gemini JSON 模式）
+	flush      func(r *eventReader) ([]Event, string) // 输出结束时调用，处理 raw 中缓存的内容
+	err        error
+	finished   bool
+}
+
+func newEventReader(src io.Reader, decode lineDecoder) *eventReader {
+	return &eventReader{src: bufio.NewReader(src), decode: decode}
+}
+
+// NewTextResponse 将纯文本输出包装为响应，每行文本作为一个 text 事件，结束时产生 result 事件
+func NewTextResponse(out io.Reader) *Response {
+	r := newEventReader(out, decodeTextLine)
+	return &Response{Out: r, events: r}
+}
+
+// newStreamJSONResponse 解析 claude CLI 的 stream-json 输出，Out 只返回最终结果文本，与 text 输出模式一致
+func newStreamJSONResponse(out io.Reader) *Response {
+	r := newEventReader(out, decodeClaudeStreamLine)
+	return &Response{Out: r, events: r}
+}
+
+// newEventStreamResponse 解析每行一个JSON编码 Event 的输出，供在进程内直接产生事件的provider使用
+func newEventStreamResponse(out io.Reader) *Response {
+	r := newEventReader(out, decodeEventLine)
+	return &Response{Out: r, events: r}
+}
+
+func (r *eventReader) Read(p []byte) (int, error) {
+	for r.pending.Len() == 0 && !r.finished {
+		r.next()
+	}
+	if r.pending.Len() > 0 {
+		return r.pending.Read(p)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+// next 读取并处理一行输出
+func (r *eventReader) next() {
+	line, err := r.src.ReadBytes('\n')
+	if len(line) > 0 {
+		events, text := r.decode(r, line)
+		r.pending.WriteString(text)
+		for _, event := range events {
+			r.emit(event)
+		}
+	}
+	if err == nil {
+		return
+	}
+
+	r.finished = true
+	if !errors.Is(err, io.EOF) {
+		r.err = err
+		// 超时错误中的部分输出替换为解码后的文本，避免把原始的 stream-json 展示给用户
+		if timeoutErr, ok := AsTimeoutError(err); ok {
+			if r.transcript.Len() > 0 {
+				r.err = newTimeoutError(timeoutErr.Provider, timeoutErr.Timeout, []byte(r.transcript.String()))
+			}
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+	}
+	// 进程异常退出时仍解析已有的输出，结果中的错误信息用于判断是否切换provider，
+	// 但不再透传文本，以便调用方在没有读到输出时改用其他provider
+	if r.flush != nil {
+		events, text := r.flush(r)
+		if r.err == nil {
+			r.pending.WriteString(text)
+		}
+		for _, event := range events {
+			r.emit(event)
+		}
+	}
+	// 没有显式结果事件的输出（文本模式、被中断的stream-json）在正常结束时补充一个
+	if r.result == nil && r.err == nil {
+		text := r.text.String()
+		if text == "" {
+			text = r.transcript.String()
+			r.pending.WriteString(text)
+		}
+		r.emit(Event{Type: EventResult, Text: text, Model: r.model})
+	}
+	if r.result != nil && r.result.IsError {
+		if r.err != nil {
+			r.err = fmt.Errorf("AI returned an error result: %s: %w", r.result.Text, r.err)
+		} else {
+			r.err = fmt.Errorf("AI returned an error result: %s", r.result.Text)
+		}
+	}
+}
+
+func (r *eventReader) emit(event Event) {
+	if event.Type == EventResult {
+		result := event
+		r.result = &result
+	}
+	for _, handler := range r.handlers {
+		handler(event)
+	}
+}
+
+// decodeTextLine 文本模式：整行作为 text 事件，原样透传
+func decodeTextLine(r *eventReader, line []byte) ([]Event, string) {
+	text := string(line)
+	r.text.WriteString(text)
+	return []Event{{Type: EventText, Text: text}}, text
+}
+
+// decodeEventLine 解析JSON编码的 Event，Out 只返回最终结果文本
+func decodeEventLine(r *eventReader, line []byte) ([]Event, string) {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		return nil, ""
+	}
+
+	var event Event
+	if json.Unmarshal(trimmed, &event) != nil || event.Type == "" {
+		return decodeTextLine(r, line)
+	}
+
+	switch event.Type {
+	case EventText:
+		r.transcript.WriteString(event.Text)
+		r.transcript.WriteString("\n")
+	case EventResult:
+		if event.Model != "" {
+			r.model = event.Model
+		}
+		text := event.Text
+		if event.IsError {
+			text = ""
+		} else if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		return []Event{event}, text
+	}
+	return []Event{event}, ""
+}
+
+// streamMessage claude stream-json 输出中的一行
+type streamMessage struct {
+	Type         string  `json:"type"`
+	Subtype      string  `json:"subtype"`
+	Model        string  `json:"model"`
+	Result       string  `json:"result"`
+	IsError      bool    `json:"is_error"`
+	DurationMS   int64   `json:"duration_ms"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	Usage        *Usage  `json:"usage"`
+	Message      *struct {
+		Model   string          `json:"model"`
+		Content []streamContent `json:"content"`
+	} `json:"message"`
+}
+
+// streamContent assistant/user 消息中的内容块
+type streamContent struct {
+	Type      string                 `json:"type"`
+	Text      string                 `json:"text"`
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Input     map[string]interface{} `json:"input"`
+	ToolUseID string                 `json:"tool_use_id"`
+	Content   json.RawMessage        `json:"content"`
+	IsError   bool                   `json:"is_error"`
+}
+
+// decodeClaudeStreamLine 解析 stream-json 的一行；非JSON行按文本透传，兼容不支持该模式的CLI
+func decodeClaudeStreamLine(r *eventReader, line []byte) ([]Event, string) {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		return nil, ""
+	}
+
+	var msg streamMessage
+	if trimmed[0] != '{' || json.Unmarshal(trimmed, &msg) != nil || msg.Type == "" {
+		return decodeTextLine(r, line)
+	}
+
+	switch msg.Type {
+	case "system":
+		if msg.Model != "" {
+			r.model = msg.Model
+		}
+		return nil, ""
+	case "assistant":
+		if msg.Message == nil {
+			return nil, ""
+		}
+		if msg.Message.Model != "" {
+			r.model = msg.Message.Model
+		}
+		var events []Event
+		for _, content := range msg.Message.Content {
+			switch content.Type {
+			case "text":
+				r.transcript.WriteString(content.Text)
+				r.transcript.WriteString("\n")
+				events = append(events, Event{Type: EventText, Text: content.Text})
+			case "tool_use":
+				events = append(events, Event{Type: EventToolCall, ToolName: content.Name, ToolID: content.ID, Input: content.Input})
+				if key, ok := fileEditTools[content.Name]; ok {
+					if path, _ := content.Input[key].(string); path != "" {
+						events = append(events, Event{Type: EventFileEdit, ToolName: content.Name, ToolID: content.ID, FilePath: path})
+					}
+				}
+			}
+		}
+		return events, ""
+	case "user":
+		if msg.Message == nil {
+			return nil, ""
+		}
+		var events []Event
+		for _, content := range msg.Message.Content {
+			if content.Type == "tool_result" {
+				events = append(events, Event{Type: EventToolResult, ToolID: content.ToolUseID, Text: toolResultText(content.Content), IsError: content.IsError})
+			}
+		}
+		return events, ""
+	case "result":
+		usage := msg.Usage
+		if usage == nil {
+			usage = &Usage{}
+		}
+		usage.CostUSD = msg.TotalCostUSD
+		event := Event{
+			Type:     EventResult,
+			Text:     msg.Result,
+			IsError:  msg.IsError,
+			Model:    r.model,
+			Usage:    usage,
+			Duration: time.Duration(msg.DurationMS) * time.Millisecond,
+		}
+		// 失败的结果作为错误返回，不透传给 Out
+		if msg.IsError {
+			return []Event{event}, ""
+		}
+		text := msg.Result
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		return []Event{event}, text
+	default:
+		log.Debugf("Ignoring stream-json message of type %s", msg.Type)
+		return nil, ""
+	}
+}
+
+// toolResultText 工具结果可以是字符串或内容块数组
+func toolResultText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &blocks); err == nil {
+		var sb strings.Builder
+		for _, block := range blocks {
+			if block.Type == "text" {
+				sb.WriteString(block.Text)
+			}
+		}
+		return sb.String()
+	}
+	return string(raw)
+}
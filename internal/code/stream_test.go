@@ -0,0 +1,100 @@
+package code
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const claudeStreamFixture = `{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet-4","tools":["Edit","Bash"]}
+{"type":"assistant","message":{"model":"claude-sonnet-4","content":[{"type":"text","text":"Let me look at the handler."},{"type":"tool_use","id":"toolu_1","name":"Read","input":{"file_path":"internal/foo.go"}}]}}
+{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"package foo"}]}]}}
+{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_2","name":"Edit","input":{"file_path":"internal/foo.go","old_string":"a","new_string":"b"}}]}}
+{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_2","content":"edit failed","is_error":true}]}}
+{"type":"result","subtype":"success","is_error":false,"duration_ms":1500,"result":"## Summary\nDone","total_cost_usd":0.25,"usage":{"input_tokens":100,"output_tokens":20,"cache_read_input_tokens":5}}
+`
+
+func TestStreamJSONResponse_Events(t *testing.T) {
+	resp := newStreamJSONResponse(strings.NewReader(claudeStreamFixture))
+
+	var events []Event
+	resp.OnEvent(func(e Event) { events = append(events, e) })
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	// Out keeps the plain text output of the CLI
+	assert.Equal(t, "## Summary\nDone\n", string(out))
+
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []EventType{EventText, EventToolCall, EventToolResult, EventToolCall, EventFileEdit, EventToolResult, EventResult}, types)
+
+	assert.Equal(t, "Read", events[1].ToolName)
+	assert.Equal(t, "package foo", events[2].Text)
+	assert.Equal(t, "internal/foo.go", events[4].FilePath)
+	assert.True(t, events[5].IsError)
+
+	result := resp.Result()
+	require.NotNil(t, result)
+	assert.Equal(t, "claude-sonnet-4", result.Model)
+	assert.Equal(t, 1500*time.Millisecond, result.Duration)
+	require.NotNil(t, result.Usage)
+	assert.EqualValues(t, 100, result.Usage.InputTokens)
+	assert.EqualValues(t, 20, result.Usage.OutputTokens)
+	assert.EqualValues(t, 5, result.Usage.CacheReadInputTokens)
+	assert.Equal(t, 0.25, result.Usage.CostUSD)
+}
+
+func TestStreamJSONResponse_PlainTextFallback(t *testing.T) {
+	// Older CLIs ignore --output-format and print plain text
+	resp := newStreamJSONResponse(strings.NewReader("plain output\nsecond line\n"))
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "plain output\nsecond line\n", string(out))
+	require.NotNil(t, resp.Result())
+	assert.Equal(t, "plain output\nsecond line\n", resp.Result().Text)
+}
+
+func TestStreamJSONResponse_InterruptedStream(t *testing.T) {
+	stream := `{"type":"assistant","message":{"content":[{"type":"text","text":"Working on it"}]}}` + "\n"
+	resp := newStreamJSONResponse(strings.NewReader(stream))
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "Working on it\n", string(out))
+}
+
+func TestTextResponse_Events(t *testing.T) {
+	resp := NewTextResponse(strings.NewReader("line one\nline two"))
+
+	var texts []string
+	resp.OnEvent(func(e Event) {
+		if e.Type == EventText {
+			texts = append(texts, e.Text)
+		}
+	})
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "line one\nline two", string(out))
+	assert.Equal(t, []string{"line one\n", "line two"}, texts)
+	require.NotNil(t, resp.Result())
+	assert.Nil(t, resp.Result().Usage)
+}
+
+func TestResponse_WithoutEvents(t *testing.T) {
+	resp := &Response{Out: strings.NewReader("raw")}
+	resp.OnEvent(func(Event) { t.Fatal("unexpected event") })
+
+	out, err := io.ReadAll(resp.Out)
+	require.NoError(t, err)
+	assert.Equal(t, "raw", string(out))
+	assert.Nil(t, resp.Result())
+}
@@ -47,7 +47,7 @@ func PromptWithRetry(ctx context.Context, code Code, prompt string, maxRetries i
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		xl.Debugf("Prompt attempt %d/%d", attempt, maxRetries)
-		resp, err := code.Prompt(prompt)
+		resp, err := code.Prompt(ctx, prompt)
 		if err == nil {
 			xl.Infof("Prompt succeeded on attempt %d", attempt)
 			return resp, nil
@@ -56,6 +56,17 @@ func PromptWithRetry(ctx context.Context, code Code, prompt string, maxRetries i
 		lastErr = err
 		xl.Warnf("Prompt attempt %d failed: %v", attempt, err)
 
+		// 任务被取消时不再重试
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// 超时重试大概率仍然超时，直接返回携带部分输出的超时错误；
+		// 认证失败重试同样无济于事（provider链已尝试过后备provider）
+		switch ClassifyError(err) {
+		case ErrorClassTimeout, ErrorClassAuth:
+			return nil, err
+		}
+
 		// 如果是 broken pipe 错误，尝试重新创建 session
 		if strings.Contains(err.Error(), "broken pipe") ||
 			strings.Contains(err.Error(), "process has already exited") {
@@ -66,7 +77,11 @@ func PromptWithRetry(ctx context.Context, code Code, prompt string, maxRetries i
 			// 等待一段时间后重试
 			sleepDuration := time.Duration(attempt) * 500 * time.Millisecond
 			xl.Infof("Waiting %v before retry", sleepDuration)
-			time.Sleep(sleepDuration)
+			select {
+			case <-time.After(sleepDuration):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 	}
 
@@ -19,6 +19,8 @@ type CommandDefinition struct {
 	Tools        []string `yaml:"tools,omitempty"`
 	AllowedTools string   `yaml:"allowed-tools,omitempty"`
 	Subagent     string   `yaml:"subagent,omitempty"`
+	// 执行命令所需的最低仓库角色（read、triage、write、maintain、admin），为空时使用全局权限策略
+	Permission string `yaml:"permission,omitempty"`
 
 	// Markdown content (everything after frontmatter)
 	Content string `yaml:"-"`
@@ -108,8 +110,8 @@ func (cl *CommandLoader) LoadAgent(name string) (*AgentDefinition, error) {
 func (cl *CommandLoader) ListCommands() (map[string]*CommandDefinition, error) {
 	commands := make(map[string]*CommandDefinition)
 
-	// Load global commands first
-	if err := cl.loadCommandsFromDirectory(filepath.Join(cl.globalPath, "commands"), "global", commands); err != nil {
+	// Load global commands first; a missing directory means there are none
+	if err := cl.loadCommandsFromDirectory(filepath.Join(cl.globalPath, "commands"), "global", commands); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("error loading global commands: %w", err)
 	}
 
@@ -130,8 +132,8 @@ func (cl *CommandLoader) ListCommands() (map[string]*CommandDefinition, error) {
 func (cl *CommandLoader) ListAgents() (map[string]*AgentDefinition, error) {
 	agents := make(map[string]*AgentDefinition)
 
-	// Load global agents first
-	if err := cl.loadAgentsFromDirectory(filepath.Join(cl.globalPath, "agents"), "global", agents); err != nil {
+	// Load global agents first; a missing directory means there are none
+	if err := cl.loadAgentsFromDirectory(filepath.Join(cl.globalPath, "agents"), "global", agents); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("error loading global agents: %w", err)
 	}
 
@@ -0,0 +1,35 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandLoader_List(t *testing.T) {
+	globalDir := t.TempDir()
+	repoDir := t.TempDir()
+	write := func(path, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write(filepath.Join(globalDir, "commands", "lint.md"), "---\ndescription: Run linters\n---\nRun the linters.")
+	write(filepath.Join(globalDir, "commands", "deploy.md"), "---\ndescription: Deploy\n---\nDeploy.")
+	write(filepath.Join(repoDir, "commands", "deploy.md"), "---\ndescription: Deploy to staging\npermission: admin\n---\nDeploy.")
+
+	// 全局目录没有agents时视为没有定义
+	loader := NewCommandLoader(globalDir, repoDir)
+	commands, err := loader.ListCommands()
+	require.NoError(t, err)
+	require.Len(t, commands, 2)
+	assert.Equal(t, "global", commands["lint"].Source)
+	assert.Equal(t, "repository", commands["deploy"].Source)
+	assert.Equal(t, "admin", commands["deploy"].Permission)
+
+	agents, err := loader.ListAgents()
+	require.NoError(t, err)
+	assert.Empty(t, agents)
+}
@@ -17,9 +17,13 @@ type Config struct {
 	Workspace    WorkspaceConfig `yaml:"workspace"`
 	Claude       ClaudeConfig    `yaml:"claude"`
 	Gemini       GeminiConfig    `yaml:"gemini"`
+	OpenAI       OpenAIConfig    `yaml:"openai"`
+	Fake         FakeConfig      `yaml:"fake"`
 	Docker       DockerConfig    `yaml:"docker"`
 	CodeProvider string          `yaml:"code_provider"`
-	UseDocker    bool            `yaml:"use_docker"`
+	// 主provider失败（限流、认证、崩溃、超时）时按顺序尝试的后备provider
+	FallbackProviders []string `yaml:"fallback_providers"`
+	UseDocker         bool     `yaml:"use_docker"`
 
 	// v0.6 Configuration
 	Commands CommandsConfig `yaml:"commands"`
@@ -27,6 +31,22 @@ type Config struct {
 	Mention MentionConfig `yaml:"mention"`
 	// Review Configuration
 	Review ReviewConfig `yaml:"review"`
+	// Webhook job queue configuration
+	Queue QueueConfig `yaml:"queue"`
+	// Handler execution concurrency configuration
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	// Token usage and cost accounting configuration
+	Usage UsageConfig `yaml:"usage"`
+	// AI usage budget configuration
+	Budget BudgetConfig `yaml:"budget"`
+	// Command permission policy configuration
+	Permissions PermissionsConfig `yaml:"permissions"`
+	// Task check run configuration
+	Checks ChecksConfig `yaml:"checks"`
+	// Failing CI auto-fix configuration
+	CIFix CIFixConfig `yaml:"ci_fix"`
+	// Scheduled job configuration
+	Cron CronConfig `yaml:"cron"`
 }
 
 type GeminiConfig struct {
@@ -36,9 +56,31 @@ type GeminiConfig struct {
 	GoogleCloudProject string        `yaml:"google_cloud_project"`
 }
 
+// OpenAIConfig OpenAI兼容接口（chat completions）的配置，可对接vLLM等自建服务
+type OpenAIConfig struct {
+	APIKey  string        `yaml:"api_key"`
+	BaseURL string        `yaml:"base_url"`
+	Model   string        `yaml:"model"`
+	Timeout time.Duration `yaml:"timeout"`
+	// 单次调用中模型与工具交互的最大轮数
+	MaxTurns int `yaml:"max_turns"`
+	// 是否向模型提供 run_shell 工具，默认关闭
+	ShellTool bool `yaml:"shell_tool"`
+	// use_docker 时执行 run_shell 命令的容器镜像，为空时在宿主机上执行
+	ShellImage string `yaml:"shell_image"`
+}
+
+// FakeConfig 回放脚本化响应的fake provider配置，仅用于测试
+type FakeConfig struct {
+	// 响应脚本文件路径（YAML）
+	Fixture string `yaml:"fixture"`
+}
+
 type ServerConfig struct {
 	Port          int    `yaml:"port"`
 	WebhookSecret string `yaml:"webhook_secret"`
+	// 管理接口（如 /usage）的 Bearer token，为空时管理接口不可用
+	AdminToken string `yaml:"admin_token"`
 }
 
 type GitHubConfig struct {
@@ -89,6 +131,109 @@ type MentionConfig struct {
 type ReviewConfig struct {
 	// 自动审查的排除账号，支持多个
 	ExcludedAccounts []string `yaml:"excluded_accounts"`
+	// 审查策略：意见严重程度（critical、major、minor、nit，没有意见时为 none）到审查结论
+	// （request_changes、comment、approve）的映射，未配置的键使用默认策略
+	Policy map[string]string `yaml:"policy"`
+}
+
+type QueueConfig struct {
+	// 队列持久化目录，默认为 <workspace.base_dir>/_state/queue
+	Dir string `yaml:"dir"`
+	// 并发处理webhook任务的worker数量
+	Workers int `yaml:"workers"`
+	// 单个任务的最大尝试次数（含首次）
+	MaxAttempts int `yaml:"max_attempts"`
+	// 首次重试的等待时间，之后按指数退避
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	// 已完成任务在磁盘上的保留时长
+	Retention time.Duration `yaml:"retention"`
+	// X-GitHub-Delivery 去重记录的保留时长，在此期间重复投递的事件会被忽略
+	DeliveryTTL time.Duration `yaml:"delivery_ttl"`
+}
+
+type SchedulerConfig struct {
+	// 全局同时执行的任务上限
+	MaxConcurrent int `yaml:"max_concurrent"`
+	// 单个仓库同时执行的任务上限
+	MaxPerRepo int `yaml:"max_per_repo"`
+}
+
+// UsageConfig token用量与费用统计配置
+type UsageConfig struct {
+	// 按模型名前缀配置的价格，覆盖内置价格；provider没有返回费用时用于估算
+	Prices map[string]ModelPrice `yaml:"prices"`
+}
+
+// ModelPrice 模型价格，单位为美元/百万token
+type ModelPrice struct {
+	Input      float64 `yaml:"input"`
+	Output     float64 `yaml:"output"`
+	CacheRead  float64 `yaml:"cache_read"`
+	CacheWrite float64 `yaml:"cache_write"`
+}
+
+// BudgetConfig AI用量预算配置，键为组织、仓库（owner/repo）或GitHub用户名，
+// "*" 表示对每个未单独配置的组织/仓库/用户生效的默认预算
+type BudgetConfig struct {
+	Orgs  map[string]BudgetLimit `yaml:"orgs"`
+	Repos map[string]BudgetLimit `yaml:"repos"`
+	Users map[string]BudgetLimit `yaml:"users"`
+	// 自动审查单独限流，其用量不计入上述人工命令的预算
+	AutoReview AutoReviewBudgetConfig `yaml:"auto_review"`
+}
+
+// BudgetLimit 每日/每月的token和费用上限，0表示不限制，周期按UTC自然日/自然月计算
+type BudgetLimit struct {
+	DailyTokens   int64   `yaml:"daily_tokens"`
+	MonthlyTokens int64   `yaml:"monthly_tokens"`
+	DailyCost     float64 `yaml:"daily_cost"`   // 美元
+	MonthlyCost   float64 `yaml:"monthly_cost"` // 美元
+}
+
+// IsZero 是否未设置任何上限
+func (l BudgetLimit) IsZero() bool {
+	return l == BudgetLimit{}
+}
+
+// AutoReviewBudgetConfig 自动审查（pull_request 事件触发）的限流配置
+type AutoReviewBudgetConfig struct {
+	// 每个仓库的自动审查预算，键为 owner/repo，"*" 为默认值
+	Repos map[string]BudgetLimit `yaml:"repos"`
+	// 同一PR两次自动审查之间的最小间隔
+	MinInterval time.Duration `yaml:"min_interval"`
+}
+
+// PermissionsConfig 命令执行权限配置，角色取值：read、triage、write、maintain、admin
+type PermissionsConfig struct {
+	// 未单独配置的命令所需的最低角色，默认 write
+	DefaultRole string `yaml:"default_role"`
+	// 按命令配置的最低角色，例如 "/review": triage，覆盖内置默认值
+	Commands map[string]string `yaml:"commands"`
+	// 用户角色查询结果的缓存时长，默认 5m
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// ChecksConfig 任务check run配置
+type ChecksConfig struct {
+	// 是否为每个任务在PR的head提交上创建check run；Checks API 只对GitHub App开放，
+	// 未设置时在配置了GitHub App时启用
+	Enabled *bool `yaml:"enabled"`
+}
+
+// CIFixConfig 修复CodeAgent PR上失败CI的配置
+type CIFixConfig struct {
+	// 是否在CodeAgent PR的CI失败时自动修复，默认关闭（/fix-ci 不受影响），仓库可通过 ci.auto_fix 覆盖
+	Auto bool `yaml:"auto"`
+	// 每个PR连续自动修复的最大次数，默认 3
+	MaxAttempts int `yaml:"max_attempts"`
+	// 每个失败job保留的日志行数，默认 200
+	MaxLogLines int `yaml:"max_log_lines"`
+}
+
+// CronConfig 内置定时任务调度配置
+type CronConfig struct {
+	// 按仓库配置 automation.schedules 调度定时任务的仓库（owner/repo），为空时不调度
+	Repos []string `yaml:"repos"`
 }
 
 func Load(configPath string) (*Config, error) {
@@ -152,15 +297,30 @@ func (c *Config) loadFromEnv() {
 	if project := os.Getenv("GOOGLE_CLOUD_PROJECT"); project != "" {
 		c.Gemini.GoogleCloudProject = project
 	}
+	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
+		c.OpenAI.APIKey = apiKey
+	}
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		c.OpenAI.BaseURL = baseURL
+	}
+	if model := os.Getenv("OPENAI_MODEL"); model != "" {
+		c.OpenAI.Model = model
+	}
 	if provider := os.Getenv("CODE_PROVIDER"); provider != "" {
 		c.CodeProvider = provider
 	} else {
 		// 必须要存在一个 provider，这里默认使用 gemini
 		c.CodeProvider = "gemini"
 	}
+	if fallbacks := getEnvList("CODE_PROVIDER_FALLBACKS"); len(fallbacks) > 0 {
+		c.FallbackProviders = fallbacks
+	}
 	if secret := os.Getenv("WEBHOOK_SECRET"); secret != "" {
 		c.Server.WebhookSecret = secret
 	}
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" {
+		c.Server.AdminToken = adminToken
+	}
 	// GitHub App configuration from environment
 	if appIDStr := os.Getenv("GITHUB_APP_ID"); appIDStr != "" {
 		if appID, err := strconv.ParseInt(appIDStr, 10, 64); err == nil {
@@ -197,6 +357,34 @@ func (c *Config) loadFromEnv() {
 	if excludedAccounts := os.Getenv("REVIEW_EXCLUDED_ACCOUNTS"); excludedAccounts != "" {
 		c.Review.ExcludedAccounts = strings.Split(excludedAccounts, ",")
 	}
+	// Permission configuration from environment
+	if role := os.Getenv("PERMISSION_DEFAULT_ROLE"); role != "" {
+		c.Permissions.DefaultRole = role
+	}
+	// Queue configuration from environment
+	if queueDir := os.Getenv("QUEUE_DIR"); queueDir != "" {
+		c.Queue.Dir = queueDir
+	}
+	if workersStr := os.Getenv("QUEUE_WORKERS"); workersStr != "" {
+		if workers, err := strconv.Atoi(workersStr); err == nil {
+			c.Queue.Workers = workers
+		}
+	}
+	// Cron configuration from environment
+	if repos := getEnvList("CRON_REPOS"); len(repos) > 0 {
+		c.Cron.Repos = repos
+	}
+	// Scheduler configuration from environment
+	if maxStr := os.Getenv("SCHEDULER_MAX_CONCURRENT"); maxStr != "" {
+		if max, err := strconv.Atoi(maxStr); err == nil {
+			c.Scheduler.MaxConcurrent = max
+		}
+	}
+	if maxStr := os.Getenv("SCHEDULER_MAX_PER_REPO"); maxStr != "" {
+		if max, err := strconv.Atoi(maxStr); err == nil {
+			c.Scheduler.MaxPerRepo = max
+		}
+	}
 }
 
 func loadFromEnv() *Config {
@@ -211,6 +399,7 @@ func loadFromEnv() *Config {
 		Server: ServerConfig{
 			Port:          port,
 			WebhookSecret: os.Getenv("WEBHOOK_SECRET"),
+			AdminToken:    os.Getenv("ADMIN_TOKEN"),
 		},
 		GitHub: GitHubConfig{
 			Token:      os.Getenv("GITHUB_TOKEN"),
@@ -236,6 +425,15 @@ func loadFromEnv() *Config {
 			Timeout:            30 * time.Minute,
 			GoogleCloudProject: os.Getenv("GOOGLE_CLOUD_PROJECT"),
 		},
+		OpenAI: OpenAIConfig{
+			APIKey:     os.Getenv("OPENAI_API_KEY"),
+			BaseURL:    getEnvOrDefault("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+			Model:      os.Getenv("OPENAI_MODEL"),
+			Timeout:    30 * time.Minute,
+			MaxTurns:   getEnvIntOrDefault("OPENAI_MAX_TURNS", 50),
+			ShellTool:  getEnvBoolOrDefault("OPENAI_SHELL_TOOL", false),
+			ShellImage: getEnvOrDefault("OPENAI_SHELL_IMAGE", "ubuntu:24.04"),
+		},
 		Docker: DockerConfig{
 			Socket:  getEnvOrDefault("DOCKER_SOCKET", "unix:///var/run/docker.sock"),
 			Network: getEnvOrDefault("DOCKER_NETWORK", "bridge"),
@@ -250,8 +448,23 @@ func loadFromEnv() *Config {
 		Review: ReviewConfig{
 			ExcludedAccounts: []string{},
 		},
-		CodeProvider: getEnvOrDefault("CODE_PROVIDER", "claude"),
-		UseDocker:    getEnvBoolOrDefault("USE_DOCKER", true),
+		Queue: QueueConfig{
+			Dir:     os.Getenv("QUEUE_DIR"),
+			Workers: getEnvIntOrDefault("QUEUE_WORKERS", 16),
+		},
+		Scheduler: SchedulerConfig{
+			MaxConcurrent: getEnvIntOrDefault("SCHEDULER_MAX_CONCURRENT", 4),
+			MaxPerRepo:    getEnvIntOrDefault("SCHEDULER_MAX_PER_REPO", 2),
+		},
+		Permissions: PermissionsConfig{
+			DefaultRole: os.Getenv("PERMISSION_DEFAULT_ROLE"),
+		},
+		Cron: CronConfig{
+			Repos: getEnvList("CRON_REPOS"),
+		},
+		CodeProvider:      getEnvOrDefault("CODE_PROVIDER", "claude"),
+		FallbackProviders: getEnvList("CODE_PROVIDER_FALLBACKS"),
+		UseDocker:         getEnvBoolOrDefault("USE_DOCKER", true),
 	}
 }
 
@@ -268,6 +481,22 @@ func (c *Config) resolvePaths(configDir string) error {
 		}
 	}
 
+	// 处理队列目录
+	if c.Queue.Dir != "" && !filepath.IsAbs(c.Queue.Dir) {
+		absPath, err := filepath.Abs(filepath.Join(configDir, c.Queue.Dir))
+		if err == nil {
+			c.Queue.Dir = absPath
+		}
+	}
+
+	// 处理fake provider脚本路径
+	if c.Fake.Fixture != "" && !filepath.IsAbs(c.Fake.Fixture) {
+		absPath, err := filepath.Abs(filepath.Join(configDir, c.Fake.Fixture))
+		if err == nil {
+			c.Fake.Fixture = absPath
+		}
+	}
+
 	// 处理全局命令路径
 	if c.Commands.GlobalPath != "" {
 		// 如果路径不是绝对路径，则相对于配置文件目录解析
@@ -293,6 +522,15 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
 func getEnvBoolOrDefault(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if b, err := strconv.ParseBool(value); err == nil {
@@ -302,6 +540,30 @@ func getEnvBoolOrDefault(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvList 读取逗号分隔的环境变量，忽略空项
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// StatePath returns the path of a named state file or directory kept under the workspace base dir
+func (c *Config) StatePath(name string) string {
+	return filepath.Join(c.Workspace.BaseDir, "_state", name)
+}
+
+// QueueDir returns the directory used to persist webhook jobs
+func (c *Config) QueueDir() string {
+	if c.Queue.Dir != "" {
+		return c.Queue.Dir
+	}
+	return c.StatePath("queue")
+}
+
 // IsGitHubTokenConfigured returns whether GitHub token is configured
 func (c *Config) IsGitHubTokenConfigured() bool {
 	return c.GitHub.Token != ""
@@ -315,6 +577,30 @@ func (c *Config) IsGitHubAppConfigured() bool {
 			c.GitHub.App.PrivateKey != "")
 }
 
+// CIFixMaxAttempts returns the maximum number of consecutive CI auto-fix attempts per PR
+func (c *Config) CIFixMaxAttempts() int {
+	if c.CIFix.MaxAttempts > 0 {
+		return c.CIFix.MaxAttempts
+	}
+	return 3
+}
+
+// CIFixMaxLogLines returns the number of log lines kept for each failing job
+func (c *Config) CIFixMaxLogLines() int {
+	if c.CIFix.MaxLogLines > 0 {
+		return c.CIFix.MaxLogLines
+	}
+	return 200
+}
+
+// TaskChecksEnabled returns whether tasks are reported as GitHub check runs
+func (c *Config) TaskChecksEnabled() bool {
+	if c.Checks.Enabled != nil {
+		return *c.Checks.Enabled
+	}
+	return c.IsGitHubAppConfigured()
+}
+
 // ValidateGitHubConfig validates the GitHub configuration
 func (c *Config) ValidateGitHubConfig() error {
 	if !c.IsGitHubTokenConfigured() && !c.IsGitHubAppConfigured() {
@@ -55,6 +55,7 @@ func (g *TemplatePromptGenerator) buildVariables(ctx *EnhancedContext, mode stri
 	vars["IS_PR"] = "false"
 	vars["MODE"] = mode
 	vars["ARGS"] = args
+	vars["APPROVED_PLAN"] = ""
 
 	// Extract information from context
 	if ctx.Type == ContextTypeIssue {
@@ -226,6 +227,17 @@ func (g *TemplatePromptGenerator) buildVariables(ctx *EnhancedContext, mode stri
 		vars["IS_FORK_PR"] = fmt.Sprintf("%v", isForkPR)
 	}
 
+	// /code approve-plan 时注入已批准的实现计划
+	if plan, ok := ctx.Metadata["approved_plan"]; ok {
+		vars["APPROVED_PLAN"] = fmt.Sprintf(`
+## Approved Plan
+
+The following implementation plan was reviewed and approved on the issue. Implement it as described; if you have to deviate from it, explain why in your summary.
+
+%v
+`, plan)
+	}
+
 	return vars
 }
 
@@ -236,6 +248,8 @@ func (g *TemplatePromptGenerator) selectTemplate(mode string) string {
 		return g.getContinueTemplate()
 	case "Code":
 		return g.getCodeTemplate()
+	case "Plan":
+		return g.getPlanTemplate()
 	case "Review":
 		return g.getDefaultTemplate()
 	default:
@@ -305,7 +319,7 @@ $COMMENTS
 
 ## Implementation Request
 $ARGS
-
+$APPROVED_PLAN
 ## Your Task
 
 Implement the requested functionality. Create new code, modify existing code as needed, and ensure the implementation follows best practices.
@@ -328,6 +342,45 @@ Implement the requested functionality. Create new code, modify existing code as
 6. Ensure proper integration`
 }
 
+// getPlanTemplate 实现计划模板，只分析代码，不做修改
+func (g *TemplatePromptGenerator) getPlanTemplate() string {
+	return `You are an AI-powered code development assistant. Before any code is written for this GitHub issue, propose an implementation plan for the maintainers to review.
+
+## Context Information
+
+Repository: $REPOSITORY
+Issue #$ISSUE_NUMBER
+
+### Current Context
+$FORMATTED_CONTEXT
+
+### Comments
+$COMMENTS
+
+## Planning Request
+$ARGS
+
+## Your Task
+
+Explore the repository and work out how the issue should be implemented. You are running in read-only mode: do not create, modify or delete any files, and do not run commands that change the repository.
+
+## Output Format
+
+Reply with the plan only, using exactly these Markdown sections:
+
+### Files to change
+A bullet list of the files to add, modify or delete, each with a short note on what changes.
+
+### Approach
+The implementation steps in order, including the tests to add or update.
+
+### Risks
+Behaviour changes, compatibility concerns and edge cases that need care.
+
+### Open questions
+Decisions the maintainers should make before implementation starts, or "None".`
+}
+
 // getDefaultTemplate 代码审查模板
 func (g *TemplatePromptGenerator) getDefaultTemplate() string {
 	return `You are codeagent, an AI assistant designed to help with GitHub issues and pull requests. Think carefully as you analyze the context and respond appropriately. Here's the context for your current task:
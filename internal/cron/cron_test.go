@@ -0,0 +1,44 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC) // 周三
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{spec: "0 3 * * *", want: time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC)},
+		{spec: "@hourly", want: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", want: time.Date(2024, 5, 1, 10, 45, 0, 0, time.UTC)},
+		{spec: "0 4 * * 1", want: time.Date(2024, 5, 6, 4, 0, 0, 0, time.UTC)},
+		{spec: "0 0 * * 7", want: time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 9 1-5 * *", want: time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 12 15 * 5", want: time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)}, // 日和周满足其一
+		{spec: "30 10,18 * * *", want: time.Date(2024, 5, 1, 18, 30, 0, 0, time.UTC)},
+		{spec: "0 0 1 1 *", want: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.Next(base))
+			assert.True(t, s.Matches(tt.want))
+		})
+	}
+}
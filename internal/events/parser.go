@@ -88,6 +88,16 @@ func (p *EventParser) ParseWebhookEvent(
 		return p.parsePullRequestEvent(ctx, payload, deliveryID)
 	case models.EventPush:
 		return p.parsePushEvent(ctx, payload, deliveryID)
+	case models.EventCheckRun:
+		return p.parseCheckRunEvent(ctx, payload, deliveryID)
+	case models.EventCheckSuite:
+		return p.parseCheckSuiteEvent(ctx, payload, deliveryID)
+	case models.EventWorkflowRun:
+		return p.parseWorkflowRunEvent(ctx, payload, deliveryID)
+	case models.EventSchedule:
+		return p.parseScheduleEvent(ctx, payload, deliveryID)
+	case models.EventWorkflowDispatch:
+		return p.parseWorkflowDispatchEvent(ctx, payload, deliveryID)
 	default:
 		return nil, UnsupportedEventTypeError(eventType)
 	}
@@ -312,10 +322,11 @@ func (p *EventParser) parsePushEvent(
 	var repo *github.Repository
 	if event.Repo != nil {
 		repo = &github.Repository{
-			ID:       event.Repo.ID,
-			Name:     event.Repo.Name,
-			FullName: event.Repo.FullName,
-			Owner:    event.Repo.Owner,
+			ID:            event.Repo.ID,
+			Name:          event.Repo.Name,
+			FullName:      event.Repo.FullName,
+			Owner:         event.Repo.Owner,
+			DefaultBranch: event.Repo.DefaultBranch,
 		}
 	}
 
@@ -333,5 +344,197 @@ func (p *EventParser) parsePushEvent(
 		Commits: event.Commits,
 		Before:  event.GetBefore(),
 		After:   event.GetAfter(),
+		Deleted: event.GetDeleted(),
+	}, nil
+}
+
+// parseCheckRunEvent 解析check_run事件
+func (p *EventParser) parseCheckRunEvent(
+	ctx context.Context,
+	payload []byte,
+	deliveryID string,
+) (*models.CheckRunContext, error) {
+	var event github.CheckRunEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal check run event: %w", err)
+	}
+
+	// 检查必需字段
+	if event.Repo == nil {
+		return nil, fmt.Errorf("missing repository in check run event")
+	}
+	if event.Sender == nil {
+		return nil, fmt.Errorf("missing sender in check run event")
+	}
+	if event.CheckRun == nil {
+		return nil, fmt.Errorf("missing check run in check run event")
+	}
+
+	return &models.CheckRunContext{
+		BaseContext: models.BaseContext{
+			Type:       models.EventCheckRun,
+			Repository: event.Repo,
+			Sender:     event.Sender,
+			RawEvent:   &event,
+			Action:     event.GetAction(),
+			DeliveryID: deliveryID,
+			Timestamp:  time.Now(),
+		},
+		CheckRun: event.CheckRun,
+	}, nil
+}
+
+// parseCheckSuiteEvent 解析check_suite事件
+func (p *EventParser) parseCheckSuiteEvent(
+	ctx context.Context,
+	payload []byte,
+	deliveryID string,
+) (*models.CheckSuiteContext, error) {
+	var event github.CheckSuiteEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal check suite event: %w", err)
+	}
+
+	// 检查必需字段
+	if event.Repo == nil {
+		return nil, fmt.Errorf("missing repository in check suite event")
+	}
+	if event.Sender == nil {
+		return nil, fmt.Errorf("missing sender in check suite event")
+	}
+	if event.CheckSuite == nil {
+		return nil, fmt.Errorf("missing check suite in check suite event")
+	}
+
+	return &models.CheckSuiteContext{
+		BaseContext: models.BaseContext{
+			Type:       models.EventCheckSuite,
+			Repository: event.Repo,
+			Sender:     event.Sender,
+			RawEvent:   &event,
+			Action:     event.GetAction(),
+			DeliveryID: deliveryID,
+			Timestamp:  time.Now(),
+		},
+		CheckSuite: event.CheckSuite,
+	}, nil
+}
+
+// parseWorkflowRunEvent 解析workflow_run事件
+func (p *EventParser) parseWorkflowRunEvent(
+	ctx context.Context,
+	payload []byte,
+	deliveryID string,
+) (*models.WorkflowRunContext, error) {
+	var event github.WorkflowRunEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workflow run event: %w", err)
+	}
+
+	// 检查必需字段
+	if event.Repo == nil {
+		return nil, fmt.Errorf("missing repository in workflow run event")
+	}
+	if event.Sender == nil {
+		return nil, fmt.Errorf("missing sender in workflow run event")
+	}
+	if event.WorkflowRun == nil {
+		return nil, fmt.Errorf("missing workflow run in workflow run event")
+	}
+
+	return &models.WorkflowRunContext{
+		BaseContext: models.BaseContext{
+			Type:       models.EventWorkflowRun,
+			Repository: event.Repo,
+			Sender:     event.Sender,
+			RawEvent:   &event,
+			Action:     event.GetAction(),
+			DeliveryID: deliveryID,
+			Timestamp:  time.Now(),
+		},
+		WorkflowRun: event.WorkflowRun,
+	}, nil
+}
+
+// SchedulePayload 内置cron调度器生成的schedule事件，GitHub不会发送该webhook
+type SchedulePayload struct {
+	Schedule    string             `json:"schedule"`
+	Job         string             `json:"job"`
+	Repo        *github.Repository `json:"repository"`
+	PullRequest int                `json:"pull_request,omitempty"` // 定时任务拆分出的单个PR的子任务
+}
+
+// parseScheduleEvent 解析schedule事件
+func (p *EventParser) parseScheduleEvent(
+	ctx context.Context,
+	payload []byte,
+	deliveryID string,
+) (*models.ScheduleContext, error) {
+	var event SchedulePayload
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schedule event: %w", err)
+	}
+
+	// 检查必需字段
+	if event.Repo == nil {
+		return nil, fmt.Errorf("missing repository in schedule event")
+	}
+	if !models.IsValidJob(event.Job) {
+		return nil, fmt.Errorf("unknown job %q in schedule event", event.Job)
+	}
+
+	return &models.ScheduleContext{
+		BaseContext: models.BaseContext{
+			Type:       models.EventSchedule,
+			Repository: event.Repo,
+			RawEvent:   &event,
+			Action:     event.Job,
+			DeliveryID: deliveryID,
+			Timestamp:  time.Now(),
+		},
+		Job:         event.Job,
+		Cron:        event.Schedule,
+		PullRequest: event.PullRequest,
+	}, nil
+}
+
+// parseWorkflowDispatchEvent 解析workflow_dispatch事件，inputs.job 指定要执行的任务
+func (p *EventParser) parseWorkflowDispatchEvent(
+	ctx context.Context,
+	payload []byte,
+	deliveryID string,
+) (*models.WorkflowDispatchContext, error) {
+	var event github.WorkflowDispatchEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workflow dispatch event: %w", err)
+	}
+
+	// 检查必需字段
+	if event.Repo == nil {
+		return nil, fmt.Errorf("missing repository in workflow dispatch event")
+	}
+	inputs := map[string]interface{}{}
+	if len(event.Inputs) > 0 {
+		if err := json.Unmarshal(event.Inputs, &inputs); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal workflow dispatch inputs: %w", err)
+		}
+	}
+	job, _ := inputs["job"].(string)
+	if !models.IsValidJob(job) {
+		return nil, fmt.Errorf("unknown job %q in workflow dispatch event", job)
+	}
+
+	return &models.WorkflowDispatchContext{
+		BaseContext: models.BaseContext{
+			Type:       models.EventWorkflowDispatch,
+			Repository: event.Repo,
+			Sender:     event.Sender,
+			RawEvent:   &event,
+			Action:     job,
+			DeliveryID: deliveryID,
+			Timestamp:  time.Now(),
+		},
+		Job:    job,
+		Inputs: inputs,
 	}, nil
 }
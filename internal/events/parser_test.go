@@ -106,6 +106,138 @@ func TestEventParser_ParsePRCommentEvent(t *testing.T) {
 	assert.True(t, issueCommentCtx.IsPRComment)
 }
 
+func TestEventParser_ParseCIEvents(t *testing.T) {
+	parser := NewEventParser()
+	ctx := context.Background()
+
+	repo := &github.Repository{
+		FullName: github.String("test/repo"),
+		Name:     github.String("repo"),
+		Owner:    &github.User{Login: github.String("test")},
+	}
+	sender := &github.User{Login: github.String("github-actions[bot]")}
+	prs := []*github.PullRequest{{Number: github.Int(8)}}
+
+	tests := []struct {
+		eventType string
+		event     interface{}
+		name      string
+	}{
+		{
+			eventType: "check_run",
+			event: &github.CheckRunEvent{Action: github.String("completed"), Repo: repo, Sender: sender, CheckRun: &github.CheckRun{
+				Name: github.String("test"), HeadSHA: github.String("abc123"), Conclusion: github.String("failure"), PullRequests: prs,
+			}},
+			name: "test",
+		},
+		{
+			eventType: "check_suite",
+			event: &github.CheckSuiteEvent{Action: github.String("completed"), Repo: repo, Sender: sender, CheckSuite: &github.CheckSuite{
+				App: &github.App{Name: github.String("GitHub Actions")}, HeadSHA: github.String("abc123"), Conclusion: github.String("failure"), PullRequests: prs,
+			}},
+			name: "GitHub Actions",
+		},
+		{
+			eventType: "workflow_run",
+			event: &github.WorkflowRunEvent{Action: github.String("completed"), Repo: repo, Sender: sender, WorkflowRun: &github.WorkflowRun{
+				Name: github.String("CI"), HeadSHA: github.String("abc123"), Conclusion: github.String("failure"), PullRequests: prs,
+			}},
+			name: "CI",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.eventType, func(t *testing.T) {
+			payload, err := json.Marshal(tt.event)
+			require.NoError(t, err)
+
+			parsedCtx, err := parser.ParseWebhookEvent(ctx, tt.eventType, "test-delivery-id", payload)
+			require.NoError(t, err)
+
+			ciCtx, ok := parsedCtx.(models.CIContext)
+			require.True(t, ok, "Expected CIContext")
+			assert.Equal(t, models.EventType(tt.eventType), ciCtx.GetEventType())
+			assert.Equal(t, "completed", ciCtx.GetEventAction())
+			assert.Equal(t, tt.name, ciCtx.CIName())
+			assert.Equal(t, "failure", ciCtx.CIConclusion())
+			assert.Equal(t, "abc123", ciCtx.HeadSHA())
+			require.Len(t, ciCtx.PullRequests(), 1)
+			assert.Equal(t, 8, ciCtx.PullRequests()[0].GetNumber())
+		})
+	}
+
+	_, err := parser.ParseWebhookEvent(ctx, "check_run", "test-delivery-id", []byte(`{"action":"completed"}`))
+	assert.Error(t, err)
+}
+
+func TestEventParser_ParseJobEvents(t *testing.T) {
+	parser := NewEventParser()
+	ctx := context.Background()
+
+	repo := &github.Repository{
+		FullName: github.String("test/repo"),
+		Name:     github.String("repo"),
+		Owner:    &github.User{Login: github.String("test")},
+	}
+
+	payload, err := json.Marshal(&SchedulePayload{Schedule: "0 3 * * *", Job: models.JobStaleIssues, Repo: repo})
+	require.NoError(t, err)
+	parsedCtx, err := parser.ParseWebhookEvent(ctx, "schedule", "schedule-1", payload)
+	require.NoError(t, err)
+	scheduleCtx, ok := parsedCtx.(*models.ScheduleContext)
+	require.True(t, ok, "Expected ScheduleContext")
+	assert.Equal(t, models.JobStaleIssues, scheduleCtx.Job)
+	assert.Equal(t, models.JobStaleIssues, scheduleCtx.GetEventAction())
+	assert.Equal(t, "0 3 * * *", scheduleCtx.Cron)
+	assert.Equal(t, "test/repo", scheduleCtx.GetRepository().GetFullName())
+
+	payload, err = json.Marshal(&github.WorkflowDispatchEvent{
+		Inputs: json.RawMessage(`{"job":"review-open-prs","limit":5}`),
+		Repo:   repo,
+	})
+	require.NoError(t, err)
+	parsedCtx, err = parser.ParseWebhookEvent(ctx, "workflow_dispatch", "dispatch-1", payload)
+	require.NoError(t, err)
+	dispatchCtx, ok := parsedCtx.(*models.WorkflowDispatchContext)
+	require.True(t, ok, "Expected WorkflowDispatchContext")
+	assert.Equal(t, models.JobReviewOpenPRs, dispatchCtx.Job)
+	assert.Equal(t, float64(5), dispatchCtx.Inputs["limit"])
+
+	// 未知任务不会被执行
+	_, err = parser.ParseWebhookEvent(ctx, "schedule", "schedule-2", []byte(`{"job":"unknown","repository":{"full_name":"test/repo"}}`))
+	assert.Error(t, err)
+	_, err = parser.ParseWebhookEvent(ctx, "workflow_dispatch", "dispatch-2", []byte(`{"repository":{"full_name":"test/repo"}}`))
+	assert.Error(t, err)
+}
+
+func TestEventParser_ParsePushEvent(t *testing.T) {
+	parser := NewEventParser()
+
+	payload := []byte(`{
+		"ref": "refs/heads/main",
+		"before": "1111111111111111111111111111111111111111",
+		"after": "2222222222222222222222222222222222222222",
+		"commits": [
+			{"id": "a", "message": "feat: add api", "added": ["api/new.go"], "modified": ["README.md"]},
+			{"id": "b", "message": "fix: api", "modified": ["api/new.go"], "removed": ["old.go"]}
+		],
+		"repository": {"name": "repo", "full_name": "test/repo", "default_branch": "main", "owner": {"login": "test"}},
+		"sender": {"login": "alice"}
+	}`)
+	parsedCtx, err := parser.ParseWebhookEvent(context.Background(), "push", "push-1", payload)
+	require.NoError(t, err)
+	pushCtx, ok := parsedCtx.(*models.PushContext)
+	require.True(t, ok, "Expected PushContext")
+	assert.Equal(t, "main", pushCtx.Branch())
+	assert.Equal(t, "main", pushCtx.GetRepository().GetDefaultBranch())
+	assert.Equal(t, []string{"api/new.go", "README.md", "old.go"}, pushCtx.ChangedFiles())
+	assert.False(t, pushCtx.Deleted)
+
+	// 推送标签没有分支
+	pushCtx.Ref = "refs/tags/v1.0.0"
+	assert.Equal(t, "", pushCtx.Branch())
+}
+
 func TestHasCommandWithConfig(t *testing.T) {
 	// 创建测试用的mention配置
 	mentionConfig := &models.ConfigMentionAdapter{
@@ -2,12 +2,18 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/qiniu/codeagent/internal/code"
+	"github.com/qiniu/codeagent/internal/workspace"
 	"github.com/qiniu/codeagent/pkg/models"
 
 	"github.com/google/go-github/v58/github"
@@ -17,6 +23,31 @@ import (
 
 type Client struct {
 	client *github.Client
+
+	loginMu sync.Mutex
+	// login CodeAgent在GitHub上的账号，GitHub App安装客户端创建时设置为 <slug>[bot]
+	login string
+}
+
+// NewClient 使用已配置好的 go-github 客户端创建 Client（例如指向 GitHub Enterprise 或测试桩服务）
+func NewClient(client *github.Client) *Client {
+	return &Client{client: client}
+}
+
+// Login 返回CodeAgent使用的GitHub账号，用于识别CodeAgent自己发布的评论；未设置时查询并缓存token对应的用户
+func (c *Client) Login(ctx context.Context) (string, error) {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	if c.login != "" {
+		return c.login, nil
+	}
+	user, _, err := c.client.Users.Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get authenticated user: %w", err)
+	}
+	c.login = user.GetLogin()
+	return c.login, nil
 }
 
 // CreateBranch creates branch locally and pushes to remote
@@ -166,7 +197,7 @@ func (c *Client) getDefaultBranch(owner, repo string) (string, error) {
 }
 
 // CommitAndPush 检测文件变更并提交推送
-func (c *Client) CommitAndPush(workspace *models.Workspace, result *models.ExecutionResult, codeClient code.Code) (string, error) {
+func (c *Client) CommitAndPush(ctx context.Context, workspace *models.Workspace, result *models.ExecutionResult, codeClient code.Code) (string, error) {
 	// 检查是否有文件变更
 	cmd := exec.Command("git", "status", "--porcelain")
 	cmd.Dir = workspace.Path
@@ -207,7 +238,11 @@ func (c *Client) CommitAndPush(workspace *models.Workspace, result *models.Execu
 	}
 
 	// 使用AI生成标准的英文commit message
-	commitMsg, err := c.generateCommitMessage(workspace, result, codeClient)
+	commitMsg, err := c.generateCommitMessage(ctx, workspace, result, codeClient)
+	if err != nil && ctx.Err() != nil {
+		// 任务已被取消，不再提交推送
+		return "", ctx.Err()
+	}
 	if err != nil {
 		log.Errorf("Failed to generate commit message with AI, using fallback: %v", err)
 		// 使用fallback的commit message
@@ -603,7 +638,7 @@ func extractSummaryFromOutput(output string) string {
 }
 
 // generateCommitMessage 使用AI生成标准的英文commit message
-func (c *Client) generateCommitMessage(workspace *models.Workspace, result *models.ExecutionResult, codeClient code.Code) (string, error) {
+func (c *Client) generateCommitMessage(ctx context.Context, workspace *models.Workspace, result *models.ExecutionResult, codeClient code.Code) (string, error) {
 	// 获取git status和diff信息
 	cmd := exec.Command("git", "status", "--porcelain")
 	cmd.Dir = workspace.Path
@@ -651,7 +686,7 @@ Important: Please return only the plain text commit message content, do not incl
 	)
 
 	// 调用AI生成commit message
-	resp, err := codeClient.Prompt(prompt)
+	resp, err := codeClient.Prompt(ctx, prompt)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate commit message: %w", err)
 	}
@@ -672,12 +707,12 @@ Important: Please return only the plain text commit message content, do not incl
 	return commitMsg, nil
 }
 
-// DeleteCodeAgentBranch 删除CodeAgent创建的分支
-func (c *Client) DeleteCodeAgentBranch(ctx context.Context, owner, repo, branchName string) error {
+// DeleteCodeAgentBranch 删除CodeAgent创建的分支，prefix为仓库配置的分支前缀（没有时为空）
+func (c *Client) DeleteCodeAgentBranch(ctx context.Context, owner, repo, branchName, prefix string) error {
 	log.Infof("Attempting to delete CodeAgent branch: %s", branchName)
 
-	// 确保只删除codeagent开头的分支
-	if !strings.HasPrefix(branchName, "codeagent") {
+	// 确保只删除CodeAgent创建的分支
+	if !workspace.IsAgentBranch(branchName, prefix) {
 		log.Warnf("Branch %s is not a CodeAgent branch, skipping deletion", branchName)
 		return nil
 	}
@@ -745,6 +780,475 @@ func (c *Client) GetComment(ctx context.Context, owner, repo string, commentID i
 	return comment, nil
 }
 
+// GetPermissionLevel 获取用户在仓库上的角色（admin、maintain、write、triage、read、none）
+func (c *Client) GetPermissionLevel(ctx context.Context, owner, repo, user string) (string, error) {
+	level, resp, err := c.client.Repositories.GetPermissionLevel(ctx, owner, repo, user)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			// 不是协作者
+			return "none", nil
+		}
+		return "", fmt.Errorf("failed to get permission level for %s: %w", user, err)
+	}
+
+	// role_name 区分 maintain/triage 等细分角色，permission 只有 admin/write/read/none
+	if role := level.GetUser().GetRoleName(); role != "" {
+		return role, nil
+	}
+	return level.GetPermission(), nil
+}
+
+// ErrFileNotFound 仓库中不存在该文件
+var ErrFileNotFound = errors.New("file not found")
+
+// GetDefaultBranchSHA 获取仓库默认分支最新提交的SHA
+func (c *Client) GetDefaultBranchSHA(ctx context.Context, owner, repo string) (string, error) {
+	repository, _, err := c.client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository info: %w", err)
+	}
+	branch, _, err := c.client.Repositories.GetBranch(ctx, owner, repo, repository.GetDefaultBranch(), 1)
+	if err != nil {
+		return "", fmt.Errorf("failed to get default branch %s: %w", repository.GetDefaultBranch(), err)
+	}
+	return branch.GetCommit().GetSHA(), nil
+}
+
+// GetFileContent 获取文件在指定ref上的内容，文件不存在时返回 ErrFileNotFound
+func (c *Client) GetFileContent(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	file, _, resp, err := c.client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to get %s@%s: %w", path, ref, err)
+	}
+	if file == nil {
+		// 路径是目录
+		return nil, ErrFileNotFound
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return []byte(content), nil
+}
+
+// DownloadDirectory 将仓库中dir目录在ref上的文件递归下载到本地dest，目录不存在时返回 ErrFileNotFound
+func (c *Client) DownloadDirectory(ctx context.Context, owner, repo, dir, ref, dest string) error {
+	_, entries, resp, err := c.client.Repositories.GetContents(ctx, owner, repo, dir, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return ErrFileNotFound
+		}
+		return fmt.Errorf("failed to list %s@%s: %w", dir, ref, err)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dest, err)
+	}
+	for _, entry := range entries {
+		target := filepath.Join(dest, filepath.Base(entry.GetName()))
+		switch entry.GetType() {
+		case "dir":
+			if err := c.DownloadDirectory(ctx, owner, repo, entry.GetPath(), ref, target); err != nil {
+				return err
+			}
+		case "file":
+			content, err := c.GetFileContent(ctx, owner, repo, entry.GetPath(), ref)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(target, content, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", target, err)
+			}
+		}
+	}
+	return nil
+}
+
+// CompareCommits 比较两个提交，返回 base..head 之间的提交和文件变更
+func (c *Client) CompareCommits(ctx context.Context, owner, repo, base, head string) (*github.CommitsComparison, error) {
+	comparison, _, err := c.client.Repositories.CompareCommits(ctx, owner, repo, base, head, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare %s...%s: %w", base, head, err)
+	}
+	return comparison, nil
+}
+
+// ListPullRequestFiles 获取PR变更的全部文件及完整diff
+func (c *Client) ListPullRequestFiles(ctx context.Context, owner, repo string, number int) ([]*github.CommitFile, error) {
+	var all []*github.CommitFile
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		files, resp, err := c.client.PullRequests.ListFiles(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of PR #%d: %w", number, err)
+		}
+		all = append(all, files...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// CreateReview 在PR上提交一个包含行内评论的审查
+func (c *Client) CreateReview(ctx context.Context, owner, repo string, number int, review *github.PullRequestReviewRequest) (*github.PullRequestReview, error) {
+	created, _, err := c.client.PullRequests.CreateReview(ctx, owner, repo, number, review)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create review on PR #%d: %w", number, err)
+	}
+	log.Infof("Created review %d on PR #%d with %d inline comments", created.GetID(), number, len(review.Comments))
+	return created, nil
+}
+
+// CreateCheckRun 在提交上创建check run（需要以GitHub App身份调用）
+func (c *Client) CreateCheckRun(ctx context.Context, owner, repo string, opts github.CreateCheckRunOptions) (*github.CheckRun, error) {
+	checkRun, _, err := c.client.Checks.CreateCheckRun(ctx, owner, repo, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create check run %s on %s: %w", opts.Name, opts.HeadSHA, err)
+	}
+	log.Infof("Created check run %s (%d) on %s/%s@%s", opts.Name, checkRun.GetID(), owner, repo, opts.HeadSHA)
+	return checkRun, nil
+}
+
+// UpdateCheckRun 更新check run的状态、结论和输出
+func (c *Client) UpdateCheckRun(ctx context.Context, owner, repo string, checkRunID int64, opts github.UpdateCheckRunOptions) (*github.CheckRun, error) {
+	checkRun, _, err := c.client.Checks.UpdateCheckRun(ctx, owner, repo, checkRunID, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update check run %d: %w", checkRunID, err)
+	}
+	log.Infof("Updated check run %s (%d) to %s", opts.Name, checkRunID, opts.GetStatus())
+	return checkRun, nil
+}
+
+// failedConclusions 视为CI失败的check run结论
+var failedConclusions = map[string]bool{
+	"failure":         true,
+	"timed_out":       true,
+	"startup_failure": true,
+}
+
+// maxJobLogBytes 下载job日志时最多保留的字节数，失败信息在日志末尾，因此保留末尾部分
+const maxJobLogBytes = 4 << 20
+
+// ListFailedCheckRuns 获取ref上最近一次运行失败的check run，忽略CodeAgent自己创建的检查
+func (c *Client) ListFailedCheckRuns(ctx context.Context, owner, repo, ref string) ([]*github.CheckRun, error) {
+	return c.listCheckRuns(ctx, owner, repo, ref, func(run *github.CheckRun) bool {
+		return failedConclusions[run.GetConclusion()]
+	})
+}
+
+// ListPendingCheckRuns 获取ref上还没有完成的check run，忽略CodeAgent自己创建的检查
+func (c *Client) ListPendingCheckRuns(ctx context.Context, owner, repo, ref string) ([]*github.CheckRun, error) {
+	return c.listCheckRuns(ctx, owner, repo, ref, func(run *github.CheckRun) bool {
+		return run.GetStatus() != "completed"
+	})
+}
+
+// listCheckRuns 获取ref上每个检查最近一次运行中满足match的check run
+func (c *Client) listCheckRuns(ctx context.Context, owner, repo, ref string, match func(*github.CheckRun) bool) ([]*github.CheckRun, error) {
+	var runs []*github.CheckRun
+	opts := &github.ListCheckRunsOptions{
+		Filter:      github.String("latest"),
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		result, resp, err := c.client.Checks.ListCheckRunsForRef(ctx, owner, repo, ref, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list check runs for %s: %w", ref, err)
+		}
+		for _, run := range result.CheckRuns {
+			if !match(run) || strings.HasPrefix(run.GetName(), "codeagent/") {
+				continue
+			}
+			runs = append(runs, run)
+		}
+		if resp.NextPage == 0 {
+			return runs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetJobLogs 下载GitHub Actions job的纯文本日志；日志过大时只保留末尾部分
+func (c *Client) GetJobLogs(ctx context.Context, owner, repo string, jobID int64) (string, error) {
+	logURL, _, err := c.client.Actions.GetWorkflowJobLogs(ctx, owner, repo, jobID, 3)
+	if err != nil {
+		return "", fmt.Errorf("failed to get logs url of job %d: %w", jobID, err)
+	}
+
+	// 日志地址是预签名的下载链接，不能携带GitHub凭证
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, logURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create logs request of job %d: %w", jobID, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download logs of job %d: %w", jobID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download logs of job %d: unexpected status %s", jobID, resp.Status)
+	}
+
+	var tail []byte
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := resp.Body.Read(buf)
+		tail = append(tail, buf[:n]...)
+		if len(tail) > 2*maxJobLogBytes {
+			tail = append([]byte(nil), tail[len(tail)-maxJobLogBytes:]...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read logs of job %d: %w", jobID, err)
+		}
+	}
+	if len(tail) > maxJobLogBytes {
+		tail = tail[len(tail)-maxJobLogBytes:]
+	}
+	return string(tail), nil
+}
+
+const reviewThreadsQuery = `query($owner: String!, $repo: String!, $number: Int!) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100) {
+        nodes {
+          isResolved
+          isOutdated
+          path
+          line
+          originalLine
+          comments(first: 1) { nodes { body author { login } } }
+        }
+      }
+    }
+  }
+}`
+
+// ListReviewThreads 获取PR上的代码审查讨论及其是否已解决；REST接口不提供解决状态，因此使用GraphQL
+func (c *Client) ListReviewThreads(ctx context.Context, owner, repo string, number int) ([]models.ReviewThread, error) {
+	payload := map[string]interface{}{
+		"query": reviewThreadsQuery,
+		"variables": map[string]interface{}{
+			"owner":  owner,
+			"repo":   repo,
+			"number": number,
+		},
+	}
+	// BaseURL 为 https://api.github.com/ 或 GitHub Enterprise 的 https://host/api/v3/，GraphQL 接口分别位于 /graphql 和 /api/graphql
+	req, err := c.client.NewRequest(http.MethodPost, "../graphql", payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build review threads query: %w", err)
+	}
+
+	var result struct {
+		Data struct {
+			Repository struct {
+				PullRequest struct {
+					ReviewThreads struct {
+						Nodes []struct {
+							IsResolved   bool   `json:"isResolved"`
+							IsOutdated   bool   `json:"isOutdated"`
+							Path         string `json:"path"`
+							Line         int    `json:"line"`
+							OriginalLine int    `json:"originalLine"`
+							Comments     struct {
+								Nodes []struct {
+									Body   string `json:"body"`
+									Author struct {
+										Login string `json:"login"`
+									} `json:"author"`
+								} `json:"nodes"`
+							} `json:"comments"`
+						} `json:"nodes"`
+					} `json:"reviewThreads"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := c.client.Do(ctx, req, &result); err != nil {
+		return nil, fmt.Errorf("failed to query review threads: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("failed to query review threads: %s", result.Errors[0].Message)
+	}
+
+	var threads []models.ReviewThread
+	for _, node := range result.Data.Repository.PullRequest.ReviewThreads.Nodes {
+		thread := models.ReviewThread{
+			Path:     node.Path,
+			Line:     node.Line,
+			Resolved: node.IsResolved,
+			Outdated: node.IsOutdated,
+		}
+		if thread.Line == 0 {
+			// 过时的讨论没有当前行号
+			thread.Line = node.OriginalLine
+		}
+		if len(node.Comments.Nodes) > 0 {
+			thread.Author = node.Comments.Nodes[0].Author.Login
+			thread.Body = node.Comments.Nodes[0].Body
+		}
+		threads = append(threads, thread)
+	}
+	return threads, nil
+}
+
+// ListOpenIssues 按更新时间从早到晚遍历仓库中未关闭的Issue（不含PR），label非空时只遍历带该标签的Issue；
+// fn返回false时停止遍历
+func (c *Client) ListOpenIssues(ctx context.Context, owner, repo, label string, fn func(*github.Issue) bool) error {
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		Sort:        "updated",
+		Direction:   "asc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	if label != "" {
+		opts.Labels = []string{label}
+	}
+	for {
+		issues, resp, err := c.client.Issues.ListByRepo(ctx, owner, repo, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list issues: %w", err)
+		}
+		for _, issue := range issues {
+			if issue.IsPullRequest() {
+				continue
+			}
+			if !fn(issue) {
+				return nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetLastIssueComment 获取Issue的最后一条评论，没有评论时返回nil
+func (c *Client) GetLastIssueComment(ctx context.Context, owner, repo string, issue *github.Issue) (*github.IssueComment, error) {
+	if issue.GetComments() == 0 {
+		return nil, nil
+	}
+	// 评论按创建时间排序，每页一条时最后一页即最后一条评论
+	comments, _, err := c.client.Issues.ListComments(ctx, owner, repo, issue.GetNumber(), &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: 1, Page: issue.GetComments()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments of issue #%d: %w", issue.GetNumber(), err)
+	}
+	if len(comments) == 0 {
+		return nil, nil
+	}
+	return comments[len(comments)-1], nil
+}
+
+// FindLastIssueComment 返回Issue/PR中最后一条满足match的评论，没有时返回nil
+func (c *Client) FindLastIssueComment(ctx context.Context, owner, repo string, number int, match func(*github.IssueComment) bool) (*github.IssueComment, error) {
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	var found *github.IssueComment
+	for {
+		comments, resp, err := c.client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list comments of #%d: %w", number, err)
+		}
+		for _, comment := range comments {
+			if match(comment) {
+				found = comment
+			}
+		}
+		if resp.NextPage == 0 {
+			return found, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// CreateIssue 创建Issue
+func (c *Client) CreateIssue(ctx context.Context, owner, repo, title, body string, labels []string) (*github.Issue, error) {
+	issue, _, err := c.client.Issues.Create(ctx, owner, repo, &github.IssueRequest{
+		Title:  github.String(title),
+		Body:   github.String(body),
+		Labels: &labels,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create issue: %w", err)
+	}
+	return issue, nil
+}
+
+// AddLabels 为Issue或PR添加标签，不存在的标签会被自动创建
+func (c *Client) AddLabels(ctx context.Context, owner, repo string, number int, labels ...string) error {
+	if _, _, err := c.client.Issues.AddLabelsToIssue(ctx, owner, repo, number, labels); err != nil {
+		return fmt.Errorf("failed to add labels to #%d: %w", number, err)
+	}
+	return nil
+}
+
+// RemoveLabel 移除Issue或PR上的标签，标签不存在时不报错
+func (c *Client) RemoveLabel(ctx context.Context, owner, repo string, number int, label string) error {
+	resp, err := c.client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, label)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to remove label %s from #%d: %w", label, number, err)
+	}
+	return nil
+}
+
+// CloseIssue 关闭Issue，reason 为 completed 或 not_planned
+func (c *Client) CloseIssue(ctx context.Context, owner, repo string, number int, reason string) error {
+	_, _, err := c.client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{
+		State:       github.String("closed"),
+		StateReason: github.String(reason),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to close issue #%d: %w", number, err)
+	}
+	return nil
+}
+
+// ClosePullRequest 关闭PR而不合并
+func (c *Client) ClosePullRequest(ctx context.Context, owner, repo string, number int) error {
+	_, _, err := c.client.PullRequests.Edit(ctx, owner, repo, number, &github.PullRequest{State: github.String("closed")})
+	if err != nil {
+		return fmt.Errorf("failed to close PR #%d: %w", number, err)
+	}
+	return nil
+}
+
+// ListOpenPullRequests 按创建时间从早到晚获取仓库中未关闭的PR
+func (c *Client) ListOpenPullRequests(ctx context.Context, owner, repo string) ([]*github.PullRequest, error) {
+	var all []*github.PullRequest
+	opts := &github.PullRequestListOptions{
+		State:       "open",
+		Sort:        "created",
+		Direction:   "asc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		prs, resp, err := c.client.PullRequests.List(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pull requests: %w", err)
+		}
+		all = append(all, prs...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
 // GetClient 获取底层的GitHub客户端（用于MCP服务器）
 func (c *Client) GetClient() *github.Client {
 	return c.client
@@ -28,6 +28,7 @@ type ClientManager struct {
 	config        *config.Config     // 配置
 	clientCache   map[string]*Client // 客户端缓存，key为"owner"
 	cacheMutex    sync.RWMutex       // 缓存读写锁
+	appLogin      string             // GitHub App的机器人账号，首次创建安装客户端时获取
 }
 
 // NewClientManager 创建客户端管理器
@@ -129,8 +130,14 @@ func (m *ClientManager) createClientForRepo(ctx context.Context, repo *models.Re
 		}
 
 		log.Infof("✅ Created GitHub App installation client for organization: %s (Installation ID: %d)", repo.Owner, installationID)
+		// 安装token无法查询 /user，机器人账号通过App信息确定
+		login, err := m.getAppLogin(ctx)
+		if err != nil {
+			log.Warnf("Failed to get GitHub App login: %v", err)
+		}
 		return &Client{
 			client: githubClient,
+			login:  login,
 		}, nil
 	}
 
@@ -145,6 +152,23 @@ func (m *ClientManager) createClientForRepo(ctx context.Context, repo *models.Re
 	}, nil
 }
 
+// getAppLogin 返回GitHub App发布评论时使用的账号 <slug>[bot]，调用方需要持有 cacheMutex
+func (m *ClientManager) getAppLogin(ctx context.Context) (string, error) {
+	if m.appLogin != "" {
+		return m.appLogin, nil
+	}
+	appClient, err := m.authenticator.GetClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get app client: %w", err)
+	}
+	app, _, err := appClient.Apps.Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get app information: %w", err)
+	}
+	m.appLogin = app.GetSlug() + "[bot]"
+	return m.appLogin, nil
+}
+
 // findInstallationForOrg 查找组织对应的GitHub App安装ID
 func (m *ClientManager) findInstallationForOrg(ctx context.Context, owner string) (int64, error) {
 	// 获取App客户端
@@ -0,0 +1,187 @@
+package interaction
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// activityUpdateInterval 活动摘要刷新评论的最小间隔，避免触发GitHub API限流
+	activityUpdateInterval = 15 * time.Second
+	// maxActivityEntries 内存中保留的活动记录上限
+	maxActivityEntries = 1000
+	// maxExecutionLogLength 最终评论中执行日志的长度上限（GitHub评论上限为65536字符）
+	maxExecutionLogLength = 40000
+
+	digestRecentTools = 8  // 实时摘要中展示的最近工具调用数
+	digestRecentFiles = 10 // 实时摘要中展示的最近编辑文件数
+	digestMessageLen  = 300
+)
+
+// ActivityKind AI执行过程中的活动类型
+type ActivityKind string
+
+const (
+	ActivityMessage   ActivityKind = "message"    // AI输出的消息
+	ActivityTool      ActivityKind = "tool"       // 调用工具
+	ActivityFileEdit  ActivityKind = "file_edit"  // 编辑文件
+	ActivityToolError ActivityKind = "tool_error" // 工具执行失败
+)
+
+// Activity 一条AI执行活动
+type Activity struct {
+	Kind    ActivityKind
+	Summary string // 消息内容、工具调用摘要或文件路径
+	Time    time.Time
+}
+
+// icon 返回活动类型对应的图标
+func (a Activity) icon() string {
+	switch a.Kind {
+	case ActivityMessage:
+		return "💬"
+	case ActivityTool:
+		return "🔧"
+	case ActivityFileEdit:
+		return "✏️"
+	case ActivityToolError:
+		return "⚠️"
+	default:
+		return "•"
+	}
+}
+
+// ActivityLog 记录AI执行过程中的活动，用于渲染实时摘要和最终执行日志
+type ActivityLog struct {
+	entries     []Activity
+	dropped     int      // 超出上限被丢弃的旧记录数
+	files       []string // 按最近编辑排序的文件列表
+	toolCalls   int
+	lastMessage string
+}
+
+// Add 追加一条活动记录
+func (l *ActivityLog) Add(activity Activity) {
+	if activity.Time.IsZero() {
+		activity.Time = time.Now()
+	}
+
+	switch activity.Kind {
+	case ActivityMessage:
+		l.lastMessage = activity.Summary
+	case ActivityTool:
+		l.toolCalls++
+	case ActivityFileEdit:
+		l.touchFile(activity.Summary)
+	}
+
+	l.entries = append(l.entries, activity)
+	if len(l.entries) > maxActivityEntries {
+		l.dropped += len(l.entries) - maxActivityEntries
+		l.entries = append([]Activity(nil), l.entries[len(l.entries)-maxActivityEntries:]...)
+	}
+}
+
+// Len 返回记录过的活动总数
+func (l *ActivityLog) Len() int {
+	return len(l.entries) + l.dropped
+}
+
+func (l *ActivityLog) touchFile(path string) {
+	for i, file := range l.files {
+		if file == path {
+			l.files = append(l.files[:i], l.files[i+1:]...)
+			break
+		}
+	}
+	l.files = append(l.files, path)
+}
+
+// RenderDigest 渲染折叠的实时活动摘要：正在编辑的文件、最近调用的工具和最后一条消息
+func (l *ActivityLog) RenderDigest() string {
+	if l.Len() == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n<details>\n<summary>🔍 Live activity (%d tool calls, %d files edited)</summary>\n\n",
+		l.toolCalls, len(l.files)))
+
+	if len(l.files) > 0 {
+		sb.WriteString("**Files being edited**\n")
+		start := max(0, len(l.files)-digestRecentFiles)
+		for i := len(l.files) - 1; i >= start; i-- {
+			sb.WriteString(fmt.Sprintf("- `%s`\n", l.files[i]))
+		}
+		sb.WriteString("\n")
+	}
+
+	var recent []Activity
+	for i := len(l.entries) - 1; i >= 0 && len(recent) < digestRecentTools; i-- {
+		if kind := l.entries[i].Kind; kind == ActivityTool || kind == ActivityToolError {
+			recent = append(recent, l.entries[i])
+		}
+	}
+	if len(recent) > 0 {
+		sb.WriteString("**Recent tools**\n")
+		for _, activity := range recent {
+			sb.WriteString(fmt.Sprintf("- %s %s\n", activity.icon(), activity.Summary))
+		}
+		sb.WriteString("\n")
+	}
+
+	if l.lastMessage != "" {
+		sb.WriteString("**Last message**\n")
+		sb.WriteString(fmt.Sprintf("> %s\n\n", strings.ReplaceAll(truncateRunes(l.lastMessage, digestMessageLen), "\n", "\n> ")))
+	}
+
+	sb.WriteString("</details>\n")
+	return sb.String()
+}
+
+// RenderLog 渲染折叠的完整执行日志，超出长度上限时省略最早的记录
+func (l *ActivityLog) RenderLog() string {
+	if l.Len() == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(l.entries))
+	length := 0
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		activity := l.entries[i]
+		summary := activity.Summary
+		if activity.Kind == ActivityMessage {
+			summary = strings.ReplaceAll(truncateRunes(summary, digestMessageLen), "\n", " ")
+		}
+		line := fmt.Sprintf("- `%s` %s %s\n", activity.Time.Format("15:04:05"), activity.icon(), summary)
+		if length+len(line) > maxExecutionLogLength {
+			break
+		}
+		length += len(line)
+		lines = append(lines, line)
+	}
+	omitted := l.Len() - len(lines)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n<details>\n<summary>📜 Execution log (%d events)</summary>\n\n", l.Len()))
+	if omitted > 0 {
+		sb.WriteString(fmt.Sprintf("*%d earlier events omitted*\n\n", omitted))
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		sb.WriteString(lines[i])
+	}
+	sb.WriteString("\n</details>\n")
+	return sb.String()
+}
+
+// truncateRunes 按字符截断文本
+func truncateRunes(text string, limit int) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit]) + "..."
+}
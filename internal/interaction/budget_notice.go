@@ -0,0 +1,26 @@
+package interaction
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RenderBudgetExceededComment 渲染因预算用完而拒绝执行的评论内容
+func RenderBudgetExceededComment(user, reason string, resetAt time.Time) string {
+	var sb strings.Builder
+
+	sb.WriteString("## 💸 CodeAgent budget exceeded\n\n")
+	if user != "" {
+		sb.WriteString(fmt.Sprintf("@%s ", user))
+	}
+	sb.WriteString("this request was not run. ")
+	sb.WriteString(reason)
+	sb.WriteString("\n\n")
+	if !resetAt.IsZero() {
+		sb.WriteString(fmt.Sprintf("The budget resets at **%s**. ", resetAt.UTC().Format("2006-01-02 15:04 MST")))
+	}
+	sb.WriteString("Please try again later or ask a CodeAgent administrator to raise the limit.\n")
+
+	return sb.String()
+}
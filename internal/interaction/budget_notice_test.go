@@ -0,0 +1,18 @@
+package interaction
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderBudgetExceededComment(t *testing.T) {
+	content := RenderBudgetExceededComment("alice", "The daily budget of **$5.00** for repo `org/a` is used up ($5.20 used).",
+		time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC))
+
+	assert.Contains(t, content, "CodeAgent budget exceeded")
+	assert.Contains(t, content, "@alice this request was not run.")
+	assert.Contains(t, content, "repo `org/a`")
+	assert.Contains(t, content, "2025-03-16 00:00 UTC")
+}
@@ -0,0 +1,209 @@
+package interaction
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/pkg/models"
+
+	githubapi "github.com/google/go-github/v58/github"
+)
+
+// maxCheckRunSummary check run总结的长度上限（GitHub 限制为65535字符）
+const maxCheckRunSummary = 60000
+
+// GitHubCheckRunClient GitHub check run客户端接口
+type GitHubCheckRunClient interface {
+	CreateCheckRun(ctx context.Context, owner, repo string, opts githubapi.CreateCheckRunOptions) (*githubapi.CheckRun, error)
+	UpdateCheckRun(ctx context.Context, owner, repo string, checkRunID int64, opts githubapi.UpdateCheckRunOptions) (*githubapi.CheckRun, error)
+}
+
+// TaskReporter 任务生命周期报告接口：任务排队、开始执行和执行结束时调用
+type TaskReporter interface {
+	Queued(ctx context.Context) error
+	Started(ctx context.Context) error
+	Completed(ctx context.Context, result *models.ProgressExecutionResult) error
+}
+
+// CheckRunReporter 将任务状态报告为head提交上的check run：queued → in_progress → completed
+type CheckRunReporter struct {
+	github     GitHubCheckRunClient
+	repo       *githubapi.Repository
+	name       string
+	headSHA    string
+	detailsURL string
+	checkRunID *int64
+	mu         sync.Mutex
+}
+
+var _ TaskReporter = (*CheckRunReporter)(nil)
+
+// NewCheckRunReporter 创建check run报告器，check run在第一次报告状态时才会创建
+func NewCheckRunReporter(github GitHubCheckRunClient, repo *githubapi.Repository, name, headSHA, detailsURL string) *CheckRunReporter {
+	return &CheckRunReporter{
+		github:     github,
+		repo:       repo,
+		name:       name,
+		headSHA:    headSHA,
+		detailsURL: detailsURL,
+	}
+}
+
+// HeadSHA 返回check run所在的提交
+func (r *CheckRunReporter) HeadSHA() string {
+	return r.headSHA
+}
+
+// Queued 创建排队状态的check run
+func (r *CheckRunReporter) Queued(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.checkRunID != nil {
+		return nil
+	}
+	return r.createLocked(ctx, githubapi.CreateCheckRunOptions{
+		Status: githubapi.String("queued"),
+		Output: &githubapi.CheckRunOutput{
+			Title:   githubapi.String("Queued"),
+			Summary: githubapi.String("Waiting for other CodeAgent tasks on this repository to finish."),
+		},
+	})
+}
+
+// Started 将check run更新为执行中，未创建过时直接创建
+func (r *CheckRunReporter) Started(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	startedAt := githubapi.Timestamp{Time: time.Now()}
+	output := &githubapi.CheckRunOutput{
+		Title:   githubapi.String("In progress"),
+		Summary: githubapi.String("CodeAgent is working on this task."),
+	}
+	if r.checkRunID == nil {
+		return r.createLocked(ctx, githubapi.CreateCheckRunOptions{
+			Status:    githubapi.String("in_progress"),
+			StartedAt: &startedAt,
+			Output:    output,
+		})
+	}
+	_, err := r.github.UpdateCheckRun(ctx, r.repo.GetOwner().GetLogin(), r.repo.GetName(), *r.checkRunID, githubapi.UpdateCheckRunOptions{
+		Name:   r.name,
+		Status: githubapi.String("in_progress"),
+		Output: output,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start check run: %w", err)
+	}
+	return nil
+}
+
+// Completed 以任务结果完成check run，未创建过时直接创建已完成的check run
+func (r *CheckRunReporter) Completed(ctx context.Context, result *models.ProgressExecutionResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	completedAt := githubapi.Timestamp{Time: time.Now()}
+	conclusion := CheckRunConclusion(result)
+	output := RenderCheckRunOutput(result)
+	if r.checkRunID == nil {
+		return r.createLocked(ctx, githubapi.CreateCheckRunOptions{
+			Status:      githubapi.String("completed"),
+			Conclusion:  githubapi.String(conclusion),
+			CompletedAt: &completedAt,
+			Output:      output,
+		})
+	}
+	_, err := r.github.UpdateCheckRun(ctx, r.repo.GetOwner().GetLogin(), r.repo.GetName(), *r.checkRunID, githubapi.UpdateCheckRunOptions{
+		Name:        r.name,
+		Status:      githubapi.String("completed"),
+		Conclusion:  githubapi.String(conclusion),
+		CompletedAt: &completedAt,
+		Output:      output,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete check run: %w", err)
+	}
+	return nil
+}
+
+func (r *CheckRunReporter) createLocked(ctx context.Context, opts githubapi.CreateCheckRunOptions) error {
+	opts.Name = r.name
+	opts.HeadSHA = r.headSHA
+	if r.detailsURL != "" {
+		opts.DetailsURL = githubapi.String(r.detailsURL)
+	}
+	checkRun, err := r.github.CreateCheckRun(ctx, r.repo.GetOwner().GetLogin(), r.repo.GetName(), opts)
+	if err != nil {
+		return fmt.Errorf("failed to create check run: %w", err)
+	}
+	r.checkRunID = checkRun.ID
+	return nil
+}
+
+// CheckRunConclusion 任务结果对应的check run结论
+func CheckRunConclusion(result *models.ProgressExecutionResult) string {
+	switch {
+	case result.Success:
+		return "success"
+	case result.Cancelled:
+		return "cancelled"
+	default:
+		return "failure"
+	}
+}
+
+// RenderCheckRunOutput 渲染check run的标题和总结，变更文件列在总结中
+func RenderCheckRunOutput(result *models.ProgressExecutionResult) *githubapi.CheckRunOutput {
+	var title string
+	switch {
+	case result.Success:
+		title = "CodeAgent completed successfully"
+	case result.Cancelled:
+		title = "CodeAgent task was cancelled"
+	default:
+		title = "CodeAgent encountered an error"
+	}
+
+	var sb strings.Builder
+	if result.Summary != "" {
+		sb.WriteString(result.Summary)
+		sb.WriteString("\n")
+	}
+	if len(result.FilesChanged) > 0 {
+		sb.WriteString("\n### Files Changed\n")
+		for _, file := range result.FilesChanged {
+			sb.WriteString(fmt.Sprintf("- `%s`\n", file))
+		}
+	}
+	if result.CommitSHA != "" {
+		sb.WriteString(fmt.Sprintf("\n### Commit\n%s\n", result.CommitSHA))
+	}
+	if result.PullRequestURL != "" {
+		sb.WriteString(fmt.Sprintf("\n### Pull Request\n[View Pull Request](%s)\n", result.PullRequestURL))
+	}
+	if result.Model != "" {
+		sb.WriteString(fmt.Sprintf("\n### Model\n%s\n", result.Model))
+	}
+	if result.Usage != nil {
+		sb.WriteString(fmt.Sprintf("\n### Usage\n%s\n", renderUsage(result.Usage)))
+	}
+	if !result.Success && !result.Cancelled && result.Error != "" {
+		sb.WriteString(fmt.Sprintf("\n### Error Details\n```\n%s\n```\n", result.Error))
+	}
+	sb.WriteString(fmt.Sprintf("\n*Completed in %s*\n", formatDuration(result.Duration)))
+
+	summary := sb.String()
+	if len(summary) > maxCheckRunSummary {
+		summary = summary[:maxCheckRunSummary] + "\n\n*(truncated)*\n"
+	}
+
+	return &githubapi.CheckRunOutput{
+		Title:   githubapi.String(title),
+		Summary: githubapi.String(summary),
+	}
+}
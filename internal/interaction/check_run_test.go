@@ -0,0 +1,86 @@
+package interaction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/pkg/models"
+
+	githubapi "github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockCheckRunClient 记录check run的创建和更新
+type mockCheckRunClient struct {
+	created []githubapi.CreateCheckRunOptions
+	updated []githubapi.UpdateCheckRunOptions
+}
+
+func (m *mockCheckRunClient) CreateCheckRun(ctx context.Context, owner, repo string, opts githubapi.CreateCheckRunOptions) (*githubapi.CheckRun, error) {
+	m.created = append(m.created, opts)
+	return &githubapi.CheckRun{ID: githubapi.Int64(int64(len(m.created)))}, nil
+}
+
+func (m *mockCheckRunClient) UpdateCheckRun(ctx context.Context, owner, repo string, checkRunID int64, opts githubapi.UpdateCheckRunOptions) (*githubapi.CheckRun, error) {
+	m.updated = append(m.updated, opts)
+	return &githubapi.CheckRun{ID: githubapi.Int64(checkRunID)}, nil
+}
+
+func TestCheckRunReporter_Flow(t *testing.T) {
+	client := &mockCheckRunClient{}
+	repo := &githubapi.Repository{
+		Name:  githubapi.String("test-repo"),
+		Owner: &githubapi.User{Login: githubapi.String("test-owner")},
+	}
+	ctx := context.Background()
+
+	reporter := NewCheckRunReporter(client, repo, "codeagent/task/code", "abc123", "")
+	require.NoError(t, reporter.Queued(ctx))
+	require.NoError(t, reporter.Started(ctx))
+	require.NoError(t, reporter.Completed(ctx, &models.ProgressExecutionResult{
+		Success:      true,
+		Summary:      "Implemented the feature.",
+		FilesChanged: []string{"main.go", "README.md"},
+		Duration:     90 * time.Second,
+	}))
+
+	require.Len(t, client.created, 1, "status changes must reuse the same check run")
+	assert.Equal(t, "codeagent/task/code", client.created[0].Name)
+	assert.Equal(t, "abc123", client.created[0].HeadSHA)
+	assert.Equal(t, "queued", client.created[0].GetStatus())
+
+	require.Len(t, client.updated, 2)
+	assert.Equal(t, "in_progress", client.updated[0].GetStatus())
+	final := client.updated[1]
+	assert.Equal(t, "completed", final.GetStatus())
+	assert.Equal(t, "success", final.GetConclusion())
+	assert.Contains(t, final.Output.GetSummary(), "Implemented the feature.")
+	assert.Contains(t, final.Output.GetSummary(), "- `main.go`")
+	assert.Contains(t, final.Output.GetSummary(), "- `README.md`")
+	assert.Empty(t, final.Output.Annotations, "changed files must not be annotated on the PR diff")
+}
+
+func TestCheckRunReporter_CompletedWithoutStart(t *testing.T) {
+	client := &mockCheckRunClient{}
+	repo := &githubapi.Repository{
+		Name:  githubapi.String("test-repo"),
+		Owner: &githubapi.User{Login: githubapi.String("test-owner")},
+	}
+
+	reporter := NewCheckRunReporter(client, repo, "codeagent/task/code", "def456", "")
+	require.NoError(t, reporter.Completed(context.Background(), &models.ProgressExecutionResult{Error: "boom"}))
+
+	require.Len(t, client.created, 1)
+	assert.Equal(t, "completed", client.created[0].GetStatus())
+	assert.Equal(t, "failure", client.created[0].GetConclusion())
+	assert.Contains(t, client.created[0].Output.GetSummary(), "boom")
+	assert.Empty(t, client.updated)
+}
+
+func TestCheckRunConclusion(t *testing.T) {
+	assert.Equal(t, "success", CheckRunConclusion(&models.ProgressExecutionResult{Success: true}))
+	assert.Equal(t, "cancelled", CheckRunConclusion(&models.ProgressExecutionResult{Cancelled: true}))
+	assert.Equal(t, "failure", CheckRunConclusion(&models.ProgressExecutionResult{}))
+}
@@ -0,0 +1,23 @@
+package interaction
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderPermissionDeniedComment 渲染因权限不足而拒绝执行命令的评论内容
+func RenderPermissionDeniedComment(user, command, role, required string) string {
+	var sb strings.Builder
+
+	sb.WriteString("## 🔒 CodeAgent permission required\n\n")
+	if user != "" {
+		sb.WriteString(fmt.Sprintf("Thanks for the request, @%s! ", user))
+	}
+	sb.WriteString(fmt.Sprintf("`%s` can only be run by users with at least **%s** access to this repository", command, required))
+	if role != "" {
+		sb.WriteString(fmt.Sprintf(", and your current access is **%s**", role))
+	}
+	sb.WriteString(".\n\nPlease ask a maintainer to run the command for you.\n")
+
+	return sb.String()
+}
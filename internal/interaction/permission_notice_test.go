@@ -0,0 +1,16 @@
+package interaction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderPermissionDeniedComment(t *testing.T) {
+	content := RenderPermissionDeniedComment("mallory", "/code", "read", "write")
+
+	assert.Contains(t, content, "CodeAgent permission required")
+	assert.Contains(t, content, "@mallory")
+	assert.Contains(t, content, "`/code` can only be run by users with at least **write** access")
+	assert.Contains(t, content, "your current access is **read**")
+}
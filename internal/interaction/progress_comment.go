@@ -27,6 +27,57 @@ type ProgressCommentManager struct {
 	lastUpdate  time.Time
 	updateMutex sync.Mutex
 	testMode    bool // 测试模式下不限制更新频率
+	queuedAt    time.Time
+	finalized   bool
+
+	// activityMu 保护活动记录，记录活动时不需要等待评论更新
+	activityMu sync.Mutex
+	activity   ActivityLog
+	// activitySeq 已记录的活动数，renderedSeq 最近一次写入评论的活动数
+	activitySeq int
+	renderedSeq int
+	// activityInterval 后台刷新实时摘要的间隔
+	activityInterval time.Duration
+	flushing         bool
+	flushStop        chan struct{}
+}
+
+// queuedCommentKey 排队时创建的进度评论在context中的key
+type queuedCommentKey struct{}
+
+// queuedComment 任务排队时在Issue/PR上创建的进度评论
+type queuedComment struct {
+	number    int
+	commentID int64
+}
+
+// WithQueuedComment 返回携带排队进度评论的context，任务开始后在同一Issue/PR上复用该评论
+func WithQueuedComment(ctx context.Context, issueNumber int, commentID int64) context.Context {
+	return context.WithValue(ctx, queuedCommentKey{}, queuedComment{number: issueNumber, commentID: commentID})
+}
+
+// QueuedComment 返回任务排队时在issueNumber上创建的进度评论ID
+func QueuedComment(ctx context.Context, issueNumber int) (int64, bool) {
+	comment, ok := ctx.Value(queuedCommentKey{}).(queuedComment)
+	if !ok || comment.number != issueNumber || comment.commentID == 0 {
+		return 0, false
+	}
+	return comment.commentID, true
+}
+
+// CreateProgressComment 在Issue/PR上创建进度评论；任务排队时已经创建过进度评论的，改为更新该评论
+func CreateProgressComment(ctx context.Context, github GitHubCommentClient, owner, repo string, issueNumber int, body string) (int64, error) {
+	if commentID, ok := QueuedComment(ctx, issueNumber); ok {
+		if err := github.UpdateComment(ctx, owner, repo, commentID, body); err != nil {
+			return 0, fmt.Errorf("failed to update queued comment: %w", err)
+		}
+		return commentID, nil
+	}
+	comment, err := github.CreateComment(ctx, owner, repo, issueNumber, body)
+	if err != nil {
+		return 0, err
+	}
+	return comment.GetID(), nil
 }
 
 // NewProgressCommentManager 创建进度评论管理器
@@ -38,8 +89,10 @@ func NewProgressCommentManager(github GitHubCommentClient, repo *githubapi.Repos
 			IssueNumber: issueNumber,
 			CreatedAt:   time.Now(),
 		},
-		tracker:  models.NewProgressTracker(),
-		testMode: false,
+		tracker:          models.NewProgressTracker(),
+		testMode:         false,
+		activityInterval: activityUpdateInterval,
+		flushStop:        make(chan struct{}),
 	}
 }
 
@@ -60,9 +113,10 @@ func (pcm *ProgressCommentManager) InitializeProgress(ctx context.Context, tasks
 	pcm.context.InitialContent = content
 	pcm.context.LastContent = content
 
-	// 创建GitHub评论
-	comment, err := pcm.github.CreateComment(
+	// 创建GitHub评论，任务排队时创建的评论会被复用
+	commentID, err := CreateProgressComment(
 		ctx,
+		pcm.github,
 		pcm.context.Repository.Owner.GetLogin(),
 		pcm.context.Repository.GetName(),
 		pcm.context.IssueNumber,
@@ -72,10 +126,53 @@ func (pcm *ProgressCommentManager) InitializeProgress(ctx context.Context, tasks
 		return fmt.Errorf("failed to create progress comment: %w", err)
 	}
 
-	pcm.context.CommentID = comment.ID
+	pcm.context.CommentID = &commentID
 	pcm.lastUpdate = time.Now()
 
-	xl.Infof("Created progress comment with ID: %d", *comment.ID)
+	xl.Infof("Created progress comment with ID: %d", commentID)
+	return nil
+}
+
+// UpdateQueuePosition 在进度评论中展示任务的排队位置，评论在第一次排队时创建
+func (pcm *ProgressCommentManager) UpdateQueuePosition(ctx context.Context, position int) error {
+	pcm.updateMutex.Lock()
+	defer pcm.updateMutex.Unlock()
+
+	if pcm.queuedAt.IsZero() {
+		pcm.queuedAt = time.Now()
+	}
+	content := pcm.renderQueuedComment(position)
+	pcm.context.LastContent = content
+
+	owner, repo := pcm.context.Repository.Owner.GetLogin(), pcm.context.Repository.GetName()
+	if pcm.context.CommentID == nil {
+		comment, err := pcm.github.CreateComment(ctx, owner, repo, pcm.context.IssueNumber, content)
+		if err != nil {
+			return fmt.Errorf("failed to create progress comment: %w", err)
+		}
+		pcm.context.CommentID = comment.ID
+		return nil
+	}
+	if err := pcm.github.UpdateComment(ctx, owner, repo, *pcm.context.CommentID, content); err != nil {
+		return fmt.Errorf("failed to update queue position: %w", err)
+	}
+	return nil
+}
+
+// MarkDequeued 任务出队开始执行时更新进度评论，未排队过时不做任何操作
+func (pcm *ProgressCommentManager) MarkDequeued(ctx context.Context) error {
+	pcm.updateMutex.Lock()
+	defer pcm.updateMutex.Unlock()
+
+	if pcm.context.CommentID == nil {
+		return nil
+	}
+	content := pcm.renderDequeuedComment()
+	pcm.context.LastContent = content
+	if err := pcm.github.UpdateComment(ctx, pcm.context.Repository.Owner.GetLogin(), pcm.context.Repository.GetName(), *pcm.context.CommentID, content); err != nil {
+		return fmt.Errorf("failed to update progress comment: %w", err)
+	}
+	pcm.lastUpdate = time.Now()
 	return nil
 }
 
@@ -141,6 +238,57 @@ func (pcm *ProgressCommentManager) HideSpinner(ctx context.Context) error {
 	return pcm.updateComment(ctx)
 }
 
+// RecordActivity 记录一条AI执行活动；实时摘要由后台按 activityUpdateInterval 刷新，不阻塞事件的读取
+func (pcm *ProgressCommentManager) RecordActivity(ctx context.Context, activity Activity) error {
+	pcm.activityMu.Lock()
+	defer pcm.activityMu.Unlock()
+
+	pcm.activity.Add(activity)
+	pcm.activitySeq++
+	if !pcm.flushing {
+		pcm.flushing = true
+		go pcm.flushActivity(ctx)
+	}
+	return nil
+}
+
+// flushActivity 定时将还没有写入评论的活动刷新到实时摘要，直到评论完成或ctx结束
+func (pcm *ProgressCommentManager) flushActivity(ctx context.Context) {
+	xl := xlog.NewWith(ctx)
+
+	ticker := time.NewTicker(pcm.activityInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pcm.flushStop:
+			return
+		case <-ticker.C:
+		}
+		if err := pcm.flushPendingActivity(ctx); err != nil {
+			xl.Warnf("Failed to flush activity: %v", err)
+		}
+	}
+}
+
+// flushPendingActivity 有新活动时更新评论
+func (pcm *ProgressCommentManager) flushPendingActivity(ctx context.Context) error {
+	pcm.updateMutex.Lock()
+	defer pcm.updateMutex.Unlock()
+
+	if pcm.finalized || pcm.context.CommentID == nil {
+		return nil
+	}
+	pcm.activityMu.Lock()
+	pending := pcm.activitySeq > pcm.renderedSeq
+	pcm.activityMu.Unlock()
+	if !pending {
+		return nil
+	}
+	return pcm.updateComment(ctx)
+}
+
 // FinalizeComment 完成评论（最终状态）
 func (pcm *ProgressCommentManager) FinalizeComment(ctx context.Context, result *models.ProgressExecutionResult) error {
 	xl := xlog.NewWith(ctx)
@@ -148,6 +296,11 @@ func (pcm *ProgressCommentManager) FinalizeComment(ctx context.Context, result *
 	pcm.updateMutex.Lock()
 	defer pcm.updateMutex.Unlock()
 
+	if !pcm.finalized {
+		pcm.finalized = true
+		close(pcm.flushStop)
+	}
+
 	// 更新跟踪器状态
 	if result.Success {
 		pcm.tracker.Complete()
@@ -194,6 +347,9 @@ func (pcm *ProgressCommentManager) updateComment(ctx context.Context) error {
 	}
 
 	// 生成当前进度内容
+	pcm.activityMu.Lock()
+	seq := pcm.activitySeq
+	pcm.activityMu.Unlock()
 	content := pcm.renderProgressUpdate()
 	pcm.context.LastContent = content
 
@@ -210,6 +366,7 @@ func (pcm *ProgressCommentManager) updateComment(ctx context.Context) error {
 	}
 
 	pcm.context.UpdateCount++
+	pcm.renderedSeq = seq
 	pcm.lastUpdate = time.Now()
 	now := time.Now()
 	pcm.context.LastUpdatedAt = &now
@@ -234,6 +391,31 @@ func (pcm *ProgressCommentManager) renderInitialComment() string {
 	return sb.String()
 }
 
+// renderQueuedComment 渲染排队中的进度评论内容
+func (pcm *ProgressCommentManager) renderQueuedComment(position int) string {
+	var sb strings.Builder
+
+	sb.WriteString("## ⏳ CodeAgent task queued\n\n")
+	sb.WriteString("Another CodeAgent task is already running, so this request will start as soon as a slot is free.\n\n")
+	sb.WriteString(fmt.Sprintf("**Queue position**: #%d\n", position))
+	sb.WriteString("\n---\n")
+	sb.WriteString(fmt.Sprintf("*Queued at: %s*\n", pcm.queuedAt.Format("15:04:05 MST")))
+
+	return sb.String()
+}
+
+// renderDequeuedComment 渲染出队后、任务初始化进度之前的评论内容
+func (pcm *ProgressCommentManager) renderDequeuedComment() string {
+	var sb strings.Builder
+
+	sb.WriteString("## 🤖 CodeAgent is working on this...\n\n")
+	sb.WriteString("This request has left the queue and is now running.\n")
+	sb.WriteString("\n---\n")
+	sb.WriteString(fmt.Sprintf("*Waited in queue for %s*\n", formatDuration(time.Since(pcm.queuedAt))))
+
+	return sb.String()
+}
+
 // renderProgressUpdate 渲染进度更新内容
 func (pcm *ProgressCommentManager) renderProgressUpdate() string {
 	var sb strings.Builder
@@ -275,6 +457,11 @@ func (pcm *ProgressCommentManager) renderProgressUpdate() string {
 			pcm.tracker.Spinner.Message))
 	}
 
+	// 实时活动摘要
+	pcm.activityMu.Lock()
+	sb.WriteString(pcm.activity.RenderDigest())
+	pcm.activityMu.Unlock()
+
 	// 进度信息
 	progress := pcm.tracker.GetOverallProgress()
 	completedTasks := pcm.tracker.GetCompletedTasksCount()
@@ -302,6 +489,8 @@ func (pcm *ProgressCommentManager) renderFinalComment(result *models.ProgressExe
 
 	if result.Success {
 		sb.WriteString("## ✅ CodeAgent completed successfully!\n\n")
+	} else if result.Cancelled {
+		sb.WriteString("## 🛑 CodeAgent task was cancelled\n\n")
 	} else {
 		sb.WriteString("## ❌ CodeAgent encountered an error\n\n")
 	}
@@ -346,11 +535,25 @@ func (pcm *ProgressCommentManager) renderFinalComment(result *models.ProgressExe
 		sb.WriteString(fmt.Sprintf("\n### Pull Request\n[View Pull Request](%s)\n", result.PullRequestURL))
 	}
 
+	if result.Model != "" {
+		sb.WriteString(fmt.Sprintf("\n### Model\n%s\n", result.Model))
+	}
+
+	if result.Usage != nil {
+		sb.WriteString(fmt.Sprintf("\n### Usage\n%s\n", renderUsage(result.Usage)))
+	}
+
 	// 错误信息
-	if !result.Success && result.Error != "" {
+	if !result.Success && !result.Cancelled && result.Error != "" {
 		sb.WriteString(fmt.Sprintf("\n### Error Details\n```\n%s\n```\n", result.Error))
+		sb.WriteString(renderPartialOutput(result.Output))
 	}
 
+	// 完整执行日志
+	pcm.activityMu.Lock()
+	sb.WriteString(pcm.activity.RenderLog())
+	pcm.activityMu.Unlock()
+
 	// 时间统计
 	sb.WriteString("\n---\n")
 	sb.WriteString(fmt.Sprintf("*Completed in %s*\n", formatDuration(result.Duration)))
@@ -358,6 +561,31 @@ func (pcm *ProgressCommentManager) renderFinalComment(result *models.ProgressExe
 	return sb.String()
 }
 
+// renderUsage 渲染AI调用次数、token用量和估算费用
+func renderUsage(u *models.TokenUsage) string {
+	line := fmt.Sprintf("%d AI call(s) · %s input / %s output tokens",
+		u.Calls, formatCount(u.InputTokens), formatCount(u.OutputTokens))
+	if cached := u.CacheReadTokens + u.CacheCreationTokens; cached > 0 {
+		line += fmt.Sprintf(" · %s cached", formatCount(cached))
+	}
+	if u.CostUSD > 0 {
+		line += fmt.Sprintf(" · est. $%.4f", u.CostUSD)
+	}
+	return line
+}
+
+// formatCount 格式化整数，每三位添加千位分隔符
+func formatCount(n int64) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	s := fmt.Sprintf("%d", n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
 // GetTracker 获取进度跟踪器
 func (pcm *ProgressCommentManager) GetTracker() *models.ProgressTracker {
 	return pcm.tracker
@@ -2,6 +2,8 @@ package interaction
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 
 // MockGitHubClient 用于测试的模拟GitHub客户端
 type MockGitHubClient struct {
+	mu       sync.Mutex
 	comments map[int64]string
 	nextID   int64
 }
@@ -26,6 +29,9 @@ func NewMockGitHubClient() *MockGitHubClient {
 }
 
 func (m *MockGitHubClient) CreateComment(ctx context.Context, owner, repo string, issueNumber int, body string) (*githubapi.IssueComment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	id := m.nextID
 	m.nextID++
 	m.comments[id] = body
@@ -37,11 +43,17 @@ func (m *MockGitHubClient) CreateComment(ctx context.Context, owner, repo string
 }
 
 func (m *MockGitHubClient) UpdateComment(ctx context.Context, owner, repo string, commentID int64, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.comments[commentID] = body
 	return nil
 }
 
 func (m *MockGitHubClient) GetComment(commentID int64) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	return m.comments[commentID]
 }
 
@@ -103,6 +115,8 @@ func TestProgressCommentManager_BasicFlow(t *testing.T) {
 		FilesChanged:   []string{"src/main.go", "src/utils.go"},
 		BranchName:     "feature/issue-123",
 		PullRequestURL: "https://github.com/test-owner/test-repo/pull/456",
+		Model:          "`gemini`, fallback from `claude`",
+		Usage:          &models.TokenUsage{Calls: 2, InputTokens: 12345, OutputTokens: 678, CacheReadTokens: 1000, CostUSD: 0.0512},
 		Duration:       30 * time.Second,
 	}
 
@@ -115,6 +129,8 @@ func TestProgressCommentManager_BasicFlow(t *testing.T) {
 	assert.Contains(t, finalContent, "Successfully implemented the requested feature")
 	assert.Contains(t, finalContent, "src/main.go")
 	assert.Contains(t, finalContent, "feature/issue-123")
+	assert.Contains(t, finalContent, "### Model\n`gemini`, fallback from `claude`")
+	assert.Contains(t, finalContent, "### Usage\n2 AI call(s) · 12,345 input / 678 output tokens · 1,000 cached · est. $0.0512")
 }
 
 func TestProgressCommentManager_TaskFailure(t *testing.T) {
@@ -169,6 +185,136 @@ func TestProgressCommentManager_TaskFailure(t *testing.T) {
 	assert.Contains(t, finalContent, "❌") // failed icon
 }
 
+func TestProgressCommentManager_Cancelled(t *testing.T) {
+	mockGitHub := NewMockGitHubClient()
+	repo := &githubapi.Repository{
+		Name: githubapi.String("test-repo"),
+		Owner: &githubapi.User{
+			Login: githubapi.String("test-owner"),
+		},
+	}
+
+	pcm := NewProgressCommentManager(mockGitHub, repo, 123)
+	pcm.SetTestMode(true)
+	ctx := context.Background()
+
+	err := pcm.InitializeProgress(ctx, []*models.Task{models.NewTask("task1", "First task")})
+	require.NoError(t, err)
+	err = pcm.UpdateTask(ctx, "task1", models.TaskStatusInProgress)
+	require.NoError(t, err)
+
+	result := &models.ProgressExecutionResult{
+		Success:   false,
+		Cancelled: true,
+		Error:     "context canceled",
+	}
+	err = pcm.FinalizeComment(ctx, result)
+	require.NoError(t, err)
+
+	finalContent := mockGitHub.GetComment(*pcm.context.CommentID)
+	assert.Contains(t, finalContent, "CodeAgent task was cancelled")
+	assert.NotContains(t, finalContent, "CodeAgent encountered an error")
+	assert.NotContains(t, finalContent, "Error Details")
+}
+
+func TestProgressCommentManager_Activity(t *testing.T) {
+	mockGitHub := NewMockGitHubClient()
+	repo := &githubapi.Repository{
+		Name: githubapi.String("test-repo"),
+		Owner: &githubapi.User{
+			Login: githubapi.String("test-owner"),
+		},
+	}
+
+	pcm := NewProgressCommentManager(mockGitHub, repo, 123)
+	pcm.SetTestMode(true)
+	pcm.activityInterval = 10 * time.Millisecond
+	ctx := context.Background()
+
+	err := pcm.InitializeProgress(ctx, []*models.Task{models.NewTask("task1", "First task")})
+	require.NoError(t, err)
+	commentID := *pcm.context.CommentID
+
+	// 记录活动不直接调用GitHub API，最后几条活动之后没有新活动也会由后台刷新
+	require.NoError(t, pcm.RecordActivity(ctx, Activity{Kind: ActivityTool, Summary: "`Bash`: `go test ./...`"}))
+	require.NoError(t, pcm.RecordActivity(ctx, Activity{Kind: ActivityFileEdit, Summary: "internal/foo.go"}))
+	require.NoError(t, pcm.RecordActivity(ctx, Activity{Kind: ActivityMessage, Summary: "Fixing the handler"}))
+	require.Eventually(t, func() bool {
+		return strings.Contains(mockGitHub.GetComment(commentID), "> Fixing the handler")
+	}, time.Second, 5*time.Millisecond)
+
+	content := mockGitHub.GetComment(commentID)
+	assert.Contains(t, content, "<summary>🔍 Live activity (1 tool calls, 1 files edited)</summary>")
+	assert.Contains(t, content, "- `internal/foo.go`")
+	assert.Contains(t, content, "🔧 `Bash`: `go test ./...`")
+	assert.Contains(t, content, "> Fixing the handler")
+
+	err = pcm.FinalizeComment(ctx, &models.ProgressExecutionResult{Success: true})
+	require.NoError(t, err)
+
+	finalContent := mockGitHub.GetComment(commentID)
+	assert.NotContains(t, finalContent, "Live activity")
+	assert.Contains(t, finalContent, "📜 Execution log (3 events)")
+	assert.Contains(t, finalContent, "✏️ internal/foo.go")
+
+	// 评论完成后后台不再刷新实时摘要
+	require.NoError(t, pcm.RecordActivity(ctx, Activity{Kind: ActivityMessage, Summary: "late"}))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, finalContent, mockGitHub.GetComment(commentID))
+}
+
+func TestProgressCommentManager_QueuePosition(t *testing.T) {
+	mockGitHub := NewMockGitHubClient()
+	repo := &githubapi.Repository{
+		Name: githubapi.String("test-repo"),
+		Owner: &githubapi.User{
+			Login: githubapi.String("test-owner"),
+		},
+	}
+	ctx := context.Background()
+
+	queued := NewProgressCommentManager(mockGitHub, repo, 42)
+
+	// 未排队时不创建评论
+	require.NoError(t, queued.MarkDequeued(ctx))
+	assert.Empty(t, mockGitHub.comments)
+
+	require.NoError(t, queued.UpdateQueuePosition(ctx, 2))
+	assert.Contains(t, mockGitHub.GetComment(1), "CodeAgent task queued")
+	assert.Contains(t, mockGitHub.GetComment(1), "**Queue position**: #2")
+
+	require.NoError(t, queued.UpdateQueuePosition(ctx, 1))
+	assert.Len(t, mockGitHub.comments, 1, "position updates must reuse the same comment")
+	assert.Contains(t, mockGitHub.GetComment(1), "**Queue position**: #1")
+
+	require.NoError(t, queued.MarkDequeued(ctx))
+	assert.Contains(t, mockGitHub.GetComment(1), "left the queue")
+
+	// 任务开始后在同一Issue/PR上的进度评论复用排队评论
+	ctx = WithQueuedComment(ctx, 42, *queued.GetContext().CommentID)
+	pcm := NewProgressCommentManager(mockGitHub, repo, 42)
+	require.NoError(t, pcm.InitializeProgress(ctx, []*models.Task{models.NewTask("task1", "First task")}))
+	assert.Len(t, mockGitHub.comments, 1)
+	assert.Contains(t, mockGitHub.GetComment(1), "First task")
+
+	// 其他Issue/PR上的进度评论不受影响
+	other := NewProgressCommentManager(mockGitHub, repo, 43)
+	require.NoError(t, other.InitializeProgress(ctx, []*models.Task{models.NewTask("task1", "First task")}))
+	assert.Len(t, mockGitHub.comments, 2)
+}
+
+func TestActivityLog_RenderLogTruncates(t *testing.T) {
+	var log ActivityLog
+	for i := 0; i < maxActivityEntries+5; i++ {
+		log.Add(Activity{Kind: ActivityTool, Summary: strings.Repeat("x", 100)})
+	}
+
+	assert.Equal(t, maxActivityEntries+5, log.Len())
+	rendered := log.RenderLog()
+	assert.Less(t, len(rendered), maxExecutionLogLength+500)
+	assert.Contains(t, rendered, "earlier events omitted")
+}
+
 func TestSpinnerState(t *testing.T) {
 	spinner := &models.SpinnerState{}
 
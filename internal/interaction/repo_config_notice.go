@@ -0,0 +1,27 @@
+package interaction
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderRepoConfigErrorComment 渲染仓库配置文件校验失败的评论内容
+func RenderRepoConfigErrorComment(path, sha string, problems []string) string {
+	var sb strings.Builder
+
+	sb.WriteString("## ⚠️ CodeAgent repository config is invalid\n\n")
+	sb.WriteString(fmt.Sprintf("`%s`", path))
+	if sha != "" {
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		sb.WriteString(fmt.Sprintf(" at `%s`", sha))
+	}
+	sb.WriteString(" on the default branch could not be applied:\n\n")
+	for _, problem := range problems {
+		sb.WriteString(fmt.Sprintf("- %s\n", problem))
+	}
+	sb.WriteString("\nCodeAgent is using the server defaults until the file is fixed.\n")
+
+	return sb.String()
+}
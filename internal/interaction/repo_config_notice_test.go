@@ -0,0 +1,19 @@
+package interaction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderRepoConfigErrorComment(t *testing.T) {
+	content := RenderRepoConfigErrorComment(".codeagent/config.yaml", "0123456789abcdef", []string{
+		`provider: unsupported provider "copilot"`,
+		"model: requires provider to be set",
+	})
+
+	assert.Contains(t, content, "repository config is invalid")
+	assert.Contains(t, content, "`.codeagent/config.yaml` at `0123456`")
+	assert.Contains(t, content, "- model: requires provider to be set\n")
+	assert.Contains(t, content, "server defaults")
+}
@@ -0,0 +1,48 @@
+package interaction
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxPartialOutputInComment 评论中展示的部分输出上限，超出时只保留末尾
+const maxPartialOutputInComment = 4000
+
+// RenderTimeoutComment 渲染AI调用超时的评论内容，附带超时前的部分输出
+func RenderTimeoutComment(provider string, timeout time.Duration, partialOutput string) string {
+	var sb strings.Builder
+
+	sb.WriteString("## ⏰ CodeAgent timed out\n\n")
+	sb.WriteString(fmt.Sprintf("The %s run was stopped after reaching the configured timeout of **%s**. ", provider, formatDuration(timeout)))
+	sb.WriteString("No further changes will be made for this request; you can retry with a narrower instruction.\n")
+	sb.WriteString(renderPartialOutput(partialOutput))
+
+	return sb.String()
+}
+
+// renderPartialOutput 将部分输出渲染为折叠块，没有输出时返回空字符串
+func renderPartialOutput(partialOutput string) string {
+	partialOutput = strings.TrimSpace(partialOutput)
+	if partialOutput == "" {
+		return ""
+	}
+
+	note := ""
+	if len(partialOutput) > maxPartialOutputInComment {
+		start := len(partialOutput) - maxPartialOutputInComment
+		for start < len(partialOutput) && !utf8.RuneStart(partialOutput[start]) {
+			start++
+		}
+		partialOutput = partialOutput[start:]
+		note = " (truncated)"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n<details>\n<summary>Partial output before the timeout%s</summary>\n\n", note))
+	sb.WriteString("```\n")
+	sb.WriteString(partialOutput)
+	sb.WriteString("\n```\n</details>\n")
+	return sb.String()
+}
@@ -0,0 +1,33 @@
+package interaction
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTimeoutComment(t *testing.T) {
+	content := RenderTimeoutComment("claude", 30*time.Minute, "Editing internal/foo.go\n")
+
+	assert.Contains(t, content, "CodeAgent timed out")
+	assert.Contains(t, content, "claude")
+	assert.Contains(t, content, "<details>")
+	assert.Contains(t, content, "Editing internal/foo.go")
+	assert.NotContains(t, content, "(truncated)")
+}
+
+func TestRenderTimeoutComment_TruncatesPartialOutput(t *testing.T) {
+	partial := strings.Repeat("a", maxPartialOutputInComment) + "tail"
+	content := RenderTimeoutComment("gemini", time.Minute, partial)
+
+	assert.Contains(t, content, "(truncated)")
+	assert.Contains(t, content, "tail")
+	assert.Less(t, len(content), maxPartialOutputInComment+500)
+}
+
+func TestRenderTimeoutComment_WithoutOutput(t *testing.T) {
+	content := RenderTimeoutComment("claude", time.Minute, "  ")
+	assert.NotContains(t, content, "<details>")
+}
@@ -0,0 +1,82 @@
+package modes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/qiniu/codeagent/internal/code"
+	"github.com/qiniu/codeagent/internal/interaction"
+
+	"github.com/qiniu/x/xlog"
+)
+
+// maxToolInputSummary 工具调用参数摘要的长度上限
+const maxToolInputSummary = 80
+
+// toolInputKeys 按优先级选取用于摘要的工具参数
+var toolInputKeys = []string{"command", "file_path", "path", "pattern", "url", "query", "description"}
+
+// recordActivity 将AI执行事件转发到进度评论的实时活动摘要
+func recordActivity(ctx context.Context, pcm *interaction.ProgressCommentManager) code.EventHandler {
+	xl := xlog.NewWith(ctx)
+	return func(event code.Event) {
+		activity, ok := activityFromEvent(event)
+		if !ok {
+			return
+		}
+		if err := pcm.RecordActivity(ctx, activity); err != nil {
+			xl.Warnf("Failed to record activity: %v", err)
+		}
+	}
+}
+
+// activityFromEvent 将结构化事件转换为进度评论中的活动记录
+func activityFromEvent(event code.Event) (interaction.Activity, bool) {
+	switch event.Type {
+	case code.EventText:
+		text := strings.TrimSpace(event.Text)
+		if text == "" {
+			return interaction.Activity{}, false
+		}
+		return interaction.Activity{Kind: interaction.ActivityMessage, Summary: text}, true
+	case code.EventToolCall:
+		// 编辑类工具由 file_edit 事件记录
+		if code.IsFileEditTool(event.ToolName) {
+			return interaction.Activity{}, false
+		}
+		return interaction.Activity{Kind: interaction.ActivityTool, Summary: summarizeToolCall(event)}, true
+	case code.EventFileEdit:
+		return interaction.Activity{Kind: interaction.ActivityFileEdit, Summary: event.FilePath}, true
+	case code.EventToolResult:
+		if !event.IsError {
+			return interaction.Activity{}, false
+		}
+		return interaction.Activity{Kind: interaction.ActivityToolError, Summary: "Tool failed: " + firstLine(event.Text, maxToolInputSummary)}, true
+	default:
+		return interaction.Activity{}, false
+	}
+}
+
+// summarizeToolCall 生成工具调用的单行摘要，如 `Bash`: go test ./...
+func summarizeToolCall(event code.Event) string {
+	summary := fmt.Sprintf("`%s`", event.ToolName)
+	for _, key := range toolInputKeys {
+		if value, ok := event.Input[key].(string); ok && value != "" {
+			return fmt.Sprintf("%s: `%s`", summary, strings.ReplaceAll(firstLine(value, maxToolInputSummary), "`", "'"))
+		}
+	}
+	return summary
+}
+
+// firstLine 返回文本的第一行，超出长度时截断
+func firstLine(text string, limit int) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i] + " ..."
+	}
+	if runes := []rune(text); len(runes) > limit {
+		text = string(runes[:limit]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,70 @@
+package modes
+
+import (
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/code"
+	"github.com/qiniu/codeagent/internal/interaction"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivityFromEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		event code.Event
+		want  interaction.Activity
+		ok    bool
+	}{
+		{
+			name:  "message",
+			event: code.Event{Type: code.EventText, Text: "  Looking at the handler\n"},
+			want:  interaction.Activity{Kind: interaction.ActivityMessage, Summary: "Looking at the handler"},
+			ok:    true,
+		},
+		{
+			name:  "tool call with command",
+			event: code.Event{Type: code.EventToolCall, ToolName: "Bash", Input: map[string]interface{}{"command": "go test ./...\ngo vet ./..."}},
+			want:  interaction.Activity{Kind: interaction.ActivityTool, Summary: "`Bash`: `go test ./... ...`"},
+			ok:    true,
+		},
+		{
+			name:  "tool call without known input",
+			event: code.Event{Type: code.EventToolCall, ToolName: "TodoWrite"},
+			want:  interaction.Activity{Kind: interaction.ActivityTool, Summary: "`TodoWrite`"},
+			ok:    true,
+		},
+		{
+			name:  "edit tool call is recorded as file edit",
+			event: code.Event{Type: code.EventToolCall, ToolName: "Edit"},
+		},
+		{
+			name:  "file edit",
+			event: code.Event{Type: code.EventFileEdit, ToolName: "Edit", FilePath: "internal/foo.go"},
+			want:  interaction.Activity{Kind: interaction.ActivityFileEdit, Summary: "internal/foo.go"},
+			ok:    true,
+		},
+		{
+			name:  "failed tool result",
+			event: code.Event{Type: code.EventToolResult, Text: "edit failed", IsError: true},
+			want:  interaction.Activity{Kind: interaction.ActivityToolError, Summary: "Tool failed: edit failed"},
+			ok:    true,
+		},
+		{
+			name:  "successful tool result",
+			event: code.Event{Type: code.EventToolResult, Text: "ok"},
+		},
+		{
+			name:  "result",
+			event: code.Event{Type: code.EventResult, Text: "done"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := activityFromEvent(tt.event)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
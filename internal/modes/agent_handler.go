@@ -2,11 +2,13 @@ package modes
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
 
+	"github.com/qiniu/codeagent/internal/automation"
 	ghclient "github.com/qiniu/codeagent/internal/github"
 	"github.com/qiniu/codeagent/internal/mcp"
+	"github.com/qiniu/codeagent/internal/repoconfig"
 	"github.com/qiniu/codeagent/internal/workspace"
 	"github.com/qiniu/codeagent/pkg/models"
 
@@ -21,10 +23,13 @@ type AgentHandler struct {
 	clientManager ghclient.ClientManagerInterface
 	workspace     *workspace.Manager
 	mcpClient     mcp.MCPClient
+	tagHandler    *TagHandler
+	issues        *automation.Store
+	jobs          JobQueue
 }
 
-// NewAgentHandler 创建Agent模式处理器
-func NewAgentHandler(clientManager ghclient.ClientManagerInterface, workspace *workspace.Manager, mcpClient mcp.MCPClient) *AgentHandler {
+// NewAgentHandler 创建Agent模式处理器，匹配仓库自动化规则的Issue交给tagHandler按 /code 流程处理
+func NewAgentHandler(clientManager ghclient.ClientManagerInterface, workspace *workspace.Manager, mcpClient mcp.MCPClient, tagHandler *TagHandler, issues *automation.Store) *AgentHandler {
 	return &AgentHandler{
 		BaseHandler: NewBaseHandler(
 			AgentMode,
@@ -34,9 +39,16 @@ func NewAgentHandler(clientManager ghclient.ClientManagerInterface, workspace *w
 		clientManager: clientManager,
 		workspace:     workspace,
 		mcpClient:     mcpClient,
+		tagHandler:    tagHandler,
+		issues:        issues,
 	}
 }
 
+// SetJobQueue 设置拆分定时任务使用的任务队列
+func (ah *AgentHandler) SetJobQueue(jobs JobQueue) {
+	ah.jobs = jobs
+}
+
 // CanHandle 检查是否能处理给定的事件
 func (ah *AgentHandler) CanHandle(ctx context.Context, event models.GitHubContext) bool {
 	xl := xlog.NewWith(ctx)
@@ -52,14 +64,9 @@ func (ah *AgentHandler) CanHandle(ctx context.Context, event models.GitHubContex
 		prCtx := event.(*models.PullRequestContext)
 		return ah.canHandlePREvent(ctx, prCtx)
 
-	case models.EventWorkflowDispatch:
-		// 处理工作流调度事件
-		xl.Infof("Agent mode can handle workflow_dispatch events")
-		return true
-
-	case models.EventSchedule:
-		// 处理定时任务事件
-		xl.Infof("Agent mode can handle schedule events")
+	case models.EventWorkflowDispatch, models.EventSchedule:
+		// 定时任务及其手动触发
+		xl.Infof("Agent mode can handle %s events", event.GetEventType())
 		return true
 
 	default:
@@ -67,26 +74,34 @@ func (ah *AgentHandler) CanHandle(ctx context.Context, event models.GitHubContex
 	}
 }
 
-// canHandleIssuesEvent 检查是否能处理Issues事件
+// canHandleIssuesEvent 检查Issues事件是否可能匹配仓库的自动化规则；模板规则需要读取模板文件，在执行时再匹配
 func (ah *AgentHandler) canHandleIssuesEvent(ctx context.Context, event *models.IssuesContext) bool {
 	xl := xlog.NewWith(ctx)
+	rules := repoconfig.FromContext(ctx)
 
 	switch event.GetEventAction() {
 	case "assigned":
-		// Issue被分配给某人时自动触发
-		xl.Infof("Agent mode can handle issue assignment")
-		return true
+		// Issue被分配给规则中的用户时触发
+		_, ok := rules.IssueRuleForAssignee(issuesEventAssignee(event))
+		return ok
 
 	case "labeled":
-		// Issue被添加特定标签时触发
-		// 这里可以检查是否包含特定的标签（如"ai-assist", "codeagent"等）
-		xl.Infof("Agent mode can handle issue labeling")
-		return ah.hasAutoTriggerLabel(event.Issue)
+		// Issue被添加规则中的标签时触发
+		_, ok := rules.IssueRuleForLabel(issuesEventLabel(event))
+		return ok
 
 	case "opened":
-		// Issue创建时的自动处理（可选）
+		// 使用规则中的模板创建Issue时触发
 		xl.Debugf("Issue opened, checking for auto-trigger conditions")
-		return ah.shouldAutoProcessIssue(event.Issue)
+		return len(rules.IssueTemplateRules()) > 0
+
+	case "closed":
+		// 关闭已自动处理过的Issue时清理认领记录
+		if ah.issues == nil {
+			return false
+		}
+		_, ok := ah.issues.Get(event.GetRepository().GetFullName(), event.Issue.GetNumber())
+		return ok
 
 	default:
 		return false
@@ -158,17 +173,24 @@ func (ah *AgentHandler) handleIssuesEvent(ctx context.Context, event *models.Iss
 	case "assigned":
 		xl.Infof("Auto-processing assigned issue #%d", event.Issue.GetNumber())
 		// 自动处理被分配的Issue
-		return ah.autoProcessIssue(ctx, event)
+		return ah.autoProcessIssue(ctx, event, client)
 
 	case "labeled":
 		xl.Infof("Auto-processing labeled issue #%d", event.Issue.GetNumber())
 		// 自动处理被标记的Issue
-		return ah.autoProcessIssue(ctx, event)
+		return ah.autoProcessIssue(ctx, event, client)
 
 	case "opened":
 		xl.Infof("Auto-processing opened issue #%d", event.Issue.GetNumber())
 		// 自动处理新创建的Issue
-		return ah.autoProcessIssue(ctx, event)
+		return ah.autoProcessIssue(ctx, event, client)
+
+	case "closed":
+		xl.Infof("Releasing automation claim of closed issue #%d", event.Issue.GetNumber())
+		if err := ah.issues.Release(event.GetRepository().GetFullName(), event.Issue.GetNumber()); err != nil {
+			return fmt.Errorf("failed to release automation claim: %w", err)
+		}
+		return nil
 
 	default:
 		return fmt.Errorf("unsupported action for Issues event: %s", event.GetEventAction())
@@ -192,65 +214,135 @@ func (ah *AgentHandler) handlePREvent(ctx context.Context, event *models.PullReq
 	}
 }
 
-// handleWorkflowDispatch 处理工作流调度事件
+// handleWorkflowDispatch 处理通过 /dispatch 接口手动触发的定时任务，inputs覆盖仓库配置中的任务参数
 func (ah *AgentHandler) handleWorkflowDispatch(ctx context.Context, event *models.WorkflowDispatchContext, client *ghclient.Client) error {
 	xl := xlog.NewWith(ctx)
-	xl.Infof("Processing workflow dispatch event with inputs: %+v", event.Inputs)
+	xl.Infof("Processing workflow dispatch of job %s with inputs: %+v", event.Job, event.Inputs)
 
-	// 这里可以根据inputs中的参数执行不同的自动化任务
-	// 例如：批量处理Issues、生成报告、清理资源等
-
-	return fmt.Errorf("workflow_dispatch handling not implemented yet")
+	rule, ok := repoconfig.FromContext(ctx).ScheduleForJob(event.Job)
+	if !ok {
+		rule = repoconfig.ScheduleRule{Job: event.Job}
+	}
+	rule, err := applyJobInputs(rule, event.Inputs)
+	if err != nil {
+		return fmt.Errorf("invalid inputs for job %s: %w", event.Job, err)
+	}
+	return ah.runJob(ctx, event, client, rule)
 }
 
-// handleSchedule 处理定时任务事件
+// handleSchedule 处理内置cron调度器按仓库配置生成的定时任务
 func (ah *AgentHandler) handleSchedule(ctx context.Context, event *models.ScheduleContext, client *ghclient.Client) error {
 	xl := xlog.NewWith(ctx)
-	xl.Infof("Processing schedule event with cron: %s", event.Cron)
-
-	// 这里可以执行定时任务，例如：
-	// - 清理过期的工作空间
-	// - 生成周期性报告
-	// - 检查待处理的Issues
+	xl.Infof("Processing scheduled job %s (cron: %s)", event.Job, event.Cron)
 
-	return fmt.Errorf("schedule handling not implemented yet")
+	return ah.runJob(ctx, event, client, jobRule(ctx, event.Job, event.Cron))
 }
 
-// autoProcessIssue 自动处理Issue
-func (ah *AgentHandler) autoProcessIssue(ctx context.Context, event *models.IssuesContext) error {
+// autoProcessIssue 按匹配的自动化规则对Issue执行 /code 流程；标签和分配事件同时触发时，Issue只处理一次
+func (ah *AgentHandler) autoProcessIssue(ctx context.Context, event *models.IssuesContext, client *ghclient.Client) error {
 	xl := xlog.NewWith(ctx)
 
-	// 将事件转换为IssueCommentEvent格式（模拟/code命令）
-	// 这样可以复用现有的agent逻辑
-	_ = event.RawEvent.(*github.IssuesEvent)
+	issue := event.Issue
+	rule, ok := ah.matchIssueRule(ctx, event, client)
+	if !ok {
+		xl.Infof("No automation rule matches issue #%d", issue.GetNumber())
+		return nil
+	}
+	if issue.GetState() == "closed" {
+		xl.Infof("Issue #%d is closed, skip automation", issue.GetNumber())
+		return nil
+	}
+
+	repo := event.GetRepository().GetFullName()
+	claimed, err := ah.issues.Claim(repo, issue.GetNumber(), event.DeliveryID, event.GetEventAction())
+	if err != nil {
+		// 认领记录已经保存在内存中，持久化失败不影响本次去重
+		xl.Warnf("Failed to persist automation claim: %v", err)
+	}
+	if !claimed {
+		xl.Infof("Issue #%d has already been processed by an automation rule, skip", issue.GetNumber())
+		return nil
+	}
 
-	xl.Infof("Auto-processing issue with new architecture")
-	// TODO: 实现自动Issue处理逻辑，使用MCP工具
-	xl.Infof("Auto-processing for Issue #%d is not yet implemented", event.Issue.GetNumber())
+	xl.Infof("Issue #%d matched automation rule %s, running %s", issue.GetNumber(), describeIssueRule(rule), models.CommandCode)
+	if err := ah.tagHandler.ProcessIssueCode(ctx, event, rule.Provider, rule.Instructions); err != nil {
+		// 处理失败时释放认领，重新添加标签或分配后可以再次处理
+		if releaseErr := ah.issues.Release(repo, issue.GetNumber()); releaseErr != nil {
+			xl.Warnf("Failed to release automation claim: %v", releaseErr)
+		}
+		return err
+	}
 	return nil
 }
 
-// hasAutoTriggerLabel 检查Issue是否包含自动触发标签
-func (ah *AgentHandler) hasAutoTriggerLabel(issue *github.Issue) bool {
-	autoTriggerLabels := []string{"ai-assist", "codeagent", "auto-code", "ai-help"}
-
-	for _, label := range issue.Labels {
-		labelName := strings.ToLower(label.GetName())
-		for _, triggerLabel := range autoTriggerLabels {
-			if labelName == triggerLabel {
-				return true
+// matchIssueRule 返回与Issues事件匹配的自动化规则
+func (ah *AgentHandler) matchIssueRule(ctx context.Context, event *models.IssuesContext, client *ghclient.Client) (repoconfig.IssueRule, bool) {
+	rules := repoconfig.FromContext(ctx)
+	switch event.GetEventAction() {
+	case "labeled":
+		return rules.IssueRuleForLabel(issuesEventLabel(event))
+	case "assigned":
+		return rules.IssueRuleForAssignee(issuesEventAssignee(event))
+	case "opened":
+		for _, rule := range rules.IssueTemplateRules() {
+			if ah.matchesTemplate(ctx, event, client, rule.Template) {
+				return rule, true
 			}
 		}
 	}
+	return repoconfig.IssueRule{}, false
+}
+
+// matchesTemplate Issue正文是否由默认分支上的模板生成
+func (ah *AgentHandler) matchesTemplate(ctx context.Context, event *models.IssuesContext, client *ghclient.Client, template string) bool {
+	xl := xlog.NewWith(ctx)
+	repo := event.GetRepository()
 
+	for _, path := range automation.TemplatePaths(template) {
+		content, err := client.GetFileContent(ctx, repo.GetOwner().GetLogin(), repo.GetName(), path, "")
+		if errors.Is(err, ghclient.ErrFileNotFound) {
+			continue
+		}
+		if err != nil {
+			xl.Warnf("Failed to read issue template %s: %v", path, err)
+			return false
+		}
+		headings, err := automation.TemplateHeadings(path, content)
+		if err != nil {
+			xl.Warnf("Failed to parse issue template %s: %v", path, err)
+			return false
+		}
+		return automation.MatchTemplate(event.Issue.GetBody(), headings)
+	}
+	xl.Warnf("Issue template %s not found in %s", template, repo.GetFullName())
 	return false
 }
 
-// shouldAutoProcessIssue 检查是否应该自动处理Issue
-func (ah *AgentHandler) shouldAutoProcessIssue(issue *github.Issue) bool {
-	// 这里可以根据配置或其他条件决定是否自动处理
-	// 例如：特定的仓库、特定的标签、特定的用户等
-	return false // 默认不自动处理新创建的Issue
+// issuesEventLabel labeled事件中添加的标签
+func issuesEventLabel(event *models.IssuesContext) string {
+	if raw, ok := event.RawEvent.(*github.IssuesEvent); ok {
+		return raw.GetLabel().GetName()
+	}
+	return ""
+}
+
+// issuesEventAssignee assigned事件中被分配的用户
+func issuesEventAssignee(event *models.IssuesContext) string {
+	if raw, ok := event.RawEvent.(*github.IssuesEvent); ok {
+		return raw.GetAssignee().GetLogin()
+	}
+	return ""
+}
+
+func describeIssueRule(rule repoconfig.IssueRule) string {
+	switch {
+	case rule.Label != "":
+		return fmt.Sprintf("label=%s", rule.Label)
+	case rule.Assignee != "":
+		return fmt.Sprintf("assignee=%s", rule.Assignee)
+	default:
+		return fmt.Sprintf("template=%s", rule.Template)
+	}
 }
 
 // shouldAutoReviewPR 检查是否应该自动审查PR
@@ -0,0 +1,78 @@
+package modes
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/automation"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentHandler_CanHandleIssues(t *testing.T) {
+	handler := NewAgentHandler(nil, nil, nil, nil, nil)
+
+	cfg, err := repoconfig.Parse([]byte(`
+automation:
+  issues:
+    - label: codeagent
+    - assignee: qiniu-ci
+`))
+	require.NoError(t, err)
+	ctx := repoconfig.NewContext(context.Background(), cfg)
+
+	issuesEvent := func(action string, raw *github.IssuesEvent) *models.IssuesContext {
+		return &models.IssuesContext{
+			BaseContext: models.BaseContext{Type: models.EventIssues, Action: action, RawEvent: raw},
+			Issue:       &github.Issue{Number: github.Int(7)},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		event models.GitHubContext
+		want  bool
+	}{
+		{name: "matching label", ctx: ctx, event: issuesEvent("labeled", &github.IssuesEvent{Label: &github.Label{Name: github.String("CodeAgent")}}), want: true},
+		{name: "other label", ctx: ctx, event: issuesEvent("labeled", &github.IssuesEvent{Label: &github.Label{Name: github.String("bug")}}), want: false},
+		{name: "assigned to bot", ctx: ctx, event: issuesEvent("assigned", &github.IssuesEvent{Assignee: &github.User{Login: github.String("qiniu-ci")}}), want: true},
+		{name: "assigned to someone else", ctx: ctx, event: issuesEvent("assigned", &github.IssuesEvent{Assignee: &github.User{Login: github.String("alice")}}), want: false},
+		{name: "opened without template rules", ctx: ctx, event: issuesEvent("opened", &github.IssuesEvent{}), want: false},
+		{name: "no repository rules", ctx: context.Background(), event: issuesEvent("labeled", &github.IssuesEvent{Label: &github.Label{Name: github.String("codeagent")}}), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, handler.CanHandle(tt.ctx, tt.event))
+		})
+	}
+}
+
+func TestAgentHandler_CanHandleClosedIssue(t *testing.T) {
+	store, err := automation.NewStore(filepath.Join(t.TempDir(), "automation.json"))
+	require.NoError(t, err)
+	_, err = store.Claim("org/repo", 7, "delivery-1", "labeled")
+	require.NoError(t, err)
+	handler := NewAgentHandler(nil, nil, nil, nil, store)
+
+	closed := func(number int) *models.IssuesContext {
+		return &models.IssuesContext{
+			BaseContext: models.BaseContext{
+				Type:       models.EventIssues,
+				Action:     "closed",
+				Repository: &github.Repository{FullName: github.String("org/repo")},
+			},
+			Issue: &github.Issue{Number: github.Int(number)},
+		}
+	}
+
+	// 只有自动处理过的Issue关闭时需要清理认领记录
+	assert.True(t, handler.CanHandle(context.Background(), closed(7)))
+	assert.False(t, handler.CanHandle(context.Background(), closed(8)))
+}
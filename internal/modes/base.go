@@ -22,6 +22,15 @@ const (
 
 	// CustomCommandMode 自定义命令模式
 	CustomCommandMode ExecutionMode = "custom-commands"
+
+	// ControlMode 控制命令模式（/cancel 等），不进入调度队列
+	ControlMode ExecutionMode = "control"
+
+	// CIFixMode 修复失败CI模式
+	CIFixMode ExecutionMode = "ci-fix"
+
+	// PushMode 推送自动化模式
+	PushMode ExecutionMode = "push"
 )
 
 // ModeHandler 模式处理器接口
@@ -92,6 +101,7 @@ func NewModeManager() *ModeManager {
 			AgentMode:         false, // 默认禁用Agent模式
 			ReviewMode:        false, // 默认禁用Review模式
 			CustomCommandMode: false, // 默认禁用自定义命令模式，需要手动启用
+			ControlMode:       true,  // 默认启用控制命令
 		},
 	}
 }
@@ -0,0 +1,351 @@
+package modes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/ci"
+	"github.com/qiniu/codeagent/internal/code"
+	"github.com/qiniu/codeagent/internal/config"
+	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/queue"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/internal/workspace"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/qiniu/x/xlog"
+)
+
+// maxFixOutputLength 完成评论中AI输出的最大长度
+const maxFixOutputLength = 4000
+
+// pendingChecksRetryInterval 自动修复等待同一提交上其他检查完成时的重试间隔
+const pendingChecksRetryInterval = time.Minute
+
+// maxPendingChecksWait 自动修复最多等待其他检查这么久，之后先修复已经失败的检查
+const maxPendingChecksWait = 30 * time.Minute
+
+// CIFixHandler 修复CodeAgent PR上失败的CI：CI结果事件触发自动修复（需要开启），或在PR中评论 /fix-ci 手动触发
+type CIFixHandler struct {
+	*BaseHandler
+	clientManager  ghclient.ClientManagerInterface
+	workspace      *workspace.Manager
+	sessionManager *code.SessionManager
+	attempts       *ci.AttemptStore
+	config         *config.Config
+	mentionConfig  models.MentionConfig
+}
+
+// NewCIFixHandler 创建CI修复处理器
+func NewCIFixHandler(clientManager ghclient.ClientManagerInterface, workspace *workspace.Manager, sessionManager *code.SessionManager, attempts *ci.AttemptStore, cfg *config.Config) *CIFixHandler {
+	return &CIFixHandler{
+		BaseHandler: NewBaseHandler(
+			CIFixMode,
+			2, // 需要先于自定义命令和mention处理器匹配 /fix-ci
+			"Fix failing CI on CodeAgent pull requests",
+		),
+		clientManager:  clientManager,
+		workspace:      workspace,
+		sessionManager: sessionManager,
+		attempts:       attempts,
+		config:         cfg,
+		mentionConfig: &models.ConfigMentionAdapter{
+			Triggers:       cfg.Mention.Triggers,
+			DefaultTrigger: cfg.Mention.DefaultTrigger,
+		},
+	}
+}
+
+// CanHandle 处理PR评论中的 /fix-ci，以及开启自动修复时CodeAgent PR上失败的CI结果事件
+func (h *CIFixHandler) CanHandle(ctx context.Context, event models.GitHubContext) bool {
+	switch e := event.(type) {
+	case *models.IssueCommentContext:
+		if !e.IsPRComment {
+			return false
+		}
+		cmdInfo, hasCmd := models.HasCommandWithConfig(event, repoconfig.FromContext(ctx).MentionConfig(h.mentionConfig))
+		return hasCmd && cmdInfo.CommandType == models.CommandTypeSlash && cmdInfo.Command == models.CommandFixCI
+	case models.CIContext:
+		return h.isAutoFixCandidate(ctx, e)
+	default:
+		return false
+	}
+}
+
+// isAutoFixCandidate CI事件是否为CodeAgent PR上需要自动修复的失败
+func (h *CIFixHandler) isAutoFixCandidate(ctx context.Context, event models.CIContext) bool {
+	if !repoconfig.FromContext(ctx).CIAutoFixEnabled(h.config.CIFix.Auto) {
+		return false
+	}
+	if event.GetEventAction() != "completed" || !isFailedConclusion(event.CIConclusion()) {
+		return false
+	}
+	// 忽略CodeAgent自己创建的检查，避免审查结论触发修复
+	if strings.HasPrefix(event.CIName(), "codeagent/") {
+		return false
+	}
+	return CIPullRequest(h.workspace, event) != nil
+}
+
+// CIPullRequest CI事件关联的PR中需要修复的CodeAgent PR，调度器也按这个PR串行执行任务
+func CIPullRequest(ws *workspace.Manager, event models.CIContext) *github.PullRequest {
+	repo := event.GetRepository()
+	return ws.AgentPullRequest(repo.GetOwner().GetLogin(), repo.GetName(), event.PullRequests())
+}
+
+// waitForPendingChecks 事件进入队列不超过 maxPendingChecksWait 时继续等待其他检查，
+// 不是从队列执行的任务无法稍后重试，不等待
+func waitForPendingChecks(ctx context.Context, now time.Time) bool {
+	job := queue.FromContext(ctx)
+	return job != nil && now.Sub(job.CreatedAt) < maxPendingChecksWait
+}
+
+func isFailedConclusion(conclusion string) bool {
+	return conclusion == "failure" || conclusion == "timed_out" || conclusion == "startup_failure"
+}
+
+// Execute 收集失败检查的日志，在PR工作空间中让AI修复并推送修复提交
+func (h *CIFixHandler) Execute(ctx context.Context, event models.GitHubContext) (err error) {
+	xl := xlog.NewWith(ctx)
+
+	var (
+		prNumber     int
+		failedSHA    string
+		instructions string
+		requester    string
+		manual       bool
+	)
+	switch e := event.(type) {
+	case *models.IssueCommentContext:
+		if action := e.GetEventAction(); action != "created" && action != "edited" {
+			return nil
+		}
+		cmdInfo, hasCmd := models.HasCommandWithConfig(event, repoconfig.FromContext(ctx).MentionConfig(h.mentionConfig))
+		if !hasCmd {
+			return fmt.Errorf("no command found in event")
+		}
+		prNumber = e.Issue.GetNumber()
+		instructions = cmdInfo.Args
+		requester = e.Comment.GetUser().GetLogin()
+		manual = true
+	case models.CIContext:
+		prNumber = CIPullRequest(h.workspace, e).GetNumber()
+		failedSHA = e.HeadSHA()
+	default:
+		return fmt.Errorf("unsupported event type for CIFixHandler: %s", event.GetEventType())
+	}
+	if prNumber == 0 {
+		return fmt.Errorf("no pull request found for event")
+	}
+
+	repo := event.GetRepository()
+	owner, repoName := repo.GetOwner().GetLogin(), repo.GetName()
+	client, err := h.clientManager.GetClient(ctx, &models.Repository{Owner: owner, Name: repoName})
+	if err != nil {
+		return fmt.Errorf("failed to get GitHub client: %w", err)
+	}
+	reply := func(body string) {
+		if _, err := client.CreateComment(ctx, owner, repoName, prNumber, body); err != nil {
+			xl.Warnf("Failed to comment on PR #%d: %v", prNumber, err)
+		}
+	}
+
+	pr, err := client.GetPullRequest(owner, repoName, prNumber)
+	if err != nil {
+		return fmt.Errorf("failed to get PR information: %w", err)
+	}
+	RegisterTaskAlias(ctx, prNumber)
+
+	branch := pr.GetHead().GetRef()
+	aiModel := h.workspace.ExtractAIModelFromBranch(owner, repoName, branch)
+	if aiModel == "" {
+		if manual {
+			reply(fmt.Sprintf("ℹ️ `%s` only works on pull requests created by CodeAgent; `%s` is not a CodeAgent branch.", models.CommandFixCI, branch))
+		}
+		return nil
+	}
+	if pr.GetState() != "open" {
+		xl.Infof("PR #%d is %s, skip fixing CI", prNumber, pr.GetState())
+		return nil
+	}
+
+	headSHA := pr.GetHead().GetSHA()
+	if !manual && failedSHA != headSHA {
+		xl.Infof("CI failed on %s but PR #%d head is now %s, skip fixing stale failure", failedSHA, prNumber, headSHA)
+		return nil
+	}
+	if !manual {
+		// 第一个失败的检查结束时其他检查可能还在运行，等它们都完成后再一起修复，
+		// 否则之后报告的失败会因为这个提交已经修复过而被忽略
+		pending, err := client.ListPendingCheckRuns(ctx, owner, repoName, headSHA)
+		if err != nil {
+			return fmt.Errorf("failed to list pending checks: %w", err)
+		}
+		if len(pending) > 0 {
+			if waitForPendingChecks(ctx, time.Now()) {
+				return queue.Defer(fmt.Errorf("%d checks on %s of PR #%d are still running", len(pending), headSHA, prNumber), pendingChecksRetryInterval)
+			}
+			xl.Warnf("%d checks on %s of PR #%d are still running after %s, fixing the failed checks now", len(pending), headSHA, prNumber, maxPendingChecksWait)
+		}
+	}
+
+	runs, err := client.ListFailedCheckRuns(ctx, owner, repoName, headSHA)
+	if err != nil {
+		return fmt.Errorf("failed to list failed checks: %w", err)
+	}
+	if len(runs) == 0 {
+		xl.Infof("No failed checks on PR #%d head %s", prNumber, headSHA)
+		if manual {
+			reply(fmt.Sprintf("✅ @%s there are no failing checks on the latest commit of this PR.", requester))
+		}
+		return nil
+	}
+
+	// 自动修复按提交去重并限制连续次数，手动触发不受上限约束
+	maxAttempts := h.config.CIFixMaxAttempts()
+	limit := maxAttempts
+	if manual {
+		limit = 0
+	}
+	attempt, result, err := h.attempts.Begin(repo.GetFullName(), prNumber, headSHA, limit)
+	if err != nil {
+		xl.Warnf("Failed to record CI fix attempt: %v", err)
+	}
+	switch {
+	case result == ci.LimitReached:
+		xl.Infof("CI auto-fix limit reached on PR #%d after %d attempts", prNumber, attempt)
+		reply(fmt.Sprintf("⚠️ CI is still failing after %d automatic fix attempts, so CodeAgent stopped fixing it automatically. "+
+			"Push a fix, or comment `%s` to try again.", attempt, models.CommandFixCI))
+		return nil
+	case result == ci.AlreadyAttempted && !manual:
+		xl.Infof("CI failure on %s of PR #%d has already been handled", headSHA, prNumber)
+		return nil
+	}
+	if result == ci.Started {
+		// 修复失败时撤销登记，之后的重试或同一提交的下一次失败事件仍会修复
+		defer func() {
+			if err == nil {
+				return
+			}
+			if cancelErr := h.attempts.Cancel(repo.GetFullName(), prNumber, headSHA); cancelErr != nil {
+				xl.Warnf("Failed to roll back CI fix attempt: %v", cancelErr)
+			}
+		}()
+	}
+	xl.Infof("Fixing %d failed checks on PR #%d (attempt %d)", len(runs), prNumber, attempt)
+
+	failures := h.collectFailures(ctx, client, owner, repoName, runs)
+
+	ws := h.workspace.GetOrCreateWorkspaceForPR(pr, aiModel)
+	if ws == nil {
+		return fmt.Errorf("failed to get or create workspace for PR #%d", prNumber)
+	}
+	if err := client.PullLatestChanges(ctx, ws, pr); err != nil {
+		xl.Warnf("Failed to pull latest changes: %v", err)
+	}
+	codeClient, err := getSession(ctx, h.sessionManager, ws)
+	if err != nil {
+		return fmt.Errorf("failed to create code session: %w", err)
+	}
+
+	prompt := ci.BuildFixPrompt(ci.FixRequest{
+		PRNumber:     prNumber,
+		PRTitle:      pr.GetTitle(),
+		Branch:       branch,
+		HeadSHA:      headSHA,
+		Failures:     failures,
+		Instructions: instructions,
+	})
+	resp, err := code.PromptWithRetry(ctx, codeClient, prompt, 3)
+	if err != nil {
+		return fmt.Errorf("failed to fix CI: %w", err)
+	}
+	output, err := io.ReadAll(resp.Out)
+	if err != nil {
+		return fmt.Errorf("failed to read CI fix output: %w", err)
+	}
+
+	commitHash, err := client.CommitAndPush(ctx, ws, &models.ExecutionResult{Output: string(output)}, codeClient)
+	if err != nil {
+		return fmt.Errorf("failed to push CI fix: %w", err)
+	}
+	if commitHash != "" {
+		if err := h.attempts.RecordFix(repo.GetFullName(), prNumber, commitHash); err != nil {
+			xl.Warnf("Failed to record CI fix commit: %v", err)
+		}
+	}
+
+	reply(renderCIFixComment(pr, failures, commitHash, string(output), attempt, maxAttempts, manual, requester, describeModel(resp, ws.AIModel)))
+	xl.Infof("CI fix on PR #%d completed, commit: %s", prNumber, commitHash)
+	return nil
+}
+
+// collectFailures 获取失败检查的日志；GitHub Actions 下载job日志并裁剪到失败步骤，其他检查使用检查输出
+func (h *CIFixHandler) collectFailures(ctx context.Context, client *ghclient.Client, owner, repo string, runs []*github.CheckRun) []ci.Failure {
+	xl := xlog.NewWith(ctx)
+	maxLines := h.config.CIFixMaxLogLines()
+
+	failures := make([]ci.Failure, 0, len(runs))
+	for _, run := range runs {
+		failure := ci.Failure{
+			Name:       run.GetName(),
+			Conclusion: run.GetConclusion(),
+			URL:        run.GetHTMLURL(),
+		}
+		var log string
+		if run.GetApp().GetSlug() == "github-actions" {
+			// Actions 的check run ID 与job ID 相同
+			jobLog, err := client.GetJobLogs(ctx, owner, repo, run.GetID())
+			if err != nil {
+				xl.Warnf("Failed to download logs of %s: %v", run.GetName(), err)
+			} else {
+				log = jobLog
+			}
+		}
+		if log == "" {
+			output := run.GetOutput()
+			log = strings.TrimSpace(strings.Join([]string{output.GetTitle(), output.GetSummary(), output.GetText()}, "\n\n"))
+		}
+		failure.Log = ci.TrimLog(log, maxLines)
+		failures = append(failures, failure)
+	}
+	return failures
+}
+
+// renderCIFixComment 渲染CI修复结果评论
+func renderCIFixComment(pr *github.PullRequest, failures []ci.Failure, commitHash, output string, attempt, maxAttempts int, manual bool, requester, model string) string {
+	var sb strings.Builder
+	if manual {
+		sb.WriteString(fmt.Sprintf("@%s ", requester))
+	}
+	names := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		names = append(names, fmt.Sprintf("`%s`", failure.Name))
+	}
+	if commitHash != "" {
+		sb.WriteString(fmt.Sprintf("🔧 Pushed a fix for the failing checks %s", strings.Join(names, ", ")))
+		if !manual {
+			sb.WriteString(fmt.Sprintf(" (automatic attempt %d/%d)", attempt, maxAttempts))
+		}
+		sb.WriteString(fmt.Sprintf("\n\n**Commit**: %s/commits/%s", pr.GetHTMLURL(), commitHash))
+	} else {
+		sb.WriteString(fmt.Sprintf("ℹ️ CodeAgent looked into the failing checks %s but did not change any files.", strings.Join(names, ", ")))
+	}
+
+	summary, _, _ := code.ParseStructuredOutput(output)
+	if summary == "" {
+		summary = strings.TrimSpace(output)
+		if len(summary) > maxFixOutputLength {
+			summary = "..." + summary[len(summary)-maxFixOutputLength:]
+		}
+	}
+	if summary != "" {
+		sb.WriteString(fmt.Sprintf("\n\n<details>\n<summary>Details</summary>\n\n%s\n\n</details>", summary))
+	}
+	sb.WriteString(fmt.Sprintf("\n\n**Model**: %s", model))
+	return sb.String()
+}
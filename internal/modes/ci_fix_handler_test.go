@@ -0,0 +1,113 @@
+package modes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/queue"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCIFixHandler_CanHandle(t *testing.T) {
+	handler := NewCIFixHandler(nil, nil, nil, nil, &config.Config{CIFix: config.CIFixConfig{Auto: true}})
+	ctx := context.Background()
+
+	agentPR := &github.PullRequest{Number: github.Int(8), Head: &github.PullRequestBranch{Ref: github.String("codeagent/claude/issue-7-1700000000")}}
+	humanPR := &github.PullRequest{Number: github.Int(9), Head: &github.PullRequestBranch{Ref: github.String("feature/login")}}
+	checkRun := func(name, conclusion string, prs ...*github.PullRequest) *models.CheckRunContext {
+		return &models.CheckRunContext{
+			BaseContext: models.BaseContext{Type: models.EventCheckRun, Action: "completed"},
+			CheckRun:    &github.CheckRun{Name: github.String(name), Conclusion: github.String(conclusion), PullRequests: prs},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		event models.GitHubContext
+		want  bool
+	}{
+		{
+			name: "fix-ci in PR comment",
+			event: &models.IssueCommentContext{
+				BaseContext: models.BaseContext{Type: models.EventIssueComment},
+				Comment:     &github.IssueComment{Body: github.String("/fix-ci only the lint job")},
+				IsPRComment: true,
+			},
+			want: true,
+		},
+		{
+			name: "fix-ci in issue comment",
+			event: &models.IssueCommentContext{
+				BaseContext: models.BaseContext{Type: models.EventIssueComment},
+				Comment:     &github.IssueComment{Body: github.String("/fix-ci")},
+			},
+			want: false,
+		},
+		{name: "failed check on agent PR", event: checkRun("test", "failure", agentPR), want: true},
+		{name: "timed out check on agent PR", event: checkRun("test", "timed_out", humanPR, agentPR), want: true},
+		{name: "successful check", event: checkRun("test", "success", agentPR), want: false},
+		{name: "failed check on human PR", event: checkRun("test", "failure", humanPR), want: false},
+		{name: "failed codeagent check", event: checkRun("codeagent/review", "failure", agentPR), want: false},
+		{name: "failed check without PR", event: checkRun("test", "failure"), want: false},
+		{
+			name: "failed workflow run on agent PR",
+			event: &models.WorkflowRunContext{
+				BaseContext: models.BaseContext{Type: models.EventWorkflowRun, Action: "completed"},
+				WorkflowRun: &github.WorkflowRun{Name: github.String("CI"), Conclusion: github.String("failure"), PullRequests: []*github.PullRequest{agentPR}},
+			},
+			want: true,
+		},
+		{
+			name: "requested workflow run",
+			event: &models.WorkflowRunContext{
+				BaseContext: models.BaseContext{Type: models.EventWorkflowRun, Action: "requested"},
+				WorkflowRun: &github.WorkflowRun{Name: github.String("CI"), PullRequests: []*github.PullRequest{agentPR}},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, handler.CanHandle(ctx, tt.event))
+		})
+	}
+}
+
+func TestCIFixHandler_AutoFixOptIn(t *testing.T) {
+	handler := NewCIFixHandler(nil, nil, nil, nil, &config.Config{})
+	event := &models.CheckSuiteContext{
+		BaseContext: models.BaseContext{Type: models.EventCheckSuite, Action: "completed"},
+		CheckSuite: &github.CheckSuite{
+			Conclusion:   github.String("failure"),
+			App:          &github.App{Name: github.String("GitHub Actions")},
+			PullRequests: []*github.PullRequest{{Head: &github.PullRequestBranch{Ref: github.String("codeagent/gemini/pr-3-1700000000")}}},
+		},
+	}
+
+	// 默认关闭自动修复，仓库配置可以开启
+	assert.False(t, handler.CanHandle(context.Background(), event))
+	enabled := true
+	ctx := repoconfig.NewContext(context.Background(), &repoconfig.Config{CI: repoconfig.CIConfig{AutoFix: &enabled}})
+	assert.True(t, handler.CanHandle(ctx, event))
+}
+
+func TestWaitForPendingChecks(t *testing.T) {
+	now := time.Now()
+	job := func(age time.Duration) context.Context {
+		return queue.NewContext(context.Background(), &queue.Job{CreatedAt: now.Add(-age)})
+	}
+
+	assert.True(t, waitForPendingChecks(job(time.Minute), now))
+	assert.True(t, waitForPendingChecks(job(maxPendingChecksWait-time.Second), now))
+	// 等待太久后不再推迟，先修复已经失败的检查
+	assert.False(t, waitForPendingChecks(job(maxPendingChecksWait), now))
+	// 不是从队列执行时无法稍后重试
+	assert.False(t, waitForPendingChecks(context.Background(), now))
+}
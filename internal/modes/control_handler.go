@@ -0,0 +1,155 @@
+package modes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/code"
+	"github.com/qiniu/codeagent/internal/config"
+	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/permission"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/internal/workspace"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/xlog"
+)
+
+// TaskController 管理正在执行的任务，由agent实现
+type TaskController interface {
+	// CancelTask 取消仓库下指定Issue/PR上正在执行的任务，返回是否找到了任务
+	CancelTask(repo string, number int) bool
+	// TaskStatus 返回仓库下指定Issue/PR上正在执行的任务和排队等待的任务数量
+	TaskStatus(repo string, number int) ([]TaskInfo, int)
+}
+
+// TaskInfo 正在执行的任务
+type TaskInfo struct {
+	Handler   string
+	StartedAt time.Time
+}
+
+// ControlHandler 处理控制类命令（如 /cancel、/help、/status），这类命令不进入调度队列，立即执行
+type ControlHandler struct {
+	*BaseHandler
+	clientManager    ghclient.ClientManagerInterface
+	workspace        *workspace.Manager
+	sessionManager   *code.SessionManager
+	tasks            TaskController
+	permissions      *permission.Policy
+	globalConfigPath string
+	defaultAIModel   string
+	mentionConfig    models.MentionConfig
+}
+
+// NewControlHandler 创建控制命令处理器，permissions 用于在 /help 中展示命令所需的角色，可以为nil
+func NewControlHandler(clientManager ghclient.ClientManagerInterface, workspace *workspace.Manager, sessionManager *code.SessionManager, tasks TaskController, cfg *config.Config, permissions *permission.Policy) *ControlHandler {
+	return &ControlHandler{
+		BaseHandler: NewBaseHandler(
+			ControlMode,
+			1, // 需要先于 /fix-ci 和自定义命令处理器匹配 mention 中的 help
+			"Handle control commands for running tasks (/cancel, /status) and /help",
+		),
+		clientManager:    clientManager,
+		workspace:        workspace,
+		sessionManager:   sessionManager,
+		tasks:            tasks,
+		permissions:      permissions,
+		globalConfigPath: cfg.Commands.GlobalPath,
+		defaultAIModel:   cfg.CodeProvider,
+		mentionConfig: &models.ConfigMentionAdapter{
+			Triggers:       cfg.Mention.Triggers,
+			DefaultTrigger: cfg.Mention.DefaultTrigger,
+		},
+	}
+}
+
+// CanHandle 只处理Issue/PR评论中的控制命令
+func (h *ControlHandler) CanHandle(ctx context.Context, event models.GitHubContext) bool {
+	if _, ok := event.(*models.IssueCommentContext); !ok {
+		return false
+	}
+	cmdInfo, hasCmd := models.HasCommandWithConfig(event, repoconfig.FromContext(ctx).MentionConfig(h.mentionConfig))
+	if !hasCmd {
+		return false
+	}
+	if models.IsHelpCommand(cmdInfo) {
+		return true
+	}
+	if cmdInfo.CommandType != models.CommandTypeSlash {
+		return false
+	}
+	return cmdInfo.Command == models.CommandCancel || cmdInfo.Command == models.CommandStatus
+}
+
+// Execute 执行控制命令
+func (h *ControlHandler) Execute(ctx context.Context, event models.GitHubContext) error {
+	commentEvent, ok := event.(*models.IssueCommentContext)
+	if !ok {
+		return fmt.Errorf("unsupported event type for ControlHandler: %s", event.GetEventType())
+	}
+	if action := commentEvent.GetEventAction(); action != "created" && action != "edited" {
+		return nil
+	}
+
+	cmdInfo, hasCmd := models.HasCommandWithConfig(event, repoconfig.FromContext(ctx).MentionConfig(h.mentionConfig))
+	if !hasCmd {
+		return fmt.Errorf("no command found in event")
+	}
+
+	if models.IsHelpCommand(cmdInfo) {
+		return h.handleHelp(ctx, commentEvent)
+	}
+	switch cmdInfo.Command {
+	case models.CommandCancel:
+		return h.handleCancel(ctx, commentEvent)
+	case models.CommandStatus:
+		return h.handleStatus(ctx, commentEvent)
+	default:
+		return fmt.Errorf("unsupported control command: %s", cmdInfo.Command)
+	}
+}
+
+// handleCancel 取消当前Issue/PR上正在执行的任务并回复结果
+func (h *ControlHandler) handleCancel(ctx context.Context, event *models.IssueCommentContext) error {
+	xl := xlog.NewWith(ctx)
+
+	repo := event.GetRepository()
+	number := event.Issue.GetNumber()
+
+	var body string
+	if h.tasks.CancelTask(repo.GetFullName(), number) {
+		xl.Infof("Cancelled running task on %s#%d", repo.GetFullName(), number)
+		body = fmt.Sprintf("🛑 @%s cancelled the running CodeAgent task. The progress comment will be updated once the task has stopped.",
+			event.Comment.GetUser().GetLogin())
+	} else {
+		xl.Infof("No running task to cancel on %s#%d", repo.GetFullName(), number)
+		body = "ℹ️ There is no running CodeAgent task on this thread to cancel."
+	}
+
+	client, err := h.clientManager.GetClient(ctx, &models.Repository{Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+	if err != nil {
+		return fmt.Errorf("failed to get GitHub client: %w", err)
+	}
+	if _, err := client.CreateComment(ctx, repo.GetOwner().GetLogin(), repo.GetName(), number, body); err != nil {
+		return fmt.Errorf("failed to reply to cancel command: %w", err)
+	}
+	return nil
+}
+
+// taskAliasKey ctx中登记任务别名回调的键
+type taskAliasKey struct{}
+
+// WithTaskAlias 在ctx中注入登记任务别名的回调，由agent在执行任务前设置
+func WithTaskAlias(ctx context.Context, register func(number int)) context.Context {
+	return context.WithValue(ctx, taskAliasKey{}, register)
+}
+
+// RegisterTaskAlias 将当前任务关联到另一个Issue/PR编号（例如 /code 创建的PR），
+// 使任务也可以在该编号下被 /cancel
+func RegisterTaskAlias(ctx context.Context, number int) {
+	if register, ok := ctx.Value(taskAliasKey{}).(func(number int)); ok && number > 0 {
+		register(number)
+	}
+}
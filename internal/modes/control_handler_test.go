@@ -0,0 +1,97 @@
+package modes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlHandler_CanHandle(t *testing.T) {
+	handler := NewControlHandler(nil, nil, nil, nil, &config.Config{Mention: config.MentionConfig{Triggers: []string{"@codeagent"}}}, nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		event models.GitHubContext
+		want  bool
+	}{
+		{
+			name: "cancel in issue comment",
+			event: &models.IssueCommentContext{
+				BaseContext: models.BaseContext{Type: models.EventIssueComment},
+				Comment:     &github.IssueComment{Body: github.String("/cancel")},
+			},
+			want: true,
+		},
+		{
+			name: "status in issue comment",
+			event: &models.IssueCommentContext{
+				BaseContext: models.BaseContext{Type: models.EventIssueComment},
+				Comment:     &github.IssueComment{Body: github.String("/status")},
+			},
+			want: true,
+		},
+		{
+			name: "other slash command",
+			event: &models.IssueCommentContext{
+				BaseContext: models.BaseContext{Type: models.EventIssueComment},
+				Comment:     &github.IssueComment{Body: github.String("/code implement it")},
+			},
+			want: false,
+		},
+		{
+			name: "help in issue comment",
+			event: &models.IssueCommentContext{
+				BaseContext: models.BaseContext{Type: models.EventIssueComment},
+				Comment:     &github.IssueComment{Body: github.String("/help")},
+			},
+			want: true,
+		},
+		{
+			name: "help mention",
+			event: &models.IssueCommentContext{
+				BaseContext: models.BaseContext{Type: models.EventIssueComment},
+				Comment:     &github.IssueComment{Body: github.String("@codeagent help")},
+			},
+			want: true,
+		},
+		{
+			name: "mention asking for help with a task",
+			event: &models.IssueCommentContext{
+				BaseContext: models.BaseContext{Type: models.EventIssueComment},
+				Comment:     &github.IssueComment{Body: github.String("@codeagent help me fix the tests")},
+			},
+			want: false,
+		},
+		{
+			name: "cancel in review comment",
+			event: &models.PullRequestReviewCommentContext{
+				BaseContext: models.BaseContext{Type: models.EventPullRequestReviewComment},
+				Comment:     &github.PullRequestComment{Body: github.String("/cancel")},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, handler.CanHandle(ctx, tt.event))
+		})
+	}
+}
+
+func TestRegisterTaskAlias(t *testing.T) {
+	var got []int
+	ctx := WithTaskAlias(context.Background(), func(number int) { got = append(got, number) })
+
+	RegisterTaskAlias(ctx, 8)
+	RegisterTaskAlias(ctx, 0)
+	RegisterTaskAlias(context.Background(), 9)
+
+	assert.Equal(t, []int{8}, got)
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/qiniu/codeagent/internal/config"
 	githubcontext "github.com/qiniu/codeagent/internal/context"
 	"github.com/qiniu/codeagent/internal/mcp"
+	"github.com/qiniu/codeagent/internal/permission"
+	"github.com/qiniu/codeagent/internal/repoconfig"
 	"github.com/qiniu/codeagent/internal/workspace"
 	"github.com/qiniu/codeagent/pkg/models"
 
@@ -35,7 +37,7 @@ type CustomCommandHandler struct {
 
 // NewCustomCommandHandler creates a new custom command handler
 func NewCustomCommandHandler(clientManager ghclient.ClientManagerInterface, workspace *workspace.Manager, sessionManager *code.SessionManager, mcpClient mcp.MCPClient, globalConfigPath string, codeProvider string, cfg *config.Config) *CustomCommandHandler {
-	baseHandler := NewBaseHandler(CustomCommandMode, 1, "CodeAgent custom commands and subagents")
+	baseHandler := NewBaseHandler(CustomCommandMode, 5, "CodeAgent custom commands and subagents")
 
 	// Create mention config adapter
 	mentionConfig := &models.ConfigMentionAdapter{
@@ -67,7 +69,7 @@ func (h *CustomCommandHandler) CanHandle(ctx context.Context, githubCtx models.G
 	}
 
 	// Extract command from the event using models.HasCommandWithConfig
-	cmdInfo, hasCmd := models.HasCommandWithConfig(githubCtx, h.mentionConfig)
+	cmdInfo, hasCmd := models.HasCommandWithConfig(githubCtx, repoconfig.FromContext(ctx).MentionConfig(h.mentionConfig))
 	if !hasCmd {
 		xl.Infof("No slash command found in event")
 		return false
@@ -82,14 +84,14 @@ func (h *CustomCommandHandler) Execute(ctx context.Context, githubCtx models.Git
 	xl := xlog.NewWith(ctx)
 
 	// Extract command and instruction using models.HasCommandWithConfig
-	cmdInfo, hasCmd := models.HasCommandWithConfig(githubCtx, h.mentionConfig)
+	cmdInfo, hasCmd := models.HasCommandWithConfig(githubCtx, repoconfig.FromContext(ctx).MentionConfig(h.mentionConfig))
 	if !hasCmd {
 		return fmt.Errorf("no command found in event")
 	}
 
 	// If user didn't specify AI model, use system default configuration
 	if strings.TrimSpace(cmdInfo.AIModel) == "" {
-		cmdInfo.AIModel = h.defaultAIModel
+		cmdInfo.AIModel = repoconfig.FromContext(ctx).ProviderOr(h.defaultAIModel)
 	}
 
 	xl.Infof("Processing custom command: %s with instruction: %s", cmdInfo.Command, cmdInfo.Args)
@@ -132,6 +134,11 @@ func (h *CustomCommandHandler) Execute(ctx context.Context, githubCtx models.Git
 
 	xl.Infof("Loaded command '%s' from %s source", cmdInfo.Command, cmdDef.Source)
 
+	// 命令定义中声明了权限要求时，检查触发用户的角色
+	if err := checkCommandPermission(ctx, githubCtx, cmdInfo.Command, cmdDef.Permission); err != nil {
+		return err
+	}
+
 	// 5. Apply final context injection to command content
 	processedContent, err := h.contextInjector.InjectContextWithLogging(ctx, cmdDef.Content, githubEvent, xl)
 	if err != nil {
@@ -143,7 +150,7 @@ func (h *CustomCommandHandler) Execute(ctx context.Context, githubCtx models.Git
 	xl.Infof("Processed command content length: %d", len(processedContent))
 
 	// 8. Get code session for the workspace
-	codeSession, err := h.sessionManager.GetSession(workspace)
+	codeSession, err := getSession(ctx, h.sessionManager, workspace)
 	if err != nil {
 		return fmt.Errorf("failed to get code session: %w", err)
 	}
@@ -173,6 +180,31 @@ func (h *CustomCommandHandler) Execute(ctx context.Context, githubCtx models.Git
 	return nil
 }
 
+// checkCommandPermission 检查触发用户的角色是否满足命令定义中声明的最低角色，
+// 用户角色由agent在执行前查询并放入ctx；ctx中没有角色时无法确认用户权限，按无权限处理
+func checkCommandPermission(ctx context.Context, githubCtx models.GitHubContext, command, required string) error {
+	if required == "" {
+		return nil
+	}
+	requiredRole, err := permission.ParseRole(required)
+	if err != nil {
+		return fmt.Errorf("invalid permission in command '%s': %w", command, err)
+	}
+	role, ok := permission.FromContext(ctx)
+	if !ok {
+		role = permission.RoleNone
+	}
+	if role >= requiredRole {
+		return nil
+	}
+	return &permission.DeniedError{
+		User:     githubCtx.GetSender().GetLogin(),
+		Command:  command,
+		Role:     role,
+		Required: requiredRole,
+	}
+}
+
 // buildGitHubEvent converts GitHub context to GitHub event format for context injection
 func (h *CustomCommandHandler) buildGitHubEvent(ctx context.Context, githubCtx models.GitHubContext, instruction string) (*githubcontext.GitHubEvent, error) {
 	xl := xlog.NewWith(ctx)
@@ -0,0 +1,38 @@
+package modes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/permission"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCommandPermission(t *testing.T) {
+	event := &models.IssueCommentContext{
+		BaseContext: models.BaseContext{Type: models.EventIssueComment, Sender: &github.User{Login: github.String("alice")}},
+	}
+	ctx := context.Background()
+
+	// 没有声明权限的命令不检查角色
+	assert.NoError(t, checkCommandPermission(ctx, event, "/deploy", ""))
+
+	assert.NoError(t, checkCommandPermission(permission.NewContext(ctx, permission.RoleMaintain), event, "/deploy", "maintain"))
+
+	err := checkCommandPermission(permission.NewContext(ctx, permission.RoleWrite), event, "/deploy", "maintain")
+	var denied *permission.DeniedError
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, "alice", denied.User)
+	assert.Equal(t, permission.RoleWrite, denied.Role)
+
+	// 没有经过权限检查时不能确认用户角色，拒绝执行
+	err = checkCommandPermission(ctx, event, "/deploy", "maintain")
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, permission.RoleNone, denied.Role)
+
+	assert.Error(t, checkCommandPermission(ctx, event, "/deploy", "owner"))
+}
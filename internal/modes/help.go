@@ -0,0 +1,209 @@
+package modes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/qiniu/codeagent/internal/command"
+	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/permission"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/xlog"
+)
+
+// builtinCommands /help 中列出的内置命令
+var builtinCommands = []struct {
+	Usage       string
+	Description string
+}{
+	{"/code [instructions]", "Implement the Issue and open a PR"},
+	{"/plan [instructions]", "Propose an implementation plan for the Issue without changing code"},
+	{"/code approve-plan [instructions]", "Implement the latest plan posted by /plan"},
+	{"/continue <instructions>", "Continue working on the PR with new instructions"},
+	{"/review", "Review the PR"},
+	{"/fix-ci [instructions]", "Fix the failing checks of a CodeAgent PR"},
+	{"/cancel", "Abort the task running on this Issue or PR"},
+	{"/status", "Show the workspaces, sessions and tasks of this Issue or PR"},
+	{"/help", "Show this help"},
+}
+
+// helpCommand /help 中展示的自定义命令
+type helpCommand struct {
+	Name        string
+	Description string
+	Source      string
+	Role        string
+}
+
+// helpData 渲染 /help 回复所需的信息
+type helpData struct {
+	Trigger  string
+	Provider string
+	// Enabled 服务端是否配置了自定义命令目录
+	Enabled  bool
+	Commands []helpCommand
+	Agents   []*command.AgentDefinition
+	// LoadErr 读取自定义命令失败的原因
+	LoadErr error
+}
+
+// handleHelp 回复内置命令、模型参数以及仓库可用的自定义命令和subagent
+func (h *ControlHandler) handleHelp(ctx context.Context, event *models.IssueCommentContext) error {
+	xl := xlog.NewWith(ctx)
+
+	repo := event.GetRepository()
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	number := event.Issue.GetNumber()
+	client, err := h.clientManager.GetClient(ctx, &models.Repository{Owner: owner, Name: name})
+	if err != nil {
+		return fmt.Errorf("failed to get GitHub client: %w", err)
+	}
+
+	data := helpData{
+		Trigger:  repoconfig.FromContext(ctx).MentionConfig(h.mentionConfig).GetDefaultTrigger(),
+		Provider: repoconfig.FromContext(ctx).ProviderOr(h.defaultAIModel),
+		Enabled:  h.globalConfigPath != "",
+	}
+	if data.Enabled {
+		// PR中使用PR head上的 .codeagent 目录，与自定义命令执行时的工作空间一致
+		ref := ""
+		if event.IsPRComment {
+			ref = fmt.Sprintf("refs/pull/%d/head", number)
+		}
+		commands, agents, err := h.loadCustomCommands(ctx, client, owner, name, ref)
+		if err != nil {
+			xl.Warnf("Failed to load custom commands for help: %v", err)
+			data.LoadErr = err
+		}
+		data.Commands = h.helpCommands(commands)
+		data.Agents = sortedAgents(agents)
+	}
+
+	if _, err := client.CreateComment(ctx, owner, name, number, renderHelp(data)); err != nil {
+		return fmt.Errorf("failed to reply to help command: %w", err)
+	}
+	xl.Infof("Replied to help command on %s#%d with %d custom commands", repo.GetFullName(), number, len(data.Commands))
+	return nil
+}
+
+// loadCustomCommands 下载仓库的 .codeagent/commands 和 .codeagent/agents，与全局目录合并后列出，仓库定义覆盖同名的全局定义
+func (h *ControlHandler) loadCustomCommands(ctx context.Context, client *ghclient.Client, owner, name, ref string) (map[string]*command.CommandDefinition, map[string]*command.AgentDefinition, error) {
+	dir, err := os.MkdirTemp("", "codeagent-help-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, sub := range []string{"commands", "agents"} {
+		err := client.DownloadDirectory(ctx, owner, name, ".codeagent/"+sub, ref, filepath.Join(dir, sub))
+		if err != nil && !errors.Is(err, ghclient.ErrFileNotFound) {
+			return nil, nil, err
+		}
+	}
+
+	loader := command.NewCommandLoader(h.globalConfigPath, dir)
+	commands, err := loader.ListCommands()
+	if err != nil {
+		return nil, nil, err
+	}
+	agents, err := loader.ListAgents()
+	if err != nil {
+		return commands, nil, err
+	}
+	return commands, agents, nil
+}
+
+// helpCommands 按名称排序自定义命令，并计算执行所需的角色：全局权限策略与命令定义中 permission 的较高者
+func (h *ControlHandler) helpCommands(commands map[string]*command.CommandDefinition) []helpCommand {
+	result := make([]helpCommand, 0, len(commands))
+	for name, def := range commands {
+		cmd := "/" + strings.TrimPrefix(name, "/")
+		role := def.Permission
+		if h.permissions != nil {
+			required := h.permissions.RequiredRole(cmd)
+			if declared, err := permission.ParseRole(def.Permission); err == nil && declared > required {
+				required = declared
+			}
+			role = required.String()
+		}
+		result = append(result, helpCommand{Name: cmd, Description: def.Description, Source: def.Source, Role: role})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func sortedAgents(agents map[string]*command.AgentDefinition) []*command.AgentDefinition {
+	result := make([]*command.AgentDefinition, 0, len(agents))
+	for _, agent := range agents {
+		result = append(result, agent)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func renderHelp(data helpData) string {
+	var sb strings.Builder
+	sb.WriteString("## CodeAgent commands\n\n")
+	sb.WriteString("### Built-in commands\n\n")
+	sb.WriteString("| Command | Description |\n|---------|-------------|\n")
+	for _, cmd := range builtinCommands {
+		sb.WriteString(fmt.Sprintf("| `%s` | %s |\n", cmd.Usage, cmd.Description))
+	}
+	if data.Trigger != "" {
+		sb.WriteString(fmt.Sprintf("| `%s <question>` | Ask CodeAgent about this Issue or PR |\n", data.Trigger))
+	}
+
+	flags := make([]string, 0, len(models.AIModels))
+	for _, model := range models.AIModels {
+		flags = append(flags, fmt.Sprintf("`-%s`", model))
+	}
+	sb.WriteString("\n### Model flags\n\n")
+	flagList := flags[len(flags)-1]
+	if len(flags) > 1 {
+		flagList = strings.Join(flags[:len(flags)-1], ", ") + " or " + flagList
+	}
+	sb.WriteString(fmt.Sprintf("Add %s right after a command to pick the provider, e.g. `/code -%s add tests`.", flagList, models.AIModels[0]))
+	if data.Provider != "" {
+		sb.WriteString(fmt.Sprintf(" Without a flag `%s` is used.", data.Provider))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("\n### Custom commands\n\n")
+	switch {
+	case !data.Enabled:
+		sb.WriteString("Custom commands are not enabled on this CodeAgent server.\n")
+		return sb.String()
+	case data.LoadErr != nil:
+		sb.WriteString("⚠️ Failed to load the custom commands of this repository; check the files in `.codeagent/`.\n")
+	case len(data.Commands) == 0:
+		sb.WriteString("No custom commands are defined. Add Markdown files to `.codeagent/commands/` to define them.\n")
+	}
+	if len(data.Commands) > 0 {
+		sb.WriteString("| Command | Description | Source | Required role |\n|---------|-------------|--------|---------------|\n")
+		for _, cmd := range data.Commands {
+			sb.WriteString(fmt.Sprintf("| `%s` | %s | %s | %s |\n", cmd.Name, tableCell(cmd.Description), cmd.Source, stringOr(cmd.Role, "default")))
+		}
+	}
+
+	if len(data.Agents) > 0 {
+		sb.WriteString("\n### Subagents\n\n")
+		sb.WriteString("| Agent | Description | Source |\n|-------|-------------|--------|\n")
+		for _, agent := range data.Agents {
+			sb.WriteString(fmt.Sprintf("| `%s` | %s | %s |\n", agent.Name, tableCell(agent.Description), agent.Source))
+		}
+	}
+	return sb.String()
+}
+
+// tableCell 转义Markdown表格单元格中的换行和竖线
+func tableCell(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "\n", " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
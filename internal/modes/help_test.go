@@ -0,0 +1,52 @@
+package modes
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/command"
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/permission"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlHandler_HelpCommands(t *testing.T) {
+	policy, err := permission.NewPolicy(config.PermissionsConfig{Commands: map[string]string{"lint": "triage"}}, nil)
+	require.NoError(t, err)
+	handler := NewControlHandler(nil, nil, nil, nil, &config.Config{}, policy)
+
+	commands := handler.helpCommands(map[string]*command.CommandDefinition{
+		"lint":   {Name: "lint", Description: "Run linters", Source: "global"},
+		"deploy": {Name: "deploy", Description: "Deploy", Source: "repository", Permission: "admin"},
+		"docs":   {Name: "docs", Source: "repository", Permission: "read"},
+	})
+
+	// 角色为全局策略与命令声明的较高者
+	assert.Equal(t, []helpCommand{
+		{Name: "/deploy", Description: "Deploy", Source: "repository", Role: "admin"},
+		{Name: "/docs", Source: "repository", Role: "write"},
+		{Name: "/lint", Description: "Run linters", Source: "global", Role: "triage"},
+	}, commands)
+}
+
+func TestRenderHelp(t *testing.T) {
+	body := renderHelp(helpData{
+		Trigger:  "@codeagent",
+		Provider: "gemini",
+		Enabled:  true,
+		Commands: []helpCommand{{Name: "/lint", Description: "Run linters\nand formatters | fast", Source: "global", Role: "triage"}},
+		Agents:   []*command.AgentDefinition{{Name: "reviewer", Description: "Strict reviewer", Source: "repository"}},
+	})
+	assert.Contains(t, body, "| `/fix-ci [instructions]` |")
+	assert.Contains(t, body, "| `@codeagent <question>` |")
+	assert.Contains(t, body, "Add `-claude`, `-gemini` or `-openai` right after a command")
+	assert.Contains(t, body, "Without a flag `gemini` is used.")
+	assert.Contains(t, body, "| `/lint` | Run linters and formatters \\| fast | global | triage |")
+	assert.Contains(t, body, "| `reviewer` | Strict reviewer | repository |")
+
+	assert.Contains(t, renderHelp(helpData{}), "Custom commands are not enabled")
+	assert.Contains(t, renderHelp(helpData{Enabled: true}), "No custom commands are defined")
+	assert.Contains(t, renderHelp(helpData{Enabled: true, LoadErr: errors.New("bad yaml")}), "Failed to load the custom commands")
+}
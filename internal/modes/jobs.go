@@ -0,0 +1,344 @@
+package modes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/qiniu/x/xlog"
+)
+
+const (
+	// defaultStaleDays 默认超过30天没有活动的Issue视为过期
+	defaultStaleDays = 30
+	// defaultStaleLabel 默认的过期标签
+	defaultStaleLabel = "stale"
+	// defaultStaleLimit 每次最多标记的过期Issue数量
+	defaultStaleLimit = 30
+	// staleMarker 过期提醒评论中的隐藏标记，用于识别标记之后是否还有新的活动
+	staleMarker = "<!-- codeagent:stale -->"
+
+	// defaultDependencyLabel 依赖升级Issue的默认标签
+	defaultDependencyLabel = "dependencies"
+	// dependencyIssueTitle 依赖升级Issue的标题前缀
+	dependencyIssueTitle = "Dependency update"
+
+	// defaultReviewLimit 每次最多审查的PR数量
+	defaultReviewLimit = 10
+)
+
+// jobRule 返回定时任务的参数：优先使用与cron表达式相同的配置，其次使用该任务的第一条配置
+func jobRule(ctx context.Context, job, cron string) repoconfig.ScheduleRule {
+	cfg := repoconfig.FromContext(ctx)
+	if cfg != nil {
+		for _, rule := range cfg.Automation.Schedules {
+			if rule.Job == job && rule.Cron == cron {
+				return rule
+			}
+		}
+	}
+	if rule, ok := cfg.ScheduleForJob(job); ok {
+		return rule
+	}
+	return repoconfig.ScheduleRule{Job: job}
+}
+
+// applyJobInputs 使用手动触发时传入的inputs覆盖任务参数，数字参数可以是数字或字符串
+func applyJobInputs(rule repoconfig.ScheduleRule, inputs map[string]interface{}) (repoconfig.ScheduleRule, error) {
+	ints := map[string]*int{"days": &rule.Days, "close_days": &rule.CloseDays, "limit": &rule.Limit}
+	strs := map[string]*string{"label": &rule.Label, "provider": &rule.Provider, "instructions": &rule.Instructions}
+
+	for key, value := range inputs {
+		if target, ok := ints[key]; ok {
+			n, err := inputInt(value)
+			if err != nil || n < 0 {
+				return rule, fmt.Errorf("input %s must be a non-negative integer, got %v", key, value)
+			}
+			*target = n
+			continue
+		}
+		if target, ok := strs[key]; ok {
+			str, ok := value.(string)
+			if !ok {
+				return rule, fmt.Errorf("input %s must be a string, got %v", key, value)
+			}
+			*target = str
+		}
+	}
+	return rule, nil
+}
+
+func inputInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("not an integer")
+		}
+		return int(v), nil
+	case string:
+		return strconv.Atoi(strings.TrimSpace(v))
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// runJob 执行定时任务
+func (ah *AgentHandler) runJob(ctx context.Context, event models.GitHubContext, client *ghclient.Client, rule repoconfig.ScheduleRule) error {
+	switch rule.Job {
+	case models.JobStaleIssues:
+		return ah.runStaleIssues(ctx, event, client, rule)
+	case models.JobDependencyUpdate:
+		return ah.runDependencyUpdate(ctx, event, client, rule)
+	case models.JobReviewOpenPRs:
+		return ah.runReviewOpenPRs(ctx, event, client, rule)
+	default:
+		return fmt.Errorf("unsupported job: %s", rule.Job)
+	}
+}
+
+// runStaleIssues 为长期没有活动的Issue添加过期标签并提醒；标记之后有新活动时移除标签，
+// 配置了 close_days 时关闭标记之后仍没有活动的Issue
+func (ah *AgentHandler) runStaleIssues(ctx context.Context, event models.GitHubContext, client *ghclient.Client, rule repoconfig.ScheduleRule) error {
+	xl := xlog.NewWith(ctx)
+
+	owner, name := event.GetRepository().GetOwner().GetLogin(), event.GetRepository().GetName()
+	days := intOr(rule.Days, defaultStaleDays)
+	label := stringOr(rule.Label, defaultStaleLabel)
+	limit := intOr(rule.Limit, defaultStaleLimit)
+	now := time.Now()
+
+	// 1. 处理已经标记过期的Issue
+	var unmarked, closed int
+	var errs []error
+	err := client.ListOpenIssues(ctx, owner, name, label, func(issue *github.Issue) bool {
+		last, err := client.GetLastIssueComment(ctx, owner, name, issue)
+		if err != nil {
+			errs = append(errs, err)
+			return true
+		}
+		number := issue.GetNumber()
+		if last == nil || !strings.Contains(last.GetBody(), staleMarker) {
+			// 标记之后有新的评论
+			if err := client.RemoveLabel(ctx, owner, name, number, label); err != nil {
+				errs = append(errs, err)
+			} else {
+				unmarked++
+			}
+			return true
+		}
+		if rule.CloseDays <= 0 || now.Sub(issue.GetUpdatedAt().Time) < daysDuration(rule.CloseDays) {
+			return true
+		}
+		body := fmt.Sprintf("Closing this issue because there has been no activity in the %d days since it was marked as stale. Feel free to reopen it if it is still relevant.", rule.CloseDays)
+		if _, err := client.CreateComment(ctx, owner, name, number, body); err != nil {
+			errs = append(errs, err)
+			return true
+		}
+		if err := client.CloseIssue(ctx, owner, name, number, "not_planned"); err != nil {
+			errs = append(errs, err)
+			return true
+		}
+		closed++
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	// 2. 标记新的过期Issue，Issue按更新时间排序，遇到未过期的Issue即可停止
+	var marked int
+	err = client.ListOpenIssues(ctx, owner, name, "", func(issue *github.Issue) bool {
+		if marked >= limit || now.Sub(issue.GetUpdatedAt().Time) < daysDuration(days) {
+			return false
+		}
+		if issueHasLabel(issue, label) {
+			return true
+		}
+		number := issue.GetNumber()
+		if err := client.AddLabels(ctx, owner, name, number, label); err != nil {
+			errs = append(errs, err)
+			return true
+		}
+		if _, err := client.CreateComment(ctx, owner, name, number, renderStaleComment(days, rule.CloseDays)); err != nil {
+			errs = append(errs, err)
+			return true
+		}
+		marked++
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	xl.Infof("Stale issue triage finished: %d marked, %d unmarked, %d closed", marked, unmarked, closed)
+	return errors.Join(errs...)
+}
+
+func renderStaleComment(days, closeDays int) string {
+	var sb strings.Builder
+	sb.WriteString(staleMarker + "\n")
+	sb.WriteString(fmt.Sprintf("This issue has had no activity for %d days and has been marked as stale.", days))
+	if closeDays > 0 {
+		sb.WriteString(fmt.Sprintf(" It will be closed in %d days if there is no further activity.", closeDays))
+	}
+	sb.WriteString(" Comment on the issue to keep it open.")
+	return sb.String()
+}
+
+// runDependencyUpdate 创建依赖升级Issue并按 /code 流程生成PR；上一次的Issue未关闭时跳过
+func (ah *AgentHandler) runDependencyUpdate(ctx context.Context, event models.GitHubContext, client *ghclient.Client, rule repoconfig.ScheduleRule) error {
+	xl := xlog.NewWith(ctx)
+
+	repo := event.GetRepository()
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	label := stringOr(rule.Label, defaultDependencyLabel)
+	title := fmt.Sprintf("%s (%s)", dependencyIssueTitle, time.Now().UTC().Format("2006-01-02"))
+
+	existing, err := findTrackingIssue(ctx, client, owner, name, label, dependencyIssueTitle)
+	if err != nil {
+		return err
+	}
+
+	issue := existing
+	switch {
+	case existing != nil && existing.GetTitle() != title:
+		xl.Infof("Dependency update issue #%d is still open, skip", existing.GetNumber())
+		return nil
+	case existing != nil:
+		// 同一次任务重试时继续处理已经创建的Issue
+		xl.Infof("Resuming dependency update issue #%d", existing.GetNumber())
+	default:
+		issue, err = client.CreateIssue(ctx, owner, name, title, dependencyIssueBody, []string{label})
+		if err != nil {
+			return err
+		}
+		xl.Infof("Created dependency update issue #%d", issue.GetNumber())
+	}
+	return ah.tagHandler.ProcessIssueCode(ctx, trackingIssueEvent(event, issue), rule.Provider, rule.Instructions)
+}
+
+// findTrackingIssue 查找带有label且标题以prefix开头的未关闭Issue，没有时返回nil
+func findTrackingIssue(ctx context.Context, client *ghclient.Client, owner, name, label, prefix string) (*github.Issue, error) {
+	var found *github.Issue
+	err := client.ListOpenIssues(ctx, owner, name, label, func(issue *github.Issue) bool {
+		if strings.HasPrefix(issue.GetTitle(), prefix) {
+			found = issue
+			return false
+		}
+		return true
+	})
+	return found, err
+}
+
+// trackingIssueEvent 为CodeAgent自己创建的跟踪Issue构造 /code 使用的事件
+func trackingIssueEvent(event models.GitHubContext, issue *github.Issue) *models.IssuesContext {
+	return &models.IssuesContext{
+		BaseContext: models.BaseContext{
+			Type:       models.EventIssues,
+			Repository: event.GetRepository(),
+			Sender:     event.GetSender(),
+			RawEvent:   issue,
+			Action:     "opened",
+			DeliveryID: event.GetDeliveryID(),
+			Timestamp:  time.Now(),
+		},
+		Issue: issue,
+	}
+}
+
+const dependencyIssueBody = `Update the dependencies of this repository to their latest compatible versions.
+
+- Use the package managers already used by the repository (for example go.mod, package.json, requirements.txt or Cargo.toml) and update their lock files.
+- Prefer minor and patch upgrades; only take a major upgrade when the required code changes are small.
+- Build the project and run the tests after upgrading, and fix any breakage caused by the upgrades.
+- List every upgraded dependency with its old and new version in the summary.
+
+_Created by the CodeAgent ` + "`dependency-update`" + ` schedule._`
+
+// JobQueue 将定时任务拆分出的单个PR的子任务加入任务队列，由agent实现
+type JobQueue interface {
+	// EnqueuePullRequestJob 为仓库下的PR加入一个定时任务事件，deliveryID相同的事件只会被处理一次
+	EnqueuePullRequestJob(ctx context.Context, repo *github.Repository, job, cron string, number int, deliveryID string) error
+}
+
+// runReviewOpenPRs 审查还没有被CodeAgent审查过的未关闭PR：
+// 每个PR作为单独的事件加入任务队列，与同一PR上的其他任务串行执行，也可以被单独取消
+func (ah *AgentHandler) runReviewOpenPRs(ctx context.Context, event models.GitHubContext, client *ghclient.Client, rule repoconfig.ScheduleRule) error {
+	xl := xlog.NewWith(ctx)
+
+	var cron string
+	if schedule, ok := event.(*models.ScheduleContext); ok {
+		if schedule.PullRequest != 0 {
+			_, err := ah.tagHandler.reviewHandler.ProcessScheduledReview(ctx, event, client, schedule.PullRequest)
+			return err
+		}
+		cron = schedule.Cron
+	}
+	if ah.jobs == nil {
+		return fmt.Errorf("job queue is not available for %s", rule.Job)
+	}
+
+	repo := event.GetRepository()
+	limit := intOr(rule.Limit, defaultReviewLimit)
+	prs, err := client.ListOpenPullRequests(ctx, repo.GetOwner().GetLogin(), repo.GetName())
+	if err != nil {
+		return err
+	}
+
+	var queued int
+	var errs []error
+	for _, pr := range prs {
+		if queued >= limit {
+			xl.Infof("Reached review limit %d, remaining PRs are left for the next run", limit)
+			break
+		}
+		if pr.GetDraft() || ah.tagHandler.reviewHandler.HasReviewed(repo.GetFullName(), pr.GetNumber()) {
+			continue
+		}
+		deliveryID := fmt.Sprintf("%s-pr-%d", event.GetDeliveryID(), pr.GetNumber())
+		if err := ah.jobs.EnqueuePullRequestJob(ctx, repo, rule.Job, cron, pr.GetNumber(), deliveryID); err != nil {
+			xl.Warnf("Failed to queue scheduled review of PR #%d: %v", pr.GetNumber(), err)
+			errs = append(errs, fmt.Errorf("PR #%d: %w", pr.GetNumber(), err))
+			continue
+		}
+		queued++
+	}
+
+	xl.Infof("Queued reviews of %d open PRs without a CodeAgent review", queued)
+	return errors.Join(errs...)
+}
+
+func issueHasLabel(issue *github.Issue, label string) bool {
+	for _, l := range issue.Labels {
+		if strings.EqualFold(l.GetName(), label) {
+			return true
+		}
+	}
+	return false
+}
+
+func daysDuration(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func intOr(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
+func stringOr(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,59 @@
+package modes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobRule(t *testing.T) {
+	cfg := &repoconfig.Config{Automation: repoconfig.AutomationConfig{Schedules: []repoconfig.ScheduleRule{
+		{Job: models.JobStaleIssues, Cron: "0 3 * * *", Days: 60},
+		{Job: models.JobStaleIssues, Cron: "0 4 * * 1", Days: 90},
+	}}}
+	ctx := repoconfig.NewContext(context.Background(), cfg)
+
+	assert.Equal(t, 90, jobRule(ctx, models.JobStaleIssues, "0 4 * * 1").Days)
+	assert.Equal(t, 60, jobRule(ctx, models.JobStaleIssues, "@daily").Days)
+	assert.Equal(t, repoconfig.ScheduleRule{Job: models.JobReviewOpenPRs}, jobRule(ctx, models.JobReviewOpenPRs, "@daily"))
+	assert.Equal(t, repoconfig.ScheduleRule{Job: models.JobReviewOpenPRs}, jobRule(context.Background(), models.JobReviewOpenPRs, ""))
+}
+
+func TestApplyJobInputs(t *testing.T) {
+	base := repoconfig.ScheduleRule{Job: models.JobStaleIssues, Days: 60, Label: "inactive"}
+
+	rule, err := applyJobInputs(base, map[string]interface{}{
+		"job":          models.JobStaleIssues,
+		"days":         float64(14),
+		"close_days":   "7",
+		"instructions": "Be nice.",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 14, rule.Days)
+	assert.Equal(t, 7, rule.CloseDays)
+	assert.Equal(t, "inactive", rule.Label)
+	assert.Equal(t, "Be nice.", rule.Instructions)
+
+	for _, inputs := range []map[string]interface{}{
+		{"days": "soon"},
+		{"limit": float64(-1)},
+		{"close_days": 1.5},
+		{"label": float64(1)},
+	} {
+		_, err := applyJobInputs(base, inputs)
+		assert.Error(t, err, inputs)
+	}
+}
+
+func TestRenderStaleComment(t *testing.T) {
+	comment := renderStaleComment(30, 7)
+	assert.Contains(t, comment, staleMarker)
+	assert.Contains(t, comment, "no activity for 30 days")
+	assert.Contains(t, comment, "closed in 7 days")
+	assert.NotContains(t, renderStaleComment(30, 0), "closed")
+}
@@ -25,8 +25,8 @@ func NewManager() *Manager {
 func (m *Manager) RegisterHandler(handler ModeHandler) {
 	m.handlers = append(m.handlers, handler)
 
-	// 按优先级排序（数字越小优先级越高）
-	sort.Slice(m.handlers, func(i, j int) bool {
+	// 按优先级排序（数字越小优先级越高），相同优先级保持注册顺序
+	sort.SliceStable(m.handlers, func(i, j int) bool {
 		return m.handlers[i].GetPriority() < m.handlers[j].GetPriority()
 	})
 }
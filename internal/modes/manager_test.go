@@ -65,6 +65,24 @@ func TestModeManager_RegisterHandler(t *testing.T) {
 	assert.Equal(t, 30, handlers[2].GetPriority())
 }
 
+func TestModeManager_RegisterHandlerKeepsOrderOfEqualPriorities(t *testing.T) {
+	manager := NewModeManager()
+
+	first := NewMockHandler(ControlMode, 0, nil)
+	second := NewMockHandler(CIFixMode, 0, nil)
+	third := NewMockHandler(PushMode, 0, nil)
+	manager.RegisterHandler(NewMockHandler(TagMode, 10, nil))
+	manager.RegisterHandler(first)
+	manager.RegisterHandler(second)
+	manager.RegisterHandler(third)
+
+	handlers := manager.GetRegisteredHandlers()
+	require.Len(t, handlers, 4)
+	assert.Same(t, first, handlers[0])
+	assert.Same(t, second, handlers[1])
+	assert.Same(t, third, handlers[2])
+}
+
 func TestModeManager_FindHandler(t *testing.T) {
 	manager := NewModeManager()
 	ctx := context.Background()
@@ -0,0 +1,214 @@
+package modes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/qiniu/codeagent/internal/code"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/qiniu/x/xlog"
+)
+
+const (
+	// planMarker 计划评论中的隐藏标记，/code approve-plan 据此找到最近一次的计划
+	planMarker = "<!-- codeagent:plan -->"
+	// planEndMarker 计划正文的结束标记
+	planEndMarker = "<!-- codeagent:plan-end -->"
+	// approvePlanArg /code 的参数，按最近一次的计划实现
+	approvePlanArg = "approve-plan"
+)
+
+// approvedPlanKey 已批准的实现计划在context中的key
+type approvedPlanKey struct{}
+
+func withApprovedPlan(ctx context.Context, plan string) context.Context {
+	return context.WithValue(ctx, approvedPlanKey{}, plan)
+}
+
+func approvedPlanFromContext(ctx context.Context) string {
+	plan, _ := ctx.Value(approvedPlanKey{}).(string)
+	return plan
+}
+
+// processIssuePlanCommand 处理Issue的/plan命令：以只读模式分析代码，回复结构化的实现计划
+func (th *TagHandler) processIssuePlanCommand(
+	ctx context.Context,
+	event *models.IssueCommentContext,
+	cmdInfo *models.CommandInfo,
+) error {
+	xl := xlog.NewWith(ctx)
+
+	issueNumber := event.Issue.GetNumber()
+	xl.Infof("Starting issue planning: issue=#%d, title=%s, AI model=%s",
+		issueNumber, event.Issue.GetTitle(), cmdInfo.AIModel)
+
+	commentID, err := th.createIssueComment(ctx, event)
+	if err != nil {
+		xl.Warnf("Failed to create pre-comment: %v", err)
+	}
+
+	// 计划与之后的 /code 使用同一个Issue工作空间，只读模式下不会修改其中的文件
+	ws := th.workspace.GetOrCreateWorkspaceForIssue(event.Issue, cmdInfo.AIModel)
+	if ws != nil {
+		// 计划发布后关闭会话，之后的 /code 重新创建会话
+		defer func() {
+			if err := th.sessionManager.CloseSession(ws); err != nil {
+				xl.Warnf("Failed to close planning session: %v", err)
+			}
+		}()
+	}
+
+	var body string
+	plan, model, err := th.generatePlan(ctx, event, cmdInfo, ws)
+	if err != nil {
+		xl.Errorf("Failed to create implementation plan: %v", err)
+		body = fmt.Sprintf("❌ Failed to create an implementation plan: %v", err)
+	} else {
+		body = renderPlanComment(plan, model)
+	}
+
+	repo := event.GetRepository()
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	client, clientErr := th.clientManager.GetClient(ctx, &models.Repository{Owner: owner, Name: name})
+	if clientErr != nil {
+		return fmt.Errorf("failed to get GitHub client: %w", clientErr)
+	}
+	if commentID != 0 {
+		if updateErr := client.UpdateComment(ctx, owner, name, commentID, body); updateErr != nil {
+			return fmt.Errorf("failed to post implementation plan: %w", updateErr)
+		}
+	} else if _, createErr := client.CreateComment(ctx, owner, name, issueNumber, body); createErr != nil {
+		return fmt.Errorf("failed to post implementation plan: %w", createErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	xl.Infof("Posted implementation plan on issue #%d, length: %d", issueNumber, len(plan))
+	return nil
+}
+
+// generatePlan 在Issue工作空间中以只读模式调用AI，返回计划正文和实际使用的模型
+func (th *TagHandler) generatePlan(
+	ctx context.Context,
+	event *models.IssueCommentContext,
+	cmdInfo *models.CommandInfo,
+	ws *models.Workspace,
+) (string, string, error) {
+	xl := xlog.NewWith(ctx)
+
+	if ws == nil {
+		return "", "", fmt.Errorf("failed to create workspace from issue")
+	}
+	xl.Infof("Planning in workspace: %s", ws.Path)
+
+	codeClient, err := getSession(ctx, th.sessionManager, ws)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get code client: %w", err)
+	}
+
+	prompt, err := th.buildIssuePrompt(ctx, event, "Plan", cmdInfo.Args)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to build plan prompt: %w", err)
+	}
+
+	resp, err := th.promptWithRetry(code.WithReadOnly(ctx), codeClient, prompt, 3)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to prompt for plan: %w", err)
+	}
+	output, err := io.ReadAll(resp.Out)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read plan output: %w", err)
+	}
+
+	plan := strings.TrimSpace(string(output))
+	if plan == "" {
+		return "", "", fmt.Errorf("AI returned an empty plan")
+	}
+	return plan, describeModel(resp, ws.AIModel), nil
+}
+
+// findApprovedPlan 返回Issue中最近一次由CodeAgent发布的实现计划，没有时返回空字符串
+func (th *TagHandler) findApprovedPlan(ctx context.Context, event *models.IssueCommentContext) (string, error) {
+	repo := event.GetRepository()
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	client, err := th.clientManager.GetClient(ctx, &models.Repository{Owner: owner, Name: name})
+	if err != nil {
+		return "", fmt.Errorf("failed to get GitHub client: %w", err)
+	}
+
+	login, err := client.Login(ctx)
+	if err != nil {
+		return "", err
+	}
+	comment, err := client.FindLastIssueComment(ctx, owner, name, event.Issue.GetNumber(), func(comment *github.IssueComment) bool {
+		return isPlanComment(comment, login)
+	})
+	if err != nil {
+		return "", err
+	}
+	if comment == nil {
+		return "", nil
+	}
+	return extractPlan(comment.GetBody()), nil
+}
+
+// replyIssue 在Issue中回复评论
+func (th *TagHandler) replyIssue(ctx context.Context, event *models.IssueCommentContext, body string) error {
+	repo := event.GetRepository()
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	client, err := th.clientManager.GetClient(ctx, &models.Repository{Owner: owner, Name: name})
+	if err != nil {
+		return fmt.Errorf("failed to get GitHub client: %w", err)
+	}
+	if _, err := client.CreateComment(ctx, owner, name, event.Issue.GetNumber(), body); err != nil {
+		return fmt.Errorf("failed to reply to issue: %w", err)
+	}
+	return nil
+}
+
+// parseApprovePlan 判断 /code 的参数是否以 approve-plan 开头，返回其余的补充说明
+func parseApprovePlan(args string) (string, bool) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], approvePlanArg) {
+		return args, false
+	}
+	return strings.TrimSpace(strings.TrimSpace(args)[len(fields[0]):]), true
+}
+
+// isPlanComment 评论是否为CodeAgent（login）发布的计划评论，
+// 避免其他用户伪造计划后被 /code approve-plan 执行
+func isPlanComment(comment *github.IssueComment, login string) bool {
+	return login != "" &&
+		strings.EqualFold(comment.GetUser().GetLogin(), login) &&
+		strings.Contains(comment.GetBody(), planMarker)
+}
+
+// extractPlan 取出计划评论中标记之间的计划正文
+func extractPlan(body string) string {
+	_, plan, ok := strings.Cut(body, planMarker)
+	if !ok {
+		return ""
+	}
+	plan, _, _ = strings.Cut(plan, planEndMarker)
+	return strings.TrimSpace(plan)
+}
+
+func renderPlanComment(plan, model string) string {
+	var sb strings.Builder
+	sb.WriteString("## 📋 Implementation plan\n\n")
+	sb.WriteString(planMarker + "\n")
+	sb.WriteString(plan + "\n")
+	sb.WriteString(planEndMarker + "\n\n")
+	sb.WriteString("---\n")
+	if model != "" {
+		sb.WriteString(fmt.Sprintf("_Planned by %s in read-only mode._ ", model))
+	}
+	sb.WriteString(fmt.Sprintf("Reply `%s %s` to implement this plan, or `%s <feedback>` to revise it.",
+		models.CommandCode, approvePlanArg, models.CommandPlan))
+	return sb.String()
+}
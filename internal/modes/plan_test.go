@@ -0,0 +1,56 @@
+package modes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseApprovePlan(t *testing.T) {
+	tests := []struct {
+		args string
+		rest string
+		ok   bool
+	}{
+		{"approve-plan", "", true},
+		{"  Approve-Plan also add a changelog entry", "also add a changelog entry", true},
+		{"approve-planning", "approve-planning", false},
+		{"implement it", "implement it", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		rest, ok := parseApprovePlan(tt.args)
+		assert.Equal(t, tt.ok, ok, tt.args)
+		assert.Equal(t, tt.rest, rest, tt.args)
+	}
+}
+
+func TestPlanComment(t *testing.T) {
+	plan := "### Files to change\n- main.go\n\n### Approach\n1. Edit main.go"
+	body := renderPlanComment(plan, "`claude`")
+	assert.Contains(t, body, "_Planned by `claude` in read-only mode._")
+	assert.Contains(t, body, "`/code approve-plan`")
+	assert.Equal(t, plan, extractPlan(body))
+	assert.Empty(t, extractPlan("no plan here"))
+
+	comment := func(login, body string) *github.IssueComment {
+		return &github.IssueComment{
+			Body:              github.String(body),
+			User:              &github.User{Login: github.String(login)},
+			AuthorAssociation: github.String("OWNER"),
+		}
+	}
+	assert.True(t, isPlanComment(comment("codeagent[bot]", body), "codeagent[bot]"))
+	// 其他用户（包括仓库成员）伪造的计划不会被执行
+	assert.False(t, isPlanComment(comment("alice", body), "codeagent[bot]"))
+	assert.False(t, isPlanComment(comment("codeagent[bot]", "plan"), "codeagent[bot]"))
+	assert.False(t, isPlanComment(comment("", body), ""))
+}
+
+func TestApprovedPlanContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, approvedPlanFromContext(ctx))
+	assert.Equal(t, "plan", approvedPlanFromContext(withApprovedPlan(ctx, "plan")))
+}
@@ -0,0 +1,328 @@
+package modes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/internal/workspace"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/qiniu/x/xlog"
+)
+
+const (
+	// defaultChangelogLabel CHANGELOG跟踪Issue的默认标签
+	defaultChangelogLabel = "changelog"
+	// changelogIssueTitle CHANGELOG跟踪Issue的标题前缀
+	changelogIssueTitle = "Update CHANGELOG for"
+
+	// defaultDocsLabel 文档跟踪Issue的默认标签
+	defaultDocsLabel = "documentation"
+	// docsIssueTitle 文档跟踪Issue的标题前缀
+	docsIssueTitle = "Update documentation for"
+
+	// maxPushCommits 跟踪Issue中最多列出的提交数量
+	maxPushCommits = 20
+)
+
+// PushHandler 按仓库配置的 automation.push 规则处理推送到默认分支或受保护分支的事件
+type PushHandler struct {
+	*BaseHandler
+	clientManager ghclient.ClientManagerInterface
+	workspace     *workspace.Manager
+	tagHandler    *TagHandler
+}
+
+// NewPushHandler 创建推送自动化处理器
+func NewPushHandler(clientManager ghclient.ClientManagerInterface, workspace *workspace.Manager, tagHandler *TagHandler) *PushHandler {
+	return &PushHandler{
+		BaseHandler: NewBaseHandler(
+			PushMode,
+			3,
+			"Run repository automation on pushes to default or protected branches",
+		),
+		clientManager: clientManager,
+		workspace:     workspace,
+		tagHandler:    tagHandler,
+	}
+}
+
+// CanHandle 处理推送到分支且匹配至少一条推送规则的事件，忽略删除分支和推送标签
+func (h *PushHandler) CanHandle(ctx context.Context, event models.GitHubContext) bool {
+	e, ok := event.(*models.PushContext)
+	if !ok || e.Deleted || e.Branch() == "" {
+		return false
+	}
+	return len(h.rules(ctx, e)) > 0
+}
+
+func (h *PushHandler) rules(ctx context.Context, event *models.PushContext) []repoconfig.PushRule {
+	return repoconfig.FromContext(ctx).PushRules(event.Branch(), event.GetRepository().GetDefaultBranch(), event.ChangedFiles())
+}
+
+// Execute 依次执行匹配的推送规则，单条规则失败不影响其他规则
+func (h *PushHandler) Execute(ctx context.Context, event models.GitHubContext) error {
+	xl := xlog.NewWith(ctx)
+
+	pushEvent, ok := event.(*models.PushContext)
+	if !ok {
+		return fmt.Errorf("unsupported event type for PushHandler: %T", event)
+	}
+	repo := pushEvent.GetRepository()
+	client, err := h.clientManager.GetClient(ctx, &models.Repository{Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+	if err != nil {
+		return fmt.Errorf("failed to get GitHub client: %w", err)
+	}
+
+	xl.Infof("Processing push to %s@%s", repo.GetFullName(), pushEvent.Branch())
+	var errs []error
+	for _, rule := range h.rules(ctx, pushEvent) {
+		if err := h.runRule(ctx, pushEvent, client, rule); err != nil {
+			xl.Warnf("Push action %s failed: %v", rule.Action, err)
+			errs = append(errs, fmt.Errorf("%s: %w", rule.Action, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *PushHandler) runRule(ctx context.Context, event *models.PushContext, client *ghclient.Client, rule repoconfig.PushRule) error {
+	switch rule.Action {
+	case models.PushActionRefreshCache:
+		return h.refreshCache(ctx, event)
+	case models.PushActionCloseMergedPRs:
+		return h.closeMergedPRs(ctx, event, client)
+	case models.PushActionChangelog:
+		return h.updateChangelog(ctx, event, client, rule)
+	case models.PushActionDocs:
+		return h.updateDocs(ctx, event, client, rule)
+	default:
+		return fmt.Errorf("unsupported push action: %s", rule.Action)
+	}
+}
+
+// refreshCache 更新仓库的bare缓存，后续任务创建工作空间时不必再拉取
+func (h *PushHandler) refreshCache(ctx context.Context, event *models.PushContext) error {
+	xl := xlog.NewWith(ctx)
+
+	repo := event.GetRepository()
+	refreshed, err := h.workspace.RefreshRepoCache(repo.GetOwner().GetLogin(), repo.GetName())
+	if err != nil {
+		return err
+	}
+	if refreshed {
+		xl.Infof("Refreshed repository cache of %s", repo.GetFullName())
+	} else {
+		xl.Infof("Repository %s is not cached yet, skip refreshing", repo.GetFullName())
+	}
+	return nil
+}
+
+// closeMergedPRs 关闭目标分支为推送分支、且所有提交都已经包含在推送后分支中的CodeAgent PR，
+// 分支和工作空间由PR关闭事件清理
+func (h *PushHandler) closeMergedPRs(ctx context.Context, event *models.PushContext, client *ghclient.Client) error {
+	xl := xlog.NewWith(ctx)
+
+	repo := event.GetRepository()
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	prs, err := client.ListOpenPullRequests(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+
+	var closed int
+	var errs []error
+	for _, pr := range prs {
+		head := pr.GetHead()
+		if pr.GetBase().GetRef() != event.Branch() || !h.workspace.IsAgentBranch(owner, name, head.GetRef()) {
+			continue
+		}
+		// 只处理同仓库的分支
+		if head.GetRepo().GetFullName() != repo.GetFullName() {
+			continue
+		}
+		comparison, err := client.CompareCommits(ctx, owner, name, event.After, head.GetSHA())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("PR #%d: %w", pr.GetNumber(), err))
+			continue
+		}
+		if comparison.GetAheadBy() > 0 {
+			continue
+		}
+
+		body := fmt.Sprintf("Closing this pull request because all of its commits are already on `%s` (%s).", event.Branch(), shortSHA(event.After))
+		if _, err := client.CreateComment(ctx, owner, name, pr.GetNumber(), body); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := client.ClosePullRequest(ctx, owner, name, pr.GetNumber()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		closed++
+		xl.Infof("Closed PR #%d whose branch %s was merged into %s", pr.GetNumber(), head.GetRef(), event.Branch())
+	}
+
+	xl.Infof("Closed %d CodeAgent PRs merged into %s", closed, event.Branch())
+	return errors.Join(errs...)
+}
+
+// updateChangelog 创建CHANGELOG跟踪Issue并按 /code 流程生成PR
+func (h *PushHandler) updateChangelog(ctx context.Context, event *models.PushContext, client *ghclient.Client, rule repoconfig.PushRule) error {
+	files := event.ChangedFiles()
+	// 只修改了CHANGELOG的推送（通常是合并了上一次生成的PR）不再触发
+	if len(files) > 0 && allFiles(files, isChangelogFile) {
+		xlog.NewWith(ctx).Infof("Push only changes the changelog, skip")
+		return nil
+	}
+	prefix := fmt.Sprintf("%s %s@", changelogIssueTitle, event.Branch())
+	return h.processTrackingIssue(ctx, event, client, rule, prefix, stringOr(rule.Label, defaultChangelogLabel), renderChangelogIssueBody(event))
+}
+
+// updateDocs 创建文档跟踪Issue并按 /code 流程生成PR，只考虑匹配规则paths的非文档文件
+func (h *PushHandler) updateDocs(ctx context.Context, event *models.PushContext, client *ghclient.Client, rule repoconfig.PushRule) error {
+	files := docsSourceFiles(rule, event.ChangedFiles())
+	if len(files) == 0 {
+		xlog.NewWith(ctx).Infof("Push only changes documentation, skip")
+		return nil
+	}
+	prefix := fmt.Sprintf("%s %s@", docsIssueTitle, event.Branch())
+	return h.processTrackingIssue(ctx, event, client, rule, prefix, stringOr(rule.Label, defaultDocsLabel), renderDocsIssueBody(event, files))
+}
+
+// processTrackingIssue 创建标题为 prefix+提交 的跟踪Issue并执行 /code；
+// 之前的跟踪Issue未关闭时只在其中补充新的提交，避免同时存在多个PR
+func (h *PushHandler) processTrackingIssue(ctx context.Context, event *models.PushContext, client *ghclient.Client, rule repoconfig.PushRule, prefix, label, body string) error {
+	xl := xlog.NewWith(ctx)
+
+	repo := event.GetRepository()
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	title := prefix + shortSHA(event.After)
+
+	existing, err := findTrackingIssue(ctx, client, owner, name, label, prefix)
+	if err != nil {
+		return err
+	}
+
+	issue := existing
+	switch {
+	case existing != nil && existing.GetTitle() != title:
+		comment := fmt.Sprintf("New commits were pushed to `%s` while this issue is still open:\n\n%s", event.Branch(), renderPushCommits(event))
+		if _, err := client.CreateComment(ctx, owner, name, existing.GetNumber(), comment); err != nil {
+			return err
+		}
+		xl.Infof("Tracking issue #%d is still open, added the new commits to it", existing.GetNumber())
+		return nil
+	case existing != nil:
+		// 同一次推送重试时继续处理已经创建的Issue
+		xl.Infof("Resuming tracking issue #%d", existing.GetNumber())
+	default:
+		issue, err = client.CreateIssue(ctx, owner, name, title, body, []string{label})
+		if err != nil {
+			return err
+		}
+		xl.Infof("Created tracking issue #%d: %s", issue.GetNumber(), title)
+	}
+	return h.tagHandler.ProcessIssueCode(ctx, trackingIssueEvent(event, issue), rule.Provider, rule.Instructions)
+}
+
+func renderChangelogIssueBody(event *models.PushContext) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Update the changelog for the commits pushed to `%s`%s.\n\n", event.Branch(), compareSuffix(event)))
+	sb.WriteString("- Add the changes to the \"Unreleased\" section of `CHANGELOG.md`, following the existing format of the file. Create the file in the Keep a Changelog format if it does not exist.\n")
+	sb.WriteString("- Describe user-visible changes only; skip refactorings, tests and CI changes.\n")
+	sb.WriteString("- Do not change any other file.\n\n")
+	sb.WriteString("## Commits\n\n")
+	sb.WriteString(renderPushCommits(event))
+	sb.WriteString("\n\n_Created by the CodeAgent `changelog` push rule._")
+	return sb.String()
+}
+
+func renderDocsIssueBody(event *models.PushContext, files []string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("The commits pushed to `%s`%s changed code that is covered by the documentation. Update the documentation so that it matches the new code.\n\n", event.Branch(), compareSuffix(event)))
+	sb.WriteString("- Only change documentation; do not change code.\n")
+	sb.WriteString("- If the documentation is still accurate, make no changes and explain why.\n\n")
+	sb.WriteString("## Changed files\n\n")
+	for _, file := range files {
+		sb.WriteString(fmt.Sprintf("- `%s`\n", file))
+	}
+	sb.WriteString("\n## Commits\n\n")
+	sb.WriteString(renderPushCommits(event))
+	sb.WriteString("\n\n_Created by the CodeAgent `docs` push rule._")
+	return sb.String()
+}
+
+func renderPushCommits(event *models.PushContext) string {
+	var lines []string
+	for i, commit := range event.Commits {
+		if i == maxPushCommits {
+			lines = append(lines, fmt.Sprintf("- … and %d more", len(event.Commits)-maxPushCommits))
+			break
+		}
+		subject, _, _ := strings.Cut(commit.GetMessage(), "\n")
+		lines = append(lines, fmt.Sprintf("- %s %s", shortSHA(commit.GetID()), subject))
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("- %s", shortSHA(event.After))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func compareSuffix(event *models.PushContext) string {
+	if raw, ok := event.RawEvent.(*github.PushEvent); ok && raw.GetCompare() != "" {
+		return fmt.Sprintf(" ([compare](%s))", raw.GetCompare())
+	}
+	return ""
+}
+
+// docsSourceFiles 返回推送中匹配规则paths的文件，忽略文档文件以免合并文档PR后再次触发
+func docsSourceFiles(rule repoconfig.PushRule, files []string) []string {
+	var result []string
+	for _, file := range files {
+		if isDocFile(file) {
+			continue
+		}
+		if len(rule.Paths) > 0 && !repoconfig.MatchAny(rule.Paths, file) {
+			continue
+		}
+		result = append(result, file)
+	}
+	return result
+}
+
+func isDocFile(file string) bool {
+	if strings.HasPrefix(file, "docs/") || strings.HasPrefix(file, "doc/") {
+		return true
+	}
+	switch strings.ToLower(path.Ext(file)) {
+	case ".md", ".mdx", ".rst", ".adoc":
+		return true
+	}
+	return false
+}
+
+func isChangelogFile(file string) bool {
+	return strings.HasPrefix(strings.ToUpper(path.Base(file)), "CHANGELOG")
+}
+
+func allFiles(files []string, fn func(string) bool) bool {
+	for _, file := range files {
+		if !fn(file) {
+			return false
+		}
+	}
+	return true
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
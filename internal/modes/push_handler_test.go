@@ -0,0 +1,79 @@
+package modes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushHandler_CanHandle(t *testing.T) {
+	handler := NewPushHandler(nil, nil, nil)
+	cfg := &repoconfig.Config{Automation: repoconfig.AutomationConfig{Push: []repoconfig.PushRule{
+		{Action: models.PushActionRefreshCache},
+		{Action: models.PushActionDocs, Branches: []string{"release-*"}, Paths: []string{"api/**"}},
+	}}}
+	ctx := repoconfig.NewContext(context.Background(), cfg)
+
+	push := func(ref string, deleted bool, files ...string) *models.PushContext {
+		return &models.PushContext{
+			BaseContext: models.BaseContext{
+				Type:       models.EventPush,
+				Repository: &github.Repository{FullName: github.String("test/repo"), DefaultBranch: github.String("main")},
+			},
+			Ref:     ref,
+			Deleted: deleted,
+			Commits: []*github.HeadCommit{{Modified: files}},
+		}
+	}
+
+	assert.True(t, handler.CanHandle(ctx, push("refs/heads/main", false, "main.go")))
+	assert.True(t, handler.CanHandle(ctx, push("refs/heads/release-1.0", false, "api/user.go")))
+	assert.False(t, handler.CanHandle(ctx, push("refs/heads/release-1.0", false, "main.go")))
+	assert.False(t, handler.CanHandle(ctx, push("refs/heads/feature", false, "api/user.go")))
+	assert.False(t, handler.CanHandle(ctx, push("refs/heads/main", true)))
+	assert.False(t, handler.CanHandle(ctx, push("refs/tags/v1.0.0", false, "main.go")))
+	// 没有仓库配置时不处理推送
+	assert.False(t, handler.CanHandle(context.Background(), push("refs/heads/main", false, "main.go")))
+	assert.False(t, handler.CanHandle(ctx, &models.IssuesContext{}))
+}
+
+func TestDocsSourceFiles(t *testing.T) {
+	files := []string{"api/user.go", "docs/api.md", "README.md", "internal/db.go"}
+
+	assert.Equal(t, []string{"api/user.go", "internal/db.go"}, docsSourceFiles(repoconfig.PushRule{}, files))
+	assert.Equal(t, []string{"api/user.go"}, docsSourceFiles(repoconfig.PushRule{Paths: []string{"api/**"}}, files))
+	assert.Empty(t, docsSourceFiles(repoconfig.PushRule{}, []string{"docs/guide.md", "CHANGELOG.md"}))
+}
+
+func TestRenderPushIssueBodies(t *testing.T) {
+	event := &models.PushContext{
+		BaseContext: models.BaseContext{
+			RawEvent: &github.PushEvent{Compare: github.String("https://github.com/test/repo/compare/1111111...2222222")},
+		},
+		Ref:   "refs/heads/main",
+		After: "2222222222222222222222222222222222222222",
+		Commits: []*github.HeadCommit{
+			{ID: github.String("abcdef0123456789"), Message: github.String("feat: add api\n\nlong description")},
+		},
+	}
+
+	changelog := renderChangelogIssueBody(event)
+	assert.Contains(t, changelog, "pushed to `main` ([compare](https://github.com/test/repo/compare/1111111...2222222))")
+	assert.Contains(t, changelog, "- abcdef0 feat: add api\n")
+	assert.NotContains(t, changelog, "long description")
+
+	docs := renderDocsIssueBody(event, []string{"api/user.go"})
+	assert.Contains(t, docs, "- `api/user.go`")
+	assert.Contains(t, docs, "- abcdef0 feat: add api")
+
+	for i := 0; i < maxPushCommits+2; i++ {
+		event.Commits = append(event.Commits, &github.HeadCommit{ID: github.String("0123456789"), Message: github.String("chore")})
+	}
+	assert.True(t, strings.HasSuffix(renderPushCommits(event), "- … and 3 more"))
+}
@@ -0,0 +1,15 @@
+package modes
+
+import (
+	"context"
+
+	"github.com/qiniu/codeagent/internal/code"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/pkg/models"
+)
+
+// getSession 获取工作空间的AI会话，仓库配置为该provider指定了模型时使用该模型
+func getSession(ctx context.Context, sessionManager *code.SessionManager, ws *models.Workspace) (code.Code, error) {
+	ws.Model = repoconfig.FromContext(ctx).ModelFor(ws.AIModel)
+	return sessionManager.GetSession(ws)
+}
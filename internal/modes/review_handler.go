@@ -12,10 +12,14 @@ import (
 	"github.com/qiniu/codeagent/internal/config"
 	ctxsys "github.com/qiniu/codeagent/internal/context"
 	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/interaction"
 	"github.com/qiniu/codeagent/internal/mcp"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/internal/review"
 	"github.com/qiniu/codeagent/internal/workspace"
 	"github.com/qiniu/codeagent/pkg/models"
 
+	"github.com/google/go-github/v58/github"
 	"github.com/qiniu/x/xlog"
 )
 
@@ -29,10 +33,12 @@ type ReviewHandler struct {
 	sessionManager *code.SessionManager
 	config         *config.Config
 	contextManager *ctxsys.ContextManager
+	reviews        *review.StateStore
+	policy         review.Policy
 }
 
 // NewReviewHandler 创建Review模式处理器
-func NewReviewHandler(clientManager ghclient.ClientManagerInterface, workspace *workspace.Manager, mcpClient mcp.MCPClient, sessionManager *code.SessionManager, config *config.Config) *ReviewHandler {
+func NewReviewHandler(clientManager ghclient.ClientManagerInterface, workspace *workspace.Manager, mcpClient mcp.MCPClient, sessionManager *code.SessionManager, config *config.Config, reviews *review.StateStore, policy review.Policy) *ReviewHandler {
 	// Create context manager with dynamic client support
 	collector := ctxsys.NewDefaultContextCollector(clientManager)
 	formatter := ctxsys.NewDefaultContextFormatter(50000) // 50k tokens limit
@@ -55,6 +61,8 @@ func NewReviewHandler(clientManager ghclient.ClientManagerInterface, workspace *
 		sessionManager: sessionManager,
 		config:         config,
 		contextManager: contextManager,
+		reviews:        reviews,
+		policy:         policy,
 	}
 }
 
@@ -83,6 +91,11 @@ func (rh *ReviewHandler) canHandlePREvent(ctx context.Context, event *models.Pul
 		xl.Infof("Review mode can handle PR opened event")
 		return true
 
+	case "synchronize":
+		// 推送新提交时增量审查
+		xl.Infof("Review mode can handle PR synchronize event")
+		return true
+
 	case "closed":
 		// PR关闭时清理资源
 		xl.Infof("Review mode can handle PR closed event")
@@ -168,6 +181,14 @@ func (rh *ReviewHandler) handlePREvent(ctx context.Context, event *models.PullRe
 		prAuthor := event.PullRequest.GetUser().GetLogin()
 		if rh.isAccountExcluded(ctx, prAuthor) {
 			xl.Infof("Skipping auto-review for PR #%d: author %s is excluded", event.PullRequest.GetNumber(), prAuthor)
+			rh.publishSkipped(ctx, client, event.PullRequest, fmt.Sprintf("Auto-review is skipped for PRs by `%s`.", prAuthor))
+			return nil
+		}
+
+		// 仓库配置可以关闭自动审查
+		if !repoconfig.FromContext(ctx).AutoReviewEnabled() {
+			xl.Infof("Skipping auto-review for PR #%d: disabled by %s", event.PullRequest.GetNumber(), repoconfig.Path)
+			rh.publishSkipped(ctx, client, event.PullRequest, fmt.Sprintf("Auto-review is disabled by `%s`.", repoconfig.Path))
 			return nil
 		}
 
@@ -193,6 +214,11 @@ func (rh *ReviewHandler) handlePRClosed(ctx context.Context, event *models.PullR
 	prBranch := pr.GetHead().GetRef()
 	xl.Infof("Starting cleanup after PR #%d closed, branch: %s, merged: %v", prNumber, prBranch, pr.GetMerged())
 
+	// 清理增量审查记录
+	if err := rh.reviews.Forget(pr.GetBase().GetRepo().GetFullName(), prNumber); err != nil {
+		xl.Warnf("Failed to forget review state for PR #%d: %v", prNumber, err)
+	}
+
 	// 获取所有与该PR相关的工作空间（可能有多个不同AI模型的工作空间）
 	workspaces := rh.workspace.GetAllWorkspacesByPR(pr)
 	if len(workspaces) == 0 {
@@ -227,12 +253,11 @@ func (rh *ReviewHandler) handlePRClosed(ctx context.Context, event *models.PullR
 	}
 
 	// 删除CodeAgent创建的分支
-	if prBranch != "" && strings.HasPrefix(prBranch, "codeagent") {
-		owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
-		repoName := pr.GetBase().GetRepo().GetName()
-
+	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
+	repoName := pr.GetBase().GetRepo().GetName()
+	if prefix := rh.workspace.BranchPrefix(owner, repoName); prBranch != "" && workspace.IsAgentBranch(prBranch, prefix) {
 		xl.Infof("Deleting CodeAgent branch: %s from repo %s/%s", prBranch, owner, repoName)
-		err := client.DeleteCodeAgentBranch(ctx, owner, repoName, prBranch)
+		err := client.DeleteCodeAgentBranch(ctx, owner, repoName, prBranch, prefix)
 		if err != nil {
 			xl.Errorf("Failed to delete branch %s: %v", prBranch, err)
 			// 不返回错误，继续完成其他清理工作
@@ -257,10 +282,55 @@ func (rh *ReviewHandler) processCodeReview(ctx context.Context, prEvent *models.
 		return fmt.Errorf("PR event is required for PR review")
 	}
 	pr := prEvent.PullRequest
-	// 使用配置中的默认AI模型进行自动审查
-	aiModel := rh.config.CodeProvider
+	// 使用配置中的默认AI模型进行自动审查，仓库配置优先
+	aiModel := repoconfig.FromContext(ctx).ProviderOr(rh.config.CodeProvider)
 	xl.Infof("Processing PR #%d with AI model: %s", pr.GetNumber(), aiModel)
 
+	// 推送新提交时只审查上次审查之后的变更
+	repoFullName := pr.GetBase().GetRepo().GetFullName()
+	headSHA := pr.GetHead().GetSHA()
+	var scope *review.Scope
+	if prEvent.GetEventAction() == "synchronize" {
+		if lastSHA, ok := rh.reviews.LastReviewed(repoFullName, pr.GetNumber()); ok {
+			if lastSHA == headSHA {
+				xl.Infof("Skipping auto-review for PR #%d: %s was already reviewed", pr.GetNumber(), headSHA)
+				return nil
+			}
+			scope = &review.Scope{Base: lastSHA, Head: headSHA}
+		}
+	}
+
+	// 收集代码上下文，只保留仓库配置中需要审查的文件
+	var codeCtx *ctxsys.CodeContext
+	if scope != nil {
+		codeCtx = rh.collectIncrementalContext(ctx, client, pr, scope)
+		if codeCtx == nil {
+			// 无法增量比较（例如强制推送覆盖了上次审查的提交），退回完整审查
+			scope = nil
+		}
+	}
+	if scope == nil {
+		codeCtx = rh.collectCodeContext(ctx, pr)
+	}
+	if codeCtx != nil && len(codeCtx.Files) == 0 && triggerComment == nil {
+		xl.Infof("Skipping auto-review for PR #%d: no changed files to review", pr.GetNumber())
+		if scope != nil {
+			rh.markReviewed(ctx, repoFullName, pr.GetNumber(), headSHA, "")
+		}
+		rh.publishSkipped(ctx, client, pr, "No changed files match the review paths.")
+		return nil
+	}
+	if scope != nil {
+		owner, name := pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName()
+		threads, err := client.ListReviewThreads(ctx, owner, name, pr.GetNumber())
+		if err != nil {
+			xl.Warnf("Failed to list earlier review threads: %v", err)
+		}
+		scope.Threads = threads
+		xl.Infof("Incremental review of PR #%d: %s..%s, %d files, %d earlier threads",
+			pr.GetNumber(), scope.Base, scope.Head, len(codeCtx.Files), len(threads))
+	}
+
 	// 2. 立即创建初始状态comment
 	owner := pr.GetBase().GetRepo().GetOwner().GetLogin()
 	repoName := pr.GetBase().GetRepo().GetName()
@@ -269,13 +339,12 @@ func (rh *ReviewHandler) processCodeReview(ctx context.Context, prEvent *models.
 	initialCommentBody := "🤖 CodeAgent is working… \n\nI'll analyze this and get back to you."
 
 	xl.Infof("Creating initial review status comment for PR #%d", prNumber)
-	initialComment, err := client.CreateComment(ctx, owner, repoName, prNumber, initialCommentBody)
+	commentID, err := interaction.CreateProgressComment(ctx, client, owner, repoName, prNumber, initialCommentBody)
 	if err != nil {
 		xl.Errorf("Failed to create initial status comment: %v", err)
 		return fmt.Errorf("failed to create initial status comment: %w", err)
 	}
 
-	commentID := initialComment.GetID()
 	xl.Infof("Created initial comment with ID: %d for PR #%d", commentID, prNumber)
 
 	// 3. 获取或创建工作空间
@@ -291,7 +360,7 @@ func (rh *ReviewHandler) processCodeReview(ctx context.Context, prEvent *models.
 
 	// 4. 初始化code client
 	xl.Infof("Initializing code client for review")
-	codeClient, err := rh.sessionManager.GetSession(ws)
+	codeClient, err := getSession(ctx, rh.sessionManager, ws)
 	if err != nil {
 		return fmt.Errorf("failed to get code session for review: %w", err)
 	}
@@ -299,7 +368,7 @@ func (rh *ReviewHandler) processCodeReview(ctx context.Context, prEvent *models.
 
 	// 5. 构建审查上下文和提示词
 	xl.Infof("Building review context and prompt")
-	prompt, err := rh.buildReviewPrompt(ctx, prEvent, commentID, triggerComment)
+	prompt, err := rh.buildReviewPrompt(ctx, prEvent, codeCtx, scope, commentID, triggerComment)
 	if err != nil {
 		xl.Errorf("Failed to build enhanced prompt : %v", err)
 	}
@@ -319,28 +388,218 @@ func (rh *ReviewHandler) processCodeReview(ctx context.Context, prEvent *models.
 	xl.Infof("AI code review completed, output length: %d", len(output))
 	xl.Debugf("Review Output: %s", string(output))
 
+	// 将结构化审查结果作为一个带行内评论的GitHub审查提交
+	decision, err := rh.submitReview(ctx, client, pr, headSHA, string(output), scope)
+	if err != nil {
+		// 审查没有提交成功时将原始输出回复到状态评论中，且不记录审查到的提交，下次审查重新覆盖全部变更
+		xl.Warnf("Failed to submit review for PR #%d: %v", prNumber, err)
+		rh.publishSkipped(ctx, client, pr, fmt.Sprintf("The review could not be submitted: %v", err))
+		if updateErr := client.UpdateComment(ctx, owner, repoName, commentID, review.RenderFallback(string(output), err)); updateErr != nil {
+			return fmt.Errorf("failed to submit review: %w", err)
+		}
+		xl.Infof("Posted raw review output on PR #%d as a fallback", prNumber)
+		return nil
+	}
+
+	// 记录本次审查到的提交，下次推送只审查新增的变更
+	rh.markReviewed(ctx, repoFullName, pr.GetNumber(), headSHA, decision)
+
 	xl.Infof("PR code review process completed successfully")
 	return nil
 }
 
-// buildReviewPrompt 构建代码审查提示词
-func (rh *ReviewHandler) buildReviewPrompt(ctx context.Context, prEvent *models.PullRequestContext, commentID int64, triggerComment *string) (string, error) {
+// submitReview 解析模型输出的结构化审查结果，对照PR diff校验行号后提交一个GitHub审查；
+// 无法附加到diff上的意见写入审查总结，审查结论由审查策略决定并同时发布为check run。
+// 增量审查时之前的意见没有全部解决，结论不低于上一次审查的结论
+func (rh *ReviewHandler) submitReview(ctx context.Context, client *ghclient.Client, pr *github.PullRequest, headSHA string, output string, scope *review.Scope) (review.Event, error) {
 	xl := xlog.NewWith(ctx)
 
-	if prEvent == nil {
-		return "", fmt.Errorf("PR event is required")
+	result, err := review.ParseResult(output)
+	if err != nil {
+		return "", err
 	}
 
-	// 先收集代码上下文
-	var codeCtx *ctxsys.CodeContext
-	if prEvent.PullRequest != nil {
-		var err error
-		codeCtx, err = rh.contextManager.Collector.CollectCodeContext(prEvent.PullRequest)
-		if err != nil {
-			xl.Warnf("Failed to collect code context: %v", err)
-		} else {
-			xl.Infof("Successfully collected code context with %d files", len(codeCtx.Files))
+	owner, name := pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName()
+	files, err := client.ListPullRequestFiles(ctx, owner, name, pr.GetNumber())
+	if err != nil {
+		return "", err
+	}
+	patches := make(map[string]string, len(files))
+	for _, file := range files {
+		patches[file.GetFilename()] = file.GetPatch()
+	}
+
+	comments, unanchored := review.Anchor(result.Findings, patches)
+	xl.Infof("Review of PR #%d has %d findings, %d anchored to the diff", pr.GetNumber(), len(result.Findings), len(comments))
+
+	policy := repoconfig.FromContext(ctx).ReviewPolicy(rh.policy)
+	decision := policy.Decide(result.Findings)
+	if scope != nil && !scope.AllResolved() {
+		previous, ok := rh.reviews.LastDecision(pr.GetBase().GetRepo().GetFullName(), pr.GetNumber())
+		if stricter := review.Stricter(decision, previous); ok && stricter != decision {
+			xl.Infof("Keeping earlier review decision %s for PR #%d: earlier findings are still open", previous, pr.GetNumber())
+			decision = stricter
+		}
+	}
+	xl.Infof("Review decision for PR #%d: %s", pr.GetNumber(), decision)
+
+	if headSHA == "" {
+		headSHA = pr.GetHead().GetSHA()
+	}
+	request := &github.PullRequestReviewRequest{
+		Body:  github.String(review.RenderSummary(result, unanchored)),
+		Event: github.String(string(decision)),
+	}
+	if headSHA != "" {
+		// 行号基于审查时的head提交
+		request.CommitID = github.String(headSHA)
+	}
+	for _, comment := range comments {
+		draft := &github.DraftReviewComment{
+			Path: github.String(comment.Path),
+			Body: github.String(comment.Body),
+			Line: github.Int(comment.Line),
+			Side: github.String("RIGHT"),
 		}
+		if comment.StartLine > 0 {
+			draft.StartLine = github.Int(comment.StartLine)
+			draft.StartSide = github.String("RIGHT")
+		}
+		request.Comments = append(request.Comments, draft)
+	}
+
+	_, err = client.CreateReview(ctx, owner, name, pr.GetNumber(), request)
+	if err != nil && decision != review.EventComment {
+		// GitHub 不允许批准或请求修改自己创建的PR，降级为评论，结论仍通过check run发布
+		xl.Warnf("Failed to submit %s review on PR #%d, retrying as comment: %v", decision, pr.GetNumber(), err)
+		request.Event = github.String(string(review.EventComment))
+		_, err = client.CreateReview(ctx, owner, name, pr.GetNumber(), request)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if headSHA != "" {
+		rh.publishCheckRun(ctx, client, owner, name, headSHA, decision.CheckRunConclusion(), decision.CheckRunTitle(),
+			review.RenderCheckRunSummary(result, decision, policy))
+	}
+	return decision, nil
+}
+
+// publishSkipped 没有得出审查结论时发布neutral的check run，要求该检查的分支保护不会一直等待
+func (rh *ReviewHandler) publishSkipped(ctx context.Context, client *ghclient.Client, pr *github.PullRequest, reason string) {
+	headSHA := pr.GetHead().GetSHA()
+	if client == nil || headSHA == "" {
+		return
+	}
+	owner, name := pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName()
+	rh.publishCheckRun(ctx, client, owner, name, headSHA, "neutral", "Review skipped", reason)
+}
+
+// publishCheckRun 将审查结论发布为已完成的check run，分支保护可以要求该检查通过
+func (rh *ReviewHandler) publishCheckRun(ctx context.Context, client *ghclient.Client, owner, name, headSHA, conclusion, title, summary string) {
+	_, err := client.CreateCheckRun(ctx, owner, name, github.CreateCheckRunOptions{
+		Name:       review.CheckRunName,
+		HeadSHA:    headSHA,
+		Status:     github.String("completed"),
+		Conclusion: github.String(conclusion),
+		Output: &github.CheckRunOutput{
+			Title:   github.String(title),
+			Summary: github.String(summary),
+		},
+	})
+	if err != nil {
+		xlog.NewWith(ctx).Warnf("Failed to publish review decision as check run: %v", err)
+	}
+}
+
+// markReviewed 记录PR已经审查到的提交及审查结论
+func (rh *ReviewHandler) markReviewed(ctx context.Context, repo string, number int, headSHA string, decision review.Event) {
+	if err := rh.reviews.MarkReviewed(repo, number, headSHA, decision); err != nil {
+		xlog.NewWith(ctx).Warnf("Failed to record review state for PR #%d: %v", number, err)
+	}
+}
+
+// collectCodeContext 收集PR的代码变更，按仓库配置的审查路径过滤；收集失败时返回nil
+func (rh *ReviewHandler) collectCodeContext(ctx context.Context, pr *github.PullRequest) *ctxsys.CodeContext {
+	xl := xlog.NewWith(ctx)
+
+	if pr == nil {
+		return nil
+	}
+	codeCtx, err := rh.contextManager.Collector.CollectCodeContext(pr)
+	if err != nil {
+		xl.Warnf("Failed to collect code context: %v", err)
+		return nil
+	}
+	xl.Infof("Successfully collected code context with %d files", len(codeCtx.Files))
+	return filterReviewPaths(ctx, codeCtx)
+}
+
+// collectIncrementalContext 收集上次审查之后新增提交的代码变更；上次审查的提交已不在分支历史中时返回nil
+func (rh *ReviewHandler) collectIncrementalContext(ctx context.Context, client *ghclient.Client, pr *github.PullRequest, scope *review.Scope) *ctxsys.CodeContext {
+	xl := xlog.NewWith(ctx)
+
+	owner, name := pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName()
+	comparison, err := client.CompareCommits(ctx, owner, name, scope.Base, scope.Head)
+	if err != nil {
+		xl.Warnf("Failed to compare with last reviewed commit: %v", err)
+		return nil
+	}
+	if status := comparison.GetStatus(); status != "ahead" && status != "identical" {
+		xl.Infof("Last reviewed commit %s is %s of head, falling back to full review", scope.Base, status)
+		return nil
+	}
+
+	codeCtx := &ctxsys.CodeContext{
+		Repository: pr.GetBase().GetRepo().GetFullName(),
+		BaseBranch: pr.GetBase().GetRef(),
+		HeadBranch: pr.GetHead().GetRef(),
+		Files:      []ctxsys.FileChange{},
+	}
+	for _, file := range comparison.Files {
+		codeCtx.Files = append(codeCtx.Files, ctxsys.FileChange{
+			Path:         file.GetFilename(),
+			Status:       file.GetStatus(),
+			Additions:    file.GetAdditions(),
+			Deletions:    file.GetDeletions(),
+			Changes:      file.GetChanges(),
+			PreviousPath: file.GetPreviousFilename(),
+			SHA:          file.GetSHA(),
+		})
+		codeCtx.TotalChanges.Additions += file.GetAdditions()
+		codeCtx.TotalChanges.Deletions += file.GetDeletions()
+	}
+	codeCtx.TotalChanges.Files = len(comparison.Files)
+	xl.Infof("Collected %d files changed since %s", len(codeCtx.Files), scope.Base)
+	return filterReviewPaths(ctx, codeCtx)
+}
+
+// filterReviewPaths 只保留仓库配置中需要审查的文件
+func filterReviewPaths(ctx context.Context, codeCtx *ctxsys.CodeContext) *ctxsys.CodeContext {
+	xl := xlog.NewWith(ctx)
+
+	repoCfg := repoconfig.FromContext(ctx)
+	if !repoCfg.HasReviewPathFilter() {
+		return codeCtx
+	}
+	files := codeCtx.Files[:0:0]
+	for _, file := range codeCtx.Files {
+		if repoCfg.ReviewIncludes(file.Path) {
+			files = append(files, file)
+		}
+	}
+	xl.Infof("Review paths matched %d of %d changed files", len(files), len(codeCtx.Files))
+	codeCtx.Files = files
+	return codeCtx
+}
+
+// buildReviewPrompt 构建代码审查提示词
+func (rh *ReviewHandler) buildReviewPrompt(ctx context.Context, prEvent *models.PullRequestContext, codeCtx *ctxsys.CodeContext, scope *review.Scope, commentID int64, triggerComment *string) (string, error) {
+	xl := xlog.NewWith(ctx)
+
+	if prEvent == nil {
+		return "", fmt.Errorf("PR event is required")
 	}
 
 	// 构建PR审查的上下文
@@ -372,7 +631,19 @@ func (rh *ReviewHandler) buildReviewPrompt(ctx context.Context, prEvent *models.
 - Overall architecture and design decisions
 - Documentation consistency: Verify that README.md and other documentation files are updated to reflect any code changes (especially new inputs, features, or configuration options)
 
-Be constructive and specific in your feedback. Give inline comments where applicable.`
+Be constructive and specific in your feedback. Report each issue as a finding tied to the file and lines it concerns.`
+			}
+			if repoconfig.FromContext(ctx).HasReviewPathFilter() && codeCtx != nil {
+				paths := make([]string, 0, len(codeCtx.Files))
+				for _, file := range codeCtx.Files {
+					paths = append(paths, file.Path)
+				}
+				metadata["trigger_comment"] = fmt.Sprintf("%s\n\nOnly review changes in these files (configured in %s): %s",
+					metadata["trigger_comment"], repoconfig.Path, strings.Join(paths, ", "))
+			}
+			metadata["trigger_comment"] = fmt.Sprintf("%s\n\n%s", metadata["trigger_comment"], review.FindingsFormat)
+			if instructions := scope.Instructions(); instructions != "" {
+				metadata["trigger_comment"] = fmt.Sprintf("%s\n\n%s", metadata["trigger_comment"], instructions)
 			}
 			return metadata
 		}(),
@@ -426,3 +697,50 @@ func (rh *ReviewHandler) ProcessManualCodeReview(ctx context.Context, event *mod
 	// 4. 调用统一的代码审查逻辑
 	return rh.processCodeReview(ctx, prEvent, client, &triggerComment)
 }
+
+// HasReviewed PR是否已经被CodeAgent审查过
+func (rh *ReviewHandler) HasReviewed(repo string, number int) bool {
+	_, ok := rh.reviews.LastReviewed(repo, number)
+	return ok
+}
+
+// ProcessScheduledReview 审查定时任务选出的未关闭PR，返回是否执行了审查；
+// 已经被CodeAgent审查过、草稿状态或作者被排除的PR会被跳过
+func (rh *ReviewHandler) ProcessScheduledReview(ctx context.Context, event models.GitHubContext, client *ghclient.Client, number int) (bool, error) {
+	xl := xlog.NewWith(ctx)
+
+	repo := event.GetRepository()
+	if rh.HasReviewed(repo.GetFullName(), number) {
+		return false, nil
+	}
+
+	pr, err := client.GetPullRequest(repo.GetOwner().GetLogin(), repo.GetName(), number)
+	if err != nil {
+		return false, fmt.Errorf("failed to get PR #%d: %w", number, err)
+	}
+	if pr.GetState() != "open" || pr.GetDraft() {
+		return false, nil
+	}
+	if author := pr.GetUser().GetLogin(); rh.isAccountExcluded(ctx, author) {
+		xl.Infof("Skipping scheduled review for PR #%d: author %s is excluded", number, author)
+		return false, nil
+	}
+
+	xl.Infof("Scheduled review for PR #%d", number)
+	prEvent := &models.PullRequestContext{
+		BaseContext: models.BaseContext{
+			Type:       models.EventPullRequest,
+			Repository: repo,
+			Sender:     event.GetSender(),
+			RawEvent:   pr,
+			Action:     "opened",
+			DeliveryID: event.GetDeliveryID(),
+			Timestamp:  time.Now(),
+		},
+		PullRequest: pr,
+	}
+	if err := rh.processCodeReview(ctx, prEvent, client, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
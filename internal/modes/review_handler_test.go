@@ -2,9 +2,16 @@ package modes
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/review"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestReviewHandler_isAccountExcluded(t *testing.T) {
@@ -209,3 +216,24 @@ func TestReviewHandler_isAccountExcluded_WildcardPatterns(t *testing.T) {
 		})
 	}
 }
+
+func TestReviewHandler_SkipsAlreadyReviewedHead(t *testing.T) {
+	reviews, err := review.NewStateStore(filepath.Join(t.TempDir(), "reviews.json"))
+	require.NoError(t, err)
+	require.NoError(t, reviews.MarkReviewed("org/repo", 8, "abc123", ""))
+
+	handler := &ReviewHandler{config: &config.Config{CodeProvider: "claude"}, reviews: reviews}
+	pr := &github.PullRequest{
+		Number: github.Int(8),
+		Head:   &github.PullRequestBranch{SHA: github.String("abc123")},
+		Base:   &github.PullRequestBranch{Repo: &github.Repository{FullName: github.String("org/repo")}},
+	}
+	event := &models.PullRequestContext{
+		BaseContext: models.BaseContext{Type: models.EventPullRequest, Action: "synchronize"},
+		PullRequest: pr,
+	}
+
+	assert.True(t, handler.canHandlePREvent(context.Background(), event))
+	// 已审查过的head不会再次审查，也不会访问GitHub
+	assert.NoError(t, handler.processCodeReview(context.Background(), event, nil, nil))
+}
@@ -0,0 +1,162 @@
+package modes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/workspace"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/qiniu/x/xlog"
+)
+
+// statusWorkspace /status 中展示的工作空间
+type statusWorkspace struct {
+	Workspace     *models.Workspace
+	SessionActive bool
+	Status        *workspace.Status
+	// Err 查询部分状态失败的原因
+	Err error
+}
+
+// statusData 渲染 /status 回复所需的信息
+type statusData struct {
+	Running    []TaskInfo
+	Queued     int
+	Workspaces []statusWorkspace
+	Now        time.Time
+}
+
+// handleStatus 回复当前Issue/PR的工作空间、会话、容器和任务状态
+func (h *ControlHandler) handleStatus(ctx context.Context, event *models.IssueCommentContext) error {
+	xl := xlog.NewWith(ctx)
+
+	repo := event.GetRepository()
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	number := event.Issue.GetNumber()
+
+	data := statusData{Now: time.Now()}
+	data.Running, data.Queued = h.tasks.TaskStatus(repo.GetFullName(), number)
+	for _, ws := range h.findWorkspaces(event) {
+		item := statusWorkspace{Workspace: ws, SessionActive: h.sessionManager.HasSession(ws)}
+		item.Status, item.Err = h.workspace.GetWorkspaceStatus(ws)
+		if item.Err != nil {
+			xl.Warnf("Failed to get status of workspace %s: %v", ws.Path, item.Err)
+		}
+		data.Workspaces = append(data.Workspaces, item)
+	}
+
+	client, err := h.clientManager.GetClient(ctx, &models.Repository{Owner: owner, Name: name})
+	if err != nil {
+		return fmt.Errorf("failed to get GitHub client: %w", err)
+	}
+	if _, err := client.CreateComment(ctx, owner, name, number, renderStatus(data)); err != nil {
+		return fmt.Errorf("failed to reply to status command: %w", err)
+	}
+	xl.Infof("Replied to status command on %s#%d: %d workspaces, %d running, %d queued",
+		repo.GetFullName(), number, len(data.Workspaces), len(data.Running), data.Queued)
+	return nil
+}
+
+// findWorkspaces 返回PR的所有工作空间，Issue上返回各AI模型还没有创建PR的工作空间
+func (h *ControlHandler) findWorkspaces(event *models.IssueCommentContext) []*models.Workspace {
+	var workspaces []*models.Workspace
+	if event.IsPRComment {
+		pr := &github.PullRequest{
+			Number: github.Int(event.Issue.GetNumber()),
+			Base:   &github.PullRequestBranch{Repo: event.GetRepository()},
+		}
+		workspaces = h.workspace.GetAllWorkspacesByPR(pr)
+	} else {
+		for _, model := range models.AIModels {
+			if ws := h.workspace.GetWorkspaceByIssue(event.Issue, model); ws != nil {
+				workspaces = append(workspaces, ws)
+			}
+		}
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].AIModel < workspaces[j].AIModel })
+	return workspaces
+}
+
+func renderStatus(data statusData) string {
+	var sb strings.Builder
+	sb.WriteString("## CodeAgent status\n\n")
+
+	sb.WriteString("### Tasks\n\n")
+	if len(data.Running) == 0 && data.Queued == 0 {
+		sb.WriteString("No task is running or queued.\n")
+	}
+	for _, task := range data.Running {
+		sb.WriteString(fmt.Sprintf("- Running `%s`, started %s (%s ago)\n",
+			task.Handler, task.StartedAt.UTC().Format(time.RFC3339), formatAge(data.Now.Sub(task.StartedAt))))
+	}
+	if data.Queued > 0 {
+		sb.WriteString(fmt.Sprintf("- %d queued, waiting for an execution slot\n", data.Queued))
+	}
+
+	sb.WriteString("\n### Workspaces\n\n")
+	if len(data.Workspaces) == 0 {
+		sb.WriteString("No workspace exists on this server.\n")
+		return sb.String()
+	}
+	for _, item := range data.Workspaces {
+		ws := item.Workspace
+		sb.WriteString(fmt.Sprintf("#### %s\n\n", ws.AIModel))
+		sb.WriteString(fmt.Sprintf("- Branch: `%s`\n", ws.Branch))
+		sb.WriteString(fmt.Sprintf("- Path: `%s`\n", ws.Path))
+		sb.WriteString(fmt.Sprintf("- Created: %s (%s ago)\n", ws.CreatedAt.UTC().Format(time.RFC3339), formatAge(data.Now.Sub(ws.CreatedAt))))
+		if item.SessionActive {
+			sb.WriteString("- Session: active\n")
+		} else {
+			sb.WriteString("- Session: none\n")
+		}
+		if status := item.Status; status != nil {
+			if len(status.RunningContainers) > 0 {
+				sb.WriteString(fmt.Sprintf("- Containers: `%s` running\n", strings.Join(status.RunningContainers, "`, `")))
+			} else {
+				sb.WriteString("- Containers: none running\n")
+			}
+			if commit := status.LastCommit; commit != nil {
+				sb.WriteString(fmt.Sprintf("- Last CodeAgent commit: %s %s (%s)\n", shortSHA(commit.SHA), commit.Subject, commit.Time.UTC().Format(time.RFC3339)))
+			} else {
+				sb.WriteString("- Last CodeAgent commit: none\n")
+			}
+			if ws.SessionPath != "" {
+				sb.WriteString(fmt.Sprintf("- Session directory: `%s` (%s)\n", ws.SessionPath, formatSize(status.SessionSize)))
+			}
+		}
+		if item.Err != nil {
+			sb.WriteString("- ⚠️ Part of the status could not be read; see the server logs.\n")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// formatAge 将时长格式化为便于阅读的形式，精确到秒
+func formatAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return d.Round(time.Second).String()
+}
+
+// formatSize 将字节数格式化为 B、KB、MB、GB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		value /= unit
+		if value < unit || suffix == "GB" {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", size)
+}
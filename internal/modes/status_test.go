@@ -0,0 +1,77 @@
+package modes
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/workspace"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderStatus(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	body := renderStatus(statusData{
+		Running: []TaskInfo{{Handler: "TagHandler", StartedAt: now.Add(-90 * time.Second)}},
+		Queued:  2,
+		Workspaces: []statusWorkspace{
+			{
+				Workspace: &models.Workspace{
+					AIModel:     "claude",
+					Branch:      "codeagent/claude/issue-1-1735800000",
+					Path:        "/data/org/repo__claude__pr__2__1735800000",
+					SessionPath: "/data/org/repo__claude__session__2__1735800000",
+					CreatedAt:   now.Add(-time.Hour),
+				},
+				SessionActive: true,
+				Status: &workspace.Status{
+					RunningContainers: []string{"claude__repo__2"},
+					LastCommit:        &workspace.CommitInfo{SHA: "0123456789abcdef", Subject: "Fix tests", Time: now.Add(-time.Minute)},
+					SessionSize:       3 * 1024 * 1024,
+				},
+			},
+			{
+				Workspace: &models.Workspace{AIModel: "gemini", Branch: "codeagent/gemini/issue-1-1735800000", Path: "/data/gemini", CreatedAt: now},
+				Status:    &workspace.Status{},
+				Err:       errors.New("docker not found"),
+			},
+		},
+		Now: now,
+	})
+
+	assert.Contains(t, body, "- Running `TagHandler`, started 2025-01-02T09:58:30Z (1m30s ago)")
+	assert.Contains(t, body, "- 2 queued, waiting for an execution slot")
+	assert.Contains(t, body, "#### claude\n\n- Branch: `codeagent/claude/issue-1-1735800000`")
+	assert.Contains(t, body, "- Created: 2025-01-02T09:00:00Z (1h0m0s ago)")
+	assert.Contains(t, body, "- Session: active")
+	assert.Contains(t, body, "- Containers: `claude__repo__2` running")
+	assert.Contains(t, body, "- Last CodeAgent commit: 0123456 Fix tests (2025-01-02T09:59:00Z)")
+	assert.Contains(t, body, "- Session directory: `/data/org/repo__claude__session__2__1735800000` (3.0 MB)")
+	assert.Contains(t, body, "#### gemini\n\n")
+	assert.Contains(t, body, "- Session: none")
+	assert.Contains(t, body, "- Containers: none running")
+	assert.Contains(t, body, "- Last CodeAgent commit: none")
+	assert.Contains(t, body, "Part of the status could not be read")
+}
+
+func TestRenderStatus_Empty(t *testing.T) {
+	body := renderStatus(statusData{Now: time.Now()})
+	assert.Contains(t, body, "No task is running or queued.")
+	assert.Contains(t, body, "No workspace exists on this server.")
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:                         "0 B",
+		1023:                      "1023 B",
+		1536:                      "1.5 KB",
+		5 * 1024 * 1024:           "5.0 MB",
+		3 * 1024 * 1024 * 1024:    "3.0 GB",
+		2048 * 1024 * 1024 * 1024: "2048.0 GB",
+	}
+	for size, want := range tests {
+		assert.Equal(t, want, formatSize(size))
+	}
+}
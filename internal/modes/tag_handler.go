@@ -2,6 +2,7 @@ package modes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -14,6 +15,8 @@ import (
 	ghclient "github.com/qiniu/codeagent/internal/github"
 	"github.com/qiniu/codeagent/internal/interaction"
 	"github.com/qiniu/codeagent/internal/mcp"
+	"github.com/qiniu/codeagent/internal/repoconfig"
+	"github.com/qiniu/codeagent/internal/usage"
 	"github.com/qiniu/codeagent/internal/workspace"
 	"github.com/qiniu/codeagent/pkg/models"
 
@@ -75,7 +78,7 @@ func (th *TagHandler) CanHandle(ctx context.Context, event models.GitHubContext)
 	xl := xlog.NewWith(ctx)
 
 	// Check if event contains commands using mention config
-	cmdInfo, hasCmd := models.HasCommandWithConfig(event, th.mentionConfig)
+	cmdInfo, hasCmd := models.HasCommandWithConfig(event, repoconfig.FromContext(ctx).MentionConfig(th.mentionConfig))
 	if !hasCmd {
 		xl.Debugf("No command found in event type: %s", event.GetEventType())
 		return false
@@ -118,14 +121,14 @@ func (th *TagHandler) Execute(ctx context.Context, event models.GitHubContext) e
 	}
 
 	// Extract command information using mention config
-	cmdInfo, hasCmd := models.HasCommandWithConfig(event, th.mentionConfig)
+	cmdInfo, hasCmd := models.HasCommandWithConfig(event, repoconfig.FromContext(ctx).MentionConfig(th.mentionConfig))
 	if !hasCmd {
 		return fmt.Errorf("no command found in event")
 	}
 
 	// If user didn't specify AI model, use system default configuration
 	if strings.TrimSpace(cmdInfo.AIModel) == "" {
-		cmdInfo.AIModel = th.defaultAIModel
+		cmdInfo.AIModel = repoconfig.FromContext(ctx).ProviderOr(th.defaultAIModel)
 	}
 
 	xl.Infof("Executing command: %s with AI model: %s, args: %s",
@@ -183,6 +186,8 @@ func (th *TagHandler) handleIssueComment(
 			switch cmdInfo.Command {
 			case models.CommandCode:
 				return th.processIssueCodeCommand(ctx, event, cmdInfo)
+			case models.CommandPlan:
+				return th.processIssuePlanCommand(ctx, event, cmdInfo)
 			default:
 				return fmt.Errorf("unsupported slash command for Issue comment: %s", cmdInfo.Command)
 			}
@@ -273,8 +278,13 @@ func (th *TagHandler) handlePRReviewComment(
 	}
 }
 
-// buildIssueCodePrompt 为Issue中的/code命令构建增强提示词
+// buildIssueCodePrompt 为Issue中的/code命令构建增强提示词，/code approve-plan 时注入已批准的实现计划
 func (th *TagHandler) buildIssueCodePrompt(ctx context.Context, event *models.IssueCommentContext, args string) (string, error) {
+	return th.buildIssuePrompt(ctx, event, "Code", args)
+}
+
+// buildIssuePrompt 收集Issue及其评论，按mode对应的模板构建提示词
+func (th *TagHandler) buildIssuePrompt(ctx context.Context, event *models.IssueCommentContext, mode, args string) (string, error) {
 	xl := xlog.NewWith(ctx)
 
 	// 收集Issue的完整上下文
@@ -297,6 +307,9 @@ func (th *TagHandler) buildIssueCodePrompt(ctx context.Context, event *models.Is
 			"sender":       event.Sender.GetLogin(),
 		},
 	}
+	if plan := approvedPlanFromContext(ctx); plan != "" {
+		enhancedCtx.Metadata["approved_plan"] = plan
+	}
 
 	// 收集Issue的评论上下文
 	issueNumber := issue.GetNumber()
@@ -336,7 +349,7 @@ func (th *TagHandler) buildIssueCodePrompt(ctx context.Context, event *models.Is
 	}
 
 	// 使用增强的提示词生成器
-	prompt, err := th.contextManager.Generator.GeneratePrompt(enhancedCtx, "Code", args)
+	prompt, err := th.contextManager.Generator.GeneratePrompt(enhancedCtx, mode, args)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate enhanced prompt: %w", err)
 	}
@@ -358,10 +371,45 @@ func (th *TagHandler) processIssueCodeCommand(
 	xl.Infof("Starting issue code processing: issue=#%d, title=%s, AI model=%s",
 		issueNumber, issueTitle, cmdInfo.AIModel)
 
+	// /code approve-plan 按Issue中最近一次的实现计划执行，在创建分支和PR之前确认计划存在
+	if args, ok := parseApprovePlan(cmdInfo.Args); ok {
+		plan, err := th.findApprovedPlan(ctx, event)
+		if err != nil {
+			return err
+		}
+		if plan == "" {
+			return th.replyIssue(ctx, event, fmt.Sprintf("No implementation plan was found on this issue. Run `%s` first, then reply `%s %s` to implement it.",
+				models.CommandPlan, models.CommandCode, approvePlanArg))
+		}
+		xl.Infof("Implementing approved plan of issue #%d", issueNumber)
+		ctx = withApprovedPlan(ctx, plan)
+		approved := *cmdInfo
+		approved.Args = args
+		cmdInfo = &approved
+	}
+
 	// 执行Issue代码处理流程
 	return th.executeIssueCodeProcessing(ctx, event, cmdInfo)
 }
 
+// ProcessIssueCode 对Issue执行与 /code 相同的流程，供自动化规则触发；aiModel为空时使用仓库默认provider
+func (th *TagHandler) ProcessIssueCode(ctx context.Context, event *models.IssuesContext, aiModel, args string) error {
+	if aiModel == "" {
+		aiModel = repoconfig.FromContext(ctx).ProviderOr(th.defaultAIModel)
+	}
+	issueEvent := &models.IssueCommentContext{
+		BaseContext: event.BaseContext,
+		Issue:       event.Issue,
+	}
+	cmdInfo := &models.CommandInfo{
+		Command:     models.CommandCode,
+		CommandType: models.CommandTypeSlash,
+		AIModel:     aiModel,
+		Args:        args,
+	}
+	return th.processIssueCodeCommand(ctx, issueEvent, cmdInfo)
+}
+
 // processIssueComment 处理Issue的评论
 func (th *TagHandler) processIssueComment(
 	ctx context.Context,
@@ -401,7 +449,7 @@ func (th *TagHandler) processIssueComment(
 
 	xl.Infof("Created temporary workspace for comment reply: %s", tempWS.Path)
 
-	codeClient, err := th.sessionManager.GetSession(tempWS)
+	codeClient, err := getSession(ctx, th.sessionManager, tempWS)
 	if err != nil {
 		return fmt.Errorf("failed to get code client: %w", err)
 	}
@@ -479,12 +527,16 @@ func (th *TagHandler) executeIssueCodeProcessing(
 	}
 
 	// 3. 调用AI并生成代码实现
-	codeOutput, err := th.callAIAndGenerateCode(ctx, event, cmdInfo, ws, pcm)
+	codeOutput, model, err := th.callAIAndGenerateCode(ctx, event, cmdInfo, ws, pcm)
 	if err != nil {
 		result = &models.ProgressExecutionResult{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to generate code: %v", err),
 		}
+		// 超时时在进度评论中附上超时前的部分输出
+		if timeoutErr, ok := code.AsTimeoutError(err); ok {
+			result.Output = timeoutErr.PartialOutput
+		}
 		return err
 	}
 
@@ -512,6 +564,7 @@ func (th *TagHandler) executeIssueCodeProcessing(
 		Summary:        summary,
 		BranchName:     ws.Branch,
 		PullRequestURL: pr.GetHTMLURL(),
+		Model:          model,
 		FilesChanged:   []string{}, // TODO: 从git diff中提取文件列表
 	}
 
@@ -570,6 +623,9 @@ func (th *TagHandler) createPRAndInitializeProgress(
 	}
 	xl.Infof("PR created successfully: #%d", pr.GetNumber())
 
+	// 任务之后也可以在PR中被 /cancel
+	RegisterTaskAlias(ctx, pr.GetNumber())
+
 	// 移动工作空间从Issue到PR
 	if err := th.workspace.MoveIssueToPR(ws, pr.GetNumber()); err != nil {
 		xl.Errorf("Failed to move workspace: %v", err)
@@ -659,7 +715,7 @@ func (th *TagHandler) callAIAndGenerateCode(
 	cmdInfo *models.CommandInfo,
 	ws *models.Workspace,
 	pcm *interaction.ProgressCommentManager,
-) ([]byte, error) {
+) ([]byte, string, error) {
 	xl := xlog.NewWith(ctx)
 
 	// 更新任务状态
@@ -669,9 +725,9 @@ func (th *TagHandler) callAIAndGenerateCode(
 
 	// 初始化code client
 	xl.Infof("Initializing code client")
-	codeClient, err := th.sessionManager.GetSession(ws)
+	codeClient, err := getSession(ctx, th.sessionManager, ws)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get code client: %w", err)
+		return nil, "", fmt.Errorf("failed to get code client: %w", err)
 	}
 	xl.Infof("Code client initialized successfully")
 
@@ -679,7 +735,7 @@ func (th *TagHandler) callAIAndGenerateCode(
 	codePrompt, err := th.buildAIPromptForCode(ctx, event, cmdInfo)
 	if err != nil {
 		xl.Errorf("Failed to build enhanced prompt: %v", err)
-		return nil, fmt.Errorf("failed to build enhanced prompt: %w", err)
+		return nil, "", fmt.Errorf("failed to build enhanced prompt: %w", err)
 	}
 
 	// 执行代码生成
@@ -690,12 +746,13 @@ func (th *TagHandler) callAIAndGenerateCode(
 
 	codeResp, err := th.promptWithRetry(ctx, codeClient, codePrompt, 3)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prompt for code modification: %w", err)
+		return nil, "", fmt.Errorf("failed to prompt for code modification: %w", err)
 	}
+	codeResp.OnEvent(recordActivity(ctx, pcm))
 
 	codeOutput, err := io.ReadAll(codeResp.Out)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read code modification output: %w", err)
+		return nil, "", fmt.Errorf("failed to read code modification output: %w", err)
 	}
 
 	if err := pcm.HideSpinner(ctx); err != nil {
@@ -709,7 +766,7 @@ func (th *TagHandler) callAIAndGenerateCode(
 		xl.Errorf("Failed to update task: %v", err)
 	}
 
-	return codeOutput, nil
+	return codeOutput, describeModel(codeResp, ws.AIModel), nil
 }
 
 // buildAIPromptForCode 构建AI代码生成提示词
@@ -742,7 +799,7 @@ func (th *TagHandler) commitAndPushChanges(
 	}
 
 	// 初始化code client用于提交
-	codeClient, err := th.sessionManager.GetSession(ws)
+	codeClient, err := getSession(ctx, th.sessionManager, ws)
 	if err != nil {
 		return fmt.Errorf("failed to get code client for commit: %w", err)
 	}
@@ -753,7 +810,7 @@ func (th *TagHandler) commitAndPushChanges(
 	if err != nil {
 		return fmt.Errorf("failed to get GitHub client: %w", err)
 	}
-	_, err = ghClient.CommitAndPush(ws, executionResult, codeClient)
+	_, err = ghClient.CommitAndPush(ctx, ws, executionResult, codeClient)
 	if err != nil {
 		return fmt.Errorf("failed to commit and push changes: %w", err)
 	}
@@ -832,6 +889,13 @@ func (th *TagHandler) finalizeIssueProcessing(
 		}
 	}
 
+	// 任务被 /cancel 中止时，以"已取消"状态结束进度评论；
+	// 此时ctx已被取消，需要使用不带取消信号的ctx更新评论
+	if !result.Success && errors.Is(ctx.Err(), context.Canceled) {
+		result.Cancelled = true
+		ctx = context.WithoutCancel(ctx)
+	}
+
 	// 添加工作空间和PR信息
 	if ws != nil {
 		result.BranchName = ws.Branch
@@ -840,6 +904,13 @@ func (th *TagHandler) finalizeIssueProcessing(
 		result.PullRequestURL = pr.GetHTMLURL()
 	}
 
+	// 附加本次任务的AI用量和费用
+	if task := usage.FromContext(ctx); task != nil {
+		if total := task.Usage(); total.Calls > 0 {
+			result.Usage = &total
+		}
+	}
+
 	if pcm != nil {
 		if err := pcm.FinalizeComment(ctx, result); err != nil {
 			xl.Errorf("Failed to finalize progress comment: %v", err)
@@ -847,6 +918,22 @@ func (th *TagHandler) finalizeIssueProcessing(
 	}
 }
 
+// describeModel 描述实际产生响应的provider和模型，发生故障转移时注明原本请求的provider
+func describeModel(resp *code.Response, requested string) string {
+	provider := resp.Provider
+	if provider == "" {
+		provider = requested
+	}
+	model := fmt.Sprintf("`%s`", provider)
+	if result := resp.Result(); result != nil && result.Model != "" && result.Model != provider {
+		model += fmt.Sprintf(" (`%s`)", result.Model)
+	}
+	if resp.FallbackFrom != "" {
+		model += fmt.Sprintf(", fallback from `%s`", resp.FallbackFrom)
+	}
+	return model
+}
+
 // promptWithRetry 带重试的提示执行
 func (th *TagHandler) promptWithRetry(ctx context.Context, codeClient code.Code, prompt string, maxRetries int) (*code.Response, error) {
 	return code.PromptWithRetry(ctx, codeClient, prompt, maxRetries)
@@ -971,7 +1058,7 @@ func (th *TagHandler) processPRCommand(
 
 	// 4. 从PR分支中提取AI模型（Review场景不使用config默认值）
 	branchName := pr.GetHead().GetRef()
-	cmdInfo.AIModel = th.workspace.ExtractAIModelFromBranch(branchName)
+	cmdInfo.AIModel = th.workspace.ExtractAIModelFromBranch(pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName(), branchName)
 	if cmdInfo.AIModel == "" {
 		xl.Errorf("Failed to extract AI model from branch: %s", branchName)
 		return fmt.Errorf("cannot extract AI model from branch name: %s", branchName)
@@ -997,7 +1084,7 @@ func (th *TagHandler) processPRCommand(
 
 	// 初始化code client
 	xl.Infof("Initializing code client")
-	codeClient, err := th.sessionManager.GetSession(ws)
+	codeClient, err := getSession(ctx, th.sessionManager, ws)
 	if err != nil {
 		return fmt.Errorf("failed to create code session: %w", err)
 	}
@@ -1048,7 +1135,7 @@ func (th *TagHandler) processPRCommand(
 	}
 
 	xl.Infof("Committing and pushing changes for PR %s", strings.ToLower(mode))
-	commitHash, err := ghClient.CommitAndPush(ws, executionResult, codeClient)
+	commitHash, err := ghClient.CommitAndPush(ctx, ws, executionResult, codeClient)
 	if err != nil {
 		xl.Errorf("Failed to commit and push changes: %v", err)
 		if mode == "Fix" {
@@ -1113,6 +1200,7 @@ func (th *TagHandler) processPRCommand(
 			commentBody = fmt.Sprintf("✅ 处理完成！\n\n**查看详情**: %s", pr.GetHTMLURL())
 		}
 	}
+	commentBody += fmt.Sprintf("\n\n**Model**: %s", describeModel(resp, ws.AIModel))
 
 	err = th.addPRCommentWithMCP(ctx, ws, pr, commentBody)
 	if err != nil {
@@ -1146,7 +1234,7 @@ func (th *TagHandler) processPRReviewCommand(
 
 	// 2. 从PR分支中提取AI模型（Review场景不使用config默认值）
 	branchName := pr.GetHead().GetRef()
-	cmdInfo.AIModel = th.workspace.ExtractAIModelFromBranch(branchName)
+	cmdInfo.AIModel = th.workspace.ExtractAIModelFromBranch(pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName(), branchName)
 	if cmdInfo.AIModel == "" {
 		xl.Errorf("Failed to extract AI model from branch: %s", branchName)
 		return fmt.Errorf("cannot extract AI model from branch name: %s", branchName)
@@ -1183,7 +1271,7 @@ func (th *TagHandler) processPRReviewCommand(
 	}
 
 	// 6. 初始化 code client
-	codeClient, err := th.sessionManager.GetSession(ws)
+	codeClient, err := getSession(ctx, th.sessionManager, ws)
 	if err != nil {
 		xl.Errorf("failed to get code client for PR batch processing from review: %v", err)
 		return err
@@ -1256,7 +1344,7 @@ func (th *TagHandler) processPRReviewCommand(
 	executionResult := &models.ExecutionResult{
 		Output: string(output),
 	}
-	commitHash, err := ghClient.CommitAndPush(ws, executionResult, codeClient)
+	commitHash, err := ghClient.CommitAndPush(ctx, ws, executionResult, codeClient)
 	if err != nil {
 		xl.Errorf("Failed to commit and push for PR batch processing from review: %v", err)
 		return err
@@ -1336,7 +1424,7 @@ func (th *TagHandler) processPRReviewCommentCommand(
 
 	// 2. 从PR分支中提取AI模型（Review场景不使用config默认值）
 	branchName := pr.GetHead().GetRef()
-	cmdInfo.AIModel = th.workspace.ExtractAIModelFromBranch(branchName)
+	cmdInfo.AIModel = th.workspace.ExtractAIModelFromBranch(pr.GetBase().GetRepo().GetOwner().GetLogin(), pr.GetBase().GetRepo().GetName(), branchName)
 	if cmdInfo.AIModel == "" {
 		xl.Errorf("Failed to extract AI model from branch: %s", branchName)
 		return fmt.Errorf("cannot extract AI model from branch name: %s", branchName)
@@ -1364,7 +1452,7 @@ func (th *TagHandler) processPRReviewCommentCommand(
 	}
 
 	// 5. 初始化 code client
-	codeClient, err := th.sessionManager.GetSession(ws)
+	codeClient, err := getSession(ctx, th.sessionManager, ws)
 	if err != nil {
 		xl.Errorf("failed to get code client for PR %s from review comment: %v", strings.ToLower(mode), err)
 		return err
@@ -1425,7 +1513,7 @@ func (th *TagHandler) processPRReviewCommentCommand(
 	executionResult := &models.ExecutionResult{
 		Output: string(output),
 	}
-	commitHash, err := ghClient.CommitAndPush(ws, executionResult, codeClient)
+	commitHash, err := ghClient.CommitAndPush(ctx, ws, executionResult, codeClient)
 	if err != nil {
 		xl.Errorf("Failed to commit and push for PR %s from review comment: %v", strings.ToLower(mode), err)
 		return err
@@ -2022,7 +2110,7 @@ func (th *TagHandler) processPRComment(
 
 	// 如果用户指定了AI模型，使用指定的；否则使用系统默认的
 	if strings.TrimSpace(cmdInfo.AIModel) == "" {
-		cmdInfo.AIModel = th.defaultAIModel
+		cmdInfo.AIModel = repoconfig.FromContext(ctx).ProviderOr(th.defaultAIModel)
 	}
 
 	// 获取完整的PR信息
@@ -2059,7 +2147,7 @@ func (th *TagHandler) processPRComment(
 
 	xl.Infof("Created temporary workspace for PR comment reply: %s", tempWS.Path)
 
-	codeClient, err := th.sessionManager.GetSession(tempWS)
+	codeClient, err := getSession(ctx, th.sessionManager, tempWS)
 	if err != nil {
 		return fmt.Errorf("failed to get code client: %w", err)
 	}
@@ -2115,7 +2203,7 @@ func (th *TagHandler) processPRCodeReviewComment(
 
 	// 如果用户指定了AI模型，使用指定的；否则使用系统默认的
 	if strings.TrimSpace(cmdInfo.AIModel) == "" {
-		cmdInfo.AIModel = th.defaultAIModel
+		cmdInfo.AIModel = repoconfig.FromContext(ctx).ProviderOr(th.defaultAIModel)
 	}
 
 	// 获取完整的PR信息
@@ -2152,7 +2240,7 @@ func (th *TagHandler) processPRCodeReviewComment(
 
 	xl.Infof("Created temporary workspace for PR review comment reply: %s", tempWS.Path)
 
-	codeClient, err := th.sessionManager.GetSession(tempWS)
+	codeClient, err := getSession(ctx, th.sessionManager, tempWS)
 	if err != nil {
 		return fmt.Errorf("failed to get code client: %w", err)
 	}
@@ -2208,7 +2296,7 @@ func (th *TagHandler) processReviewCommand(
 
 	// 如果用户指定了AI模型，使用指定的；否则使用系统默认的
 	if strings.TrimSpace(cmdInfo.AIModel) == "" {
-		cmdInfo.AIModel = th.defaultAIModel
+		cmdInfo.AIModel = repoconfig.FromContext(ctx).ProviderOr(th.defaultAIModel)
 	}
 
 	xl.Infof("Executing /review command with AI model: %s, args: %s", cmdInfo.AIModel, cmdInfo.Args)
@@ -2232,23 +2320,22 @@ func (th *TagHandler) createIssueComment(ctx context.Context, event *models.Issu
 		return 0, fmt.Errorf("failed to get GitHub client: %w", err)
 	}
 
-	// Create comment using GitHub API
-	comment, _, err := client.GetClient().Issues.CreateComment(
+	// Create comment using GitHub API, reusing the comment that showed the queue position
+	commentID, err := interaction.CreateProgressComment(
 		ctx,
+		client,
 		repoInfo.Owner,
 		repoInfo.Name,
 		event.Issue.GetNumber(),
-		&github.IssueComment{
-			Body: github.String("🤖 CodeAgent is working… \n\nI'll analyze this and get back to you."),
-		},
+		"🤖 CodeAgent is working… \n\nI'll analyze this and get back to you.",
 	)
 	if err != nil {
 		xl.Errorf("Failed to create issue comment: %v", err)
 		return 0, fmt.Errorf("failed to create issue comment: %w", err)
 	}
 
-	xl.Infof("Successfully created issue comment with ID: %d", comment.GetID())
-	return comment.GetID(), nil
+	xl.Infof("Successfully created issue comment with ID: %d", commentID)
+	return commentID, nil
 }
 
 // createPRComment creates a regular comment on a PR conversation
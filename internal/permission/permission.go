@@ -0,0 +1,209 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+)
+
+// defaultCacheTTL 用户角色查询结果的默认缓存时长
+const defaultCacheTTL = 5 * time.Minute
+
+// Role 用户在仓库上的角色，按权限从低到高排列
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleRead
+	RoleTriage
+	RoleWrite
+	RoleMaintain
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleNone:     "none",
+	RoleRead:     "read",
+	RoleTriage:   "triage",
+	RoleWrite:    "write",
+	RoleMaintain: "maintain",
+	RoleAdmin:    "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// ParseRole 解析角色名，兼容 GitHub 旧的 pull/push 权限名
+func ParseRole(name string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "none":
+		return RoleNone, nil
+	case "read", "pull":
+		return RoleRead, nil
+	case "triage":
+		return RoleTriage, nil
+	case "write", "push":
+		return RoleWrite, nil
+	case "maintain":
+		return RoleMaintain, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", name)
+}
+
+// defaultCommandRoles 内置命令所需的最低角色，未列出的命令使用 default_role
+var defaultCommandRoles = map[string]Role{
+	models.CommandCode:     RoleWrite,
+	models.CommandContinue: RoleWrite,
+	models.CommandReview:   RoleTriage,
+	models.CommandCancel:   RoleWrite,
+	models.CommandFixCI:    RoleWrite,
+	models.CommandHelp:     RoleRead,
+	models.CommandStatus:   RoleWrite,
+	models.CommandPlan:     RoleWrite,
+}
+
+// Resolver 查询用户在仓库上的角色名
+type Resolver func(ctx context.Context, owner, repo, user string) (string, error)
+
+// DeniedError 用户角色低于命令要求
+type DeniedError struct {
+	User     string
+	Command  string
+	Role     Role
+	Required Role
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("user %s with role %s is not allowed to run %s (requires %s)", e.User, e.Role, e.Command, e.Required)
+}
+
+type cacheEntry struct {
+	role    Role
+	expires time.Time
+}
+
+// Policy 命令执行权限策略：每个命令声明所需的最低角色，用户角色通过 Resolver 查询并缓存
+type Policy struct {
+	resolver    Resolver
+	defaultRole Role
+	commands    map[string]Role
+	ttl         time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewPolicy 根据配置创建权限策略，配置中的角色名无效时返回错误
+func NewPolicy(cfg config.PermissionsConfig, resolver Resolver) (*Policy, error) {
+	p := &Policy{
+		resolver:    resolver,
+		defaultRole: RoleWrite,
+		commands:    make(map[string]Role, len(defaultCommandRoles)+len(cfg.Commands)),
+		ttl:         cfg.CacheTTL,
+		now:         time.Now,
+		cache:       make(map[string]cacheEntry),
+	}
+	if p.ttl <= 0 {
+		p.ttl = defaultCacheTTL
+	}
+	if cfg.DefaultRole != "" {
+		role, err := ParseRole(cfg.DefaultRole)
+		if err != nil {
+			return nil, fmt.Errorf("invalid permissions.default_role: %w", err)
+		}
+		p.defaultRole = role
+	}
+	for command, role := range defaultCommandRoles {
+		p.commands[command] = role
+	}
+	for command, name := range cfg.Commands {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid permission for command %s: %w", command, err)
+		}
+		p.commands[normalizeCommand(command)] = role
+	}
+	return p, nil
+}
+
+// RequiredRole 返回命令所需的最低角色
+func (p *Policy) RequiredRole(command string) Role {
+	if role, ok := p.commands[normalizeCommand(command)]; ok {
+		return role
+	}
+	return p.defaultRole
+}
+
+// Role 查询用户在仓库上的角色，结果在 cache_ttl 内复用
+func (p *Policy) Role(ctx context.Context, owner, repo, user string) (Role, error) {
+	key := strings.ToLower(owner + "/" + repo + "/" + user)
+	now := p.now()
+
+	p.mu.Lock()
+	entry, ok := p.cache[key]
+	p.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.role, nil
+	}
+
+	name, err := p.resolver(ctx, owner, repo, user)
+	if err != nil {
+		return RoleNone, fmt.Errorf("failed to resolve permission of %s on %s/%s: %w", user, owner, repo, err)
+	}
+	role, err := ParseRole(name)
+	if err != nil {
+		// 未知的自定义角色按最低权限处理
+		role = RoleNone
+	}
+
+	p.mu.Lock()
+	p.cache[key] = cacheEntry{role: role, expires: now.Add(p.ttl)}
+	p.mu.Unlock()
+	return role, nil
+}
+
+// Check 检查用户是否可以执行命令，返回用户角色；角色不足时返回 *DeniedError
+func (p *Policy) Check(ctx context.Context, owner, repo, user, command string) (Role, error) {
+	role, err := p.Role(ctx, owner, repo, user)
+	if err != nil {
+		return RoleNone, err
+	}
+	if required := p.RequiredRole(command); role < required {
+		return role, &DeniedError{User: user, Command: command, Role: role, Required: required}
+	}
+	return role, nil
+}
+
+// normalizeCommand 统一命令名的大小写，配置中允许省略斜杠
+func normalizeCommand(command string) string {
+	command = strings.ToLower(strings.TrimSpace(command))
+	if command != "" && !strings.HasPrefix(command, "/") && !strings.HasPrefix(command, "@") {
+		command = "/" + command
+	}
+	return command
+}
+
+type roleKey struct{}
+
+// NewContext 返回携带触发用户角色的ctx，供处理器检查命令自身声明的权限
+func NewContext(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// FromContext 返回ctx中的用户角色，没有经过权限检查时返回false
+func FromContext(ctx context.Context) (Role, bool) {
+	role, ok := ctx.Value(roleKey{}).(Role)
+	return role, ok
+}
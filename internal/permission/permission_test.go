@@ -0,0 +1,100 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRole(t *testing.T) {
+	for name, want := range map[string]Role{
+		"none": RoleNone, "read": RoleRead, "pull": RoleRead, "Triage": RoleTriage,
+		"write": RoleWrite, "push": RoleWrite, "maintain": RoleMaintain, "admin": RoleAdmin,
+	} {
+		role, err := ParseRole(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, role, name)
+	}
+	_, err := ParseRole("owner")
+	assert.Error(t, err)
+	assert.Equal(t, "maintain", RoleMaintain.String())
+}
+
+func TestPolicy_RequiredRole(t *testing.T) {
+	policy, err := NewPolicy(config.PermissionsConfig{
+		DefaultRole: "maintain",
+		Commands:    map[string]string{"review": "read", "/Deploy": "admin"},
+	}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, RoleWrite, policy.RequiredRole("/code"))
+	assert.Equal(t, RoleRead, policy.RequiredRole("/review"))
+	assert.Equal(t, RoleAdmin, policy.RequiredRole("/deploy"))
+	assert.Equal(t, RoleMaintain, policy.RequiredRole("/unknown"))
+	assert.Equal(t, RoleMaintain, policy.RequiredRole("@qiniu-ci"))
+
+	_, err = NewPolicy(config.PermissionsConfig{DefaultRole: "owner"}, nil)
+	assert.Error(t, err)
+	_, err = NewPolicy(config.PermissionsConfig{Commands: map[string]string{"/code": "everyone"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestPolicy_CheckCachesRoles(t *testing.T) {
+	calls := 0
+	roles := map[string]string{"alice": "admin", "bob": "triage", "mallory": "custom-role"}
+	policy, err := NewPolicy(config.PermissionsConfig{CacheTTL: time.Minute}, func(ctx context.Context, owner, repo, user string) (string, error) {
+		calls++
+		if user == "broken" {
+			return "", errors.New("api unavailable")
+		}
+		return roles[user], nil
+	})
+	require.NoError(t, err)
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	policy.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	role, err := policy.Check(ctx, "org", "repo", "alice", "/code")
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, role)
+
+	_, err = policy.Check(ctx, "org", "repo", "bob", "/review")
+	require.NoError(t, err)
+	_, err = policy.Check(ctx, "org", "repo", "bob", "/code")
+	var denied *DeniedError
+	require.True(t, errors.As(err, &denied))
+	assert.Equal(t, RoleTriage, denied.Role)
+	assert.Equal(t, RoleWrite, denied.Required)
+	assert.Equal(t, 2, calls, "bob's role should be cached")
+
+	// 未知角色按最低权限处理
+	_, err = policy.Check(ctx, "org", "repo", "mallory", "/review")
+	require.True(t, errors.As(err, &denied))
+	assert.Equal(t, RoleNone, denied.Role)
+
+	// 查询失败不缓存，也不当作拒绝
+	_, err = policy.Check(ctx, "org", "repo", "broken", "/code")
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &denied))
+
+	// 缓存过期后重新查询
+	roles["bob"] = "write"
+	now = now.Add(2 * time.Minute)
+	_, err = policy.Check(ctx, "org", "repo", "bob", "/code")
+	assert.NoError(t, err)
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	role, ok := FromContext(NewContext(context.Background(), RoleTriage))
+	assert.True(t, ok)
+	assert.Equal(t, RoleTriage, role)
+}
@@ -0,0 +1,87 @@
+package persist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Map 保存在单个JSON文件中的map，每次修改后原子地写回文件。
+// Map 不加锁，调用方需要自行保证并发安全
+type Map[V any] struct {
+	path string
+	// name 错误信息中使用的状态名称
+	name  string
+	items map[string]V
+}
+
+// Open 打开（或创建）path处的map，name用于错误信息
+func Open[V any](path, name string) (*Map[V], error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s directory: %w", name, err)
+	}
+
+	m := &Map[V]{path: path, name: name, items: make(map[string]V)}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &m.items); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+	}
+	return m, nil
+}
+
+// Get 返回key对应的值
+func (m *Map[V]) Get(key string) (V, bool) {
+	value, ok := m.items[key]
+	return value, ok
+}
+
+// Set 设置key对应的值并写回文件
+func (m *Map[V]) Set(key string, value V) error {
+	m.items[key] = value
+	return m.Save()
+}
+
+// Delete 删除key并写回文件，key不存在时不写文件
+func (m *Map[V]) Delete(key string) error {
+	if _, ok := m.items[key]; !ok {
+		return nil
+	}
+	delete(m.items, key)
+	return m.Save()
+}
+
+// DeleteFunc 删除所有满足del的项，不写回文件
+func (m *Map[V]) DeleteFunc(del func(key string, value V) bool) {
+	for key, value := range m.items {
+		if del(key, value) {
+			delete(m.items, key)
+		}
+	}
+}
+
+// Save 先写临时文件再重命名，原子地写回文件
+func (m *Map[V]) Save() error {
+	data, err := json.Marshal(m.items)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", m.name, err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", m.name, err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to commit %s: %w", m.name, err)
+	}
+	return nil
+}
+
+// IssueKey 以 owner/repo#number 作为Issue或PR的key
+func IssueKey(repo string, number int) string {
+	return fmt.Sprintf("%s#%d", repo, number)
+}
@@ -0,0 +1,42 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMap_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "counts.json")
+	m, err := Open[int](path, "test state")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Set(IssueKey("org/repo", 7), 1))
+	require.NoError(t, m.Set(IssueKey("org/repo", 8), 2))
+	require.NoError(t, m.Delete(IssueKey("org/repo", 8)))
+	require.NoError(t, m.Delete("missing"))
+	m.DeleteFunc(func(key string, value int) bool { return value > 1 })
+
+	reopened, err := Open[int](path, "test state")
+	require.NoError(t, err)
+	value, ok := reopened.Get("org/repo#7")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	_, ok = reopened.Get("org/repo#8")
+	assert.False(t, ok)
+
+	// 写入完成后不残留临时文件
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMap_CorruptedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+
+	_, err := Open[int](path, "test state")
+	assert.ErrorContains(t, err, "failed to parse test state")
+}
@@ -0,0 +1,82 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/persist"
+)
+
+const defaultDeliveryTTL = 72 * time.Hour
+
+// DeliveryStore remembers processed X-GitHub-Delivery IDs for a limited time so
+// that redelivered webhooks are not processed twice. Entries are persisted to a
+// single JSON file and expire after the configured TTL.
+type DeliveryStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries *persist.Map[time.Time]
+	now     func() time.Time
+}
+
+// NewDeliveryStore opens (or creates) a delivery store backed by the file at path
+func NewDeliveryStore(path string, ttl time.Duration) (*DeliveryStore, error) {
+	if ttl <= 0 {
+		ttl = defaultDeliveryTTL
+	}
+	entries, err := persist.Open[time.Time](path, "delivery store")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DeliveryStore{
+		ttl:     ttl,
+		entries: entries,
+		now:     time.Now,
+	}
+	s.pruneLocked()
+	return s, nil
+}
+
+// MarkIfNew records deliveryID as seen and reports whether it was unseen (or expired).
+// When force is true the delivery is always (re-)recorded and reported as new.
+func (s *DeliveryStore) MarkIfNew(deliveryID string, force bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if seenAt, ok := s.entries.Get(deliveryID); ok && !force && now.Sub(seenAt) < s.ttl {
+		return false, nil
+	}
+
+	s.pruneLocked()
+	if err := s.entries.Set(deliveryID, now); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// Seen reports whether deliveryID was processed within the TTL
+func (s *DeliveryStore) Seen(deliveryID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seenAt, ok := s.entries.Get(deliveryID)
+	return ok && s.now().Sub(seenAt) < s.ttl
+}
+
+// Forget removes deliveryID so that the next delivery with the same ID is processed again
+func (s *DeliveryStore) Forget(deliveryID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entries.Delete(deliveryID)
+}
+
+// pruneLocked drops expired entries; callers must hold s.mu
+func (s *DeliveryStore) pruneLocked() {
+	cutoff := s.now().Add(-s.ttl)
+	s.entries.DeleteFunc(func(_ string, seenAt time.Time) bool {
+		return seenAt.Before(cutoff)
+	})
+}
@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryStore_MarkIfNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deliveries.json")
+	s, err := NewDeliveryStore(path, time.Hour)
+	require.NoError(t, err)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	isNew, err := s.MarkIfNew("delivery-1", false)
+	require.NoError(t, err)
+	assert.True(t, isNew)
+
+	isNew, err = s.MarkIfNew("delivery-1", false)
+	require.NoError(t, err)
+	assert.False(t, isNew, "redelivery within TTL must be rejected")
+
+	isNew, err = s.MarkIfNew("delivery-1", true)
+	require.NoError(t, err)
+	assert.True(t, isNew, "force must bypass deduplication")
+
+	now = now.Add(2 * time.Hour)
+	isNew, err = s.MarkIfNew("delivery-1", false)
+	require.NoError(t, err)
+	assert.True(t, isNew, "expired entries must be processed again")
+}
+
+func TestDeliveryStore_PersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deliveries.json")
+	s, err := NewDeliveryStore(path, time.Hour)
+	require.NoError(t, err)
+
+	_, err = s.MarkIfNew("delivery-1", false)
+	require.NoError(t, err)
+
+	reopened, err := NewDeliveryStore(path, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reopened.Seen("delivery-1"))
+
+	require.NoError(t, reopened.Forget("delivery-1"))
+	assert.False(t, reopened.Seen("delivery-1"))
+}
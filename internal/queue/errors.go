@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"errors"
+	"time"
+)
+
+// Predefined error types for queue operations
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrNoJobReady   = errors.New("no job ready")
+	ErrInvalidJob   = errors.New("invalid job")
+	ErrCorruptedJob = errors.New("corrupted job file")
+)
+
+// permanentError marks an error that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps err so that the queue fails the job without further retries
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// deferredError marks a job that cannot run yet and should be retried later
+// without using up one of its attempts
+type deferredError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *deferredError) Error() string {
+	return e.err.Error()
+}
+
+func (e *deferredError) Unwrap() error {
+	return e.err
+}
+
+// Defer wraps err so that the queue re-queues the job after delay without counting the attempt
+func Defer(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &deferredError{err: err, delay: delay}
+}
+
+// DeferDelay reports whether err was marked with Defer and returns the requested delay
+func DeferDelay(err error) (time.Duration, bool) {
+	var de *deferredError
+	if !errors.As(err, &de) {
+		return 0, false
+	}
+	return de.delay, true
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// JobState represents the lifecycle state of a queued webhook job
+type JobState string
+
+const (
+	JobStateQueued    JobState = "queued"    // waiting to be picked up by a worker
+	JobStateRunning   JobState = "running"   // claimed by a worker
+	JobStateSucceeded JobState = "succeeded" // processed successfully
+	JobStateFailed    JobState = "failed"    // permanently failed (retries exhausted or not retryable)
+)
+
+// Job is a single webhook delivery persisted on disk until it has been processed
+type Job struct {
+	ID          string    `json:"id"`
+	EventType   string    `json:"event_type"`
+	DeliveryID  string    `json:"delivery_id"`
+	Payload     []byte    `json:"payload"`
+	Force       bool      `json:"force,omitempty"` // bypass delivery deduplication
+	State       JobState  `json:"state"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	Deferrals   int       `json:"deferrals,omitempty"` // times the job was put back without counting an attempt
+	LastError   string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	NextRunAt   time.Time `json:"next_run_at"`
+}
+
+// IsTerminal reports whether the job will not be processed again
+func (j *Job) IsTerminal() bool {
+	return j.State == JobStateSucceeded || j.State == JobStateFailed
+}
+
+// clone returns a copy that callers can use without holding the store lock
+func (j *Job) clone() *Job {
+	c := *j
+	return &c
+}
+
+type jobKey struct{}
+
+// NewContext returns a copy of ctx carrying the job being processed
+func NewContext(ctx context.Context, job *Job) context.Context {
+	return context.WithValue(ctx, jobKey{}, job)
+}
+
+// FromContext returns the job being processed, nil when ctx does not come from a queue worker
+func FromContext(ctx context.Context) *Job {
+	job, _ := ctx.Value(jobKey{}).(*Job)
+	return job
+}
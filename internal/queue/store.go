@@ -0,0 +1,376 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/log"
+)
+
+const (
+	defaultMaxAttempts  = 3
+	defaultRetryBackoff = 30 * time.Second
+	defaultMaxBackoff   = 10 * time.Minute
+	defaultRetention    = 7 * 24 * time.Hour
+)
+
+// Options controls retry and retention behaviour of a FileStore
+type Options struct {
+	// MaxAttempts is the number of times a job is tried before it is marked failed
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry, doubled on every further attempt
+	RetryBackoff time.Duration
+	// MaxBackoff caps the exponential retry delay
+	MaxBackoff time.Duration
+	// Retention is how long finished jobs are kept on disk before Prune removes them
+	Retention time.Duration
+}
+
+// FileStore is a durable job queue that keeps one JSON file per job in a directory.
+// Jobs survive restarts: anything that was running when the process stopped is
+// re-queued when the store is opened again.
+type FileStore struct {
+	dir    string
+	opts   Options
+	mu     sync.Mutex
+	jobs   map[string]*Job
+	notify chan struct{}
+	seq    uint64
+	now    func() time.Time
+}
+
+// NewFileStore opens (or creates) a queue rooted at dir and loads persisted jobs
+func NewFileStore(dir string, opts Options) (*FileStore, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = defaultRetryBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	s := &FileStore{
+		dir:    dir,
+		opts:   opts,
+		jobs:   make(map[string]*Job),
+		notify: make(chan struct{}, 1),
+		now:    time.Now,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads all persisted jobs and re-queues the ones interrupted while running
+func (s *FileStore) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read queue directory: %w", err)
+	}
+
+	recovered := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read job file %s: %w", path, err)
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" {
+			log.Warnf("Skipping %s: %v", path, ErrCorruptedJob)
+			continue
+		}
+		if job.State == JobStateRunning {
+			job.State = JobStateQueued
+			job.UpdatedAt = s.now()
+			if err := s.persist(&job); err != nil {
+				return err
+			}
+			recovered++
+		}
+		s.jobs[job.ID] = &job
+	}
+
+	if recovered > 0 {
+		log.Infof("Re-queued %d interrupted jobs from %s", recovered, s.dir)
+	}
+	return nil
+}
+
+// Enqueue persists a new job and wakes up a waiting worker.
+// force marks the job to be processed even if its delivery was already seen.
+func (s *FileStore) Enqueue(eventType, deliveryID string, payload []byte, force bool) (*Job, error) {
+	if eventType == "" {
+		return nil, fmt.Errorf("%w: missing event type", ErrInvalidJob)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.seq++
+	job := &Job{
+		ID:          newJobID(now, s.seq),
+		EventType:   eventType,
+		DeliveryID:  deliveryID,
+		Payload:     payload,
+		Force:       force,
+		State:       JobStateQueued,
+		MaxAttempts: s.opts.MaxAttempts,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		NextRunAt:   now,
+	}
+
+	if err := s.persist(job); err != nil {
+		return nil, err
+	}
+	s.jobs[job.ID] = job
+
+	s.signal()
+	return job.clone(), nil
+}
+
+// Claim marks the oldest runnable job as running and returns it.
+// ErrNoJobReady is returned when nothing is due yet.
+func (s *FileStore) Claim() (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var next *Job
+	for _, job := range s.jobs {
+		if job.State != JobStateQueued || job.NextRunAt.After(now) {
+			continue
+		}
+		if next == nil || job.NextRunAt.Before(next.NextRunAt) ||
+			(job.NextRunAt.Equal(next.NextRunAt) && job.ID < next.ID) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil, ErrNoJobReady
+	}
+
+	updated := next.clone()
+	updated.State = JobStateRunning
+	updated.Attempts++
+	updated.UpdatedAt = now
+	if err := s.persist(updated); err != nil {
+		return nil, err
+	}
+	s.jobs[updated.ID] = updated
+	return updated.clone(), nil
+}
+
+// Complete marks a running job as succeeded
+func (s *FileStore) Complete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	updated := job.clone()
+	updated.State = JobStateSucceeded
+	updated.LastError = ""
+	updated.UpdatedAt = s.now()
+	if err := s.persist(updated); err != nil {
+		return err
+	}
+	s.jobs[id] = updated
+	return nil
+}
+
+// Fail records a processing error. The job is re-queued with exponential backoff
+// unless its attempts are exhausted or the error was marked Permanent.
+// Errors marked with Defer re-queue the job after the requested delay and give the attempt back.
+func (s *FileStore) Fail(id string, cause error) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	now := s.now()
+	updated := job.clone()
+	updated.UpdatedAt = now
+	if cause != nil {
+		updated.LastError = cause.Error()
+	}
+	if delay, ok := DeferDelay(cause); ok {
+		updated.State = JobStateQueued
+		updated.Attempts--
+		updated.Deferrals++
+		updated.NextRunAt = now.Add(delay)
+	} else if IsPermanent(cause) || updated.Attempts >= updated.MaxAttempts {
+		updated.State = JobStateFailed
+	} else {
+		updated.State = JobStateQueued
+		updated.NextRunAt = now.Add(s.backoff(updated.Attempts))
+	}
+	if err := s.persist(updated); err != nil {
+		return nil, err
+	}
+	s.jobs[id] = updated
+	return updated.clone(), nil
+}
+
+// Wake makes a queued job that is waiting for its retry time runnable immediately
+func (s *FileStore) Wake(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	now := s.now()
+	if job.State != JobStateQueued || !job.NextRunAt.After(now) {
+		return nil
+	}
+	updated := job.clone()
+	updated.NextRunAt = now
+	updated.UpdatedAt = now
+	if err := s.persist(updated); err != nil {
+		return err
+	}
+	s.jobs[id] = updated
+	s.signal()
+	return nil
+}
+
+// Get returns a copy of the job with the given ID
+func (s *FileStore) Get(id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job.clone(), nil
+}
+
+// List returns copies of all known jobs ordered by creation
+func (s *FileStore) List() []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job.clone())
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// Stats returns the number of jobs per state
+func (s *FileStore) Stats() map[JobState]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make(map[JobState]int)
+	for _, job := range s.jobs {
+		stats[job.State]++
+	}
+	return stats
+}
+
+// Prune removes finished jobs older than the retention period and returns how many were removed
+func (s *FileStore) Prune() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-s.opts.Retention)
+	removed := 0
+	for id, job := range s.jobs {
+		if !job.IsTerminal() || job.UpdatedAt.After(cutoff) {
+			continue
+		}
+		if err := os.Remove(s.jobPath(id)); err != nil && !os.IsNotExist(err) {
+			log.Warnf("Failed to remove job file for %s: %v", id, err)
+			continue
+		}
+		delete(s.jobs, id)
+		removed++
+	}
+	return removed
+}
+
+// Ready returns a channel that receives a value whenever a job is enqueued
+func (s *FileStore) Ready() <-chan struct{} {
+	return s.notify
+}
+
+func (s *FileStore) signal() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *FileStore) backoff(attempts int) time.Duration {
+	d := s.opts.RetryBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= s.opts.MaxBackoff {
+			return s.opts.MaxBackoff
+		}
+	}
+	return d
+}
+
+// persist atomically writes the job to disk; callers must hold s.mu
+func (s *FileStore) persist(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job %s: %w", job.ID, err)
+	}
+	path := s.jobPath(job.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write job %s: %w", job.ID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to commit job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+func (s *FileStore) jobPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// newJobID returns a sortable, unique job ID
+func newJobID(now time.Time, seq uint64) string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%020d-%08d", now.UnixNano(), seq)
+	}
+	return fmt.Sprintf("%020d-%08d-%s", now.UnixNano(), seq, hex.EncodeToString(b))
+}
@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T, dir string) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(dir, Options{MaxAttempts: 2, RetryBackoff: time.Minute})
+	require.NoError(t, err)
+	return s
+}
+
+func TestFileStore_EnqueueClaimComplete(t *testing.T) {
+	s := newTestStore(t, t.TempDir())
+
+	first, err := s.Enqueue("issue_comment", "delivery-1", []byte(`{"a":1}`), false)
+	require.NoError(t, err)
+	_, err = s.Enqueue("pull_request", "delivery-2", []byte(`{"b":2}`), false)
+	require.NoError(t, err)
+
+	job, err := s.Claim()
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, job.ID)
+	assert.Equal(t, JobStateRunning, job.State)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, []byte(`{"a":1}`), job.Payload)
+
+	require.NoError(t, s.Complete(job.ID))
+	got, err := s.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStateSucceeded, got.State)
+
+	stats := s.Stats()
+	assert.Equal(t, 1, stats[JobStateSucceeded])
+	assert.Equal(t, 1, stats[JobStateQueued])
+}
+
+func TestFileStore_RetryWithBackoff(t *testing.T) {
+	s := newTestStore(t, t.TempDir())
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	_, err := s.Enqueue("issue_comment", "delivery-1", nil, false)
+	require.NoError(t, err)
+
+	job, err := s.Claim()
+	require.NoError(t, err)
+
+	failed, err := s.Fail(job.ID, errors.New("boom"))
+	require.NoError(t, err)
+	assert.Equal(t, JobStateQueued, failed.State)
+	assert.Equal(t, "boom", failed.LastError)
+	assert.Equal(t, now.Add(time.Minute), failed.NextRunAt)
+
+	// Not due yet
+	_, err = s.Claim()
+	assert.ErrorIs(t, err, ErrNoJobReady)
+
+	now = now.Add(2 * time.Minute)
+	job, err = s.Claim()
+	require.NoError(t, err)
+	assert.Equal(t, 2, job.Attempts)
+
+	// Attempts exhausted
+	failed, err = s.Fail(job.ID, errors.New("boom again"))
+	require.NoError(t, err)
+	assert.Equal(t, JobStateFailed, failed.State)
+}
+
+func TestFileStore_PermanentFailure(t *testing.T) {
+	s := newTestStore(t, t.TempDir())
+
+	_, err := s.Enqueue("issue_comment", "delivery-1", nil, false)
+	require.NoError(t, err)
+	job, err := s.Claim()
+	require.NoError(t, err)
+
+	failed, err := s.Fail(job.ID, Permanent(errors.New("unsupported")))
+	require.NoError(t, err)
+	assert.Equal(t, JobStateFailed, failed.State)
+}
+
+func TestFileStore_DeferAndWake(t *testing.T) {
+	s := newTestStore(t, t.TempDir())
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	_, err := s.Enqueue("issue_comment", "delivery-1", nil, false)
+	require.NoError(t, err)
+	job, err := s.Claim()
+	require.NoError(t, err)
+
+	// Deferring does not use up an attempt
+	deferred, err := s.Fail(job.ID, Defer(errors.New("no slot"), time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, JobStateQueued, deferred.State)
+	assert.Equal(t, 0, deferred.Attempts)
+	assert.Equal(t, 1, deferred.Deferrals)
+	assert.Equal(t, now.Add(time.Minute), deferred.NextRunAt)
+
+	_, err = s.Claim()
+	assert.ErrorIs(t, err, ErrNoJobReady)
+
+	require.NoError(t, s.Wake(job.ID))
+	job, err = s.Claim()
+	require.NoError(t, err)
+	assert.Equal(t, 1, job.Attempts)
+
+	// Running jobs are left alone
+	require.NoError(t, s.Wake(job.ID))
+	got, err := s.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStateRunning, got.State)
+	assert.ErrorIs(t, s.Wake("missing"), ErrJobNotFound)
+}
+
+func TestFileStore_RecoversRunningJobsOnRestart(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStore(t, dir)
+
+	queued, err := s.Enqueue("issue_comment", "delivery-1", []byte(`{}`), false)
+	require.NoError(t, err)
+	_, err = s.Claim()
+	require.NoError(t, err)
+
+	// Simulate a crash: open a fresh store on the same directory
+	reopened := newTestStore(t, dir)
+	job, err := reopened.Get(queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStateQueued, job.State)
+	assert.Equal(t, 1, job.Attempts)
+
+	claimed, err := reopened.Claim()
+	require.NoError(t, err)
+	assert.Equal(t, queued.ID, claimed.ID)
+	assert.Equal(t, 2, claimed.Attempts)
+}
+
+func TestFileStore_Prune(t *testing.T) {
+	s := newTestStore(t, t.TempDir())
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	done, err := s.Enqueue("issue_comment", "delivery-1", nil, false)
+	require.NoError(t, err)
+	_, err = s.Enqueue("issue_comment", "delivery-2", nil, false)
+	require.NoError(t, err)
+
+	job, err := s.Claim()
+	require.NoError(t, err)
+	require.Equal(t, done.ID, job.ID)
+	require.NoError(t, s.Complete(job.ID))
+
+	now = now.Add(defaultRetention + time.Hour)
+	assert.Equal(t, 1, s.Prune())
+	_, err = s.Get(done.ID)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	assert.Len(t, s.List(), 1)
+}
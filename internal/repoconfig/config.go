@@ -0,0 +1,463 @@
+package repoconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/cron"
+	"github.com/qiniu/codeagent/internal/review"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Path 仓库配置文件的路径，与 .codeagent/commands、.codeagent/agents 位于同一目录
+const Path = ".codeagent/config.yaml"
+
+// Config 仓库级配置，从仓库默认分支读取，覆盖服务端全局配置中的对应项；未设置的字段沿用全局配置
+type Config struct {
+	// 默认AI provider：claude、gemini、openai
+	Provider string `yaml:"provider"`
+	// 默认provider使用的模型，需要同时设置 provider
+	Model   string        `yaml:"model"`
+	Review  ReviewConfig  `yaml:"review"`
+	Mention MentionConfig `yaml:"mention"`
+	Branch  BranchConfig  `yaml:"branch"`
+	CI      CIConfig      `yaml:"ci"`
+	// 自动化规则
+	Automation AutomationConfig `yaml:"automation"`
+}
+
+// ReviewConfig 代码审查配置
+type ReviewConfig struct {
+	// 是否在PR打开时自动审查，未设置时沿用全局行为（启用）
+	Auto *bool `yaml:"auto"`
+	// 只审查匹配这些路径的文件（glob，支持 **），为空时审查全部文件
+	Paths []string `yaml:"paths"`
+	// 不审查匹配这些路径的文件
+	ExcludePaths []string `yaml:"exclude_paths"`
+	// 审查策略，覆盖全局 review.policy 中的对应项，例如 {nit: approve, none: approve}
+	Policy map[string]string `yaml:"policy"`
+}
+
+// MentionConfig mention触发配置
+type MentionConfig struct {
+	// 在全局触发词之外额外响应的触发词，例如 @my-bot
+	Triggers []string `yaml:"triggers"`
+}
+
+// BranchConfig 分支命名配置
+type BranchConfig struct {
+	// CodeAgent 创建分支的前缀，默认 codeagent
+	Prefix string `yaml:"prefix"`
+}
+
+// CIConfig CI失败修复配置
+type CIConfig struct {
+	// CodeAgent PR的CI失败时是否自动修复，未设置时沿用全局 ci_fix.auto
+	AutoFix *bool `yaml:"auto_fix"`
+}
+
+// AutomationConfig 自动化规则配置
+type AutomationConfig struct {
+	// 自动对Issue执行 /code 的规则，按顺序使用第一条匹配的规则
+	Issues []IssueRule `yaml:"issues"`
+	// 定时任务，需要在服务端 cron.repos 中登记仓库才会被调度
+	Schedules []ScheduleRule `yaml:"schedules"`
+	// 推送到默认分支或受保护分支时执行的动作，所有匹配的规则都会执行
+	Push []PushRule `yaml:"push"`
+}
+
+// IssueRule Issue自动处理规则，label、assignee、template 必须且只能设置一个
+type IssueRule struct {
+	// Issue被添加该标签时触发
+	Label string `yaml:"label"`
+	// Issue被分配给该用户时触发，通常为CodeAgent使用的账号
+	Assignee string `yaml:"assignee"`
+	// 使用该模板创建Issue时触发，值为 .github/ISSUE_TEMPLATE 下不含扩展名的文件名
+	Template string `yaml:"template"`
+	// 处理Issue使用的provider，未设置时使用仓库默认provider
+	Provider string `yaml:"provider"`
+	// 附加给 /code 的说明
+	Instructions string `yaml:"instructions"`
+}
+
+// ScheduleRule 定时任务
+type ScheduleRule struct {
+	// 任务：stale-issues、dependency-update、review-open-prs
+	Job string `yaml:"job"`
+	// 5段cron表达式，按UTC计算，支持 @daily、@weekly 等简写
+	Cron string `yaml:"cron"`
+	// stale-issues：超过多少天没有活动的Issue标记为过期，默认30
+	Days int `yaml:"days"`
+	// stale-issues：标记过期后多少天仍没有活动则关闭，为0时不关闭
+	CloseDays int `yaml:"close_days"`
+	// stale-issues 的过期标签，默认 stale；dependency-update 创建的Issue的标签，默认 dependencies
+	Label string `yaml:"label"`
+	// 每次运行最多处理的Issue/PR数量
+	Limit int `yaml:"limit"`
+	// dependency-update 使用的provider，未设置时使用仓库默认provider
+	Provider string `yaml:"provider"`
+	// 附加给任务的说明
+	Instructions string `yaml:"instructions"`
+}
+
+// PushRule 推送自动化规则
+type PushRule struct {
+	// 动作：changelog、docs、refresh-cache、close-merged-prs
+	Action string `yaml:"action"`
+	// 触发的分支，支持 * 通配符（不匹配 /），为空时只匹配默认分支
+	Branches []string `yaml:"branches"`
+	// 推送的提交修改了匹配这些路径的文件时才触发，为空时不限制
+	Paths []string `yaml:"paths"`
+	// changelog、docs 创建的跟踪Issue的标签，默认分别为 changelog、documentation
+	Label string `yaml:"label"`
+	// changelog、docs 使用的provider，未设置时使用仓库默认provider
+	Provider string `yaml:"provider"`
+	// 附加给 /code 的说明
+	Instructions string `yaml:"instructions"`
+}
+
+// ValidationError 仓库配置不符合schema
+type ValidationError struct {
+	Repo     string
+	SHA      string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s in %s@%s: %s", Path, e.Repo, shortSHA(e.SHA), strings.Join(e.Problems, "; "))
+}
+
+var (
+	validProviders = map[string]bool{
+		models.AIModelClaude: true,
+		models.AIModelGemini: true,
+		models.AIModelOpenAI: true,
+	}
+	triggerPattern      = regexp.MustCompile(`^@[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	branchPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*$`)
+)
+
+// Parse 解析并校验仓库配置，未知字段和非法取值都会被报告
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, &ValidationError{Problems: yamlProblems(err)}
+	}
+	if problems := cfg.validate(); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return &cfg, nil
+}
+
+// yamlProblems 将yaml解析错误拆分为逐条问题
+func yamlProblems(err error) []string {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		return typeErr.Errors
+	}
+	return []string{err.Error()}
+}
+
+func (c *Config) validate() []string {
+	var problems []string
+	if c.Provider != "" && !validProviders[c.Provider] {
+		problems = append(problems, fmt.Sprintf("provider: unsupported provider %q (want claude, gemini or openai)", c.Provider))
+	}
+	if c.Model != "" && c.Provider == "" {
+		problems = append(problems, "model: requires provider to be set")
+	}
+	for _, pattern := range append(append([]string{}, c.Review.Paths...), c.Review.ExcludePaths...) {
+		if _, err := compileGlob(pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("review: invalid path pattern %q: %v", pattern, err))
+		}
+	}
+	if _, err := review.ParsePolicy(c.Review.Policy); err != nil {
+		problems = append(problems, fmt.Sprintf("review.policy: %v", err))
+	}
+	for _, trigger := range c.Mention.Triggers {
+		if !triggerPattern.MatchString(trigger) {
+			problems = append(problems, fmt.Sprintf("mention.triggers: %q must look like @name", trigger))
+		}
+	}
+	for i, rule := range c.Automation.Issues {
+		set := 0
+		for _, value := range []string{rule.Label, rule.Assignee, rule.Template} {
+			if strings.TrimSpace(value) != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			problems = append(problems, fmt.Sprintf("automation.issues[%d]: exactly one of label, assignee or template must be set", i))
+		}
+		if strings.ContainsAny(rule.Template, "/\\") {
+			problems = append(problems, fmt.Sprintf("automation.issues[%d].template: %q must be a file name in .github/ISSUE_TEMPLATE", i, rule.Template))
+		}
+		if rule.Provider != "" && !validProviders[rule.Provider] {
+			problems = append(problems, fmt.Sprintf("automation.issues[%d].provider: unsupported provider %q (want claude, gemini or openai)", i, rule.Provider))
+		}
+	}
+	for i, rule := range c.Automation.Schedules {
+		if !models.IsValidJob(rule.Job) {
+			problems = append(problems, fmt.Sprintf("automation.schedules[%d].job: unsupported job %q (want %s, %s or %s)",
+				i, rule.Job, models.JobStaleIssues, models.JobDependencyUpdate, models.JobReviewOpenPRs))
+		}
+		if _, err := cron.Parse(rule.Cron); err != nil {
+			problems = append(problems, fmt.Sprintf("automation.schedules[%d].cron: %v", i, err))
+		}
+		if rule.Days < 0 || rule.CloseDays < 0 || rule.Limit < 0 {
+			problems = append(problems, fmt.Sprintf("automation.schedules[%d]: days, close_days and limit must not be negative", i))
+		}
+		if rule.Provider != "" && !validProviders[rule.Provider] {
+			problems = append(problems, fmt.Sprintf("automation.schedules[%d].provider: unsupported provider %q (want claude, gemini or openai)", i, rule.Provider))
+		}
+	}
+	for i, rule := range c.Automation.Push {
+		if !models.IsValidPushAction(rule.Action) {
+			problems = append(problems, fmt.Sprintf("automation.push[%d].action: unsupported action %q (want %s, %s, %s or %s)",
+				i, rule.Action, models.PushActionChangelog, models.PushActionDocs, models.PushActionRefreshCache, models.PushActionCloseMergedPRs))
+		}
+		for _, pattern := range rule.Branches {
+			if _, err := path.Match(pattern, ""); err != nil {
+				problems = append(problems, fmt.Sprintf("automation.push[%d].branches: invalid pattern %q: %v", i, pattern, err))
+			}
+		}
+		for _, pattern := range rule.Paths {
+			if _, err := compileGlob(pattern); err != nil {
+				problems = append(problems, fmt.Sprintf("automation.push[%d].paths: invalid path pattern %q: %v", i, pattern, err))
+			}
+		}
+		if rule.Provider != "" && !validProviders[rule.Provider] {
+			problems = append(problems, fmt.Sprintf("automation.push[%d].provider: unsupported provider %q (want claude, gemini or openai)", i, rule.Provider))
+		}
+	}
+	if prefix := c.Branch.Prefix; prefix != "" && !branchPrefixPattern.MatchString(prefix) {
+		problems = append(problems, fmt.Sprintf("branch.prefix: %q is not a valid branch prefix", prefix))
+	}
+	return problems
+}
+
+// ProviderOr 返回仓库配置的默认provider，未配置时返回 fallback
+func (c *Config) ProviderOr(fallback string) string {
+	if c == nil || c.Provider == "" {
+		return fallback
+	}
+	return c.Provider
+}
+
+// ModelFor 返回仓库配置中指定provider使用的模型，没有覆盖时返回空字符串
+func (c *Config) ModelFor(provider string) string {
+	if c == nil || c.Provider != provider {
+		return ""
+	}
+	return c.Model
+}
+
+// AutoReviewEnabled PR打开时是否自动审查
+func (c *Config) AutoReviewEnabled() bool {
+	if c == nil || c.Review.Auto == nil {
+		return true
+	}
+	return *c.Review.Auto
+}
+
+// CIAutoFixEnabled CodeAgent PR的CI失败时是否自动修复，global为全局配置
+func (c *Config) CIAutoFixEnabled(global bool) bool {
+	if c == nil || c.CI.AutoFix == nil {
+		return global
+	}
+	return *c.CI.AutoFix
+}
+
+// ReviewIncludes 文件是否在审查范围内
+func (c *Config) ReviewIncludes(path string) bool {
+	if c == nil {
+		return true
+	}
+	if len(c.Review.Paths) > 0 && !MatchAny(c.Review.Paths, path) {
+		return false
+	}
+	return !MatchAny(c.Review.ExcludePaths, path)
+}
+
+// HasReviewPathFilter 是否配置了审查路径过滤
+func (c *Config) HasReviewPathFilter() bool {
+	return c != nil && (len(c.Review.Paths) > 0 || len(c.Review.ExcludePaths) > 0)
+}
+
+// ReviewPolicy 返回仓库配置覆盖后的审查策略
+func (c *Config) ReviewPolicy(base review.Policy) review.Policy {
+	if c == nil || len(c.Review.Policy) == 0 {
+		return base
+	}
+	// 配置在加载时已经校验过
+	override, _ := review.ParsePolicy(c.Review.Policy)
+	return base.Merge(override)
+}
+
+// MentionConfig 返回全局触发词加上仓库额外触发词的mention配置
+func (c *Config) MentionConfig(base models.MentionConfig) models.MentionConfig {
+	if c == nil || len(c.Mention.Triggers) == 0 {
+		return base
+	}
+	var triggers []string
+	defaultTrigger := models.CommandMention
+	if base != nil {
+		triggers = append(triggers, base.GetTriggers()...)
+		if base.GetDefaultTrigger() != "" {
+			defaultTrigger = base.GetDefaultTrigger()
+		}
+	}
+	if len(triggers) == 0 {
+		// 保留全局触发词
+		triggers = []string{defaultTrigger}
+	}
+	triggers = append(triggers, c.Mention.Triggers...)
+	return &models.ConfigMentionAdapter{Triggers: triggers, DefaultTrigger: defaultTrigger}
+}
+
+// BranchPrefix 返回CodeAgent分支前缀，未配置时返回空字符串
+func (c *Config) BranchPrefix() string {
+	if c == nil {
+		return ""
+	}
+	return c.Branch.Prefix
+}
+
+// IssueRuleForLabel 返回添加label时触发的第一条规则
+func (c *Config) IssueRuleForLabel(label string) (IssueRule, bool) {
+	if c == nil || label == "" {
+		return IssueRule{}, false
+	}
+	for _, rule := range c.Automation.Issues {
+		if strings.EqualFold(rule.Label, label) {
+			return rule, true
+		}
+	}
+	return IssueRule{}, false
+}
+
+// IssueRuleForAssignee 返回分配给assignee时触发的第一条规则
+func (c *Config) IssueRuleForAssignee(assignee string) (IssueRule, bool) {
+	if c == nil || assignee == "" {
+		return IssueRule{}, false
+	}
+	for _, rule := range c.Automation.Issues {
+		if strings.EqualFold(strings.TrimPrefix(rule.Assignee, "@"), assignee) {
+			return rule, true
+		}
+	}
+	return IssueRule{}, false
+}
+
+// IssueTemplateRules 返回按模板触发的规则
+func (c *Config) IssueTemplateRules() []IssueRule {
+	if c == nil {
+		return nil
+	}
+	var rules []IssueRule
+	for _, rule := range c.Automation.Issues {
+		if rule.Template != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// DueSchedules 返回在t所在分钟需要运行的定时任务
+func (c *Config) DueSchedules(t time.Time) []ScheduleRule {
+	if c == nil {
+		return nil
+	}
+	var due []ScheduleRule
+	for _, rule := range c.Automation.Schedules {
+		// 配置在加载时已经校验过
+		if schedule, err := cron.Parse(rule.Cron); err == nil && schedule.Matches(t) {
+			due = append(due, rule)
+		}
+	}
+	return due
+}
+
+// ScheduleForJob 返回任务的第一条定时配置，手动触发时用作参数默认值
+func (c *Config) ScheduleForJob(job string) (ScheduleRule, bool) {
+	if c == nil {
+		return ScheduleRule{}, false
+	}
+	for _, rule := range c.Automation.Schedules {
+		if rule.Job == job {
+			return rule, true
+		}
+	}
+	return ScheduleRule{}, false
+}
+
+// PushRules 返回推送到branch时触发的规则，files为推送的提交修改的文件
+func (c *Config) PushRules(branch, defaultBranch string, files []string) []PushRule {
+	if c == nil || branch == "" {
+		return nil
+	}
+	var rules []PushRule
+	for _, rule := range c.Automation.Push {
+		if !rule.matchesBranch(branch, defaultBranch) {
+			continue
+		}
+		if len(rule.Paths) > 0 && !anyFileMatches(rule.Paths, files) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (r PushRule) matchesBranch(branch, defaultBranch string) bool {
+	if len(r.Branches) == 0 {
+		return branch == defaultBranch
+	}
+	for _, pattern := range r.Branches {
+		// 配置在加载时已经校验过
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func anyFileMatches(patterns, files []string) bool {
+	for _, file := range files {
+		if MatchAny(patterns, file) {
+			return true
+		}
+	}
+	return false
+}
+
+type configKey struct{}
+
+// NewContext 返回携带仓库配置的ctx
+func NewContext(ctx context.Context, cfg *Config) context.Context {
+	return context.WithValue(ctx, configKey{}, cfg)
+}
+
+// FromContext 返回ctx中的仓库配置，没有时返回nil；nil配置的所有方法都返回全局默认行为
+func FromContext(ctx context.Context) *Config {
+	cfg, _ := ctx.Value(configKey{}).(*Config)
+	return cfg
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
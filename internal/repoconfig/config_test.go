@@ -0,0 +1,181 @@
+package repoconfig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/review"
+	"github.com/qiniu/codeagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+provider: gemini
+model: gemini-2.5-pro
+review:
+  auto: false
+  paths: ["src/**", "*.go"]
+  exclude_paths: ["**/testdata/**"]
+  policy:
+    nit: approve
+mention:
+  triggers: ["@docs-bot"]
+branch:
+  prefix: bots/codeagent
+ci:
+  auto_fix: true
+automation:
+  issues:
+    - label: CodeAgent
+      provider: claude
+      instructions: Keep the change small.
+    - assignee: "@qiniu-ci"
+    - template: feature_request
+  schedules:
+    - job: stale-issues
+      cron: "0 3 * * *"
+      days: 60
+      close_days: 14
+    - job: review-open-prs
+      cron: "@weekly"
+  push:
+    - action: close-merged-prs
+    - action: docs
+      branches: ["main", "release/*"]
+      paths: ["api/**"]
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "gemini", cfg.ProviderOr("claude"))
+	assert.Equal(t, "gemini-2.5-pro", cfg.ModelFor("gemini"))
+	assert.Empty(t, cfg.ModelFor("claude"))
+	assert.False(t, cfg.AutoReviewEnabled())
+	assert.Equal(t, "bots/codeagent", cfg.BranchPrefix())
+	assert.True(t, cfg.CIAutoFixEnabled(false))
+
+	rule, ok := cfg.IssueRuleForLabel("codeagent")
+	require.True(t, ok)
+	assert.Equal(t, "claude", rule.Provider)
+	assert.Equal(t, "Keep the change small.", rule.Instructions)
+	_, ok = cfg.IssueRuleForLabel("bug")
+	assert.False(t, ok)
+	_, ok = cfg.IssueRuleForAssignee("qiniu-ci")
+	assert.True(t, ok)
+	assert.Equal(t, []IssueRule{{Template: "feature_request"}}, cfg.IssueTemplateRules())
+
+	due := cfg.DueSchedules(time.Date(2024, 5, 5, 3, 0, 0, 0, time.UTC)) // 周日
+	require.Len(t, due, 1)
+	assert.Equal(t, 60, due[0].Days)
+	assert.Len(t, cfg.DueSchedules(time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)), 1)
+	assert.Empty(t, cfg.DueSchedules(time.Date(2024, 5, 6, 3, 1, 0, 0, time.UTC)))
+	schedule, ok := cfg.ScheduleForJob("stale-issues")
+	require.True(t, ok)
+	assert.Equal(t, 14, schedule.CloseDays)
+	_, ok = cfg.ScheduleForJob("dependency-update")
+	assert.False(t, ok)
+
+	rules := cfg.PushRules("main", "main", []string{"api/v1/user.go"})
+	require.Len(t, rules, 2)
+	assert.Equal(t, "docs", rules[1].Action)
+	assert.Len(t, cfg.PushRules("main", "main", []string{"README.md"}), 1)
+	assert.Len(t, cfg.PushRules("release/v1", "main", []string{"api/v1/user.go"}), 1)
+	assert.Empty(t, cfg.PushRules("release/v1/hotfix", "main", []string{"api/v1/user.go"}))
+	assert.Empty(t, cfg.PushRules("", "main", nil))
+
+	assert.True(t, cfg.ReviewIncludes("src/app/main.ts"))
+	assert.True(t, cfg.ReviewIncludes("cmd/server/main.go"))
+	assert.False(t, cfg.ReviewIncludes("README.md"))
+	assert.False(t, cfg.ReviewIncludes("src/testdata/fixture.go"))
+
+	policy := cfg.ReviewPolicy(review.DefaultPolicy())
+	assert.Equal(t, review.EventApprove, policy.Decide([]review.Finding{{Severity: review.SeverityNit}}))
+	assert.Equal(t, review.EventRequestChanges, policy.Decide([]review.Finding{{Severity: review.SeverityCritical}}))
+
+	mention := cfg.MentionConfig(&models.ConfigMentionAdapter{Triggers: []string{"@qiniu-ci"}, DefaultTrigger: "@qiniu-ci"})
+	assert.Equal(t, []string{"@qiniu-ci", "@docs-bot"}, mention.GetTriggers())
+	assert.Equal(t, "@qiniu-ci", mention.GetDefaultTrigger())
+}
+
+func TestParse_Empty(t *testing.T) {
+	cfg, err := Parse(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "claude", cfg.ProviderOr("claude"))
+	assert.True(t, cfg.AutoReviewEnabled())
+	assert.True(t, cfg.ReviewIncludes("any/file.go"))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse([]byte(`
+provider: copilot
+review:
+  paths: ["src/[ab]/*"]
+  policy:
+    nit: merge
+mention:
+  triggers: ["bot"]
+branch:
+  prefix: "bad prefix"
+automation:
+  issues:
+    - label: codeagent
+      template: feature_request
+  schedules:
+    - job: cleanup
+      cron: "0 25 * * *"
+  push:
+    - action: deploy
+      branches: ["release/["]
+`))
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 10)
+
+	_, err = Parse([]byte("model: gpt-4o\n"))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Problems[0], "requires provider")
+
+	_, err = Parse([]byte("reviews:\n  auto: true\n"))
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Problems[0], "reviews")
+}
+
+func TestNilConfig(t *testing.T) {
+	var cfg *Config
+	base := &models.ConfigMentionAdapter{Triggers: []string{"@qiniu-ci"}}
+
+	assert.Equal(t, "claude", cfg.ProviderOr("claude"))
+	assert.Empty(t, cfg.ModelFor("claude"))
+	assert.True(t, cfg.AutoReviewEnabled())
+	assert.True(t, cfg.ReviewIncludes("main.go"))
+	assert.False(t, cfg.CIAutoFixEnabled(false))
+	_, ok := cfg.IssueRuleForLabel("codeagent")
+	assert.False(t, ok)
+	assert.Same(t, base, cfg.MentionConfig(base))
+	assert.Empty(t, cfg.DueSchedules(time.Now()))
+	assert.Empty(t, cfg.PushRules("main", "main", nil))
+	assert.Nil(t, FromContext(context.Background()))
+}
+
+func TestMatchAny(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.md", "README.md", true},
+		{"*.md", "docs/guide/intro.md", true},
+		{"docs", "docs/guide/intro.md", true},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"docs/**/*.md", "docs/intro.md", true},
+		{"docs/**/*.md", "docs/guide/intro.md", true},
+		{"internal/**", "internal/agent/agent.go", true},
+		{"internal/**", "cmd/server/main.go", false},
+		{"main.go?", "main.go", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchAny([]string{tt.pattern}, tt.path), "%s ~ %s", tt.pattern, tt.path)
+	}
+}
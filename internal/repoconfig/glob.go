@@ -0,0 +1,59 @@
+package repoconfig
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// compileGlob 将路径glob转换为正则：* 匹配单层路径中的任意字符，** 匹配任意多层目录，? 匹配单个字符；
+// 不含 / 的模式匹配任意目录下的文件名，例如 *.md
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "/")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !strings.Contains(pattern, "/") {
+		sb.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					// **/ 匹配零或多层目录
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[', ']':
+			return nil, fmt.Errorf("character classes are not supported")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// 目录模式匹配目录下的所有文件
+	sb.WriteString("(?:/.*)?$")
+	return regexp.Compile(sb.String())
+}
+
+// MatchAny 路径是否匹配任一glob模式，非法模式视为不匹配
+func MatchAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		re, err := compileGlob(pattern)
+		if err == nil && re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package repoconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/log"
+)
+
+// headTTL 默认分支最新提交的缓存时长，避免每个webhook事件都查询分支
+const headTTL = time.Minute
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("not found")
+
+// Fetcher 读取仓库默认分支的提交和文件
+type Fetcher interface {
+	// DefaultBranchSHA 返回默认分支最新提交的SHA
+	DefaultBranchSHA(ctx context.Context, owner, repo string) (string, error)
+	// FileContent 返回指定提交中的文件内容，文件不存在时返回 ErrNotFound
+	FileContent(ctx context.Context, owner, repo, path, ref string) ([]byte, error)
+}
+
+// snapshot 默认分支某个提交上的配置
+type snapshot struct {
+	sha      string
+	config   *Config
+	err      error
+	reported bool
+}
+
+type head struct {
+	sha     string
+	fetched time.Time
+}
+
+// Loader 读取并按提交SHA缓存仓库配置
+type Loader struct {
+	fetcher Fetcher
+	now     func() time.Time
+
+	mu        sync.Mutex
+	snapshots map[string]*snapshot // owner/repo，只保留最近一次加载的提交
+	heads     map[string]head      // owner/repo
+	latest    map[string]*Config   // owner/repo
+}
+
+// NewLoader 创建仓库配置加载器
+func NewLoader(fetcher Fetcher) *Loader {
+	return &Loader{
+		fetcher:   fetcher,
+		now:       time.Now,
+		snapshots: make(map[string]*snapshot),
+		heads:     make(map[string]head),
+		latest:    make(map[string]*Config),
+	}
+}
+
+// Load 返回仓库默认分支上的配置；仓库没有配置文件时返回 nil, nil，
+// 配置不合法时返回 *ValidationError
+func (l *Loader) Load(ctx context.Context, owner, repo string) (*Config, error) {
+	fullName := owner + "/" + repo
+	sha, err := l.headSHA(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve default branch of %s: %w", fullName, err)
+	}
+
+	l.mu.Lock()
+	snap, ok := l.snapshots[fullName]
+	l.mu.Unlock()
+	if !ok || snap.sha != sha {
+		snap = l.fetch(ctx, owner, repo, sha)
+		if snap == nil {
+			return nil, fmt.Errorf("failed to read %s from %s", Path, fullName)
+		}
+		snap.sha = sha
+		l.mu.Lock()
+		// 默认分支前进后旧提交的配置不再使用，替换掉以免缓存无限增长
+		l.snapshots[fullName] = snap
+		l.latest[strings.ToLower(fullName)] = snap.config
+		l.mu.Unlock()
+	}
+	return snap.config, snap.err
+}
+
+// fetch 读取并解析指定提交中的配置，读取失败（网络等）时返回nil，不缓存
+func (l *Loader) fetch(ctx context.Context, owner, repo, sha string) *snapshot {
+	data, err := l.fetcher.FileContent(ctx, owner, repo, Path, sha)
+	if errors.Is(err, ErrNotFound) {
+		return &snapshot{}
+	}
+	if err != nil {
+		log.Warnf("Failed to read %s from %s/%s@%s: %v", Path, owner, repo, shortSHA(sha), err)
+		return nil
+	}
+
+	cfg, err := Parse(data)
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			validationErr.Repo = owner + "/" + repo
+			validationErr.SHA = sha
+		}
+		return &snapshot{err: err}
+	}
+	log.Infof("Loaded %s from %s/%s@%s", Path, owner, repo, shortSHA(sha))
+	return &snapshot{config: cfg}
+}
+
+func (l *Loader) headSHA(ctx context.Context, owner, repo string) (string, error) {
+	key := owner + "/" + repo
+	now := l.now()
+
+	l.mu.Lock()
+	cached, ok := l.heads[key]
+	l.mu.Unlock()
+	if ok && now.Sub(cached.fetched) < headTTL {
+		return cached.sha, nil
+	}
+
+	sha, err := l.fetcher.DefaultBranchSHA(ctx, owner, repo)
+	if err != nil {
+		return "", err
+	}
+	l.mu.Lock()
+	l.heads[key] = head{sha: sha, fetched: now}
+	l.mu.Unlock()
+	return sha, nil
+}
+
+// MarkReported 记录配置错误已经回复过，同一提交的错误只需要回复一次；返回是否是第一次
+func (l *Loader) MarkReported(err *ValidationError) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snap, ok := l.snapshots[err.Repo]
+	if !ok || snap.sha != err.SHA || snap.reported {
+		return false
+	}
+	snap.reported = true
+	return true
+}
+
+// BranchPrefix 返回仓库最近一次加载的配置中的分支前缀，没有配置时返回空字符串
+func (l *Loader) BranchPrefix(owner, repo string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.latest[strings.ToLower(owner+"/"+repo)].BranchPrefix()
+}
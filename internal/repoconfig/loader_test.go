@@ -0,0 +1,103 @@
+package repoconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFetcher struct {
+	sha       string
+	files     map[string]string // sha -> content
+	headCalls int
+	fileCalls int
+	err       error
+}
+
+func (f *fakeFetcher) DefaultBranchSHA(ctx context.Context, owner, repo string) (string, error) {
+	f.headCalls++
+	return f.sha, nil
+}
+
+func (f *fakeFetcher) FileContent(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	f.fileCalls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	content, ok := f.files[ref]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return []byte(content), nil
+}
+
+func TestLoader_CachesBySHA(t *testing.T) {
+	fetcher := &fakeFetcher{sha: "aaa", files: map[string]string{
+		"aaa": "provider: gemini\n",
+		"bbb": "provider: openai\nbranch:\n  prefix: bots\n",
+	}}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	loader := NewLoader(fetcher)
+	loader.now = func() time.Time { return now }
+
+	cfg, err := loader.Load(context.Background(), "org", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "gemini", cfg.Provider)
+
+	_, err = loader.Load(context.Background(), "org", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, 1, fetcher.headCalls, "head SHA should be cached")
+	assert.Equal(t, 1, fetcher.fileCalls)
+
+	// 默认分支有新提交后重新读取
+	fetcher.sha = "bbb"
+	now = now.Add(2 * headTTL)
+	cfg, err = loader.Load(context.Background(), "org", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "openai", cfg.Provider)
+	assert.Equal(t, 2, fetcher.fileCalls)
+	assert.Equal(t, "bots", loader.BranchPrefix("Org", "Repo"))
+
+	// 只保留每个仓库最新提交的配置
+	assert.Len(t, loader.snapshots, 1)
+	assert.Equal(t, "bbb", loader.snapshots["org/repo"].sha)
+}
+
+func TestLoader_MissingFile(t *testing.T) {
+	loader := NewLoader(&fakeFetcher{sha: "aaa"})
+	cfg, err := loader.Load(context.Background(), "org", "repo")
+	require.NoError(t, err)
+	assert.Nil(t, cfg)
+	assert.Empty(t, loader.BranchPrefix("org", "repo"))
+}
+
+func TestLoader_FetchErrorNotCached(t *testing.T) {
+	fetcher := &fakeFetcher{sha: "aaa", err: errors.New("boom")}
+	loader := NewLoader(fetcher)
+
+	_, err := loader.Load(context.Background(), "org", "repo")
+	require.Error(t, err)
+
+	fetcher.err = nil
+	fetcher.files = map[string]string{"aaa": "provider: claude\n"}
+	cfg, err := loader.Load(context.Background(), "org", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "claude", cfg.Provider)
+}
+
+func TestLoader_ReportValidationErrorOnce(t *testing.T) {
+	loader := NewLoader(&fakeFetcher{sha: "abcdef123456", files: map[string]string{"abcdef123456": "provider: copilot\n"}})
+
+	_, err := loader.Load(context.Background(), "org", "repo")
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "org/repo", validationErr.Repo)
+	assert.Contains(t, validationErr.Error(), "org/repo@abcdef1")
+
+	assert.True(t, loader.MarkReported(validationErr))
+	assert.False(t, loader.MarkReported(validationErr))
+}
@@ -0,0 +1,146 @@
+package review
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxInlineComments 一次审查最多提交的行内评论数，其余意见写入审查总结
+const maxInlineComments = 50
+
+// InlineComment 可以附加到PR diff上的行内评论
+type InlineComment struct {
+	Path string
+	// 多行评论的起始行，0表示单行
+	StartLine int
+	Line      int
+	Body      string
+	Finding   Finding
+}
+
+// Anchor 将审查意见与PR diff对照，返回可以作为行内评论提交的意见和无法定位的意见。
+// patches 为PR中每个文件的diff，没有diff（例如二进制文件）的文件无法评论
+func Anchor(findings []Finding, patches map[string]string) ([]InlineComment, []Finding) {
+	parsed := make(map[string]DiffLines, len(patches))
+	var comments []InlineComment
+	var unanchored []Finding
+	for _, finding := range findings {
+		lines, ok := parsed[finding.Path]
+		if !ok {
+			if patch, exists := patches[finding.Path]; exists {
+				lines = ParsePatch(patch)
+			}
+			parsed[finding.Path] = lines
+		}
+
+		anchored := finding.Line > 0 && lines.Contains(finding.Line)
+		if anchored && finding.StartLine > 0 {
+			anchored = lines.ContainsRange(finding.StartLine, finding.Line)
+		}
+		if !anchored || len(comments) >= maxInlineComments {
+			unanchored = append(unanchored, finding)
+			continue
+		}
+		comments = append(comments, InlineComment{
+			Path:      finding.Path,
+			StartLine: finding.StartLine,
+			Line:      finding.Line,
+			Body:      RenderFinding(finding),
+			Finding:   finding,
+		})
+	}
+	return comments, unanchored
+}
+
+// RenderFinding 渲染行内评论的内容，建议代码使用GitHub的 suggestion 代码块
+func RenderFinding(finding Finding) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**%s** %s\n", severityLabel(finding.Severity), finding.Message))
+	if finding.Suggestion != nil {
+		fence := codeFence(*finding.Suggestion)
+		suggestion := strings.TrimSuffix(*finding.Suggestion, "\n")
+		sb.WriteString(fmt.Sprintf("\n%ssuggestion\n%s\n%s\n", fence, suggestion, fence))
+	}
+	return sb.String()
+}
+
+// RenderSummary 渲染审查总结，包含无法附加到diff上的意见
+func RenderSummary(result *Result, unanchored []Finding) string {
+	var sb strings.Builder
+	sb.WriteString("## 🤖 CodeAgent Review\n\n")
+	if summary := strings.TrimSpace(result.Summary); summary != "" {
+		sb.WriteString(summary)
+		sb.WriteString("\n")
+	}
+	if len(result.Findings) == 0 {
+		sb.WriteString("\nNo issues found.\n")
+	}
+	if len(unanchored) > 0 {
+		sb.WriteString("\n### Other findings\n\n")
+		sb.WriteString("These findings could not be attached to lines in the diff:\n\n")
+		for _, finding := range unanchored {
+			sb.WriteString(fmt.Sprintf("- **%s** `%s`: %s\n", severityLabel(finding.Severity), finding.Location(), finding.Message))
+			if finding.Suggestion != nil {
+				fence := codeFence(*finding.Suggestion)
+				sb.WriteString(fmt.Sprintf("\n  %s\n  %s\n  %s\n", fence, indent(strings.TrimSuffix(*finding.Suggestion, "\n"), "  "), fence))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// maxFallbackOutput 回退评论中原始输出的最大长度，GitHub评论最长65536个字符
+const maxFallbackOutput = 60000
+
+// RenderFallback 审查结果无法作为GitHub审查提交时，将模型的原始输出渲染为普通评论
+func RenderFallback(output string, err error) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxFallbackOutput {
+		output = output[:maxFallbackOutput] + "\n\n… (truncated)"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## 🤖 CodeAgent Review\n\n")
+	sb.WriteString(fmt.Sprintf("⚠️ The review could not be submitted as a GitHub review: %v\n\n", err))
+	sb.WriteString("The raw review output is shown below. The next review of this PR will cover all of its changes again.\n\n")
+	sb.WriteString("<details>\n<summary>Review output</summary>\n\n")
+	sb.WriteString(output)
+	sb.WriteString("\n\n</details>\n")
+	return sb.String()
+}
+
+func severityLabel(severity Severity) string {
+	switch severity {
+	case SeverityCritical:
+		return "🔴 Critical"
+	case SeverityMajor:
+		return "🟠 Major"
+	case SeverityNit:
+		return "⚪ Nit"
+	default:
+		return "🟡 Minor"
+	}
+}
+
+// codeFence 返回比内容中最长的反引号序列更长的代码块围栏
+func codeFence(content string) string {
+	longest, current := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			current++
+			if current > longest {
+				longest = current
+			}
+		} else {
+			current = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+func indent(text, prefix string) string {
+	return strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
@@ -0,0 +1,57 @@
+package review
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnchor(t *testing.T) {
+	suggestion := "import \"fmt\""
+	findings := []Finding{
+		{Path: "main.go", StartLine: 2, Line: 4, Severity: SeverityMajor, Message: "use a single import", Suggestion: &suggestion},
+		{Path: "main.go", Line: 22, Severity: SeverityNit, Message: "magic number"},
+		{Path: "main.go", Line: 40, Severity: SeverityMinor, Message: "outside the diff"},
+		{Path: "main.go", StartLine: 5, Line: 21, Severity: SeverityMinor, Message: "spans hunks"},
+		{Path: "other.go", Line: 1, Severity: SeverityCritical, Message: "file not in PR"},
+	}
+
+	comments, unanchored := Anchor(findings, map[string]string{"main.go": samplePatch})
+	require.Len(t, comments, 2)
+	assert.Equal(t, 2, comments[0].StartLine)
+	assert.Equal(t, 4, comments[0].Line)
+	assert.Contains(t, comments[0].Body, "**🟠 Major** use a single import")
+	assert.Contains(t, comments[0].Body, "```suggestion\nimport \"fmt\"\n```")
+	assert.Equal(t, 22, comments[1].Line)
+
+	require.Len(t, unanchored, 3)
+	summary := RenderSummary(&Result{Summary: "Overall fine.", Findings: findings}, unanchored)
+	assert.Contains(t, summary, "Overall fine.")
+	assert.Contains(t, summary, "`main.go:40`: outside the diff")
+	assert.Contains(t, summary, "**🔴 Critical** `other.go:1`: file not in PR")
+}
+
+func TestRenderFinding_FenceLongerThanContent(t *testing.T) {
+	suggestion := "doc := \"```go\""
+	body := RenderFinding(Finding{Path: "a.go", Line: 1, Severity: SeverityMinor, Message: "m", Suggestion: &suggestion})
+	assert.Contains(t, body, "````suggestion\n")
+}
+
+func TestRenderSummary_NoFindings(t *testing.T) {
+	summary := RenderSummary(&Result{Summary: "LGTM"}, nil)
+	assert.Contains(t, summary, "No issues found.")
+	assert.NotContains(t, summary, "Other findings")
+}
+
+func TestRenderFallback(t *testing.T) {
+	body := RenderFallback("  Looks mostly fine.\n<review_findings>not json</review_findings>\n", errors.New("invalid review findings"))
+	assert.Contains(t, body, "could not be submitted as a GitHub review: invalid review findings")
+	assert.Contains(t, body, "<summary>Review output</summary>\n\nLooks mostly fine.\n<review_findings>not json</review_findings>\n\n</details>")
+
+	long := RenderFallback(strings.Repeat("x", maxFallbackOutput+10), errors.New("boom"))
+	assert.Contains(t, long, "(truncated)")
+	assert.Less(t, len(long), maxFallbackOutput+1000)
+}
@@ -0,0 +1,57 @@
+package review
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// DiffLines PR diff中新文件一侧可以评论的行，记录每一行所属的hunk
+type DiffLines map[int]int
+
+// ParsePatch 解析GitHub返回的单个文件的unified diff
+func ParsePatch(patch string) DiffLines {
+	lines := make(DiffLines)
+	hunk := 0
+	newLine := 0
+	for _, text := range strings.Split(patch, "\n") {
+		if match := hunkHeaderPattern.FindStringSubmatch(text); match != nil {
+			hunk++
+			newLine, _ = strconv.Atoi(match[1])
+			continue
+		}
+		if hunk == 0 || text == "" {
+			continue
+		}
+		switch text[0] {
+		case '+', ' ':
+			lines[newLine] = hunk
+			newLine++
+		case '-', '\\':
+			// 删除的行和 "\ No newline at end of file" 不占新文件的行号
+		}
+	}
+	return lines
+}
+
+// Contains 行是否在diff中
+func (d DiffLines) Contains(line int) bool {
+	_, ok := d[line]
+	return ok
+}
+
+// ContainsRange start..end 是否都在diff的同一个hunk中
+func (d DiffLines) ContainsRange(start, end int) bool {
+	hunk, ok := d[start]
+	if !ok {
+		return false
+	}
+	for line := start + 1; line <= end; line++ {
+		if d[line] != hunk {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const samplePatch = `@@ -1,4 +1,5 @@
+ package main
+-import "fmt"
++import (
++	"fmt"
++)
+ func main() {}
+@@ -20,3 +21,3 @@ func helper() {
+ 	a := 1
+-	b := 2
++	b := 3
+\ No newline at end of file`
+
+func TestParsePatch(t *testing.T) {
+	lines := ParsePatch(samplePatch)
+
+	for _, line := range []int{1, 2, 3, 4, 5, 21, 22} {
+		assert.True(t, lines.Contains(line), "line %d", line)
+	}
+	for _, line := range []int{0, 6, 20, 23} {
+		assert.False(t, lines.Contains(line), "line %d", line)
+	}
+
+	assert.True(t, lines.ContainsRange(2, 4))
+	assert.False(t, lines.ContainsRange(5, 21), "range spans two hunks")
+	assert.False(t, lines.ContainsRange(22, 23))
+}
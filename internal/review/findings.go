@@ -0,0 +1,127 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Severity 审查发现的问题的严重程度
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityMajor    Severity = "major"
+	SeverityMinor    Severity = "minor"
+	SeverityNit      Severity = "nit"
+)
+
+// 结构化审查结果在模型输出中的起止标记
+const (
+	findingsStartTag = "<review_findings>"
+	findingsEndTag   = "</review_findings>"
+)
+
+// Finding 一条审查意见
+type Finding struct {
+	Path string `json:"path"`
+	// 多行意见的起始行，0表示单行
+	StartLine int      `json:"start_line,omitempty"`
+	Line      int      `json:"line"`
+	Severity  Severity `json:"severity"`
+	Message   string   `json:"message"`
+	// 替换 StartLine..Line 的建议代码，nil表示没有建议
+	Suggestion *string `json:"suggestion,omitempty"`
+}
+
+// Location 返回意见所在的文件和行号
+func (f Finding) Location() string {
+	switch {
+	case f.Line <= 0:
+		return f.Path
+	case f.StartLine > 0 && f.StartLine < f.Line:
+		return fmt.Sprintf("%s:%d-%d", f.Path, f.StartLine, f.Line)
+	default:
+		return fmt.Sprintf("%s:%d", f.Path, f.Line)
+	}
+}
+
+// Result 模型输出的结构化审查结果
+type Result struct {
+	Summary  string    `json:"summary"`
+	Findings []Finding `json:"findings"`
+}
+
+// FindingsFormat 要求模型输出结构化审查结果的说明，追加在审查提示词中
+const FindingsFormat = `## Review output format
+
+Do not post inline review comments yourself: CodeAgent submits them as one GitHub review from your findings.
+Keep your tracking comment to a short overview, and finish your final answer with the findings as JSON between
+` + findingsStartTag + ` and ` + findingsEndTag + ` tags:
+
+` + findingsStartTag + `
+{
+  "summary": "One paragraph overall assessment of the change",
+  "findings": [
+    {
+      "path": "path/relative/to/repo.go",
+      "start_line": 10,
+      "line": 12,
+      "severity": "critical | major | minor | nit",
+      "message": "What is wrong and why",
+      "suggestion": "optional replacement code for lines start_line..line of the new file"
+    }
+  ]
+}
+` + findingsEndTag + `
+
+Line numbers refer to the new version of the file and must be lines changed or shown in the PR diff.
+Omit start_line for single-line findings and omit suggestion when you have no concrete replacement.
+Use an empty findings list when the change looks good.
+
+Severity decides whether the pull request is approved or blocked, so classify each finding carefully:
+- critical: bugs, security issues or data loss that must be fixed before merging
+- major: incorrect behavior or missing handling that should be fixed
+- minor: maintainability or robustness improvements
+- nit: style, naming and other cosmetic suggestions`
+
+// ParseResult 从模型输出中解析最后一段结构化审查结果，没有找到时返回错误
+func ParseResult(output string) (*Result, error) {
+	end := strings.LastIndex(output, findingsEndTag)
+	if end < 0 {
+		return nil, fmt.Errorf("no %s block in review output", findingsStartTag)
+	}
+	start := strings.LastIndex(output[:end], findingsStartTag)
+	if start < 0 {
+		return nil, fmt.Errorf("no %s block in review output", findingsStartTag)
+	}
+	body := strings.TrimSpace(output[start+len(findingsStartTag) : end])
+	// 兼容模型在标记内又包了一层代码块
+	body = strings.TrimPrefix(body, "```json")
+	body = strings.TrimPrefix(body, "```")
+	body = strings.TrimSuffix(body, "```")
+
+	var result Result
+	if err := json.Unmarshal([]byte(strings.TrimSpace(body)), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse review findings: %w", err)
+	}
+	for i := range result.Findings {
+		result.Findings[i].normalize()
+	}
+	return &result, nil
+}
+
+// normalize 规范化模型给出的字段
+func (f *Finding) normalize() {
+	f.Path = strings.TrimPrefix(strings.TrimSpace(f.Path), "./")
+	f.Severity = Severity(strings.ToLower(strings.TrimSpace(string(f.Severity))))
+	switch f.Severity {
+	case SeverityCritical, SeverityMajor, SeverityMinor, SeverityNit:
+	default:
+		f.Severity = SeverityMinor
+	}
+	if f.StartLine >= f.Line {
+		f.StartLine = 0
+	}
+	f.Message = strings.TrimSpace(f.Message)
+}
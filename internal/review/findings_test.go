@@ -0,0 +1,53 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResult(t *testing.T) {
+	output := "I reviewed the change and updated the comment.\n\n" +
+		"<review_findings>\n```json\n" + `{
+  "summary": "Looks mostly good.",
+  "findings": [
+    {"path": "./main.go", "start_line": 10, "line": 12, "severity": "CRITICAL", "message": " nil dereference ", "suggestion": "if x != nil {\n}"},
+    {"path": "util.go", "line": 3, "severity": "style", "message": "naming"},
+    {"path": "util.go", "start_line": 5, "line": 5, "severity": "nit", "message": "typo"}
+  ]
+}` + "\n```\n</review_findings>"
+
+	result, err := ParseResult(output)
+	require.NoError(t, err)
+	assert.Equal(t, "Looks mostly good.", result.Summary)
+	require.Len(t, result.Findings, 3)
+
+	first := result.Findings[0]
+	assert.Equal(t, "main.go", first.Path)
+	assert.Equal(t, SeverityCritical, first.Severity)
+	assert.Equal(t, "nil dereference", first.Message)
+	require.NotNil(t, first.Suggestion)
+	assert.Equal(t, "main.go:10-12", first.Location())
+
+	assert.Equal(t, SeverityMinor, result.Findings[1].Severity, "unknown severities default to minor")
+	assert.Equal(t, 0, result.Findings[2].StartLine, "single-line range is normalized")
+	assert.Equal(t, "util.go:5", result.Findings[2].Location())
+}
+
+func TestParseResult_UsesLastBlock(t *testing.T) {
+	output := FindingsFormat + "\n<review_findings>{\"summary\": \"final\", \"findings\": []}</review_findings>"
+
+	result, err := ParseResult(output)
+	require.NoError(t, err)
+	assert.Equal(t, "final", result.Summary)
+	assert.Empty(t, result.Findings)
+}
+
+func TestParseResult_Missing(t *testing.T) {
+	_, err := ParseResult("just prose")
+	assert.Error(t, err)
+
+	_, err = ParseResult("<review_findings>not json</review_findings>")
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Event 提交GitHub审查时的审查结论
+type Event string
+
+const (
+	EventRequestChanges Event = "REQUEST_CHANGES"
+	EventComment        Event = "COMMENT"
+	EventApprove        Event = "APPROVE"
+)
+
+// PolicyClean 策略中表示"没有发现问题"的键
+const PolicyClean = "none"
+
+// CheckRunName 发布审查结论的check run名称，可以在分支保护中设为必需检查
+const CheckRunName = "codeagent/review"
+
+// eventRank 多条意见对应不同结论时取最严格的一个
+var eventRank = map[Event]int{
+	EventApprove:        0,
+	EventComment:        1,
+	EventRequestChanges: 2,
+}
+
+var policyKeys = []string{string(SeverityCritical), string(SeverityMajor), string(SeverityMinor), string(SeverityNit), PolicyClean}
+
+// Policy 审查策略：按意见的严重程度（或没有意见时的 none）映射到审查结论
+type Policy map[string]Event
+
+// DefaultPolicy 默认策略：存在 critical 意见时请求修改，其余情况只评论
+func DefaultPolicy() Policy {
+	return Policy{
+		string(SeverityCritical): EventRequestChanges,
+		string(SeverityMajor):    EventComment,
+		string(SeverityMinor):    EventComment,
+		string(SeverityNit):      EventComment,
+		PolicyClean:              EventComment,
+	}
+}
+
+// ParsePolicy 解析配置中的审查策略，例如 {critical: request_changes, nit: approve, none: approve}；
+// 未配置的键沿用其他策略
+func ParsePolicy(rules map[string]string) (Policy, error) {
+	policy := make(Policy, len(rules))
+	var problems []string
+	for key, value := range rules {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !isPolicyKey(key) {
+			problems = append(problems, fmt.Sprintf("unknown severity %q (want %s)", key, strings.Join(policyKeys, ", ")))
+			continue
+		}
+		event, err := parseEvent(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		policy[key] = event
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return policy, nil
+}
+
+func isPolicyKey(key string) bool {
+	for _, k := range policyKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func parseEvent(value string) (Event, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "request_changes", "request-changes":
+		return EventRequestChanges, nil
+	case "comment":
+		return EventComment, nil
+	case "approve":
+		return EventApprove, nil
+	}
+	return "", fmt.Errorf("unknown review event %q (want request_changes, comment or approve)", value)
+}
+
+// Merge 返回用 override 覆盖后的策略，不修改原策略
+func (p Policy) Merge(override Policy) Policy {
+	merged := make(Policy, len(p)+len(override))
+	for key, event := range p {
+		merged[key] = event
+	}
+	for key, event := range override {
+		merged[key] = event
+	}
+	return merged
+}
+
+// Decide 根据审查意见返回审查结论，多条意见取最严格的结论
+func (p Policy) Decide(findings []Finding) Event {
+	if len(findings) == 0 {
+		return p.event(PolicyClean)
+	}
+	decision := EventApprove
+	for _, finding := range findings {
+		if event := p.event(string(finding.Severity)); eventRank[event] > eventRank[decision] {
+			decision = event
+		}
+	}
+	return decision
+}
+
+// Stricter 返回两个结论中更严格的一个
+func Stricter(a, b Event) Event {
+	if eventRank[b] > eventRank[a] {
+		return b
+	}
+	return a
+}
+
+func (p Policy) event(key string) Event {
+	if event, ok := p[key]; ok {
+		return event
+	}
+	return DefaultPolicy()[key]
+}
+
+// CheckRunConclusion 审查结论对应的check run结论：请求修改为 failure，批准为 success，评论为 neutral
+func (e Event) CheckRunConclusion() string {
+	switch e {
+	case EventRequestChanges:
+		return "failure"
+	case EventApprove:
+		return "success"
+	default:
+		return "neutral"
+	}
+}
+
+// CheckRunTitle 审查结论在check run中的标题
+func (e Event) CheckRunTitle() string {
+	switch e {
+	case EventRequestChanges:
+		return "Changes requested"
+	case EventApprove:
+		return "Approved"
+	default:
+		return "Reviewed with comments"
+	}
+}
+
+// RenderCheckRunSummary 渲染check run的总结：各严重程度的意见数量和适用的策略
+func RenderCheckRunSummary(result *Result, decision Event, policy Policy) string {
+	counts := make(map[Severity]int)
+	for _, finding := range result.Findings {
+		counts[finding.Severity]++
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Decision:** `%s`\n\n", decision))
+	if len(result.Findings) == 0 {
+		sb.WriteString("No issues found.\n")
+	} else {
+		sb.WriteString("| Severity | Findings | Policy |\n|---|---|---|\n")
+		for _, severity := range []Severity{SeverityCritical, SeverityMajor, SeverityMinor, SeverityNit} {
+			sb.WriteString(fmt.Sprintf("| %s | %d | `%s` |\n", severityLabel(severity), counts[severity], policy.event(string(severity))))
+		}
+	}
+	if summary := strings.TrimSpace(result.Summary); summary != "" {
+		sb.WriteString("\n")
+		sb.WriteString(summary)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,53 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyDecide(t *testing.T) {
+	critical := Finding{Path: "a.go", Line: 1, Severity: SeverityCritical}
+	minor := Finding{Path: "a.go", Line: 2, Severity: SeverityMinor}
+	nit := Finding{Path: "a.go", Line: 3, Severity: SeverityNit}
+
+	policy := DefaultPolicy()
+	assert.Equal(t, EventRequestChanges, policy.Decide([]Finding{nit, critical, minor}))
+	assert.Equal(t, EventComment, policy.Decide([]Finding{minor}))
+	assert.Equal(t, EventComment, policy.Decide(nil))
+
+	override, err := ParsePolicy(map[string]string{"nit": "approve", "none": "APPROVE", "major": "request-changes"})
+	require.NoError(t, err)
+	policy = policy.Merge(override)
+	assert.Equal(t, EventApprove, policy.Decide([]Finding{nit, nit}))
+	assert.Equal(t, EventApprove, policy.Decide(nil))
+	assert.Equal(t, EventComment, policy.Decide([]Finding{nit, minor}))
+	assert.Equal(t, EventRequestChanges, policy.Decide([]Finding{{Severity: SeverityMajor}}))
+
+	// 原策略不受覆盖影响
+	assert.Equal(t, EventComment, DefaultPolicy().Decide(nil))
+}
+
+func TestStricter(t *testing.T) {
+	assert.Equal(t, EventRequestChanges, Stricter(EventApprove, EventRequestChanges))
+	assert.Equal(t, EventRequestChanges, Stricter(EventRequestChanges, EventComment))
+	assert.Equal(t, EventComment, Stricter(EventComment, ""))
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	_, err := ParsePolicy(map[string]string{"blocker": "comment", "nit": "merge"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown severity "blocker"`)
+	assert.Contains(t, err.Error(), `unknown review event "merge"`)
+
+	policy, err := ParsePolicy(nil)
+	require.NoError(t, err)
+	assert.Equal(t, EventRequestChanges, policy.Decide([]Finding{{Severity: SeverityCritical}}))
+}
+
+func TestEventCheckRunConclusion(t *testing.T) {
+	assert.Equal(t, "failure", EventRequestChanges.CheckRunConclusion())
+	assert.Equal(t, "neutral", EventComment.CheckRunConclusion())
+	assert.Equal(t, "success", EventApprove.CheckRunConclusion())
+}
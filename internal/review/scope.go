@@ -0,0 +1,107 @@
+package review
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qiniu/codeagent/pkg/models"
+)
+
+const (
+	// maxPreviousThreads 提示词中最多列出的历史审查讨论数
+	maxPreviousThreads = 50
+	// maxThreadSummary 每条历史讨论摘要的最大长度
+	maxThreadSummary = 200
+)
+
+// Scope 一次增量审查的范围：只审查 Base..Head 之间新增的提交
+type Scope struct {
+	Base string
+	Head string
+	// 之前审查中已经提出的问题
+	Threads []models.ReviewThread
+}
+
+// Instructions 返回追加到审查提示词中的增量审查说明
+func (s *Scope) Instructions() string {
+	if s == nil || s.Base == "" {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## Incremental review\n\n")
+	sb.WriteString(fmt.Sprintf("This PR was already reviewed at commit %s. Only review the changes pushed since then: ", shortSHA(s.Base)))
+	sb.WriteString(fmt.Sprintf("use 'git diff %s..%s' and 'git log %s..%s' instead of diffing against the base branch. ", s.Base, s.Head, s.Base, s.Head))
+	sb.WriteString("Do not comment on code that was not touched by these commits.\n")
+
+	var resolved, open []models.ReviewThread
+	for _, thread := range s.Threads {
+		if thread.Resolved {
+			resolved = append(resolved, thread)
+		} else {
+			open = append(open, thread)
+		}
+	}
+	if len(resolved)+len(open) == 0 {
+		return sb.String()
+	}
+
+	sb.WriteString("\nThe following issues were already raised in earlier review threads. Do not raise them again. ")
+	sb.WriteString("Resolved threads were settled by the author and must never be repeated; for open threads, only mention them if the new commits make them worse.\n")
+	written := 0
+	for _, group := range []struct {
+		title   string
+		threads []models.ReviewThread
+	}{{"Resolved", resolved}, {"Open", open}} {
+		if len(group.threads) == 0 || written >= maxPreviousThreads {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%s:\n", group.title))
+		for _, thread := range group.threads {
+			if written >= maxPreviousThreads {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", thread.Location(), summarize(thread.Body)))
+			written++
+		}
+	}
+	if total := len(s.Threads); total > written {
+		sb.WriteString(fmt.Sprintf("- ... and %d more earlier threads\n", total-written))
+	}
+	return sb.String()
+}
+
+// AllResolved 之前的审查讨论是否都已解决；没有讨论时无法确认之前的意见已经处理，返回false
+func (s *Scope) AllResolved() bool {
+	if s == nil || len(s.Threads) == 0 {
+		return false
+	}
+	for _, thread := range s.Threads {
+		if !thread.Resolved {
+			return false
+		}
+	}
+	return true
+}
+
+// summarize 取讨论的第一行非空内容作为摘要
+func summarize(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) > maxThreadSummary {
+			line = line[:maxThreadSummary] + "..."
+		}
+		return line
+	}
+	return "(no description)"
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,52 @@
+package review
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qiniu/codeagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScope_Instructions(t *testing.T) {
+	var full *Scope
+	assert.Empty(t, full.Instructions())
+
+	scope := &Scope{
+		Base: "1111111aaaa",
+		Head: "2222222bbbb",
+		Threads: []models.ReviewThread{
+			{Path: "main.go", Line: 12, Resolved: true, Body: "\nMissing error check on Close\nmore details"},
+			{Path: "util.go", Body: "Consider a table-driven test"},
+		},
+	}
+	text := scope.Instructions()
+
+	assert.Contains(t, text, "already reviewed at commit 1111111")
+	assert.Contains(t, text, "git diff 1111111aaaa..2222222bbbb")
+	assert.Contains(t, text, "Resolved:\n- main.go:12: Missing error check on Close\n")
+	assert.Contains(t, text, "Open:\n- util.go: Consider a table-driven test\n")
+	assert.NotContains(t, text, "more details")
+}
+
+func TestScope_AllResolved(t *testing.T) {
+	var full *Scope
+	assert.False(t, full.AllResolved())
+	assert.False(t, (&Scope{Base: "a", Head: "b"}).AllResolved(), "no threads to confirm earlier findings were addressed")
+
+	scope := &Scope{Base: "a", Head: "b", Threads: []models.ReviewThread{{Resolved: true}, {Resolved: false}}}
+	assert.False(t, scope.AllResolved())
+	scope.Threads[1].Resolved = true
+	assert.True(t, scope.AllResolved())
+}
+
+func TestScope_InstructionsLimitsThreads(t *testing.T) {
+	scope := &Scope{Base: "a", Head: "b"}
+	for i := 0; i < maxPreviousThreads+5; i++ {
+		scope.Threads = append(scope.Threads, models.ReviewThread{Path: "main.go", Resolved: true, Body: "issue"})
+	}
+	text := scope.Instructions()
+
+	assert.Equal(t, maxPreviousThreads, strings.Count(text, "- main.go: issue"))
+	assert.Contains(t, text, "and 5 more earlier threads")
+}
@@ -0,0 +1,85 @@
+package review
+
+import (
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/persist"
+)
+
+// PRState 一个PR最近一次审查的状态
+type PRState struct {
+	// 最近一次审查时PR的head提交
+	HeadSHA    string    `json:"head_sha"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+	// 最近一次提交的审查结论
+	Decision Event `json:"decision,omitempty"`
+}
+
+// StateStore 记录每个PR最近一次审查的head提交，用于增量审查；状态保存在单个JSON文件中
+type StateStore struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	states *persist.Map[PRState] // owner/repo#number
+}
+
+// NewStateStore 打开（或创建）path处的审查状态存储
+func NewStateStore(path string) (*StateStore, error) {
+	states, err := persist.Open[PRState](path, "review state")
+	if err != nil {
+		return nil, err
+	}
+	return &StateStore{now: time.Now, states: states}, nil
+}
+
+// LastReviewed 返回PR最近一次审查的head提交，没有审查记录时返回false
+func (s *StateStore) LastReviewed(repo string, number int) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states.Get(persist.IssueKey(repo, number))
+	return state.HeadSHA, ok && state.HeadSHA != ""
+}
+
+// LastDecision 返回PR最近一次提交的审查结论，没有记录时返回false
+func (s *StateStore) LastDecision(repo string, number int) (Event, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states.Get(persist.IssueKey(repo, number))
+	return state.Decision, ok && state.Decision != ""
+}
+
+// MarkReviewed 记录PR已经审查到headSHA，decision为空时（没有提交审查）保留之前的结论
+func (s *StateStore) MarkReviewed(repo string, number int, headSHA string, decision Event) error {
+	if s == nil || headSHA == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := persist.IssueKey(repo, number)
+	if decision == "" {
+		previous, _ := s.states.Get(key)
+		decision = previous.Decision
+	}
+	return s.states.Set(key, PRState{HeadSHA: headSHA, ReviewedAt: s.now(), Decision: decision})
+}
+
+// Forget 删除PR的审查记录（PR关闭时调用）
+func (s *StateStore) Forget(repo string, number int) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.states.Delete(persist.IssueKey(repo, number))
+}
@@ -0,0 +1,51 @@
+package review
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reviews.json")
+	store, err := NewStateStore(path)
+	require.NoError(t, err)
+
+	_, ok := store.LastReviewed("org/repo", 8)
+	assert.False(t, ok)
+
+	require.NoError(t, store.MarkReviewed("org/repo", 8, "abc123", ""))
+	sha, ok := store.LastReviewed("org/repo", 8)
+	assert.True(t, ok)
+	assert.Equal(t, "abc123", sha)
+
+	// 重启后状态仍然存在
+	reopened, err := NewStateStore(path)
+	require.NoError(t, err)
+	sha, ok = reopened.LastReviewed("org/repo", 8)
+	assert.True(t, ok)
+	assert.Equal(t, "abc123", sha)
+
+	// 没有提交审查时保留之前的结论
+	_, ok = reopened.LastDecision("org/repo", 8)
+	assert.False(t, ok)
+	require.NoError(t, reopened.MarkReviewed("org/repo", 8, "def456", EventRequestChanges))
+	require.NoError(t, reopened.MarkReviewed("org/repo", 8, "fed789", ""))
+	decision, ok := reopened.LastDecision("org/repo", 8)
+	assert.True(t, ok)
+	assert.Equal(t, EventRequestChanges, decision)
+
+	require.NoError(t, reopened.Forget("org/repo", 8))
+	_, ok = reopened.LastReviewed("org/repo", 8)
+	assert.False(t, ok)
+}
+
+func TestStateStore_Nil(t *testing.T) {
+	var store *StateStore
+	_, ok := store.LastReviewed("org/repo", 8)
+	assert.False(t, ok)
+	assert.NoError(t, store.MarkReviewed("org/repo", 8, "abc123", ""))
+	assert.NoError(t, store.Forget("org/repo", 8))
+}
@@ -0,0 +1,99 @@
+package usage
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handler 导出用量合计的HTTP接口：
+//
+//	GET /usage?group_by=repo&since=2025-01-01&until=2025-02-01&org=owner&repo=owner/repo&user=login&trigger=auto&format=csv
+//
+// group_by 支持 task、pr、repo、user、provider、model，默认 repo；since/until 支持日期或RFC3339时间，
+// until 不包含在内。请求需要携带 Authorization: Bearer <admin_token>，未配置token时接口不可用。
+func Handler(store *Store, adminToken string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if adminToken == "" {
+			http.Error(w, "usage export is disabled: server.admin_token is not configured", http.StatusForbidden)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		groupBy := Group(query.Get("group_by"))
+		if groupBy == "" {
+			groupBy = GroupByRepo
+		}
+		filter := Filter{
+			Org:     query.Get("org"),
+			Repo:    query.Get("repo"),
+			User:    query.Get("user"),
+			Trigger: Trigger(query.Get("trigger")),
+		}
+		var err error
+		if filter.Since, err = parseTime(query.Get("since")); err != nil {
+			http.Error(w, fmt.Sprintf("invalid since: %v", err), http.StatusBadRequest)
+			return
+		}
+		if filter.Until, err = parseTime(query.Get("until")); err != nil {
+			http.Error(w, fmt.Sprintf("invalid until: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		summary, err := store.Summarize(filter, groupBy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if query.Get("format") == "csv" {
+			writeCSV(w, summary)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	})
+}
+
+// parseTime 解析日期（YYYY-MM-DD，UTC）或RFC3339时间，空字符串返回零值
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func writeCSV(w http.ResponseWriter, summary *Summary) {
+	w.Header().Set("Content-Type", "text/csv")
+	writer := csv.NewWriter(w)
+	writer.Write([]string{string(summary.GroupBy), "calls", "input_tokens", "output_tokens", "cache_read_tokens", "cache_creation_tokens", "cost_usd"})
+	for _, group := range summary.Groups {
+		writer.Write([]string{
+			group.Key,
+			strconv.Itoa(group.Calls),
+			strconv.FormatInt(group.InputTokens, 10),
+			strconv.FormatInt(group.OutputTokens, 10),
+			strconv.FormatInt(group.CacheReadTokens, 10),
+			strconv.FormatInt(group.CacheCreationTokens, 10),
+			strconv.FormatFloat(group.CostUSD, 'f', 6, 64),
+		})
+	}
+	writer.Flush()
+}
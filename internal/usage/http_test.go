@@ -0,0 +1,59 @@
+package usage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "usage.jsonl"), config.UsageConfig{})
+	require.NoError(t, err)
+	store.now = func() time.Time { return time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC) }
+	_, err = store.Append(Record{Repo: "org/a", User: "alice", Provider: "claude", TokenUsage: models.TokenUsage{Calls: 1, InputTokens: 10, CostUSD: 1.5}})
+	require.NoError(t, err)
+
+	request := func(handler http.Handler, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusForbidden, request(Handler(store, ""), "/usage", "secret").Code)
+
+	handler := Handler(store, "secret")
+	assert.Equal(t, http.StatusUnauthorized, request(handler, "/usage", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(handler, "/usage", "wrong").Code)
+	assert.Equal(t, http.StatusBadRequest, request(handler, "/usage?since=yesterday", "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, request(handler, "/usage?group_by=team", "secret").Code)
+
+	rec := request(handler, "/usage?group_by=user&since=2025-03-01", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var summary Summary
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &summary))
+	assert.Equal(t, GroupByUser, summary.GroupBy)
+	require.Len(t, summary.Groups, 1)
+	assert.Equal(t, "alice", summary.Groups[0].Key)
+	assert.Equal(t, 1.5, summary.Groups[0].CostUSD)
+
+	rec = request(handler, "/usage?until=2025-03-01&format=csv", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "repo,calls,input_tokens,output_tokens,cache_read_tokens,cache_creation_tokens,cost_usd", strings.TrimSpace(rec.Body.String()))
+
+	rec = request(handler, "/usage?format=csv", "secret")
+	assert.Contains(t, rec.Body.String(), "org/a,1,10,0,0,0,1.500000")
+}
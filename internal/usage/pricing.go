@@ -0,0 +1,66 @@
+package usage
+
+import (
+	"strings"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+)
+
+// defaultPrices 常用模型的公开价格（美元/百万token），按模型名前缀匹配
+var defaultPrices = map[string]config.ModelPrice{
+	"claude-opus-4":     {Input: 15, Output: 75, CacheRead: 1.5, CacheWrite: 18.75},
+	"claude-sonnet-4":   {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-7-sonnet": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-5-sonnet": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4, CacheRead: 0.08, CacheWrite: 1},
+	"gemini-2.5-pro":    {Input: 1.25, Output: 10, CacheRead: 0.31},
+	"gemini-2.5-flash":  {Input: 0.3, Output: 2.5, CacheRead: 0.075},
+	"gemini-2.0-flash":  {Input: 0.1, Output: 0.4, CacheRead: 0.025},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.6, CacheRead: 0.075},
+	"gpt-4o":            {Input: 2.5, Output: 10, CacheRead: 1.25},
+	"gpt-4.1-mini":      {Input: 0.4, Output: 1.6, CacheRead: 0.1},
+	"gpt-4.1":           {Input: 2, Output: 8, CacheRead: 0.5},
+}
+
+// Pricing 估算没有返回费用的AI调用的费用
+type Pricing struct {
+	prices map[string]config.ModelPrice
+}
+
+// NewPricing 合并内置价格与配置中的价格，配置优先
+func NewPricing(overrides map[string]config.ModelPrice) *Pricing {
+	prices := make(map[string]config.ModelPrice, len(defaultPrices)+len(overrides))
+	for model, price := range defaultPrices {
+		prices[model] = price
+	}
+	for model, price := range overrides {
+		prices[strings.ToLower(model)] = price
+	}
+	return &Pricing{prices: prices}
+}
+
+// Estimate 按最长前缀匹配的模型价格估算费用，未知模型返回0
+func (p *Pricing) Estimate(model string, u models.TokenUsage) float64 {
+	if p == nil || model == "" {
+		return 0
+	}
+	model = strings.ToLower(model)
+
+	var price config.ModelPrice
+	matched := ""
+	for prefix, candidate := range p.prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			price = candidate
+			matched = prefix
+		}
+	}
+	if matched == "" {
+		return 0
+	}
+
+	return (float64(u.InputTokens)*price.Input +
+		float64(u.OutputTokens)*price.Output +
+		float64(u.CacheReadTokens)*price.CacheRead +
+		float64(u.CacheCreationTokens)*price.CacheWrite) / 1e6
+}
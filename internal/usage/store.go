@@ -0,0 +1,259 @@
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/log"
+)
+
+// Record 一次AI调用的用量记录
+type Record struct {
+	Time     time.Time `json:"time"`
+	TaskID   string    `json:"task_id"`
+	Repo     string    `json:"repo"`
+	Number   int       `json:"number,omitempty"` // 触发任务的Issue/PR编号
+	PR       int       `json:"pr,omitempty"`     // 任务所属的PR编号（/code 在Issue上创建的PR）
+	User     string    `json:"user,omitempty"`   // 触发任务的用户
+	Command  string    `json:"command,omitempty"`
+	Trigger  Trigger   `json:"trigger,omitempty"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model,omitempty"`
+	models.TokenUsage
+}
+
+// Trigger 任务的触发方式
+type Trigger string
+
+const (
+	// TriggerCommand 用户通过命令或mention触发
+	TriggerCommand Trigger = "command"
+	// TriggerAuto 由事件自动触发，例如PR打开时的自动审查
+	TriggerAuto Trigger = "auto"
+)
+
+// Group 聚合维度
+type Group string
+
+const (
+	GroupByTask     Group = "task"
+	GroupByPR       Group = "pr"
+	GroupByRepo     Group = "repo"
+	GroupByUser     Group = "user"
+	GroupByProvider Group = "provider"
+	GroupByModel    Group = "model"
+)
+
+// key 返回记录在该维度下的分组键
+func (g Group) key(r Record) (string, bool) {
+	switch g {
+	case GroupByTask:
+		return r.TaskID, true
+	case GroupByPR:
+		number := r.PR
+		if number == 0 {
+			number = r.Number
+		}
+		return fmt.Sprintf("%s#%d", r.Repo, number), true
+	case GroupByRepo:
+		return r.Repo, true
+	case GroupByUser:
+		return r.User, true
+	case GroupByProvider:
+		return r.Provider, true
+	case GroupByModel:
+		return r.Model, true
+	}
+	return "", false
+}
+
+// Filter 查询条件，零值表示不限制
+type Filter struct {
+	Since   time.Time
+	Until   time.Time
+	Org     string
+	Repo    string
+	User    string
+	Trigger Trigger
+}
+
+func (f Filter) match(r Record) bool {
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Time.Before(f.Until) {
+		return false
+	}
+	if f.Org != "" && !strings.HasPrefix(r.Repo, f.Org+"/") {
+		return false
+	}
+	if f.Repo != "" && r.Repo != f.Repo {
+		return false
+	}
+	if f.User != "" && r.User != f.User {
+		return false
+	}
+	if f.Trigger != "" && r.Trigger != f.Trigger {
+		return false
+	}
+	return true
+}
+
+// GroupTotal 一个分组的用量合计
+type GroupTotal struct {
+	Key string `json:"key"`
+	models.TokenUsage
+}
+
+// Summary 按维度聚合的用量
+type Summary struct {
+	GroupBy Group             `json:"group_by"`
+	Total   models.TokenUsage `json:"total"`
+	Groups  []GroupTotal      `json:"groups"`
+}
+
+// Store 用量记录的持久化存储，每条记录一行JSON追加写入文件
+type Store struct {
+	path    string
+	pricing *Pricing
+
+	mu      sync.Mutex
+	records []Record
+	seq     uint64
+	now     func() time.Time
+}
+
+// NewStore 打开（或创建）path处的用量存储并加载已有记录
+func NewStore(path string, cfg config.UsageConfig) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create usage store directory: %w", err)
+	}
+
+	s := &Store{
+		path:    path,
+		pricing: NewPricing(cfg.Prices),
+		now:     time.Now,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read usage store: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			// 进程崩溃时可能留下不完整的最后一行
+			log.Warnf("Skipping malformed usage record: %v", err)
+			continue
+		}
+		s.records = append(s.records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse usage store: %w", err)
+	}
+	return s, nil
+}
+
+// Append 补全费用后保存一条记录
+func (s *Store) Append(record Record) (Record, error) {
+	if record.CostUSD == 0 {
+		record.CostUSD = s.pricing.Estimate(record.Model, record.TokenUsage)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record.Time.IsZero() {
+		record.Time = s.now()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return record, fmt.Errorf("failed to marshal usage record: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return record, fmt.Errorf("failed to open usage store: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return record, fmt.Errorf("failed to write usage record: %w", err)
+	}
+
+	s.records = append(s.records, record)
+	return record, nil
+}
+
+// Summarize 按维度聚合满足条件的记录，分组按费用从高到低排序
+func (s *Store) Summarize(filter Filter, groupBy Group) (*Summary, error) {
+	if _, ok := groupBy.key(Record{}); !ok {
+		return nil, fmt.Errorf("unsupported group_by: %q", groupBy)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := &Summary{GroupBy: groupBy, Groups: []GroupTotal{}}
+	totals := make(map[string]*models.TokenUsage)
+	for _, record := range s.records {
+		if !filter.match(record) {
+			continue
+		}
+		key, _ := groupBy.key(record)
+		if totals[key] == nil {
+			totals[key] = &models.TokenUsage{}
+		}
+		totals[key].Add(record.TokenUsage)
+		summary.Total.Add(record.TokenUsage)
+	}
+
+	for key, total := range totals {
+		summary.Groups = append(summary.Groups, GroupTotal{Key: key, TokenUsage: *total})
+	}
+	sort.Slice(summary.Groups, func(i, j int) bool {
+		if summary.Groups[i].CostUSD != summary.Groups[j].CostUSD {
+			return summary.Groups[i].CostUSD > summary.Groups[j].CostUSD
+		}
+		return summary.Groups[i].Key < summary.Groups[j].Key
+	})
+	return summary, nil
+}
+
+// Total 返回满足条件的记录的用量合计
+func (s *Store) Total(filter Filter) models.TokenUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total models.TokenUsage
+	for _, record := range s.records {
+		if filter.match(record) {
+			total.Add(record.TokenUsage)
+		}
+	}
+	return total
+}
+
+// newTaskID 生成按时间排序的任务ID
+func (s *Store) newTaskID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return fmt.Sprintf("%s-%d", s.now().UTC().Format("20060102T150405"), s.seq)
+}
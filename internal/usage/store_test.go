@@ -0,0 +1,109 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricing_Estimate(t *testing.T) {
+	pricing := NewPricing(map[string]config.ModelPrice{
+		"Internal-Model": {Input: 1, Output: 2},
+	})
+
+	u := models.TokenUsage{InputTokens: 1_000_000, OutputTokens: 1_000_000, CacheReadTokens: 1_000_000}
+	assert.InDelta(t, 3+15+0.3, pricing.Estimate("claude-sonnet-4-20250514", u), 1e-9)
+	// 更长的前缀优先
+	assert.InDelta(t, 0.15+0.6+0.075, pricing.Estimate("gpt-4o-mini-2024-07-18", u), 1e-9)
+	assert.InDelta(t, 3, pricing.Estimate("internal-model-v2", u), 1e-9)
+	assert.Zero(t, pricing.Estimate("unknown-model", u))
+}
+
+func TestStore_AppendAndSummarize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "usage.jsonl")
+	store, err := NewStore(path, config.UsageConfig{})
+	require.NoError(t, err)
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return day }
+
+	// 没有费用时按价格估算
+	record, err := store.Append(Record{Repo: "org/a", Number: 1, User: "alice", Provider: "claude", Model: "claude-sonnet-4",
+		TokenUsage: models.TokenUsage{Calls: 1, InputTokens: 1_000_000}})
+	require.NoError(t, err)
+	assert.InDelta(t, 3, record.CostUSD, 1e-9)
+	assert.Equal(t, day, record.Time)
+
+	_, err = store.Append(Record{Repo: "org/a", Number: 2, PR: 5, User: "bob", Provider: "gemini",
+		TokenUsage: models.TokenUsage{Calls: 1, OutputTokens: 10, CostUSD: 1}})
+	require.NoError(t, err)
+	_, err = store.Append(Record{Time: day.AddDate(0, 1, 0), Repo: "org/b", Number: 3, User: "alice", Provider: "claude",
+		TokenUsage: models.TokenUsage{Calls: 1, CostUSD: 5}})
+	require.NoError(t, err)
+
+	// 重新打开后记录仍然存在
+	store, err = NewStore(path, config.UsageConfig{})
+	require.NoError(t, err)
+
+	summary, err := store.Summarize(Filter{}, GroupByRepo)
+	require.NoError(t, err)
+	assert.Equal(t, 3, summary.Total.Calls)
+	assert.InDelta(t, 9, summary.Total.CostUSD, 1e-9)
+	require.Len(t, summary.Groups, 2)
+	assert.Equal(t, "org/b", summary.Groups[0].Key)
+	assert.Equal(t, "org/a", summary.Groups[1].Key)
+	assert.Equal(t, 2, summary.Groups[1].Calls)
+
+	summary, err = store.Summarize(Filter{Until: day.AddDate(0, 0, 1)}, GroupByPR)
+	require.NoError(t, err)
+	var keys []string
+	for _, group := range summary.Groups {
+		keys = append(keys, group.Key)
+	}
+	assert.Equal(t, []string{"org/a#1", "org/a#5"}, keys)
+
+	summary, err = store.Summarize(Filter{User: "alice"}, GroupByUser)
+	require.NoError(t, err)
+	require.Len(t, summary.Groups, 1)
+	assert.InDelta(t, 8, summary.Groups[0].CostUSD, 1e-9)
+
+	_, err = store.Summarize(Filter{}, Group("team"))
+	assert.Error(t, err)
+}
+
+func TestTask_Add(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "usage.jsonl"), config.UsageConfig{})
+	require.NoError(t, err)
+
+	task := store.NewTask(TaskInfo{Repo: "org/a", Number: 7, User: "alice", Command: "/code"})
+	require.NotEmpty(t, task.ID)
+	task.Add("claude", "claude-sonnet-4", models.TokenUsage{InputTokens: 100, CostUSD: 0.1})
+	// /code 在Issue上创建PR后，后续调用归属到该PR
+	task.SetPR(8)
+	task.Add("claude", "claude-sonnet-4", models.TokenUsage{InputTokens: 50, CostUSD: 0.2})
+
+	total := task.Usage()
+	assert.Equal(t, 2, total.Calls)
+	assert.EqualValues(t, 150, total.InputTokens)
+	assert.InDelta(t, 0.3, total.CostUSD, 1e-9)
+
+	summary, err := store.Summarize(Filter{}, GroupByTask)
+	require.NoError(t, err)
+	require.Len(t, summary.Groups, 1)
+	assert.Equal(t, task.ID, summary.Groups[0].Key)
+
+	summary, err = store.Summarize(Filter{}, GroupByPR)
+	require.NoError(t, err)
+	assert.Len(t, summary.Groups, 2)
+
+	// 没有存储时只累加
+	var nilStore *Store
+	task = nilStore.NewTask(TaskInfo{Repo: "org/a"})
+	task.Add("gemini", "", models.TokenUsage{OutputTokens: 5})
+	assert.Equal(t, 1, task.Usage().Calls)
+}
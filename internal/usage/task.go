@@ -0,0 +1,98 @@
+package usage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/log"
+)
+
+// TaskInfo 任务的归属信息
+type TaskInfo struct {
+	Repo    string // owner/repo
+	Number  int    // 触发任务的Issue/PR编号
+	PR      int    // 已知的PR编号
+	User    string // 触发任务的用户
+	Command string // 命令或事件，例如 /code、/review、pull_request.opened
+	Trigger Trigger
+}
+
+// Task 累加一次任务（一次命令或一次自动审查）中所有AI调用的用量
+type Task struct {
+	ID    string
+	store *Store
+
+	mu    sync.Mutex
+	info  TaskInfo
+	total models.TokenUsage
+}
+
+// NewTask 创建任务，store为nil时只累加不持久化
+func (s *Store) NewTask(info TaskInfo) *Task {
+	task := &Task{info: info, store: s}
+	if s != nil {
+		task.ID = s.newTaskID()
+	}
+	return task
+}
+
+// SetPR 记录任务关联的PR，例如在Issue上执行 /code 时创建的PR
+func (t *Task) SetPR(number int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.info.PR = number
+}
+
+// Add 记录一次AI调用的用量
+func (t *Task) Add(provider, model string, u models.TokenUsage) {
+	u.Calls = 1
+
+	t.mu.Lock()
+	record := Record{
+		TaskID:     t.ID,
+		Repo:       t.info.Repo,
+		Number:     t.info.Number,
+		PR:         t.info.PR,
+		User:       t.info.User,
+		Command:    t.info.Command,
+		Trigger:    t.info.Trigger,
+		Provider:   provider,
+		Model:      model,
+		TokenUsage: u,
+	}
+	t.mu.Unlock()
+
+	if t.store != nil {
+		saved, err := t.store.Append(record)
+		if err != nil {
+			log.Warnf("Failed to persist usage for task %s: %v", t.ID, err)
+		}
+		record = saved
+	}
+
+	t.mu.Lock()
+	t.total.Add(record.TokenUsage)
+	t.mu.Unlock()
+}
+
+// Usage 返回目前为止的用量合计
+func (t *Task) Usage() models.TokenUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
+
+type taskKey struct{}
+
+// NewContext 返回携带用量任务的ctx
+func NewContext(ctx context.Context, task *Task) context.Context {
+	return context.WithValue(ctx, taskKey{}, task)
+}
+
+// FromContext 返回ctx中的用量任务，没有时返回nil
+func FromContext(ctx context.Context) *Task {
+	task, _ := ctx.Value(taskKey{}).(*Task)
+	return task
+}
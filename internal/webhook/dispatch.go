@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/qiniu/codeagent/pkg/models"
+
+	"github.com/qiniu/x/reqid"
+	"github.com/qiniu/x/xlog"
+)
+
+// DispatchRequest 手动触发定时任务的请求
+type DispatchRequest struct {
+	// 仓库，owner/repo
+	Repo string `json:"repo"`
+	// 任务：stale-issues、dependency-update、review-open-prs
+	Job string `json:"job"`
+	// 覆盖仓库配置中的任务参数，例如 {"days": 14, "limit": 5}
+	Inputs map[string]interface{} `json:"inputs"`
+}
+
+// HandleDispatch 手动触发仓库的定时任务，任务作为 workflow_dispatch 事件进入任务队列：
+//
+//	POST /dispatch {"repo": "owner/repo", "job": "review-open-prs", "inputs": {"limit": 5}}
+//
+// 请求需要携带 Authorization: Bearer <admin_token>，未配置token时接口不可用。
+func (h *Handler) HandleDispatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	adminToken := h.config.Server.AdminToken
+	if adminToken == "" {
+		http.Error(w, "job dispatch is disabled: server.admin_token is not configured", http.StatusForbidden)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req DispatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if owner, name, ok := strings.Cut(req.Repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		http.Error(w, "repo must be in owner/repo form", http.StatusBadRequest)
+		return
+	}
+	if !models.IsValidJob(req.Job) {
+		http.Error(w, fmt.Sprintf("unknown job %q (want %s, %s or %s)", req.Job, models.JobStaleIssues, models.JobDependencyUpdate, models.JobReviewOpenPRs), http.StatusBadRequest)
+		return
+	}
+
+	ctx := reqid.NewContext(r.Context(), "dispatch")
+	xl := xlog.NewWith(ctx)
+	job, err := h.enhancedAgent.DispatchJob(ctx, req.Repo, req.Job, req.Inputs)
+	if err != nil {
+		xl.Errorf("failed to dispatch job %s for %s: %v", req.Job, req.Repo, err)
+		http.Error(w, "failed to enqueue job", http.StatusInternalServerError)
+		return
+	}
+	xl.Infof("dispatched job %s for %s", req.Job, req.Repo)
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("job queued: " + job.ID))
+}
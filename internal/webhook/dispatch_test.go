@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/config"
+)
+
+func TestHandleDispatch_Validation(t *testing.T) {
+	handler := NewHandler(&config.Config{Server: config.ServerConfig{AdminToken: "admin"}}, nil)
+
+	tests := []struct {
+		name           string
+		method         string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{name: "wrong method", method: http.MethodGet, token: "admin", expectedStatus: http.StatusMethodNotAllowed},
+		{name: "missing token", method: http.MethodPost, body: `{}`, expectedStatus: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodPost, token: "guess", body: `{}`, expectedStatus: http.StatusUnauthorized},
+		{name: "invalid body", method: http.MethodPost, token: "admin", body: `{`, expectedStatus: http.StatusBadRequest},
+		{name: "invalid repo", method: http.MethodPost, token: "admin", body: `{"repo":"owner","job":"stale-issues"}`, expectedStatus: http.StatusBadRequest},
+		{name: "unknown job", method: http.MethodPost, token: "admin", body: `{"repo":"owner/repo","job":"cleanup"}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/dispatch", strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			handler.HandleDispatch(rr, req)
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleDispatch_Disabled(t *testing.T) {
+	handler := NewHandler(&config.Config{}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/dispatch", strings.NewReader(`{"repo":"owner/repo","job":"stale-issues"}`))
+	rr := httptest.NewRecorder()
+	handler.HandleDispatch(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
@@ -1,20 +1,22 @@
 package webhook
 
 import (
-	"context"
-	"errors"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/qiniu/codeagent/internal/agent"
 	"github.com/qiniu/codeagent/internal/config"
-	"github.com/qiniu/codeagent/internal/events"
 	"github.com/qiniu/codeagent/pkg/signature"
 
 	"github.com/qiniu/x/reqid"
 	"github.com/qiniu/x/xlog"
 )
 
+// ReprocessHeader 强制重新处理已处理过的投递的请求头
+const ReprocessHeader = "X-CodeAgent-Reprocess"
+
 type Handler struct {
 	config        *config.Config
 	enhancedAgent *agent.EnhancedAgent
@@ -79,22 +81,28 @@ func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		traceID = "unknown"
 	}
 
-	ctx := reqid.NewContext(context.Background(), traceID)
-	// 5. 使用Enhanced Agent的统一事件处理，传递原始字节数据
-	go func(eventType string, payload []byte, deliveryID string, traceCtx context.Context) {
-		traceLog := xlog.NewWith(traceCtx)
-		if err := h.enhancedAgent.ProcessGitHubWebhookEvent(traceCtx, eventType, deliveryID, payload); err != nil {
-			if errors.Is(err, events.ErrUnsupportedEventType) {
-				traceLog.Debugf("enhanced agent unsupported event type: %v", err)
-			} else {
-				traceLog.Warnf("enhanced agent event processing error: %v", err)
-			}
-		} else {
-			traceLog.Infof("enhanced agent event processing completed successfully")
-		}
-	}(eventType, body, deliveryID, ctx)
+	ctx := reqid.NewContext(r.Context(), traceID)
+	xl := xlog.NewWith(ctx)
+
+	// 5. 将事件写入持久化队列，由Enhanced Agent的worker池异步处理
+	// 签名校验通过的请求可以通过 X-CodeAgent-Reprocess 头强制重新处理已处理过的投递
+	force := isTruthy(r.Header.Get(ReprocessHeader))
+	if force {
+		xl.Infof("forced reprocessing requested for delivery %s", deliveryID)
+	}
+	job, err := h.enhancedAgent.EnqueueWebhookEvent(ctx, eventType, deliveryID, body, force)
+	if err != nil {
+		xl.Errorf("failed to enqueue webhook event: %v", err)
+		http.Error(w, "failed to enqueue event", http.StatusInternalServerError)
+		return
+	}
+
+	// 6. 返回已受理响应
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("enhanced event queued: " + job.ID))
+}
 
-	// 7. 返回成功响应
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("enhanced event processing started"))
+func isTruthy(value string) bool {
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	return err == nil && b
 }
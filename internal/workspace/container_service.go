@@ -105,6 +105,9 @@ func (c *containerService) GenerateContainerNames(ws *models.Workspace) []string
 			containerNames = append(containerNames, fmt.Sprintf("gemini__%s__%s__issue__%d", ws.Org, ws.Repo, ws.Issue.GetNumber()))
 		}
 
+	case "openai":
+		// The OpenAI-compatible provider runs in-process and has no container
+
 	default:
 		// If AI model is unknown, try all possible patterns
 		if ws.PRNumber > 0 {
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/qiniu/x/log"
 )
@@ -24,6 +25,7 @@ type GitService interface {
 	CheckoutBranch(repoPath, branchName string) error
 	CreateTrackingBranch(repoPath, branchName string) error
 	FetchAndCheckoutPR(repoPath string, prNumber int) error
+	GetLastCommit(repoPath string) (*CommitInfo, error)
 }
 
 type gitService struct{}
@@ -146,6 +148,32 @@ func (g *gitService) GetCurrentCommit(repoPath string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// GetLastCommit gets the latest commit on HEAD committed with the repository's git identity, nil if there is none
+func (g *gitService) GetLastCommit(repoPath string) (*CommitInfo, error) {
+	args := []string{"log", "-1", "--format=%H%x00%s%x00%cI"}
+	cmd := exec.Command("git", "config", "user.email")
+	cmd.Dir = repoPath
+	if output, err := cmd.Output(); err == nil && strings.TrimSpace(string(output)) != "" {
+		args = append(args, "--fixed-strings", "--committer="+strings.TrimSpace(string(output)))
+	}
+
+	cmd = exec.Command("git", args...)
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, GitError("get_last_commit", repoPath, err)
+	}
+	fields := strings.Split(strings.TrimSpace(string(output)), "\x00")
+	if len(fields) != 3 {
+		return nil, nil
+	}
+	committedAt, err := time.Parse(time.RFC3339, fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse commit time %q: %w", fields[2], err)
+	}
+	return &CommitInfo{SHA: fields[0], Subject: fields[1], Time: committedAt}, nil
+}
+
 // GetBranchCommit gets the commit hash for a specific branch
 func (g *gitService) GetBranchCommit(repoPath, branch string) (string, error) {
 	cmd := exec.Command("git", "rev-parse", fmt.Sprintf("origin/%s", branch))
@@ -34,7 +34,7 @@ type WorkspaceManager interface {
 	MoveIssueToPR(ws *models.Workspace, prNumber int) error
 
 	// Utility methods
-	ExtractAIModelFromBranch(branchName string) string
+	ExtractAIModelFromBranch(org, repo, branchName string) string
 
 	// Directory format delegation
 	GenerateIssueDirName(aiModel, repo string, issueNumber int, timestamp int64) string
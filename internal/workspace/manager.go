@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +20,35 @@ const (
 	BranchPrefix = "codeagent"
 )
 
+// agentBranchSuffix matches the part after a custom per-repository prefix: [{aiModel}/]issue-{number}-{timestamp}
+var agentBranchSuffix = regexp.MustCompile(`^(?:(claude|gemini|openai)/)?issue-\d+-\d{10,}$`)
+
+// IsAgentBranch reports whether the branch was created by codeagent, either with the default prefix
+// or with prefix, the custom prefix configured for the repository (empty when there is none)
+func IsAgentBranch(branchName, prefix string) bool {
+	if strings.HasPrefix(branchName, BranchPrefix+"/") {
+		return true
+	}
+	_, ok := customPrefixModel(branchName, prefix)
+	return ok
+}
+
+// customPrefixModel matches a branch created with the custom prefix and returns the AI model in its name
+func customPrefixModel(branchName, prefix string) (string, bool) {
+	if prefix == "" || prefix == BranchPrefix {
+		return "", false
+	}
+	rest, ok := strings.CutPrefix(branchName, prefix+"/")
+	if !ok {
+		return "", false
+	}
+	match := agentBranchSuffix.FindStringSubmatch(rest)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
 // Manager manages workspace lifecycle
 type Manager struct {
 	baseDir string
@@ -30,6 +60,9 @@ type Manager struct {
 	containerService ContainerService
 	dirFormatter     DirFormatter
 	repoCacheService RepoCacheService
+
+	// branchPrefix returns the per-repository branch prefix, empty means BranchPrefix
+	branchPrefix func(org, repo string) string
 }
 
 // NewManager creates a new workspace manager with service dependencies
@@ -54,6 +87,34 @@ func NewManager(cfg *config.Config) *Manager {
 	return m
 }
 
+// SetBranchPrefixFunc sets the lookup for per-repository branch prefixes
+func (m *Manager) SetBranchPrefixFunc(fn func(org, repo string) string) {
+	m.branchPrefix = fn
+}
+
+// BranchPrefix returns the custom branch prefix configured for the repository, empty when there is none
+func (m *Manager) BranchPrefix(org, repo string) string {
+	if m == nil || m.branchPrefix == nil {
+		return ""
+	}
+	return m.branchPrefix(org, repo)
+}
+
+// IsAgentBranch reports whether the branch of the repository was created by codeagent
+func (m *Manager) IsAgentBranch(org, repo, branchName string) bool {
+	return IsAgentBranch(branchName, m.BranchPrefix(org, repo))
+}
+
+// AgentPullRequest returns the first pull request of the repository whose head is a codeagent branch, nil when there is none
+func (m *Manager) AgentPullRequest(org, repo string, prs []*github.PullRequest) *github.PullRequest {
+	for _, pr := range prs {
+		if m.IsAgentBranch(org, repo, pr.GetHead().GetRef()) {
+			return pr
+		}
+	}
+	return nil
+}
+
 // GetBaseDir returns the base directory for workspaces
 func (m *Manager) GetBaseDir() string {
 	return m.baseDir
@@ -106,12 +167,16 @@ func (m *Manager) CreateWorkspaceFromIssue(issue *github.Issue, aiModel string)
 	}
 
 	// Generate branch name with AI model information
+	prefix := BranchPrefix
+	if p := m.BranchPrefix(org, repo); p != "" {
+		prefix = p
+	}
 	timestamp := time.Now().Unix()
 	var branchName string
 	if aiModel != "" {
-		branchName = fmt.Sprintf("%s/%s/issue-%d-%d", BranchPrefix, aiModel, issue.GetNumber(), timestamp)
+		branchName = fmt.Sprintf("%s/%s/issue-%d-%d", prefix, aiModel, issue.GetNumber(), timestamp)
 	} else {
-		branchName = fmt.Sprintf("%s/issue-%d-%d", BranchPrefix, issue.GetNumber(), timestamp)
+		branchName = fmt.Sprintf("%s/issue-%d-%d", prefix, issue.GetNumber(), timestamp)
 	}
 
 	// Generate Issue workspace directory name
@@ -153,6 +218,18 @@ func (m *Manager) CreateWorkspaceFromIssue(issue *github.Issue, aiModel string)
 	return ws
 }
 
+// RefreshRepoCache fetches the latest changes into the cached repository so that new workspaces start from
+// an up-to-date clone; it returns false when the repository has not been cached yet
+func (m *Manager) RefreshRepoCache(org, repo string) (bool, error) {
+	if !m.repoCacheService.CachedRepoExists(org, repo) {
+		return false, nil
+	}
+	if err := m.repoCacheService.UpdateCachedRepo(m.repoCacheService.GetCachedRepoPath(org, repo)); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
 // GetOrCreateWorkspaceForIssue gets or creates workspace for Issue with AI model
 func (m *Manager) GetOrCreateWorkspaceForIssue(issue *github.Issue, aiModel string) *models.Workspace {
 	// Try to get existing workspace for the specific AI model
@@ -387,11 +464,20 @@ func (m *Manager) IsForkRepositoryPR(pr *github.PullRequest) bool {
 	return m.isForkRepositoryPR(pr)
 }
 
-// ExtractAIModelFromBranch extracts AI model information from branch name
-func (m *Manager) ExtractAIModelFromBranch(branchName string) string {
+// ExtractAIModelFromBranch extracts AI model information from a branch of the repository,
+// returning an empty string when the branch was not created by codeagent
+func (m *Manager) ExtractAIModelFromBranch(org, repo, branchName string) string {
 	// Check if it's a codeagent branch
 	if !strings.HasPrefix(branchName, BranchPrefix+"/") {
-		return ""
+		// Branches created with the custom prefix of the repository
+		model, ok := customPrefixModel(branchName, m.BranchPrefix(org, repo))
+		if !ok {
+			return ""
+		}
+		if model != "" {
+			return model
+		}
+		return m.config.CodeProvider
 	}
 
 	// Remove codeagent/ prefix
@@ -402,7 +488,7 @@ func (m *Manager) ExtractAIModelFromBranch(branchName string) string {
 	if len(parts) >= 2 {
 		aiModel := parts[0]
 		// Validate if it's a valid AI model
-		if aiModel == "claude" || aiModel == "gemini" {
+		if aiModel == "claude" || aiModel == "gemini" || aiModel == "openai" {
 			return aiModel
 		}
 	}
@@ -54,9 +54,19 @@ func TestExtractAIModelFromBranch(t *testing.T) {
 		CodeProvider: "claude",
 	}
 	manager := NewManager(cfg)
+	manager.SetBranchPrefixFunc(func(org, repo string) string {
+		switch repo {
+		case "nested":
+			return "bots/ai"
+		case "custom":
+			return "bots"
+		}
+		return ""
+	})
 
 	tests := []struct {
 		name       string
+		repo       string
 		branchName string
 		expected   string
 	}{
@@ -80,11 +90,40 @@ func TestExtractAIModelFromBranch(t *testing.T) {
 			branchName: "main",
 			expected:   "",
 		},
+		{
+			name:       "Custom prefix branch",
+			repo:       "nested",
+			branchName: "bots/ai/gemini/issue-12-1700000000",
+			expected:   "gemini",
+		},
+		{
+			name:       "Custom prefix branch without model",
+			repo:       "custom",
+			branchName: "bots/issue-12-1700000000",
+			expected:   "claude",
+		},
+		{
+			name:       "Custom prefix not configured for the repository",
+			repo:       "other",
+			branchName: "bots/issue-12-1700000000",
+			expected:   "",
+		},
+		{
+			name:       "Human branch shaped like an agent branch",
+			repo:       "custom",
+			branchName: "fix/issue-12-1700000000",
+			expected:   "",
+		},
+		{
+			name:       "Feature branch mentioning an issue",
+			branchName: "feature/issue-12-fix",
+			expected:   "",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := manager.ExtractAIModelFromBranch(tt.branchName)
+			result := manager.ExtractAIModelFromBranch("org", tt.repo, tt.branchName)
 			if result != tt.expected {
 				t.Errorf("ExtractAIModelFromBranch(%s) = %s, want %s",
 					tt.branchName, result, tt.expected)
@@ -753,6 +792,10 @@ func TestWorkspaceCleanupScenarios(t *testing.T) {
 // mockGitService provides a mock implementation for testing
 type mockGitService struct{}
 
+func (m *mockGitService) GetLastCommit(repoPath string) (*CommitInfo, error) {
+	return nil, nil
+}
+
 func (m *mockGitService) CloneRepository(repoURL, clonePath, branch string, createNewBranch bool) error {
 	return nil
 }
@@ -1136,3 +1179,26 @@ func TestWorkspaceStateConsistency(t *testing.T) {
 		}
 	})
 }
+
+func TestIsAgentBranch(t *testing.T) {
+	tests := []struct {
+		branchName string
+		prefix     string
+		expected   bool
+	}{
+		{"codeagent/claude/issue-123-1700000000", "", true},
+		{"codeagent/issue-123-1700000000", "bots", true},
+		{"bots/openai/issue-7-1700000000", "bots", true},
+		{"bots/openai/issue-7-1700000000", "", false},
+		{"fix/issue-12-1700000000", "", false},
+		{"fix/issue-12-1700000000", "bots", false},
+		{"main", "bots", false},
+		{"feature/issue-7", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsAgentBranch(tt.branchName, tt.prefix); got != tt.expected {
+			t.Errorf("IsAgentBranch(%s, %q) = %v, want %v", tt.branchName, tt.prefix, got, tt.expected)
+		}
+	}
+}
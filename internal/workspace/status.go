@@ -0,0 +1,87 @@
+package workspace
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/qiniu/codeagent/pkg/models"
+)
+
+// CommitInfo describes a commit in a workspace
+type CommitInfo struct {
+	SHA     string
+	Subject string
+	Time    time.Time
+}
+
+// Status is the runtime state of a workspace
+type Status struct {
+	// RunningContainers lists the containers of the workspace that are running
+	RunningContainers []string
+	// LastCommit is the latest commit made by codeagent in the workspace, nil if there is none
+	LastCommit *CommitInfo
+	// SessionSize is the size of the session directory in bytes
+	SessionSize int64
+}
+
+// GetWorkspaceStatus collects the containers, latest commit and session size of a workspace.
+// When some of them cannot be determined the rest of the status is still returned along with the error
+func (m *Manager) GetWorkspaceStatus(ws *models.Workspace) (*Status, error) {
+	status := &Status{}
+	var errs []error
+
+	for _, name := range m.containerService.GenerateContainerNames(ws) {
+		running, err := m.containerService.ContainerExists(name)
+		if err != nil {
+			errs = append(errs, ContainerError("status", name, err))
+			break
+		}
+		if running {
+			status.RunningContainers = append(status.RunningContainers, name)
+		}
+	}
+
+	if ws.Path != "" {
+		commit, err := m.gitService.GetLastCommit(ws.Path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		status.LastCommit = commit
+	}
+
+	if ws.SessionPath != "" {
+		size, err := dirSize(ws.SessionPath)
+		if err != nil {
+			errs = append(errs, FileSystemError("session_size", ws.SessionPath, err))
+		}
+		status.SessionSize = size
+	}
+
+	return status, errors.Join(errs...)
+}
+
+// dirSize returns the total size of the regular files under path
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
@@ -20,6 +20,9 @@ const (
 	EventWorkflowDispatch         EventType = "workflow_dispatch"
 	EventSchedule                 EventType = "schedule"
 	EventPush                     EventType = "push"
+	EventCheckRun                 EventType = "check_run"
+	EventCheckSuite               EventType = "check_suite"
+	EventWorkflowRun              EventType = "workflow_run"
 )
 
 // GitHubContext is the interface for all GitHub event contexts
@@ -107,16 +110,53 @@ type PullRequestContext struct {
 	PullRequest *github.PullRequest `json:"pull_request"`
 }
 
-// WorkflowDispatchContext workflow_dispatch事件上下文
+// WorkflowDispatchContext workflow_dispatch事件上下文，由 /dispatch 接口手动触发定时任务
 type WorkflowDispatchContext struct {
 	BaseContext
+	// 要执行的任务，取自 inputs.job
+	Job    string                 `json:"job"`
 	Inputs map[string]interface{} `json:"inputs"`
 }
 
-// ScheduleContext schedule事件上下文
+// ScheduleContext schedule事件上下文，由内置的cron调度器按仓库配置生成
 type ScheduleContext struct {
 	BaseContext
-	Cron string `json:"cron"`
+	Job         string `json:"job"`
+	Cron        string `json:"cron"`
+	PullRequest int    `json:"pull_request,omitempty"` // 非0时只对该PR执行任务
+}
+
+// 定时任务
+const (
+	JobStaleIssues      = "stale-issues"      // 标记并关闭长期没有活动的Issue
+	JobDependencyUpdate = "dependency-update" // 创建依赖升级PR
+	JobReviewOpenPRs    = "review-open-prs"   // 审查还没有被CodeAgent审查过的PR
+)
+
+// 推送到默认分支或受保护分支时的自动化动作
+const (
+	PushActionChangelog      = "changelog"        // 创建更新CHANGELOG的PR
+	PushActionDocs           = "docs"             // 创建更新文档的PR
+	PushActionRefreshCache   = "refresh-cache"    // 刷新本地仓库缓存
+	PushActionCloseMergedPRs = "close-merged-prs" // 关闭分支已经被合并的CodeAgent PR
+)
+
+// IsValidPushAction 检查是否为支持的推送自动化动作
+func IsValidPushAction(action string) bool {
+	switch action {
+	case PushActionChangelog, PushActionDocs, PushActionRefreshCache, PushActionCloseMergedPRs:
+		return true
+	}
+	return false
+}
+
+// IsValidJob 检查是否为支持的定时任务
+func IsValidJob(job string) bool {
+	switch job {
+	case JobStaleIssues, JobDependencyUpdate, JobReviewOpenPRs:
+		return true
+	}
+	return false
 }
 
 // PushContext push事件上下文
@@ -126,8 +166,80 @@ type PushContext struct {
 	Commits []*github.HeadCommit `json:"commits"`
 	Before  string               `json:"before"`
 	After   string               `json:"after"`
+	Deleted bool                 `json:"deleted"`
+}
+
+// Branch 推送的分支名，推送标签时返回空字符串
+func (pc *PushContext) Branch() string {
+	if !strings.HasPrefix(pc.Ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(pc.Ref, "refs/heads/")
+}
+
+// ChangedFiles 推送的提交中新增、修改和删除的文件，去重后按出现顺序返回
+func (pc *PushContext) ChangedFiles() []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, commit := range pc.Commits {
+		for _, list := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+			for _, file := range list {
+				if !seen[file] {
+					seen[file] = true
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	return files
+}
+
+// CIContext CI结果事件（check_run、check_suite、workflow_run）的公共接口
+type CIContext interface {
+	GitHubContext
+	// CIName 检查或工作流的名称
+	CIName() string
+	// CIConclusion CI的结论（success、failure 等），未完成时为空
+	CIConclusion() string
+	// HeadSHA CI运行所在的提交
+	HeadSHA() string
+	// PullRequests CI运行关联的PR（只包含同仓库分支的PR）
+	PullRequests() []*github.PullRequest
 }
 
+// CheckRunContext check_run事件上下文
+type CheckRunContext struct {
+	BaseContext
+	CheckRun *github.CheckRun `json:"check_run"`
+}
+
+func (c *CheckRunContext) CIName() string                      { return c.CheckRun.GetName() }
+func (c *CheckRunContext) CIConclusion() string                { return c.CheckRun.GetConclusion() }
+func (c *CheckRunContext) HeadSHA() string                     { return c.CheckRun.GetHeadSHA() }
+func (c *CheckRunContext) PullRequests() []*github.PullRequest { return c.CheckRun.PullRequests }
+
+// CheckSuiteContext check_suite事件上下文
+type CheckSuiteContext struct {
+	BaseContext
+	CheckSuite *github.CheckSuite `json:"check_suite"`
+}
+
+func (c *CheckSuiteContext) CIName() string                      { return c.CheckSuite.GetApp().GetName() }
+func (c *CheckSuiteContext) CIConclusion() string                { return c.CheckSuite.GetConclusion() }
+func (c *CheckSuiteContext) HeadSHA() string                     { return c.CheckSuite.GetHeadSHA() }
+func (c *CheckSuiteContext) PullRequests() []*github.PullRequest { return c.CheckSuite.PullRequests }
+
+// WorkflowRunContext workflow_run事件上下文
+type WorkflowRunContext struct {
+	BaseContext
+	WorkflowRun *github.WorkflowRun `json:"workflow_run"`
+}
+
+func (c *WorkflowRunContext) CIName() string                      { return c.WorkflowRun.GetName() }
+func (c *WorkflowRunContext) CIConclusion() string                { return c.WorkflowRun.GetConclusion() }
+func (c *WorkflowRunContext) HeadSHA() string                     { return c.WorkflowRun.GetHeadSHA() }
+func (c *WorkflowRunContext) PullRequests() []*github.PullRequest { return c.WorkflowRun.PullRequests }
+
 // Repository 简单的仓库信息结构体
 type Repository struct {
 	Owner string `json:"owner"` // 仓库所有者（组织或用户）
@@ -138,7 +250,7 @@ type Repository struct {
 type CommandInfo struct {
 	Command      string      `json:"command"`        // 实际的触发词（如 /code, @qiniu-ci, @claude-ai）
 	CommandType  CommandType `json:"command_type"`   // 命令类型枚举
-	AIModel      string      `json:"ai_model"`       // claude, gemini
+	AIModel      string      `json:"ai_model"`       // claude, gemini, openai
 	Args         string      `json:"args"`           // 命令参数
 	RawText      string      `json:"raw_text"`       // 原始文本
 	PreCommentID int64       `json:"pre_comment_id"` // 预创建评论ID，传递给AI prompt
@@ -158,14 +270,23 @@ const (
 	CommandContinue = "/continue"
 	CommandMention  = "@qiniu-ci"
 	CommandReview   = "/review"
+	CommandCancel   = "/cancel"
+	CommandFixCI    = "/fix-ci"
+	CommandHelp     = "/help"
+	CommandStatus   = "/status"
+	CommandPlan     = "/plan"
 )
 
 // AI模型类型
 const (
 	AIModelClaude = "claude"
 	AIModelGemini = "gemini"
+	AIModelOpenAI = "openai"
 )
 
+// AIModels 可以在命令后用 -<model> 指定的AI模型
+var AIModels = []string{AIModelClaude, AIModelGemini, AIModelOpenAI}
+
 // MentionConfig 提及配置接口
 type MentionConfig interface {
 	GetTriggers() []string
@@ -224,6 +345,22 @@ func HasCommandWithConfig(ctx GitHubContext, mentionConfig MentionConfig) (*Comm
 	return parseMention(content)
 }
 
+// IsHelpCommand 命令是否为 /help 或 "@trigger help"
+func IsHelpCommand(cmdInfo *CommandInfo) bool {
+	if cmdInfo == nil {
+		return false
+	}
+	switch cmdInfo.CommandType {
+	case CommandTypeSlash:
+		return cmdInfo.Command == CommandHelp
+	case CommandTypeMention:
+		rest := strings.TrimSpace(strings.Replace(cmdInfo.RawText, cmdInfo.Command, "", 1))
+		return strings.EqualFold(strings.TrimRight(rest, ".!?"), "help")
+	default:
+		return false
+	}
+}
+
 // parseCommand 解析命令字符串
 func parseCommand(content string) (*CommandInfo, bool) {
 	content = strings.TrimSpace(content)
@@ -244,17 +381,14 @@ func parseCommand(content string) (*CommandInfo, bool) {
 
 	// 解析AI模型和参数
 	var aiModel string
-	var args string
-
-	if strings.HasPrefix(remaining, "-claude") {
-		aiModel = AIModelClaude
-		args = strings.TrimSpace(strings.TrimPrefix(remaining, "-claude"))
-	} else if strings.HasPrefix(remaining, "-gemini") {
-		aiModel = AIModelGemini
-		args = strings.TrimSpace(strings.TrimPrefix(remaining, "-gemini"))
-	} else {
-		aiModel = ""
-		args = remaining
+	args := remaining
+
+	for _, model := range AIModels {
+		if strings.HasPrefix(remaining, "-"+model) {
+			aiModel = model
+			args = strings.TrimSpace(strings.TrimPrefix(remaining, "-"+model))
+			break
+		}
 	}
 
 	return &CommandInfo{
@@ -308,10 +442,11 @@ func parseMentionWithTrigger(content string, trigger string) (*CommandInfo, bool
 	var aiModel string
 
 	// 查找模型指定标志
-	if strings.Contains(fullContent, "-claude") {
-		aiModel = AIModelClaude
-	} else if strings.Contains(fullContent, "-gemini") {
-		aiModel = AIModelGemini
+	for _, model := range AIModels {
+		if strings.Contains(fullContent, "-"+model) {
+			aiModel = model
+			break
+		}
 	}
 
 	return &CommandInfo{
@@ -327,7 +462,8 @@ func parseMentionWithTrigger(content string, trigger string) (*CommandInfo, bool
 func IsValidEventType(eventType string) bool {
 	switch EventType(eventType) {
 	case EventIssueComment, EventPullRequestReview, EventPullRequestReviewComment,
-		EventIssues, EventPullRequest, EventWorkflowDispatch, EventSchedule, EventPush:
+		EventIssues, EventPullRequest, EventWorkflowDispatch, EventSchedule, EventPush,
+		EventCheckRun, EventCheckSuite, EventWorkflowRun:
 		return true
 	default:
 		return false
@@ -64,6 +64,13 @@ func TestParseMention(t *testing.T) {
 			args:     "@qiniu-ci -gemini 这个模块如何重构", // 完整内容
 			aiModel:  AIModelGemini,
 		},
+		{
+			name:     "@qiniu-ci指定OpenAI兼容模型",
+			content:  "@qiniu-ci -openai 补充单元测试",
+			expected: true,
+			args:     "@qiniu-ci -openai 补充单元测试", // 完整内容
+			aiModel:  AIModelOpenAI,
+		},
 		// 不匹配的案例
 		{
 			name:     "作为其他词的一部分",
@@ -134,6 +141,59 @@ func TestParseMention(t *testing.T) {
 	}
 }
 
+func TestParseCommand_AIModelFlag(t *testing.T) {
+	tests := []struct {
+		content string
+		aiModel string
+		args    string
+	}{
+		{content: "/code -claude 实现登录", aiModel: AIModelClaude, args: "实现登录"},
+		{content: "/code -gemini 实现登录", aiModel: AIModelGemini, args: "实现登录"},
+		{content: "/continue -openai 补充测试", aiModel: AIModelOpenAI, args: "补充测试"},
+		{content: "/code 实现登录", aiModel: "", args: "实现登录"},
+	}
+
+	for _, tt := range tests {
+		cmdInfo, found := parseCommand(tt.content)
+		if !found {
+			t.Fatalf("parseCommand(%q) found = false", tt.content)
+		}
+		if cmdInfo.AIModel != tt.aiModel {
+			t.Errorf("parseCommand(%q) aiModel = %q, want %q", tt.content, cmdInfo.AIModel, tt.aiModel)
+		}
+		if cmdInfo.Args != tt.args {
+			t.Errorf("parseCommand(%q) args = %q, want %q", tt.content, cmdInfo.Args, tt.args)
+		}
+	}
+}
+
+func TestIsHelpCommand(t *testing.T) {
+	mentionConfig := &ConfigMentionAdapter{Triggers: []string{"@qiniu-ci"}}
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{content: "/help", want: true},
+		{content: "/help -claude", want: true},
+		{content: "@qiniu-ci help", want: true},
+		{content: "@qiniu-ci Help!", want: true},
+		{content: "@qiniu-ci help me fix the tests", want: false},
+		{content: "/code help", want: false},
+	}
+
+	for _, tt := range tests {
+		var cmdInfo *CommandInfo
+		if info, ok := parseCommand(tt.content); ok {
+			cmdInfo = info
+		} else if info, ok := parseMentionWithConfig(tt.content, mentionConfig); ok {
+			cmdInfo = info
+		}
+		if got := IsHelpCommand(cmdInfo); got != tt.want {
+			t.Errorf("IsHelpCommand(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
 func TestHasCommandWithConfig(t *testing.T) {
 	// 创建测试用的mention配置
 	mentionConfig := &ConfigMentionAdapter{
@@ -169,6 +229,13 @@ func TestHasCommandWithConfig(t *testing.T) {
 			command:  CommandCode,
 			config:   mentionConfig,
 		},
+		{
+			name:     "取消命令",
+			context:  "/cancel",
+			expected: true,
+			command:  CommandCancel,
+			config:   mentionConfig,
+		},
 		{
 			name:     "无匹配命令",
 			context:  "这是一个普通的评论，没有特殊指令",
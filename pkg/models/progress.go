@@ -333,6 +333,7 @@ func (pt *ProgressTracker) HasErrors() bool {
 // ProgressExecutionResult 带进度信息的执行结果
 type ProgressExecutionResult struct {
 	Success        bool                   `json:"success"`
+	Cancelled      bool                   `json:"cancelled,omitempty"` // 任务被 /cancel 中止
 	Output         string                 `json:"output"`
 	Error          string                 `json:"error,omitempty"`
 	FilesChanged   []string               `json:"files_changed"`
@@ -341,6 +342,8 @@ type ProgressExecutionResult struct {
 	CommitSHA      string                 `json:"commit_sha,omitempty"`
 	BranchName     string                 `json:"branch_name,omitempty"`
 	PullRequestURL string                 `json:"pull_request_url,omitempty"`
+	Model          string                 `json:"model,omitempty"` // 实际产生变更的provider和模型
+	Usage          *TokenUsage            `json:"usage,omitempty"` // 本次任务所有AI调用的用量
 	TaskResults    []*Task                `json:"task_results"`
 	Metadata       map[string]interface{} `json:"metadata,omitempty"`
 }
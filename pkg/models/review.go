@@ -0,0 +1,22 @@
+package models
+
+import "fmt"
+
+// ReviewThread PR上的一个代码审查讨论
+type ReviewThread struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line,omitempty"`
+	Resolved bool   `json:"resolved"`
+	Outdated bool   `json:"outdated"`
+	// 发起讨论的用户和第一条评论
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// Location 返回讨论所在的文件和行号
+func (t ReviewThread) Location() string {
+	if t.Line > 0 {
+		return fmt.Sprintf("%s:%d", t.Path, t.Line)
+	}
+	return t.Path
+}
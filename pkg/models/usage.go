@@ -0,0 +1,26 @@
+package models
+
+// TokenUsage AI调用的token用量与估算费用，可以累加
+type TokenUsage struct {
+	Calls               int     `json:"calls"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CostUSD             float64 `json:"cost_usd"`
+}
+
+// Add 累加另一份用量
+func (u *TokenUsage) Add(other TokenUsage) {
+	u.Calls += other.Calls
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CacheCreationTokens += other.CacheCreationTokens
+	u.CostUSD += other.CostUSD
+}
+
+// TotalTokens 所有类型token的总数
+func (u TokenUsage) TotalTokens() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheCreationTokens
+}
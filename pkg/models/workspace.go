@@ -13,8 +13,10 @@ type Workspace struct {
 	Repo string `json:"repo"`
 	// PR number
 	PRNumber int `json:"pr_number"`
-	// AI model name (claude or gemini)
+	// AI model name (claude, gemini or openai)
 	AIModel string `json:"ai_model"`
+	// model override from the repository config, empty means the provider's configured model
+	Model string `json:"model,omitempty"`
 	// workspace path in local file system
 	Path string `json:"path"`
 	// session path in local file system
@@ -0,0 +1,236 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/qiniu/codeagent/internal/code"
+	"github.com/qiniu/codeagent/internal/config"
+	"github.com/qiniu/codeagent/internal/events"
+	ghclient "github.com/qiniu/codeagent/internal/github"
+	"github.com/qiniu/codeagent/internal/mcp"
+	"github.com/qiniu/codeagent/internal/mcp/servers"
+	"github.com/qiniu/codeagent/internal/modes"
+	"github.com/qiniu/codeagent/internal/workspace"
+	"github.com/qiniu/codeagent/pkg/models"
+
+	githubapi "github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubGitHub 模拟 /code 流程用到的GitHub API，并记录创建的PR和评论
+type stubGitHub struct {
+	mu       sync.Mutex
+	prHead   string
+	prBody   string
+	comments map[int64]string
+	nextID   int64
+}
+
+func newStubGitHub() *stubGitHub {
+	return &stubGitHub{comments: map[int64]string{}, nextID: 100}
+}
+
+func (s *stubGitHub) handler() http.Handler {
+	const repo = "/repos/qiniu/demo"
+	pr := func() map[string]interface{} {
+		return map[string]interface{}{
+			"number":   2,
+			"html_url": "https://github.com/qiniu/demo/pull/2",
+			"head":     map[string]interface{}{"ref": s.prHead},
+			"base":     map[string]interface{}{"ref": "main"},
+			"body":     s.prBody,
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+repo, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"name": "demo", "full_name": "qiniu/demo", "default_branch": "main"})
+	})
+	mux.HandleFunc("GET "+repo+"/issues/{number}/comments", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []interface{}{})
+	})
+	mux.HandleFunc("POST "+repo+"/pulls", func(w http.ResponseWriter, r *http.Request) {
+		var req githubapi.NewPullRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.prHead = req.GetHead()
+		s.prBody = req.GetBody()
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, pr())
+	})
+	mux.HandleFunc("GET "+repo+"/pulls/2", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeJSON(w, pr())
+	})
+	mux.HandleFunc("PATCH "+repo+"/pulls/2", func(w http.ResponseWriter, r *http.Request) {
+		var req githubapi.PullRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.prBody = req.GetBody()
+		writeJSON(w, pr())
+	})
+	mux.HandleFunc("POST "+repo+"/issues/{number}/comments", func(w http.ResponseWriter, r *http.Request) {
+		var req githubapi.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.nextID++
+		s.comments[s.nextID] = req.GetBody()
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, map[string]interface{}{"id": s.nextID, "body": req.GetBody()})
+	})
+	mux.HandleFunc("PATCH "+repo+"/issues/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		var req githubapi.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.comments[id] = req.GetBody()
+		writeJSON(w, map[string]interface{}{"id": id, "body": req.GetBody()})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// stubClientManager 始终返回指向 stub API 的客户端
+type stubClientManager struct {
+	client *ghclient.Client
+}
+
+func (m *stubClientManager) GetClient(ctx context.Context, repo *models.Repository) (*ghclient.Client, error) {
+	return m.client, nil
+}
+
+func (m *stubClientManager) Close() error {
+	return nil
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %s: %s", strings.Join(args, " "), output)
+	return strings.TrimSpace(string(output))
+}
+
+// setupBareRemote 创建本地裸仓库，并把 https://github.com/ 重写到该目录
+func setupBareRemote(t *testing.T) string {
+	root := t.TempDir()
+	remotes := filepath.Join(root, "remote")
+	bare := filepath.Join(remotes, "qiniu", "demo.git")
+	runGit(t, root, "init", "--bare", "-b", "main", bare)
+
+	t.Setenv("GIT_CONFIG_COUNT", "3")
+	t.Setenv("GIT_CONFIG_KEY_0", fmt.Sprintf("url.file://%s/.insteadOf", remotes))
+	t.Setenv("GIT_CONFIG_VALUE_0", "https://github.com/")
+	t.Setenv("GIT_CONFIG_KEY_1", "user.name")
+	t.Setenv("GIT_CONFIG_VALUE_1", "codeagent-test")
+	t.Setenv("GIT_CONFIG_KEY_2", "user.email")
+	t.Setenv("GIT_CONFIG_VALUE_2", "codeagent-test@example.com")
+
+	seed := filepath.Join(root, "seed")
+	runGit(t, root, "clone", "https://github.com/qiniu/demo.git", seed)
+	runGit(t, seed, "commit", "--allow-empty", "-m", "initial commit")
+	runGit(t, seed, "push", "origin", "HEAD:main")
+	return bare
+}
+
+// TestCodeFlowWithFakeProvider 使用 fake provider、本地裸仓库和 stub GitHub API 跑通 Issue 上的 /code 流程
+func TestCodeFlowWithFakeProvider(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping integration test: git not found")
+	}
+
+	bare := setupBareRemote(t)
+
+	stub := newStubGitHub()
+	server := httptest.NewServer(stub.handler())
+	defer server.Close()
+
+	gh := githubapi.NewClient(nil)
+	baseURL, err := gh.BaseURL.Parse(server.URL + "/")
+	require.NoError(t, err)
+	gh.BaseURL = baseURL
+	clientManager := &stubClientManager{client: ghclient.NewClient(gh)}
+
+	fixture, err := filepath.Abs(filepath.Join("testdata", "fake_code_flow.yaml"))
+	require.NoError(t, err)
+	cfg := &config.Config{
+		CodeProvider: code.ProviderFake,
+		Fake:         config.FakeConfig{Fixture: fixture},
+		Workspace:    config.WorkspaceConfig{BaseDir: t.TempDir()},
+	}
+
+	mcpManager := mcp.NewManager()
+	require.NoError(t, mcpManager.RegisterServer("github-files", servers.NewGitHubFilesServer(clientManager)))
+	require.NoError(t, mcpManager.RegisterServer("github-comments", servers.NewGitHubCommentsServer(clientManager)))
+	mcpClient := mcp.NewClient(mcpManager)
+
+	sessionManager := code.NewSessionManager(cfg)
+	sessionManager.SetMCPClient(mcpClient)
+	workspaceManager := workspace.NewManager(cfg)
+
+	handler := modes.NewTagHandler(code.ProviderFake, clientManager, workspaceManager, mcpClient, sessionManager, nil, cfg)
+
+	event, err := events.NewEventParser().ParseIssueCommentEvent(context.Background(), &githubapi.IssueCommentEvent{
+		Action: githubapi.String("created"),
+		Issue: &githubapi.Issue{
+			Number:  githubapi.Int(1),
+			Title:   githubapi.String("Add a greeting helper"),
+			Body:    githubapi.String("We need a helper that greets people."),
+			HTMLURL: githubapi.String("https://github.com/qiniu/demo/issues/1"),
+		},
+		Comment: &githubapi.IssueComment{
+			ID:   githubapi.Int64(1),
+			Body: githubapi.String("/code add a greeting helper"),
+			User: &githubapi.User{Login: githubapi.String("octocat")},
+		},
+		Repo: &githubapi.Repository{
+			Name:     githubapi.String("demo"),
+			FullName: githubapi.String("qiniu/demo"),
+			Owner:    &githubapi.User{Login: githubapi.String("qiniu")},
+		},
+		Sender: &githubapi.User{Login: githubapi.String("octocat")},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, handler.Execute(context.Background(), event))
+
+	// 分支、提交和文件内容已推送到远端
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	require.True(t, strings.HasPrefix(stub.prHead, "codeagent/fake/issue-1-"), "unexpected PR head %q", stub.prHead)
+	runGit(t, bare, "rev-parse", "--verify", "refs/heads/"+stub.prHead)
+	assert.Contains(t, runGit(t, bare, "log", "-1", "--format=%B", stub.prHead), "feat: add greeting helper")
+	assert.Contains(t, runGit(t, bare, "show", stub.prHead+":greeting/greeting.go"), `return "Hello, " + name + "!"`)
+
+	// PR描述使用AI输出更新，进度评论以成功结束
+	assert.Contains(t, stub.prBody, "Added a Greeting helper.")
+	require.Len(t, stub.comments, 1)
+	for _, body := range stub.comments {
+		assert.Contains(t, body, "completed successfully")
+		assert.Contains(t, body, stub.prHead)
+	}
+}
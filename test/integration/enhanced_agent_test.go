@@ -60,7 +60,7 @@ func TestEnhancedAgentIntegration(t *testing.T) {
 	assert.Contains(t, mcpServers, "github-comments")
 
 	// 验证模式处理器已注册
-	assert.Equal(t, 3, enhancedAgent.GetModeManager().GetHandlerCount())
+	assert.Equal(t, 4, enhancedAgent.GetModeManager().GetHandlerCount())
 }
 
 // TestEnhancedAgentIssueCommentFlow 测试Issue评论处理流程